@@ -0,0 +1,181 @@
+// Command keyadmin manages the auth-service's signing keys through KeyAdminService.
+//
+// Usage:
+//
+//	keyadmin [flags] list
+//	keyadmin [flags] rotate
+//	keyadmin [flags] revoke <kid>
+//	keyadmin [flags] status
+//	keyadmin [flags] import -alg <algorithm> [-kid <kid>] [-not-before <RFC 3339 time>] <key file>
+//
+// The key file contains a PEM encoded private key or a private JWK.
+// Public keys are imported as verify-only keys, which are never used to sign tokens.
+// Keys are stored under their RFC 7638 thumbprint, which is printed after import.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-lib/cert"
+	"google.golang.org/grpc"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:50051", "Address of the auth-service")
+	caPath := flag.String("ca", os.Getenv("TLS_CA_PATH"), "Path of the CA certificate")
+	certPath := flag.String("cert", os.Getenv("TLS_CLIENT_CERT_PATH"), "Path of the client certificate")
+	keyPath := flag.String("key", os.Getenv("TLS_CLIENT_KEY_PATH"), "Path of the client certificate's key")
+	timeout := flag.Duration("timeout", time.Second*30, "Timeout of the call")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client, conn, err := dial(*addr, *caPath, *certPath, *keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect:", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := run(ctx, client, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func dial(addr, caPath, certPath, keyPath string) (pb.KeyAdminServiceClient, *grpc.ClientConn, error) {
+	caPool, err := cert.LoadCaPool(caPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientCert, err := cert.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(cert.NewClientMTLSCreds(caPool, clientCert)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pb.NewKeyAdminServiceClient(conn), conn, nil
+}
+
+func run(ctx context.Context, client pb.KeyAdminServiceClient, command string, args []string) error {
+	switch command {
+	case "list":
+		return list(ctx, client)
+	case "rotate":
+		_, err := client.RotateNow(ctx, &empty.Empty{})
+		return err
+	case "revoke":
+		if len(args) != 1 {
+			return errors.New("usage: keyadmin revoke <kid>")
+		}
+		_, err := client.RevokeKey(ctx, &pb.RevokeKeyRequest{Kid: args[0]})
+		return err
+	case "status":
+		return rotationStatus(ctx, client)
+	case "import":
+		return importKey(ctx, client, args)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func list(ctx context.Context, client pb.KeyAdminServiceClient) error {
+	resp, err := client.ListKeys(ctx, &empty.Empty{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALGORITHM\tTYPE\tACTIVE\tIMPORTED\tCREATED AT\tNOT BEFORE")
+
+	for _, key := range resp.GetKeys() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\t%s\t%s\n", key.GetKid(), key.GetAlgorithm(), key.GetKeyType(),
+			key.GetActive(), key.GetImported(), formatTime(key.GetCreatedAt()), formatTime(key.GetNotBefore()))
+	}
+
+	return w.Flush()
+}
+
+func rotationStatus(ctx context.Context, client pb.KeyAdminServiceClient) error {
+	resp, err := client.GetRotationStatus(ctx, &empty.Empty{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Last rotated at: ", formatTime(resp.GetLastRotatedAt()))
+	fmt.Println("Next rotation at:", formatTime(resp.GetNextRotationAt()))
+	fmt.Println("Leader:          ", resp.GetLeader())
+	fmt.Println("Lease expires at:", formatTime(resp.GetLeaseExpiresAt()))
+
+	return nil
+}
+
+func importKey(ctx context.Context, client pb.KeyAdminServiceClient, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	kid := flags.String("kid", "", "Expected thumbprint of the key, not checked if empty")
+	algorithm := flags.String("alg", "", "Algorithm the key is used with, e.g. ES256")
+	notBefore := flags.String("not-before", "", "RFC 3339 time the key starts to sign tokens at, right away if empty")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 || *algorithm == "" {
+		return errors.New("usage: keyadmin import -alg <algorithm> [-kid <kid>] [-not-before <RFC 3339 time>] <key file>")
+	}
+
+	key, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	req := &pb.ImportKeyRequest{
+		Kid:       *kid,
+		Algorithm: *algorithm,
+		Key:       string(key),
+	}
+
+	if *notBefore != "" {
+		t, err := time.Parse(time.RFC3339, *notBefore)
+		if err != nil {
+			return fmt.Errorf("invalid not-before: %w", err)
+		}
+		req.NotBefore = timestamppb.New(t)
+	}
+
+	resp, err := client.ImportKey(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(resp.GetKid())
+
+	return nil
+}
+
+func formatTime(t *timestamppb.Timestamp) string {
+	if t == nil {
+		return "-"
+	}
+	return t.AsTime().Format(time.RFC3339)
+}
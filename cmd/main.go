@@ -2,23 +2,36 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
 	"github.com/krixlion/dev_forum-auth/pkg/grpc/server"
 	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/jwks"
 	"github.com/krixlion/dev_forum-auth/pkg/service"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/filestore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
 	"github.com/krixlion/dev_forum-auth/pkg/storage/mongo"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/transit"
 	"github.com/krixlion/dev_forum-auth/pkg/storage/vault"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
 	"github.com/krixlion/dev_forum-auth/pkg/tokens"
 	"github.com/krixlion/dev_forum-auth/pkg/tokens/manager"
 	"github.com/krixlion/dev_forum-lib/cert"
 	"github.com/krixlion/dev_forum-lib/env"
+	"github.com/krixlion/dev_forum-lib/event"
 	"github.com/krixlion/dev_forum-lib/event/broker"
 	"github.com/krixlion/dev_forum-lib/event/dispatcher"
 	"github.com/krixlion/dev_forum-lib/logging"
@@ -27,9 +40,12 @@ import (
 	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -38,13 +54,16 @@ const projectDir = "app"
 const serviceName = "auth-service"
 
 var port int
+var jwksPort int
 var isTLS bool
 
 func init() {
 	portFlag := flag.Int("p", 50051, "The gRPC server port")
+	jwksPortFlag := flag.Int("jwks-port", 0, "The HTTP port serving the JWK Set, disabled if 0")
 	insecureFlag := flag.Bool("insecure", false, "Whether to not use TLS over gRPC")
 	flag.Parse()
 	port = *portFlag
+	jwksPort = *jwksPortFlag
 	isTLS = !(*insecureFlag)
 }
 
@@ -81,6 +100,7 @@ func main() {
 func getServiceDependencies(ctx context.Context, serviceName string, isTLS bool) (service.Dependencies, error) {
 	clientCreds := insecure.NewCredentials()
 	serverCreds := insecure.NewCredentials()
+	var serverTLSConfig *tls.Config
 	if isTLS {
 		caCertPool, err := cert.LoadCaPool(os.Getenv("TLS_CA_PATH"))
 		if err != nil {
@@ -92,6 +112,8 @@ func getServiceDependencies(ctx context.Context, serviceName string, isTLS bool)
 			return service.Dependencies{}, err
 		}
 
+		serverTLSConfig = &tls.Config{Certificates: []tls.Certificate{serverCert}, MinVersion: tls.VersionTLS12}
+
 		serverCreds = cert.NewServerOptionalMTLSCreds(caCertPool, serverCert)
 
 		clientCert, err := cert.LoadX509KeyPair(os.Getenv("TLS_CLIENT_CERT_PATH"), os.Getenv("TLS_CLIENT_KEY_PATH"))
@@ -149,12 +171,14 @@ func getServiceDependencies(ctx context.Context, serviceName string, isTLS bool)
 	}
 	userClient := userPb.NewUserServiceClient(userConn)
 
-	vaultConfig := vault.Config{
-		MountPath:          os.Getenv("VAULT_MOUNT_PATH"),
-		KeyCount:           10,
-		KeyRefreshInterval: time.Hour * 24, // Daily
+	healthServer := health.NewServer()
+
+	vault, algorithms, err := makeVault(ctx, storage, broker, dispatcher, healthServer, tracer, logger)
+	if err != nil {
+		return service.Dependencies{}, err
 	}
-	vault, err := vault.Make(ctx, os.Getenv("VAULT_HOST"), os.Getenv("VAULT_PORT"), os.Getenv("VAULT_TOKEN"), vaultConfig, broker, tracer, logger)
+
+	signingAlgorithm, err := signingAlgorithm(os.Getenv("JWT_SIGNING_ALGORITHM"), algorithms)
 	if err != nil {
 		return service.Dependencies{}, err
 	}
@@ -164,17 +188,41 @@ func getServiceDependencies(ctx context.Context, serviceName string, isTLS bool)
 		VerifyClientCert:         isTLS,
 		AccessTokenValidityTime:  time.Hour * 24 * 7, // One week
 		RefreshTokenValidityTime: time.Hour * 24 * 7, // One week
+
+		PasswordResetTokenValidityTime:     time.Minute * 30,
+		EmailVerificationTokenValidityTime: time.Hour * 24,
+		MagicLinkTokenValidityTime:         time.Minute * 10,
+		MagicLinkRateLimit:                 5,
+		MagicLinkRateLimitWindow:           time.Hour,
+
+		AdminUserIds:                   splitList(os.Getenv("ADMIN_USER_IDS")),
+		AdminClientNames:               splitList(os.Getenv("ADMIN_CLIENT_NAMES")),
+		ImpersonationTokenValidityTime: time.Minute * 15,
+
+		DPoPTokenEndpoint: os.Getenv("DPOP_TOKEN_ENDPOINT"),
+		DPoPProofMaxAge:   time.Minute,
+
+		CertificateBoundClientNames: splitList(os.Getenv("CERTIFICATE_BOUND_CLIENT_NAMES")),
+
+		SigningAlgorithm: signingAlgorithm,
+	}
+
+	dpopReplayCache, err := mongo.MakeReplayCache(ctx, storage)
+	if err != nil {
+		return service.Dependencies{}, err
 	}
 
 	authDependencies := server.Dependencies{
 		Services: server.Services{
 			User: userClient,
 		},
-		Storage:      storage,
-		Vault:        vault,
-		Logger:       logger,
-		Tracer:       tracer,
-		TokenManager: tokenManager,
+		Storage:         storage,
+		Vault:           vault,
+		Broker:          broker,
+		Logger:          logger,
+		Tracer:          tracer,
+		TokenManager:    tokenManager,
+		DPoPReplayCache: dpopReplayCache,
 	}
 
 	authServer := server.MakeAuthServer(authDependencies, authConfig)
@@ -190,16 +238,266 @@ func getServiceDependencies(ctx context.Context, serviceName string, isTLS bool)
 
 	reflection.Register(grpcServer)
 	pb.RegisterAuthServiceServer(grpcServer, authServer)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	if isTLS {
+		registerKeyAdminServer(grpcServer, vault, broker, tracer, logger)
+	}
+
+	httpServer := makeJWKSServer(vault, serverTLSConfig, tracer, logger)
 
 	return service.Dependencies{
 		Logger:     logger,
 		Broker:     broker,
 		GRPCServer: grpcServer,
+		HTTPServer: httpServer,
 		Storage:    storage,
 		Dispatcher: dispatcher,
 		ShutdownFunc: func() error {
 			grpcServer.GracefulStop()
-			return errors.Join(userConn.Close(), storage.Close(), mq.Close(), shutdownTracing(), logger.Sync())
+
+			var httpErr error
+			if httpServer != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+				defer cancel()
+				httpErr = httpServer.Shutdown(ctx)
+			}
+
+			return errors.Join(httpErr, userConn.Close(), storage.Close(), mq.Close(), shutdownTracing(), logger.Sync())
 		},
 	}, nil
 }
+
+// signingAlgorithm returns the algorithm translated JWTs are signed with.
+// It defaults to the first of given algorithms the key store maintains keys for
+// and fails if the configured algorithm is not one of them.
+func signingAlgorithm(configured string, algorithms []entity.Algorithm) (entity.Algorithm, error) {
+	if len(algorithms) == 0 {
+		return "", errors.New("key store maintains no signing keys")
+	}
+
+	if configured == "" {
+		return algorithms[0], nil
+	}
+
+	algorithm := entity.Algorithm(configured)
+	if !slices.Contains(algorithms, algorithm) {
+		return "", fmt.Errorf("JWT_SIGNING_ALGORITHM %s is not maintained by the key store, expected one of %v", algorithm, algorithms)
+	}
+
+	return algorithm, nil
+}
+
+// makeJWKSServer returns an HTTP server publishing the keyset on jwks.Path
+// or nil if the JWKS port is not set. It serves HTTPS if tlsConfig is not nil.
+func makeJWKSServer(vault storage.Vault, tlsConfig *tls.Config, tracer trace.Tracer, logger logging.Logger) *http.Server {
+	if jwksPort == 0 {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(jwks.Path, jwks.NewHandler(vault, tracer, logger))
+
+	return &http.Server{
+		Addr:              fmt.Sprintf("0.0.0.0:%d", jwksPort),
+		Handler:           mux,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: time.Second * 5,
+	}
+}
+
+// registerKeyAdminServer exposes key management if the key store supports it.
+// It must only be called when mTLS is enabled.
+func registerKeyAdminServer(grpcServer *grpc.Server, vault storage.Vault, broker event.Broker, tracer trace.Tracer, logger logging.Logger) {
+	keyAdmin, ok := vault.(storage.KeyAdmin)
+	if !ok {
+		return
+	}
+
+	dependencies := server.KeyAdminDependencies{
+		Keys:   keyAdmin,
+		Broker: broker,
+		Logger: logger,
+		Tracer: tracer,
+	}
+	config := server.KeyAdminConfig{
+		AdminClientNames: splitList(os.Getenv("KEY_ADMIN_CLIENT_NAMES")),
+	}
+
+	pb.RegisterKeyAdminServiceServer(grpcServer, server.MakeKeyAdminServer(dependencies, config))
+}
+
+// splitList splits a comma-separated list skipping empty elements.
+func splitList(list string) []string {
+	elements := []string{}
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// makeVaultClient returns a Vault client authenticated with VAULT_AUTH_METHOD.
+// Vault is connected to over HTTPS if VAULT_TLS is set to "true".
+// Expiry of the client's token is reported to the health server as the "vault" service.
+func makeVaultClient(ctx context.Context, healthServer *health.Server, logger logging.Logger) (*vaultclient.Client, error) {
+	method := vaultclient.AuthMethod(os.Getenv("VAULT_AUTH_METHOD"))
+
+	role := os.Getenv("VAULT_K8S_ROLE")
+	if method == vaultclient.CertAuth {
+		role = os.Getenv("VAULT_CERT_ROLE")
+	}
+
+	config := vaultclient.Config{
+		Host: os.Getenv("VAULT_HOST"),
+		Port: os.Getenv("VAULT_PORT"),
+		Auth: vaultclient.AuthConfig{
+			Method:                  method,
+			MountPath:               os.Getenv("VAULT_AUTH_MOUNT_PATH"),
+			Token:                   os.Getenv("VAULT_TOKEN"),
+			Role:                    role,
+			ServiceAccountTokenPath: os.Getenv("VAULT_K8S_TOKEN_PATH"),
+			RoleId:                  os.Getenv("VAULT_APPROLE_ROLE_ID"),
+			SecretId:                os.Getenv("VAULT_APPROLE_SECRET_ID"),
+		},
+	}
+
+	if os.Getenv("VAULT_TLS") == "true" {
+		config.TLS = &vaultclient.TLSConfig{
+			CAPath:     os.Getenv("VAULT_TLS_CA_PATH"),
+			CertPath:   os.Getenv("VAULT_TLS_CERT_PATH"),
+			KeyPath:    os.Getenv("VAULT_TLS_KEY_PATH"),
+			ServerName: os.Getenv("VAULT_TLS_SERVER_NAME"),
+		}
+	}
+
+	client, err := vaultclient.New(ctx, config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	go client.ReportHealth(ctx, healthServer, "vault")
+
+	return client, nil
+}
+
+// makeVault returns a key storage selected with KEY_STORE.
+// "filesystem" keeps PEM files in KEY_STORE_DIR, optionally encrypted with
+// KEY_STORE_PASSPHRASE. "mongo" keeps keys encrypted with KEY_STORE_PASSPHRASE
+// in the service's database. Otherwise keys are kept in Vault.
+//
+// VAULT_ENGINE set to "transit" selects Vault's Transit engine which signs tokens
+// without exposing private keys. Its rotation lease is kept in the KVv2 engine mounted at VAULT_MOUNT_PATH.
+// Otherwise keys are kept in a KVv2 engine and signed in-process.
+//
+// If VAULT_CA_PATH is set, keys kept outside of the Transit engine are certified by the CA
+// kept at that path in the KVv2 engine mounted at VAULT_CA_MOUNT_PATH.
+func makeVault(ctx context.Context, tokens mongo.Mongo, broker event.Broker, d *dispatcher.Dispatcher, healthServer *health.Server, tracer trace.Tracer, logger logging.Logger) (storage.Vault, []entity.Algorithm, error) {
+	keyStore := os.Getenv("KEY_STORE")
+
+	if (keyStore == "" || keyStore == "vault") && os.Getenv("VAULT_ENGINE") == "transit" {
+		client, err := makeVaultClient(ctx, healthServer, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		transitConfig := transit.Config{
+			MountPath:           os.Getenv("VAULT_TRANSIT_MOUNT_PATH"),
+			KeyCount:            10,
+			KeyRotationInterval: time.Hour * 24,     // Daily
+			RetiredKeyTTL:       time.Hour * 24 * 8, // Outlives tokens valid for a week.
+			LeaseStore:          lease.NewKVStore(client.Client, os.Getenv("VAULT_MOUNT_PATH"), "leases/transit-rotation"),
+			LeaseTTL:            time.Second * 30,
+			ActiveKeyTTL:        time.Minute,
+		}
+		db, err := transit.MakeWithClient(ctx, client.Client, transitConfig, broker, tracer, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Drop cached active keys whenever any instance rotates them.
+		d.Register(db)
+		return db, transit.SupportedAlgorithms(), nil
+	}
+
+	keyPolicy, err := vault.ParseKeyPolicy(os.Getenv("VAULT_KEY_POLICY"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vaultConfig := vault.Config{
+		MountPath:          os.Getenv("VAULT_MOUNT_PATH"),
+		KeyCount:           10,
+		KeyRefreshInterval: time.Hour * 24, // Daily
+		KeyPolicy:          keyPolicy,
+		KeySnapshotTTL:     time.Minute * 5,
+		RetiredKeyTTL:      time.Hour * 24 * 7, // Outlives tokens valid for a week.
+		LeaseTTL:           time.Second * 30,
+	}
+
+	var client *vaultclient.Client
+	if keyStore == "" || keyStore == "vault" || os.Getenv("VAULT_CA_PATH") != "" {
+		client, err = makeVaultClient(ctx, healthServer, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if path := os.Getenv("VAULT_CA_PATH"); path != "" {
+		caConfig := vault.CAConfig{
+			MountPath: os.Getenv("VAULT_CA_MOUNT_PATH"),
+			Path:      path,
+			Validity:  time.Hour * 24 * 30, // Outlives keys rotated daily.
+		}
+
+		ca, err := vault.LoadCA(ctx, client.Client, caConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		vaultConfig.CertificateIssuer = ca
+	}
+
+	var db vault.Vault
+
+	switch keyStore {
+	case "filesystem":
+		store, err := filestore.Make(filestore.Config{
+			Dir:        os.Getenv("KEY_STORE_DIR"),
+			Passphrase: os.Getenv("KEY_STORE_PASSPHRASE"),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		db, err = vault.MakeWithStore(ctx, store, vaultConfig, broker, tracer, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+
+	case "mongo":
+		store, err := mongo.MakeKeyStore(ctx, tokens, os.Getenv("KEY_STORE_PASSPHRASE"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		db, err = vault.MakeWithStore(ctx, store, vaultConfig, broker, tracer, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+
+	case "", "vault":
+		db, err = vault.MakeWithClient(ctx, client.Client, vaultConfig, broker, tracer, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+
+	default:
+		return nil, nil, errors.New("unknown key store: " + keyStore)
+	}
+
+	// Reload cached keys whenever any instance rotates them.
+	d.Register(db)
+
+	return db, vaultConfig.Algorithms(), nil
+}
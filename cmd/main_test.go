@@ -4,6 +4,7 @@ import (
 	"log"
 	"testing"
 
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
 	mongo "github.com/krixlion/dev_forum-auth/pkg/storage/mongo/testdata"
 	vault "github.com/krixlion/dev_forum-auth/pkg/storage/vault/testdata"
 	"go.uber.org/goleak"
@@ -30,3 +31,48 @@ func TestMain(m *testing.M) {
 
 	goleak.VerifyTestMain(m)
 }
+
+func Test_signingAlgorithm(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		algorithms []entity.Algorithm
+		want       entity.Algorithm
+		wantErr    bool
+	}{
+		{
+			name:       "Test if defaults to the first algorithm of the key policy",
+			algorithms: []entity.Algorithm{entity.EdDSA, entity.ES256},
+			want:       entity.EdDSA,
+		},
+		{
+			name:       "Test if accepts an algorithm of the key policy",
+			configured: "ES256",
+			algorithms: []entity.Algorithm{entity.EdDSA, entity.ES256},
+			want:       entity.ES256,
+		},
+		{
+			name:       "Test if fails on an algorithm missing from the key policy",
+			configured: "RS256",
+			algorithms: []entity.Algorithm{entity.EdDSA, entity.ES256},
+			wantErr:    true,
+		},
+		{
+			name:    "Test if fails on an empty key policy",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := signingAlgorithm(tt.configured, tt.algorithms)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("signingAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("signingAlgorithm():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
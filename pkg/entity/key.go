@@ -2,17 +2,38 @@ package entity
 
 import (
 	"crypto"
+	"crypto/x509"
 	"errors"
 
 	"google.golang.org/protobuf/proto"
 )
 
+var (
+	ErrSignerMissing       = errors.New("signer is nil")
+	ErrEncodeFuncMissing   = errors.New("encodeFunc is nil")
+	ErrPublicKeyMissing    = errors.New("signer has no public key")
+	ErrPublicKeyNotEncoded = errors.New("key has no encoded public key")
+)
+
+// Key is a signing key. Asymmetric keys are represented by a crypto.Signer
+// so that software keys, keys kept in Vault's Transit engine and keys
+// stored in HSMs are all used in the same way.
+// Use NewKey and NewSymmetricKey to create valid keys.
 type Key struct {
-	Id         string
-	Type       KeyType
-	Algorithm  Algorithm
-	Raw        crypto.PrivateKey
-	EncodeFunc KeyEncodeFunc
+	Id        string
+	Type      KeyType
+	Algorithm Algorithm
+	// Signer is used to sign with asymmetric keys.
+	// Nil for symmetric keys and verify-only keys.
+	Signer crypto.Signer
+	// Secret is a symmetric key. Nil for asymmetric keys.
+	Secret []byte
+	// Certificates certify the public key, leaf first. Published as "x5c".
+	// Nil if the key store does not issue certificates.
+	Certificates []*x509.Certificate
+
+	// public is the public key encoded once during construction.
+	public proto.Message
 }
 
 type KeyEncodeFunc func(crypto.PublicKey) (proto.Message, error)
@@ -23,22 +44,95 @@ const (
 	RSA   KeyType = "RSA"
 	ECDSA KeyType = "ECDSA"
 	HMAC  KeyType = "HMAC"
+	// OKP is an Octet Key Pair as described in RFC 8037, eg. Ed25519.
+	OKP KeyType = "OKP"
 )
 
 type Algorithm string
 
 const (
 	RS256 Algorithm = "RS256"
+	RS384 Algorithm = "RS384"
+	RS512 Algorithm = "RS512"
+	PS256 Algorithm = "PS256"
+	PS384 Algorithm = "PS384"
+	PS512 Algorithm = "PS512"
 	ES256 Algorithm = "ES256"
+	ES384 Algorithm = "ES384"
+	ES512 Algorithm = "ES512"
 	HS256 Algorithm = "HS256"
+	// EdDSA signs with Ed25519 keys.
+	EdDSA Algorithm = "EdDSA"
 )
 
-// Encode is a helper method used to invoke EncodeFunc safely.
+// NewKey returns an asymmetric key which signs using given signer.
+// The public key is exported with encodeFunc upfront so that a key which
+// cannot be published is rejected here instead of when it is requested.
+func NewKey(id string, keyType KeyType, algorithm Algorithm, signer crypto.Signer, encodeFunc KeyEncodeFunc) (Key, error) {
+	if signer == nil {
+		return Key{}, ErrSignerMissing
+	}
+
+	if encodeFunc == nil {
+		return Key{}, ErrEncodeFuncMissing
+	}
+
+	key, err := NewVerificationKey(id, keyType, algorithm, signer.Public(), encodeFunc)
+	if err != nil {
+		return Key{}, err
+	}
+
+	key.Signer = signer
+
+	return key, nil
+}
+
+// NewVerificationKey returns an asymmetric key without its private part,
+// e.g. a retired key or one held by an external party. It's published so that
+// tokens signed with it can be verified but it's never used to sign tokens.
+func NewVerificationKey(id string, keyType KeyType, algorithm Algorithm, publicKey crypto.PublicKey, encodeFunc KeyEncodeFunc) (Key, error) {
+	if encodeFunc == nil {
+		return Key{}, ErrEncodeFuncMissing
+	}
+
+	if publicKey == nil {
+		return Key{}, ErrPublicKeyMissing
+	}
+
+	public, err := encodeFunc(publicKey)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		Id:        id,
+		Type:      keyType,
+		Algorithm: algorithm,
+		public:    public,
+	}, nil
+}
+
+// NewSymmetricKey returns an HMAC key. Symmetric keys are never published.
+func NewSymmetricKey(id string, algorithm Algorithm, secret []byte) Key {
+	return Key{
+		Id:        id,
+		Type:      HMAC,
+		Algorithm: algorithm,
+		Secret:    secret,
+	}
+}
+
+// VerifyOnly returns true if the key cannot be used to sign tokens.
+func (key Key) VerifyOnly() bool {
+	return key.Signer == nil && len(key.Secret) == 0
+}
+
+// Encode returns the public key encoded during construction.
+// It returns ErrPublicKeyNotEncoded for symmetric keys and keys not created with NewKey.
 func (key Key) Encode() (proto.Message, error) {
-	if key.EncodeFunc == nil {
-		// Return generic zero value and an error.
-		return nil, errors.New("encodeFunc is nil")
+	if key.public == nil {
+		return nil, ErrPublicKeyNotEncoded
 	}
 
-	return key.EncodeFunc(key.Raw.(interface{ Public() crypto.PublicKey }).Public())
+	return key.public, nil
 }
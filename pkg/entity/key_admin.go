@@ -0,0 +1,41 @@
+package entity
+
+import "time"
+
+// KeyInfo describes a stored key without exposing its key material.
+type KeyInfo struct {
+	Id        string
+	Type      KeyType
+	Algorithm Algorithm
+	// Active is true if the key is used to sign tokens with its algorithm.
+	Active    bool
+	CreatedAt time.Time
+	// Imported is true for keys generated outside of the service.
+	Imported bool
+	// Zero for keys which were not imported.
+	NotBefore time.Time
+}
+
+// ImportedKey is a key generated outside of the service, e.g. in an HSM.
+// Public keys are imported as verify-only keys.
+type ImportedKey struct {
+	// Id is optional. If set it has to be the RFC 7638 thumbprint of the key.
+	Id        string
+	Algorithm Algorithm
+	// EncodedKey is a PEM encoded private or public key or a JWK.
+	EncodedKey string
+	// The key is published right away but not used to sign tokens before NotBefore.
+	// It's used right away if zero.
+	NotBefore time.Time
+}
+
+// RotationStatus describes the key rotation shared by all instances.
+type RotationStatus struct {
+	// Zero if keys were never rotated.
+	LastRotatedAt time.Time
+	// Zero if keys are not rotated periodically.
+	NextRotationAt time.Time
+	// Leader is the id of the instance holding the rotation lease.
+	Leader         string
+	LeaseExpiresAt time.Time
+}
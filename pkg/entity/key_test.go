@@ -0,0 +1,221 @@
+package entity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// nilPublicSigner is a crypto.Signer without a public key.
+type nilPublicSigner struct{}
+
+func (nilPublicSigner) Public() crypto.PublicKey { return nil }
+
+func (nilPublicSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestNewKey(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ecdsa key: %s", err)
+	}
+
+	encodeFunc := func(crypto.PublicKey) (proto.Message, error) {
+		return wrapperspb.String("public"), nil
+	}
+
+	tests := []struct {
+		name       string
+		signer     crypto.Signer
+		encodeFunc KeyEncodeFunc
+		wantErr    error
+	}{
+		{
+			name:       "Test if public key is encoded on valid signer",
+			signer:     ecdsaKey,
+			encodeFunc: encodeFunc,
+		},
+		{
+			name:       "Test if fails on nil signer",
+			encodeFunc: encodeFunc,
+			wantErr:    ErrSignerMissing,
+		},
+		{
+			name:    "Test if fails on nil encodeFunc",
+			signer:  ecdsaKey,
+			wantErr: ErrEncodeFuncMissing,
+		},
+		{
+			name:       "Test if fails on signer without a public key",
+			signer:     nilPublicSigner{},
+			encodeFunc: encodeFunc,
+			wantErr:    ErrPublicKeyMissing,
+		},
+		{
+			name:   "Test if forwards encodeFunc errors",
+			signer: ecdsaKey,
+			encodeFunc: func(crypto.PublicKey) (proto.Message, error) {
+				return nil, errors.New("test err")
+			},
+			wantErr: errors.New("test err"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewKey("test", ECDSA, ES256, tt.signer, tt.encodeFunc)
+			if (err != nil) != (tt.wantErr != nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Errorf("NewKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			encoded, err := got.Encode()
+			if err != nil {
+				t.Errorf("Key.Encode() error = %v", err)
+				return
+			}
+
+			if !proto.Equal(encoded, wrapperspb.String("public")) {
+				t.Errorf("Key.Encode() = %v, want encoded public key", encoded)
+			}
+		})
+	}
+}
+
+func TestNewVerificationKey(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ecdsa key: %s", err)
+	}
+
+	encodeFunc := func(crypto.PublicKey) (proto.Message, error) {
+		return wrapperspb.String("public"), nil
+	}
+
+	tests := []struct {
+		name       string
+		publicKey  crypto.PublicKey
+		encodeFunc KeyEncodeFunc
+		wantErr    error
+	}{
+		{
+			name:       "Test if public key is encoded",
+			publicKey:  ecdsaKey.Public(),
+			encodeFunc: encodeFunc,
+		},
+		{
+			name:       "Test if fails on nil public key",
+			encodeFunc: encodeFunc,
+			wantErr:    ErrPublicKeyMissing,
+		},
+		{
+			name:      "Test if fails on nil encodeFunc",
+			publicKey: ecdsaKey.Public(),
+			wantErr:   ErrEncodeFuncMissing,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewVerificationKey("test", ECDSA, ES256, tt.publicKey, tt.encodeFunc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewVerificationKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !got.VerifyOnly() {
+				t.Errorf("Key.VerifyOnly() = false, want true")
+			}
+
+			encoded, err := got.Encode()
+			if err != nil {
+				t.Errorf("Key.Encode() error = %v", err)
+				return
+			}
+
+			if !proto.Equal(encoded, wrapperspb.String("public")) {
+				t.Errorf("Key.Encode() = %v, want encoded public key", encoded)
+			}
+		})
+	}
+}
+
+func TestKey_VerifyOnly(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ecdsa key: %s", err)
+	}
+
+	signingKey, err := NewKey("test", ECDSA, ES256, ecdsaKey, func(crypto.PublicKey) (proto.Message, error) {
+		return wrapperspb.String("public"), nil
+	})
+	if err != nil {
+		t.Fatalf("NewKey() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  Key
+		want bool
+	}{
+		{
+			name: "Test if asymmetric key can sign",
+			key:  signingKey,
+			want: false,
+		},
+		{
+			name: "Test if symmetric key can sign",
+			key:  NewSymmetricKey("test", HS256, []byte("secret")),
+			want: false,
+		},
+		{
+			name: "Test if zero key cannot sign",
+			key:  Key{},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.VerifyOnly(); got != tt.want {
+				t.Errorf("Key.VerifyOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey_Encode(t *testing.T) {
+	tests := []struct {
+		name string
+		key  Key
+	}{
+		{
+			name: "Test if returns an error on symmetric key",
+			key:  NewSymmetricKey("test", HS256, []byte("secret")),
+		},
+		{
+			name: "Test if returns an error instead of panicking on zero key",
+			key:  Key{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.key.Encode(); !errors.Is(err, ErrPublicKeyNotEncoded) {
+				t.Errorf("Key.Encode() error = %v, want %v", err, ErrPublicKeyNotEncoded)
+			}
+		})
+	}
+}
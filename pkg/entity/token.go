@@ -8,13 +8,56 @@ type Token struct {
 	Id        string // Token's ID is its related decoded opaque token.
 	UserId    string
 	Type      TokenType
-	ExpiresAt time.Time
+	ExpiresAt time.Time // Zero value means that the token does not expire.
 	IssuedAt  time.Time
+
+	// Fields below are set only for personal access tokens.
+	Name       string
+	Scopes     []string
+	LastUsedAt time.Time
+
+	// Actor is set only for tokens issued through impersonation.
+	// It identifies the admin acting on behalf of the user.
+	Actor string
+
+	// Hex-encoded SHA-256 hash of the nonce binding a magic link token to the requesting device.
+	NonceHash string
+
+	// Hex-encoded SHA-256 hash of the normalised email, set only for magic link requests.
+	EmailHash string
+
+	// Confirmation binds the token to a key held by the client.
+	// Zero value means that the token is a bearer token.
+	Confirmation Confirmation
+}
+
+// Confirmation describes the key a sender-constrained token is bound to
+// as in the "cnf" claim described in RFC 7800.
+type Confirmation struct {
+	// JKT is a Base64URL encoded SHA-256 JWK thumbprint of the client's DPoP key (RFC 9449).
+	JKT string
+	// X5tS256 is a Base64URL encoded SHA-256 thumbprint of the client's mTLS certificate (RFC 8705).
+	X5tS256 string
+}
+
+// IsZero reports whether the confirmation binds the token to no key.
+func (c Confirmation) IsZero() bool {
+	return c == Confirmation{}
 }
 
 type TokenType string
 
 const (
-	RefreshToken TokenType = "refresh-token"
-	AccessToken  TokenType = "access-token"
+	RefreshToken        TokenType = "refresh-token"
+	AccessToken         TokenType = "access-token"
+	PersonalAccessToken TokenType = "personal-access-token"
+
+	// Single-use tokens delivered to the user by email.
+	PasswordResetToken     TokenType = "password-reset-token"
+	EmailVerificationToken TokenType = "email-verification-token"
+	MagicLinkToken         TokenType = "magic-link-token"
+
+	// MagicLinkRequest records a magic link requested for an email in order to rate limit
+	// the requests. It outlives the magic link token and can't be redeemed.
+	MagicLinkRequest TokenType = "magic-link-request"
 )
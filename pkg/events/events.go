@@ -0,0 +1,51 @@
+// Package events declares events published by the auth-service
+// which are not shared through github.com/krixlion/dev_forum-lib/event.
+package events
+
+import (
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+const (
+	PasswordResetRequested     event.EventType = "password_reset-requested"
+	EmailVerificationRequested event.EventType = "email_verification-requested"
+	EmailVerified              event.EventType = "email-verified"
+	MagicLinkRequested         event.EventType = "magic_link-requested"
+
+	// Audit events.
+	UserImpersonated event.EventType = "user-impersonated"
+	KeysRotated      event.EventType = "keys-rotated"
+	KeyRevoked       event.EventType = "key-revoked"
+	KeyImported      event.EventType = "key-imported"
+)
+
+// TokenIssued is the body of events published when a token that has to be
+// delivered to the user, eg. by a mail service, is issued.
+type TokenIssued struct {
+	UserId    string    `json:"user_id,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Token     string    `json:"token,omitempty"` // Encoded opaque token.
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// UserEmailVerified is the body of the EmailVerified event.
+type UserEmailVerified struct {
+	UserId string `json:"user_id,omitempty"`
+	Email  string `json:"email,omitempty"`
+}
+
+// Impersonation is the body of the UserImpersonated event.
+type Impersonation struct {
+	Actor     string    `json:"actor,omitempty"`
+	UserId    string    `json:"user_id,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// KeyManagement is the body of the KeysRotated, KeyRevoked and KeyImported events.
+type KeyManagement struct {
+	Actor string `json:"actor,omitempty"`
+	Kid   string `json:"kid,omitempty"` // Empty for KeysRotated.
+}
@@ -6,13 +6,19 @@ import (
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
 
 	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop"
 	"github.com/krixlion/dev_forum-lib/tracing"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // NewAuthFunc returns a callback to be used with grpc_auth interceptor.
 // It reads the Bearer token from the context of an incoming request
 // and verifies it using given tokens.Validator.
+// If the request contains a DPoP proof in its "dpop" metadata the token is read using
+// the DPoP scheme instead and verified along with the proof, in which case
+// given validator has to implement tokens.ProofValidator.
 // If the validator fails to verify the token an error is returned.
 // Otherwise the context is returned unaltered.
 func NewAuthFunc(tokenValidator tokens.Validator, tracer trace.Tracer) grpc_auth.AuthFunc {
@@ -21,6 +27,24 @@ func NewAuthFunc(tokenValidator tokens.Validator, tracer trace.Tracer) grpc_auth
 		defer span.End()
 		defer tracing.SetSpanErr(span, err)
 
+		if proof, uri, ok := dpop.ProofFromIncomingContext(ctx); ok {
+			token, err := grpc_auth.AuthFromMD(ctx, "DPoP")
+			if err != nil {
+				return nil, err
+			}
+
+			proofValidator, ok := tokenValidator.(tokens.ProofValidator)
+			if !ok {
+				return nil, status.Error(codes.Unauthenticated, "DPoP proofs are not supported")
+			}
+
+			if err := proofValidator.ValidateTokenWithProof(ctx, token, proof, dpop.GRPCMethod, uri); err != nil {
+				return nil, err
+			}
+
+			return ctx, nil
+		}
+
 		token, err := grpc_auth.AuthFromMD(ctx, "Bearer")
 		if err != nil {
 			return nil, err
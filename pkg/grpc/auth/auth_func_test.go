@@ -10,6 +10,7 @@ import (
 	"github.com/krixlion/dev_forum-auth/pkg/tokens"
 	"github.com/krixlion/dev_forum-auth/pkg/tokens/tokensmocks"
 	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/stretchr/testify/mock"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -59,6 +60,35 @@ func TestNewAuthFunc(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Test no error is returned on valid DPoP-bound token and proof",
+			args: args{
+				tokenValidator: func() tokensmocks.TokenValidator {
+					m := tokensmocks.NewTokenValidator()
+					m.On("ValidateTokenWithProof", mock.Anything, "test-token", "test-proof", "POST", "https://test-service").Return(nil).Once()
+					return m
+				}(),
+				tracer: nulls.NullTracer{},
+				ctx: metadata.MD{}.
+					Add("authorization", "DPoP test-token").
+					Add("dpop", "test-proof").
+					Add(":authority", "test-service").
+					ToIncoming(context.Background()),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Test error is returned if DPoP proof is sent along with a Bearer token",
+			args: args{
+				tokenValidator: tokensmocks.NewTokenValidator(),
+				tracer:         nulls.NullTracer{},
+				ctx: metadata.MD{}.
+					Add("authorization", "Bearer test-token").
+					Add("dpop", "test-proof").
+					ToIncoming(context.Background()),
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
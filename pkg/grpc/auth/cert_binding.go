@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+
+	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// PeerCertificate returns the verified client certificate of the incoming request's peer.
+// Returns false if the peer did not present a certificate.
+//
+// Certificate is only propagated through context if grpc.Creds() is used.
+func PeerCertificate(ctx context.Context) (*x509.Certificate, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) < 1 || len(tlsInfo.State.VerifiedChains[0]) < 1 {
+		return nil, false
+	}
+
+	return tlsInfo.State.VerifiedChains[0][0], true
+}
+
+// NewCertificateBindingInterceptor returns an interceptor which rejects calls made
+// with certificate-bound access tokens if the token's "cnf.x5t#S256" thumbprint
+// does not match the certificate of the caller, as described in RFC 8705.
+// Calls made with bearer tokens or without a token are passed through, so
+// it should be used along with the grpc_auth interceptor using NewAuthFunc.
+func NewCertificateBindingInterceptor(parser tokens.Parser, tracer trace.Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := verifyCertificateBinding(ctx, parser, tracer); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewCertificateBindingStreamInterceptor is a stream equivalent of NewCertificateBindingInterceptor.
+func NewCertificateBindingStreamInterceptor(parser tokens.Parser, tracer trace.Tracer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := verifyCertificateBinding(stream.Context(), parser, tracer); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func verifyCertificateBinding(ctx context.Context, parser tokens.Parser, tracer trace.Tracer) (err error) {
+	ctx, span := tracer.Start(ctx, "auth.verifyCertificateBinding")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	token, err := grpc_auth.AuthFromMD(ctx, "Bearer")
+	if err != nil {
+		token, err = grpc_auth.AuthFromMD(ctx, "DPoP")
+		if err != nil {
+			// Leave authentication to the auth interceptor.
+			return nil
+		}
+	}
+
+	claims, err := parser.ParseToken(token)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if claims.Confirmation.X5tS256 == "" {
+		return nil
+	}
+
+	cert, ok := PeerCertificate(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "certificate-bound token requires a client certificate")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(jwkutil.CertificateThumbprint(cert)), []byte(claims.Confirmation.X5tS256)) != 1 {
+		return status.Error(codes.Unauthenticated, "token is bound to a different certificate")
+	}
+
+	return nil
+}
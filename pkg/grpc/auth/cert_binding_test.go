@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/metadata"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/tokensmocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestNewCertificateBindingInterceptor(t *testing.T) {
+	clientCert := generateTestCertificate(t, "test-client")
+	otherCert := generateTestCertificate(t, "other-client")
+
+	withToken := func(ctx context.Context) context.Context {
+		return metadata.MD{}.Add("authorization", "Bearer test-token").ToIncoming(ctx)
+	}
+	withPeer := func(ctx context.Context, cert *x509.Certificate) context.Context {
+		return peer.NewContext(ctx, &peer.Peer{
+			AuthInfo: credentials.TLSInfo{
+				State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			},
+		})
+	}
+	parserReturning := func(claims tokens.Claims, err error) tokensmocks.TokenValidator {
+		m := tokensmocks.NewTokenValidator()
+		m.On("ParseToken", "test-token").Return(claims, err).Once()
+		return m
+	}
+	boundClaims := tokens.Claims{
+		Subject:      "test-id",
+		Confirmation: entity.Confirmation{X5tS256: jwkutil.CertificateThumbprint(clientCert)},
+	}
+
+	tests := []struct {
+		name    string
+		parser  tokens.Parser
+		ctx     context.Context
+		wantErr bool
+	}{
+		{
+			name:   "Test if passes bound token presented with its certificate",
+			parser: parserReturning(boundClaims, nil),
+			ctx:    withPeer(withToken(context.Background()), clientCert),
+		},
+		{
+			name:   "Test if passes bearer token without a certificate",
+			parser: parserReturning(tokens.Claims{Subject: "test-id"}, nil),
+			ctx:    withToken(context.Background()),
+		},
+		{
+			name:   "Test if passes request without a token",
+			parser: tokensmocks.NewTokenValidator(),
+			ctx:    context.Background(),
+		},
+		{
+			name:    "Test if fails on bound token presented with a different certificate",
+			parser:  parserReturning(boundClaims, nil),
+			ctx:     withPeer(withToken(context.Background()), otherCert),
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on bound token presented without a certificate",
+			parser:  parserReturning(boundClaims, nil),
+			ctx:     withToken(context.Background()),
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on invalid token",
+			parser:  parserReturning(tokens.Claims{}, errors.New("test err")),
+			ctx:     withToken(context.Background()),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlerCalled := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handlerCalled = true
+				return nil, nil
+			}
+
+			_, err := NewCertificateBindingInterceptor(tt.parser, nulls.NullTracer{})(tt.ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCertificateBindingInterceptor():\n error = %v\n wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			if handlerCalled == tt.wantErr {
+				t.Errorf("NewCertificateBindingInterceptor():\n handlerCalled = %v\n wantErr = %v", handlerCalled, tt.wantErr)
+			}
+		})
+	}
+}
+
+func generateTestCertificate(t *testing.T, commonName string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	return cert
+}
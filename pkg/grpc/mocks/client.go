@@ -46,3 +46,53 @@ func (m AuthClient) TranslateAccessToken(ctx context.Context, opts ...grpc.CallO
 	args := m.Called(ctx, opts)
 	return args.Get(0).(pb.AuthService_TranslateAccessTokenClient), args.Error(1)
 }
+
+func (m AuthClient) CreatePersonalAccessToken(ctx context.Context, in *pb.CreatePersonalAccessTokenRequest, opts ...grpc.CallOption) (*pb.CreatePersonalAccessTokenResponse, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*pb.CreatePersonalAccessTokenResponse), args.Error(1)
+}
+
+func (m AuthClient) ListPersonalAccessTokens(ctx context.Context, in *pb.ListPersonalAccessTokensRequest, opts ...grpc.CallOption) (*pb.ListPersonalAccessTokensResponse, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*pb.ListPersonalAccessTokensResponse), args.Error(1)
+}
+
+func (m AuthClient) RevokePersonalAccessToken(ctx context.Context, in *pb.RevokePersonalAccessTokenRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*emptypb.Empty), args.Error(1)
+}
+
+func (m AuthClient) RequestPasswordReset(ctx context.Context, in *pb.RequestPasswordResetRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*emptypb.Empty), args.Error(1)
+}
+
+func (m AuthClient) ResetPassword(ctx context.Context, in *pb.ResetPasswordRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*emptypb.Empty), args.Error(1)
+}
+
+func (m AuthClient) RequestEmailVerification(ctx context.Context, in *pb.RequestEmailVerificationRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*emptypb.Empty), args.Error(1)
+}
+
+func (m AuthClient) VerifyEmail(ctx context.Context, in *pb.VerifyEmailRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*emptypb.Empty), args.Error(1)
+}
+
+func (m AuthClient) RequestMagicLink(ctx context.Context, in *pb.RequestMagicLinkRequest, opts ...grpc.CallOption) (*pb.RequestMagicLinkResponse, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*pb.RequestMagicLinkResponse), args.Error(1)
+}
+
+func (m AuthClient) RedeemMagicLink(ctx context.Context, in *pb.RedeemMagicLinkRequest, opts ...grpc.CallOption) (*pb.RedeemMagicLinkResponse, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*pb.RedeemMagicLinkResponse), args.Error(1)
+}
+
+func (m AuthClient) Impersonate(ctx context.Context, in *pb.ImpersonateRequest, opts ...grpc.CallOption) (*pb.ImpersonateResponse, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*pb.ImpersonateResponse), args.Error(1)
+}
@@ -0,0 +1,231 @@
+package protokey
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var (
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+	ErrKeyTypeMismatch    = errors.New("key does not match its key type")
+)
+
+// JwkReceiver is a stream of keys, e.g. the one returned by
+// AuthServiceClient.GetValidationKeySet.
+type JwkReceiver interface {
+	Recv() (*pb.Jwk, error)
+}
+
+// ReceiveKeySet reads keys from given stream until io.EOF
+// and returns them as a JWK Set as described in RFC 7517.
+func ReceiveKeySet(stream JwkReceiver) (jwk.Set, error) {
+	keySet := jwk.NewSet()
+
+	for {
+		message, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return keySet, nil
+			}
+			return nil, err
+		}
+
+		key, err := JwkToKey(message)
+		if err != nil {
+			return nil, err
+		}
+
+		keySet.Add(key)
+	}
+}
+
+// JwksToKeySet returns given keys as a JWK Set.
+func JwksToKeySet(messages []*pb.Jwk) (jwk.Set, error) {
+	keySet := jwk.NewSet()
+
+	for _, message := range messages {
+		key, err := JwkToKey(message)
+		if err != nil {
+			return nil, err
+		}
+
+		keySet.Add(key)
+	}
+
+	return keySet, nil
+}
+
+// KeySetToJwks returns public parts of the keys in given JWK Set as Jwk messages.
+// Returns ErrUnsupportedKeyType if the set contains symmetric keys.
+func KeySetToJwks(keySet jwk.Set) ([]*pb.Jwk, error) {
+	messages := make([]*pb.Jwk, 0, keySet.Len())
+
+	for i := 0; i < keySet.Len(); i++ {
+		key, _ := keySet.Get(i)
+
+		message, err := KeyToJwk(key)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// JwkToKey converts given Jwk message to a public JWK,
+// including its "kid", "alg", "use", "key_ops", "x5c" and "x5t#S256" parameters.
+// Returns ErrKeyTypeMismatch if the message's kty does not match its key.
+func JwkToKey(message *pb.Jwk) (jwk.Key, error) {
+	if message == nil || message.GetKey() == nil {
+		return nil, ErrKeyNil
+	}
+
+	encoded, err := message.GetKey().UnmarshalNew()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := DeserializeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := jwk.New(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if kty, err := keyTypeFromProto(message.GetKty()); err != nil || kty != key.KeyType() {
+		return nil, fmt.Errorf("%w: kty %q, key %s", ErrKeyTypeMismatch, message.GetKty(), key.KeyType())
+	}
+
+	headers := map[string]interface{}{}
+
+	if kid := message.GetKid(); kid != "" {
+		headers[jwk.KeyIDKey] = kid
+	}
+
+	if alg := message.GetAlg(); alg != "" {
+		headers[jwk.AlgorithmKey] = alg
+	}
+
+	if use := message.GetUse(); use != "" {
+		headers[jwk.KeyUsageKey] = use
+	}
+
+	if ops := message.GetKeyOps(); len(ops) > 0 {
+		headers[jwk.KeyOpsKey] = ops
+	}
+
+	if chain := message.GetX5C(); len(chain) > 0 {
+		encodedChain := make([]string, 0, len(chain))
+		for _, der := range chain {
+			encodedChain = append(encodedChain, base64.StdEncoding.EncodeToString(der))
+		}
+		headers[jwk.X509CertChainKey] = encodedChain
+	}
+
+	if thumbprint := message.GetX5TS256(); thumbprint != "" {
+		headers[jwk.X509CertThumbprintS256Key] = thumbprint
+	}
+
+	for name, value := range headers {
+		if err := key.Set(name, value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	return key, nil
+}
+
+// KeyToJwk converts given JWK to a Jwk message. Private keys are converted to their public parts.
+// Returns ErrUnsupportedKeyType for symmetric keys and curves other than
+// the ones supported by SerializeECDSA and SerializeEd25519.
+func KeyToJwk(key jwk.Key) (*pb.Jwk, error) {
+	if key == nil {
+		return nil, ErrKeyNil
+	}
+
+	publicKey, err := jwk.PublicKeyOf(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, key.KeyType())
+	}
+
+	var raw interface{}
+	if err := publicKey.Raw(&raw); err != nil {
+		return nil, err
+	}
+
+	var encoded proto.Message
+	var kty entity.KeyType
+
+	switch raw.(type) {
+	case *rsa.PublicKey:
+		encoded, err = SerializeRSA(raw)
+		kty = entity.RSA
+	case *ecdsa.PublicKey:
+		encoded, err = SerializeECDSA(raw)
+		kty = entity.ECDSA
+	case ed25519.PublicKey:
+		encoded, err = SerializeEd25519(raw)
+		kty = entity.OKP
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKeyType, raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	marshaledKey, err := anypb.New(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &pb.Jwk{
+		Kid:     key.KeyID(),
+		Kty:     string(kty),
+		Alg:     key.Algorithm(),
+		Key:     marshaledKey,
+		Use:     key.KeyUsage(),
+		X5TS256: key.X509CertThumbprintS256(),
+	}
+
+	for _, op := range key.KeyOps() {
+		message.KeyOps = append(message.KeyOps, string(op))
+	}
+
+	for _, certificate := range key.X509CertChain() {
+		message.X5C = append(message.X5C, certificate.Raw)
+	}
+
+	return message, nil
+}
+
+// keyTypeFromProto returns the JWK key type of a Jwk message's kty.
+// Messages use entity.KeyType, which names EC keys "ECDSA".
+func keyTypeFromProto(kty string) (jwa.KeyType, error) {
+	switch entity.KeyType(kty) {
+	case entity.RSA:
+		return jwa.RSA, nil
+	case entity.ECDSA, "EC":
+		return jwa.EC, nil
+	case entity.OKP:
+		return jwa.OKP, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedKeyType, kty)
+	}
+}
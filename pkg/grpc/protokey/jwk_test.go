@@ -0,0 +1,253 @@
+package protokey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	ecpb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1/ec"
+	okppb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1/okp"
+	rsapb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1/rsa"
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
+	"github.com/lestrrat-go/jwx/jwk"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// jwkStream is a JwkReceiver returning given keys followed by err.
+type jwkStream struct {
+	keys []*pb.Jwk
+	err  error
+}
+
+func (s *jwkStream) Recv() (*pb.Jwk, error) {
+	if len(s.keys) == 0 {
+		return nil, s.err
+	}
+
+	key := s.keys[0]
+	s.keys = s.keys[1:]
+	return key, nil
+}
+
+func mustAny(t *testing.T, message proto.Message) *anypb.Any {
+	t.Helper()
+
+	marshaled, err := anypb.New(message)
+	if err != nil {
+		t.Fatalf("Failed to marshal key to anypb.Any: %v", err)
+	}
+	return marshaled
+}
+
+// normalizeJSON decodes given JSON so that documents can be compared regardless of formatting.
+func normalizeJSON(t *testing.T, data []byte) interface{} {
+	t.Helper()
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode JSON: %v", err)
+	}
+	return decoded
+}
+
+// TestKeySetConversion_golden converts the example key sets from RFC 7517, Appendix A.1
+// and RFC 8037, Appendix A.2 to Jwk messages and back.
+func TestKeySetConversion_golden(t *testing.T) {
+	tests := []struct {
+		golden string
+		want   []*pb.Jwk
+	}{
+		{
+			golden: "rfc7517_a1.json",
+			want: []*pb.Jwk{
+				{
+					Kid: "1",
+					Kty: "ECDSA",
+					Use: "enc",
+					Key: mustAny(t, &ecpb.EC{
+						Crv: ecpb.ECType_P256,
+						X:   "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",
+						Y:   "4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM",
+					}),
+				},
+				{
+					Kid: "2011-04-29",
+					Kty: "RSA",
+					Alg: "RS256",
+					Key: mustAny(t, &rsapb.RSA{
+						N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+						E: "AQAB",
+					}),
+				},
+			},
+		},
+		{
+			golden: "rfc8037_a2.json",
+			want: []*pb.Jwk{
+				{
+					Kty: "OKP",
+					Key: mustAny(t, &okppb.OKP{
+						Crv: okppb.OKPType_Ed25519,
+						X:   testdata.Ed25519.X,
+					}),
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run("Test if converts "+tt.golden, func(t *testing.T) {
+			golden, err := os.ReadFile(filepath.Join("testdata", tt.golden))
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v", err)
+			}
+
+			keySet, err := jwk.Parse(golden)
+			if err != nil {
+				t.Fatalf("Failed to parse golden file: %v", err)
+			}
+
+			got, err := KeySetToJwks(keySet)
+			if err != nil {
+				t.Fatalf("KeySetToJwks() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("KeySetToJwks() mismatch (-want +got):\n%s", diff)
+			}
+
+			converted, err := JwksToKeySet(got)
+			if err != nil {
+				t.Fatalf("JwksToKeySet() error = %v", err)
+			}
+
+			encoded, err := json.Marshal(converted)
+			if err != nil {
+				t.Fatalf("Failed to marshal key set: %v", err)
+			}
+
+			if diff := cmp.Diff(normalizeJSON(t, golden), normalizeJSON(t, encoded)); diff != "" {
+				t.Errorf("JwksToKeySet() does not match %s (-want +got):\n%s", tt.golden, diff)
+			}
+		})
+	}
+}
+
+func TestReceiveKeySet(t *testing.T) {
+	ecdsaJwk := &pb.Jwk{
+		Kid:     testdata.ECDSA.Id,
+		Kty:     "ECDSA",
+		Alg:     "ES256",
+		Use:     "sig",
+		KeyOps:  []string{"verify"},
+		X5C:     [][]byte{testdata.CA.Cert.Raw},
+		X5TS256: jwkutil.CertificateThumbprint(testdata.CA.Cert),
+		Key:     mustAny(t, &ecpb.EC{Crv: testdata.ECDSA.Crv, X: testdata.ECDSA.X, Y: testdata.ECDSA.Y}),
+	}
+
+	tests := []struct {
+		name    string
+		stream  *jwkStream
+		wantErr error
+	}{
+		{
+			name:   "Test if receives keys with all parameters",
+			stream: &jwkStream{keys: []*pb.Jwk{ecdsaJwk}, err: io.EOF},
+		},
+		{
+			name:    "Test if forwards stream errors",
+			stream:  &jwkStream{keys: []*pb.Jwk{ecdsaJwk}, err: context.DeadlineExceeded},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "Test if rejects a key not matching its kty",
+			stream: &jwkStream{keys: []*pb.Jwk{{
+				Kty: "RSA",
+				Key: mustAny(t, &ecpb.EC{Crv: testdata.ECDSA.Crv, X: testdata.ECDSA.X, Y: testdata.ECDSA.Y}),
+			}}, err: io.EOF},
+			wantErr: ErrKeyTypeMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := append([]*pb.Jwk{}, tt.stream.keys...)
+
+			keySet, err := ReceiveKeySet(tt.stream)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ReceiveKeySet() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReceiveKeySet() error = %v", err)
+			}
+
+			key, ok := keySet.LookupKeyID(testdata.ECDSA.Id)
+			if !ok {
+				t.Fatalf("ReceiveKeySet() is missing key %s", testdata.ECDSA.Id)
+			}
+
+			if chain := key.X509CertChain(); len(chain) != 1 || !chain[0].Equal(testdata.CA.Cert) {
+				t.Errorf("ReceiveKeySet() x5c = %v, want the CA certificate", chain)
+			}
+
+			got, err := KeySetToJwks(keySet)
+			if err != nil {
+				t.Fatalf("KeySetToJwks() error = %v", err)
+			}
+
+			if diff := cmp.Diff(want, got, protocmp.Transform(), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("KeySetToJwks() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKeyToJwk(t *testing.T) {
+	t.Run("Test if converts private keys to public keys", func(t *testing.T) {
+		key, err := jwk.New(testdata.Ed25519.PrivKey)
+		if err != nil {
+			t.Fatalf("jwk.New() error = %v", err)
+		}
+
+		if err := key.Set(jwk.KeyIDKey, testdata.Ed25519.Id); err != nil {
+			t.Fatalf("Failed to set kid: %v", err)
+		}
+
+		got, err := KeyToJwk(key)
+		if err != nil {
+			t.Fatalf("KeyToJwk() error = %v", err)
+		}
+
+		want := &pb.Jwk{
+			Kid: testdata.Ed25519.Id,
+			Kty: "OKP",
+			Key: mustAny(t, &okppb.OKP{Crv: okppb.OKPType_Ed25519, X: testdata.Ed25519.X}),
+		}
+
+		if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+			t.Errorf("KeyToJwk() mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Test if rejects symmetric keys", func(t *testing.T) {
+		key, err := jwk.New([]byte("secret"))
+		if err != nil {
+			t.Fatalf("jwk.New() error = %v", err)
+		}
+
+		if _, err := KeyToJwk(key); !errors.Is(err, ErrUnsupportedKeyType) {
+			t.Errorf("KeyToJwk() error = %v, want %v", err, ErrUnsupportedKeyType)
+		}
+	})
+}
@@ -3,16 +3,16 @@ package protokey
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
 	"encoding/base64"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/big"
-	"strconv"
 
 	ecpb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1/ec"
+	okppb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1/okp"
 	rsapb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1/rsa"
 	"google.golang.org/protobuf/proto"
 )
@@ -20,6 +20,9 @@ import (
 var (
 	ErrUnknownMessageType = errors.New("unknown message type")
 	ErrKeyNil             = errors.New("key is nil")
+	ErrUnknownCurve       = errors.New("unknown elliptic curve")
+	ErrInvalidExponent    = errors.New("invalid RSA public exponent")
+	ErrInvalidModulus     = errors.New("invalid RSA modulus")
 )
 
 // DeserializeKey detects a gRPC format of key and deserializes it using the corresponding function.
@@ -29,46 +32,45 @@ func DeserializeKey(input proto.Message) (interface{}, error) {
 		return DeserializeRSA(msg)
 	case *ecpb.EC:
 		return DeserializeEC(msg)
+	case *okppb.OKP:
+		return DeserializeOKP(msg)
 	default:
 		return nil, ErrUnknownMessageType
 	}
 }
 
 // DeserializeRSA deserializes an RSA message.
+// Both N and E are unsigned big-endian integers as described in RFC 7518, Section 6.3.1.
+// Leading zero octets are tolerated since older versions of SerializeRSA
+// wrote E as four octets, e.g. "AAEAAQ" instead of "AQAB".
+// Returns ErrInvalidExponent if E is not an odd number between 3 and 2^31-1.
 func DeserializeRSA(input *rsapb.RSA) (*rsa.PublicKey, error) {
 	if input == nil {
 		return nil, ErrKeyNil
 	}
 
-	n, err := base64.RawURLEncoding.DecodeString(input.GetN())
+	N, err := decodeUint(input.GetN())
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to decode RSA modulus: %w", err)
 	}
 
-	N := new(big.Int).SetBytes(n)
-
-	// The short path.
-	// The default exponent is usually 65537, so just compare the base64 for [1,0,1] or [0,1,0,1].
-	if input.GetE() == "AQAB" || input.GetE() == "AAEAAQ" {
-		return &rsa.PublicKey{
-			E: 65537,
-			N: N,
-		}, nil
+	if N.Sign() == 0 {
+		return nil, ErrInvalidModulus
 	}
 
-	eBytes, err := base64.RawURLEncoding.DecodeString(input.GetE())
+	E, err := decodeUint(input.GetE())
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to decode RSA exponent: %w", err)
 	}
 
-	E, err := strconv.Atoi(string(eBytes))
-	if err != nil {
-		return nil, err
+	// Same bounds as enforced by crypto/rsa when verifying signatures.
+	if !E.IsInt64() || E.Int64() < 3 || E.Int64() > 1<<31-1 || E.Bit(0) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidExponent, E)
 	}
 
 	return &rsa.PublicKey{
 		N: N,
-		E: E,
+		E: int(E.Int64()),
 	}, nil
 }
 
@@ -112,7 +114,35 @@ func DeserializeEC(input *ecpb.EC) (*ecdsa.PublicKey, error) {
 	}, nil
 }
 
+// DeserializeOKP deserializes an OKP message.
+func DeserializeOKP(input *okppb.OKP) (ed25519.PublicKey, error) {
+	if input == nil {
+		return nil, ErrKeyNil
+	}
+
+	switch input.GetCrv() {
+	case okppb.OKPType_Ed25519:
+	case okppb.OKPType_OKP_UNDEFINED:
+		return nil, errors.New("failed to parse OKP key: curve undefined")
+	default:
+		return nil, errors.New("failed to parse OKP key: unexpected curve")
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(input.GetX())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(x) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("failed to parse OKP key: invalid Ed25519 public key size %d", len(x))
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
 // SerializeRSA encodes given RSA PublicKey into a supported gRPC message format.
+// N and E are encoded with the minimum number of octets as required by RFC 7518,
+// e.g. the exponent 65537 is encoded as "AQAB".
 // Returns an error if given key is not of type rsa.PublicKey or a pointer to it.
 func SerializeRSA(key crypto.PublicKey) (proto.Message, error) {
 	var pubKey *rsa.PublicKey
@@ -126,14 +156,17 @@ func SerializeRSA(key crypto.PublicKey) (proto.Message, error) {
 		return nil, fmt.Errorf("received invalid key type, expected *rsa.PublicKey, received %T", key)
 	}
 
-	e := make([]byte, 4)
-	binary.BigEndian.PutUint32(e, uint32(pubKey.E))
+	if pubKey.N == nil || pubKey.N.Sign() <= 0 {
+		return nil, ErrInvalidModulus
+	}
 
-	n := pubKey.N.Bytes()
+	if pubKey.E <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidExponent, pubKey.E)
+	}
 
 	message := &rsapb.RSA{
-		N: base64.RawURLEncoding.EncodeToString(n),
-		E: base64.RawURLEncoding.EncodeToString(e),
+		N: encodeUint(pubKey.N),
+		E: encodeUint(big.NewInt(int64(pubKey.E))),
 	}
 
 	return message, nil
@@ -141,6 +174,7 @@ func SerializeRSA(key crypto.PublicKey) (proto.Message, error) {
 
 // SerializeECDSA encodes given EC PublicKey into a supported gRPC message format.
 // Returns an error if given key is not of type ecdsa.PublicKey or a pointer to it.
+// Returns ErrUnknownCurve if the key's curve is not one of P-256, P-384 or P-521.
 func SerializeECDSA(key crypto.PublicKey) (proto.Message, error) {
 	if key == nil {
 		return nil, errors.New("received nil key")
@@ -156,9 +190,6 @@ func SerializeECDSA(key crypto.PublicKey) (proto.Message, error) {
 		return nil, fmt.Errorf("received invalid key type, expected *ecdsa.PublicKey, received %T", key)
 	}
 
-	x := pubKey.X.Bytes()
-	y := pubKey.Y.Bytes()
-
 	var crv ecpb.ECType
 	switch pubKey.Curve {
 	case elliptic.P256():
@@ -167,8 +198,15 @@ func SerializeECDSA(key crypto.PublicKey) (proto.Message, error) {
 		crv = ecpb.ECType_P384
 	case elliptic.P521():
 		crv = ecpb.ECType_P521
+	default:
+		return nil, ErrUnknownCurve
 	}
 
+	// Coordinates are padded to the full length of the curve's field as required by RFC 7518.
+	size := (pubKey.Curve.Params().BitSize + 7) / 8
+	x := pubKey.X.FillBytes(make([]byte, size))
+	y := pubKey.Y.FillBytes(make([]byte, size))
+
 	message := &ecpb.EC{
 		Crv: crv,
 		X:   base64.RawURLEncoding.EncodeToString(x),
@@ -177,3 +215,52 @@ func SerializeECDSA(key crypto.PublicKey) (proto.Message, error) {
 
 	return message, nil
 }
+
+// SerializeEd25519 encodes given Ed25519 PublicKey into a supported gRPC message format.
+// Returns an error if given key is not of type ed25519.PublicKey or a pointer to it.
+func SerializeEd25519(key crypto.PublicKey) (proto.Message, error) {
+	var pubKey ed25519.PublicKey
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		pubKey = k
+	case *ed25519.PublicKey:
+		if k == nil {
+			return nil, errors.New("received nil key")
+		}
+		pubKey = *k
+	default:
+		return nil, fmt.Errorf("received invalid key type, expected ed25519.PublicKey, received %T", key)
+	}
+
+	if len(pubKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("received invalid Ed25519 public key size %d", len(pubKey))
+	}
+
+	message := &okppb.OKP{
+		Crv: okppb.OKPType_Ed25519,
+		X:   base64.RawURLEncoding.EncodeToString(pubKey),
+	}
+
+	return message, nil
+}
+
+// encodeUint returns given non-negative integer as a base64url encoded
+// big-endian octet sequence without leading zero octets.
+func encodeUint(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// decodeUint parses a base64url encoded unsigned big-endian integer.
+func decodeUint(encoded string) (*big.Int, error) {
+	if encoded == "" {
+		return nil, errors.New("value is empty")
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(decoded), nil
+}
@@ -3,16 +3,22 @@ package protokey
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/base64"
 	"math/big"
+	mathrand "math/rand"
+	"reflect"
 	"testing"
+	"testing/quick"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
 	ecpb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1/ec"
+	okppb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1/okp"
 	rsapb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1/rsa"
 	"google.golang.org/protobuf/proto"
 )
@@ -42,6 +48,48 @@ func TestDeserializeRSA(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Test if accepts an exponent with leading zero octets written by older versions",
+			args: args{
+				input: &rsapb.RSA{E: "AAEAAQ", N: "sZGVa39d"},
+			},
+			want: &rsa.PublicKey{E: 65537, N: new(big.Int).SetBytes([]byte{0xB1, 0x91, 0x95, 0x6B, 0x7F, 0x5D})},
+		},
+		{
+			name: "Test if decodes exponents other than 65537",
+			args: args{
+				input: &rsapb.RSA{E: "Aw", N: "sZGVa39d"},
+			},
+			want: &rsa.PublicKey{E: 3, N: new(big.Int).SetBytes([]byte{0xB1, 0x91, 0x95, 0x6B, 0x7F, 0x5D})},
+		},
+		{
+			name: "Test if rejects an even exponent",
+			args: args{
+				input: &rsapb.RSA{E: "BA", N: "sZGVa39d"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if rejects an exponent larger than 2^31-1",
+			args: args{
+				input: &rsapb.RSA{E: "AQAAAAE", N: "sZGVa39d"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if rejects an empty exponent",
+			args: args{
+				input: &rsapb.RSA{N: "sZGVa39d"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if rejects a zero modulus",
+			args: args{
+				input: &rsapb.RSA{E: "AQAB", N: "AA"},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -50,6 +98,9 @@ func TestDeserializeRSA(t *testing.T) {
 				t.Errorf("RSA(): error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
 
 			if !cmp.Equal(got, tt.want) {
 				t.Errorf("RSA():\n got = %v\n want = %v", got, tt.want)
@@ -101,6 +152,61 @@ func TestDeserializeECDSA(t *testing.T) {
 	}
 }
 
+func TestDeserializeOKP(t *testing.T) {
+	type args struct {
+		input *okppb.OKP
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    ed25519.PublicKey
+		wantErr bool
+	}{
+		{
+			name: "Test a valid public key is correctly deserialized",
+			args: args{
+				input: &okppb.OKP{
+					Crv: testdata.Ed25519.Crv,
+					X:   testdata.Ed25519.X,
+				},
+			},
+			want:    testdata.Ed25519.PubKey,
+			wantErr: false,
+		},
+		{
+			name: "Test if returns an error on undefined curve",
+			args: args{
+				input: &okppb.OKP{
+					X: testdata.Ed25519.X,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if returns an error on invalid key size",
+			args: args{
+				input: &okppb.OKP{
+					Crv: okppb.OKPType_Ed25519,
+					X:   "AQAB",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DeserializeOKP(tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("OKP(): error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("OKP():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestSerializeRSA(t *testing.T) {
 	type args struct {
 		key crypto.PrivateKey
@@ -121,6 +227,23 @@ func TestSerializeRSA(t *testing.T) {
 				E: testdata.RSA.E,
 			},
 		},
+		{
+			name: "Test if exponent is encoded without leading zero octets",
+			args: args{
+				key: &rsa.PublicKey{E: 3, N: big.NewInt(0xB1)},
+			},
+			want: &rsapb.RSA{
+				N: "sQ",
+				E: "Aw",
+			},
+		},
+		{
+			name: "Test if returns an error on a key without modulus",
+			args: args{
+				key: &rsa.PublicKey{E: 65537},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -158,6 +281,19 @@ func TestSerializeECDSA(t *testing.T) {
 				Y:   testdata.ECDSA.Y,
 			},
 		},
+		{
+			name: "Test if returns an error on unknown curve",
+			args: args{
+				key: func() crypto.PublicKey {
+					key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+					if err != nil {
+						t.Fatalf("Failed to generate a P-224 key: %v", err)
+					}
+					return key.Public()
+				}(),
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -173,6 +309,112 @@ func TestSerializeECDSA(t *testing.T) {
 	}
 }
 
+func TestSerializeEd25519(t *testing.T) {
+	type args struct {
+		key crypto.PublicKey
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    proto.Message
+		wantErr bool
+	}{
+		{
+			name: "Test if valid Ed25519 public key is marshaled into correct public key",
+			args: args{
+				key: testdata.Ed25519.PubKey,
+			},
+			want: &okppb.OKP{
+				Crv: testdata.Ed25519.Crv,
+				X:   testdata.Ed25519.X,
+			},
+		},
+		{
+			name: "Test if returns an error on private key",
+			args: args{
+				key: testdata.Ed25519.PrivKey,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SerializeEd25519(tt.args.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SerializeEd25519() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !cmp.Equal(got, tt.want, cmpopts.IgnoreUnexported(okppb.OKP{})) {
+				t.Errorf("SerializeEd25519():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRSAEncoding_properties checks that RSA public keys survive a round trip
+// and are encoded minimally across the whole range of valid exponents and modulus sizes.
+func TestRSAEncoding_properties(t *testing.T) {
+	config := &quick.Config{
+		MaxCount: 500,
+		Values: func(values []reflect.Value, r *mathrand.Rand) {
+			// Odd exponents between 3 and 2^31-1.
+			e := 3 + 2*r.Intn(1<<30-1)
+
+			// Moduli from 1 to 4096 bits long.
+			n := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), uint(1+r.Intn(4096))))
+			n.SetBit(n, 0, 1)
+
+			values[0] = reflect.ValueOf(&rsa.PublicKey{E: e, N: n})
+		},
+	}
+
+	t.Run("Test if keys are deserialized into the serialized key", func(t *testing.T) {
+		roundTrip := func(key *rsa.PublicKey) bool {
+			serialized, err := SerializeRSA(key)
+			if err != nil {
+				t.Logf("SerializeRSA() error = %v", err)
+				return false
+			}
+
+			got, err := DeserializeRSA(serialized.(*rsapb.RSA))
+			if err != nil {
+				t.Logf("DeserializeRSA() error = %v", err)
+				return false
+			}
+
+			return got.Equal(key)
+		}
+
+		if err := quick.Check(roundTrip, config); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Test if values are encoded with the minimum number of octets", func(t *testing.T) {
+		minimal := func(key *rsa.PublicKey) bool {
+			serialized, err := SerializeRSA(key)
+			if err != nil {
+				t.Logf("SerializeRSA() error = %v", err)
+				return false
+			}
+
+			message := serialized.(*rsapb.RSA)
+			for _, encoded := range []string{message.GetN(), message.GetE()} {
+				decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+				if err != nil || len(decoded) == 0 || decoded[0] == 0 {
+					return false
+				}
+			}
+
+			return true
+		}
+
+		if err := quick.Check(minimal, config); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
 func TestKeySerializationFlowCompatibilityForRSA(t *testing.T) {
 	original, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -205,32 +447,68 @@ func TestKeySerializationFlowCompatibilityForRSA(t *testing.T) {
 }
 
 func TestKeySerializationFlowCompatibilityForECDSA(t *testing.T) {
-	original, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	curves := []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()}
+
+	for _, curve := range curves {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			original, err := ecdsa.GenerateKey(curve, rand.Reader)
+			if err != nil {
+				t.Errorf("Failed to generate a ECDSA key: error = %v", err)
+				return
+			}
+
+			serialized, err := SerializeECDSA(original.PublicKey)
+			if err != nil {
+				t.Errorf("Failed to encode ECDSA key: error = %v", err)
+				return
+			}
+
+			pubKey, err := DeserializeKey(serialized)
+			if err != nil {
+				t.Errorf("Failed to serialize ECDSA key: error = %v", err)
+				return
+			}
+
+			want := original.PublicKey
+			got, ok := pubKey.(*ecdsa.PublicKey)
+			if !ok {
+				t.Errorf("Received key is of invalid type: got = %T, want = %T", pubKey, got)
+				return
+			}
+
+			if !want.Equal(got) {
+				t.Errorf("Public Keys are not equal: %v", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestKeySerializationFlowCompatibilityForEd25519(t *testing.T) {
+	original, _, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
-		t.Errorf("Failed to generate a ECDSA key: error = %v", err)
+		t.Errorf("Failed to generate an Ed25519 key: error = %v", err)
 		return
 	}
 
-	serialized, err := SerializeECDSA(original.PublicKey)
+	serialized, err := SerializeEd25519(original)
 	if err != nil {
-		t.Errorf("Failed to encode ECDSA key: error = %v", err)
+		t.Errorf("Failed to encode Ed25519 key: error = %v", err)
 		return
 	}
 
 	pubKey, err := DeserializeKey(serialized)
 	if err != nil {
-		t.Errorf("Failed to serialize ECDSA key: error = %v", err)
+		t.Errorf("Failed to serialize Ed25519 key: error = %v", err)
 		return
 	}
 
-	want := original.PublicKey
-	got, ok := pubKey.(*ecdsa.PublicKey)
+	got, ok := pubKey.(ed25519.PublicKey)
 	if !ok {
 		t.Errorf("Received key is of invalid type: got = %T, want = %T", pubKey, got)
 		return
 	}
 
-	if !want.Equal(got) {
-		t.Errorf("Public Keys are not equal: %v", cmp.Diff(want, got))
+	if !original.Equal(got) {
+		t.Errorf("Public Keys are not equal: %v", cmp.Diff(original, got))
 	}
 }
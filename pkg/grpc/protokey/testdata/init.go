@@ -3,4 +3,6 @@ package testdata
 func init() {
 	initRSA()
 	initECDSA()
+	initEd25519()
+	initCA()
 }
@@ -0,0 +1,39 @@
+package protokey
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// ErrThumbprintMismatch is returned when a key's id is not the thumbprint of the key.
+var ErrThumbprintMismatch = errors.New("key id does not match its thumbprint")
+
+// Thumbprint returns the base64url encoded SHA-256 JWK thumbprint of given public key
+// as defined in RFC 7638. It's used as the key's id.
+func Thumbprint(publicKey crypto.PublicKey) (string, error) {
+	key, err := jwk.New(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	return jwkutil.Thumbprint(key)
+}
+
+// VerifyThumbprint returns an error wrapping ErrThumbprintMismatch
+// if kid is not the thumbprint of given public key.
+func VerifyThumbprint(kid string, publicKey crypto.PublicKey) error {
+	thumbprint, err := Thumbprint(publicKey)
+	if err != nil {
+		return err
+	}
+
+	if kid != thumbprint {
+		return fmt.Errorf("%w: kid %q, thumbprint %q", ErrThumbprintMismatch, kid, thumbprint)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package protokey
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+)
+
+// rfc7638Key is the example key from RFC 7638, Section 3.1.
+func rfc7638Key(t *testing.T) *rsa.PublicKey {
+	t.Helper()
+
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	if err != nil {
+		t.Fatalf("Failed to decode modulus: %v", err)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+}
+
+func TestThumbprint(t *testing.T) {
+	tests := []struct {
+		name string
+		key  func(t *testing.T) crypto.PublicKey
+		want string
+	}{
+		{
+			name: "Test if matches the RSA example from RFC 7638",
+			key:  func(t *testing.T) crypto.PublicKey { return rfc7638Key(t) },
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			name: "Test if matches the Ed25519 example from RFC 8037",
+			key:  func(t *testing.T) crypto.PublicKey { return testdata.Ed25519.PubKey },
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+		{
+			name: "Test if ECDSA keys are supported",
+			key:  func(t *testing.T) crypto.PublicKey { return testdata.ECDSA.PubKey },
+			want: testdata.ECDSA.Id,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Thumbprint(tt.key(t))
+			if err != nil {
+				t.Fatalf("Thumbprint() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Thumbprint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyThumbprint(t *testing.T) {
+	tests := []struct {
+		name    string
+		kid     string
+		key     crypto.PublicKey
+		wantErr error
+	}{
+		{
+			name: "Test if accepts a matching kid",
+			kid:  testdata.RSA.Id,
+			key:  testdata.RSA.PubKey,
+		},
+		{
+			name:    "Test if rejects a kid of another key",
+			kid:     testdata.ECDSA.Id,
+			key:     testdata.RSA.PubKey,
+			wantErr: ErrThumbprintMismatch,
+		},
+		{
+			name:    "Test if rejects a random kid",
+			kid:     "kid",
+			key:     testdata.Ed25519.PubKey,
+			wantErr: ErrThumbprintMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyThumbprint(tt.kid, tt.key); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyThumbprint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"slices"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/events"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-lib/cert"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (server AuthServer) Impersonate(ctx context.Context, req *pb.ImpersonateRequest) (_ *pb.ImpersonateResponse, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.Impersonate")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	actor, err := server.authorizeImpersonation(ctx, req.GetRefreshToken())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := server.services.User.Get(ctx, &userPb.GetUserRequest{Id: req.GetUserId()}); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	encodedOpaqueAccessToken, accessTokenId, err := server.tokenManager.GenerateOpaque(tokens.AccessToken)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	now := server.config.Now()
+	accessToken := entity.Token{
+		Id:        accessTokenId,
+		UserId:    req.GetUserId(),
+		Type:      entity.AccessToken,
+		ExpiresAt: now.Add(server.config.ImpersonationTokenValidityTime),
+		IssuedAt:  now,
+		Actor:     actor,
+	}
+
+	if err := server.storage.Create(ctx, accessToken); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	body := events.Impersonation{
+		Actor:     actor,
+		UserId:    accessToken.UserId,
+		Reason:    req.GetReason(),
+		ExpiresAt: accessToken.ExpiresAt,
+	}
+
+	e, err := event.MakeEvent(event.AuthAggregate, events.UserImpersonated, body, tracing.ExtractMetadataFromContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := server.broker.ResilientPublish(e); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.ImpersonateResponse{
+		AccessToken: encodedOpaqueAccessToken,
+		ExpiresAt:   timestamppb.New(accessToken.ExpiresAt),
+	}, nil
+}
+
+// authorizeImpersonation returns the identity of the admin making the request.
+// If a refresh token is provided its owner has to be listed in config.AdminUserIds.
+// Otherwise the caller has to present an mTLS client certificate valid for
+// one of config.AdminClientNames. Returned errors are already converted to gRPC statuses.
+func (server AuthServer) authorizeImpersonation(ctx context.Context, encodedOpaqueRefreshToken string) (string, error) {
+	if encodedOpaqueRefreshToken != "" {
+		refreshToken, err := server.getRefreshToken(ctx, encodedOpaqueRefreshToken)
+		if err != nil {
+			return "", err
+		}
+
+		if !slices.Contains(server.config.AdminUserIds, refreshToken.UserId) {
+			return "", status.Error(codes.PermissionDenied, "user is not allowed to impersonate")
+		}
+
+		return refreshToken.UserId, nil
+	}
+
+	if name, ok := verifiedClientName(ctx, server.config.AdminClientNames); ok {
+		return name, nil
+	}
+
+	return "", status.Error(codes.PermissionDenied, "client is not allowed to impersonate")
+}
+
+// verifiedClientName returns the first of given names the caller's
+// mTLS client certificate is valid for.
+func verifiedClientName(ctx context.Context, names []string) (string, bool) {
+	for _, name := range names {
+		if err := cert.VerifyClientTLS(ctx, name); err == nil {
+			return name, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,147 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/events"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/server/servertest"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/storagemocks"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/tokensmocks"
+	"github.com/krixlion/dev_forum-lib/event"
+	libmocks "github.com/krixlion/dev_forum-lib/mocks"
+	usermocks "github.com/krixlion/dev_forum-user/pkg/grpc/mocks"
+	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAuthServer_Impersonate(t *testing.T) {
+	now := time.Unix(0, 0)
+	adminRefreshToken := entity.Token{
+		Id:     "admin-refresh-seed",
+		UserId: "admin-id",
+		Type:   entity.RefreshToken,
+	}
+
+	type args struct {
+		req *pb.ImpersonateRequest
+	}
+	tests := []struct {
+		name    string
+		deps    servertest.Deps
+		args    args
+		want    *pb.ImpersonateResponse
+		wantErr bool
+	}{
+		{
+			name: "Test if access token naming the admin is issued and audited on valid flow",
+			deps: servertest.Deps{
+				Now:          func() time.Time { return now },
+				AdminUserIds: []string{"admin-id"},
+				UserClient: func() usermocks.UserClient {
+					m := usermocks.NewUserClient()
+					m.On("Get", mock.Anything, mock.MatchedBy(func(r *userPb.GetUserRequest) bool {
+						return r.GetId() == "test-user"
+					}), mock.Anything).Return(&userPb.GetUserResponse{User: &userPb.User{Id: "test-user"}}, nil).Once()
+					return m
+				}(),
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.RefreshToken, "admin-refresh").Return(adminRefreshToken.Id, nil).Once()
+					m.On("GenerateOpaque", tokens.AccessToken).Return("test-access", "test-access-seed", nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, adminRefreshToken.Id).Return(adminRefreshToken, nil).Once()
+					m.On("Create", mock.Anything, entity.Token{
+						Id:        "test-access-seed",
+						UserId:    "test-user",
+						Type:      entity.AccessToken,
+						ExpiresAt: now.Add(time.Minute),
+						IssuedAt:  now,
+						Actor:     "admin-id",
+					}).Return(nil).Once()
+					return m
+				}(),
+				Broker: func() event.Broker {
+					m := libmocks.NewBroker()
+					m.On("ResilientPublish", mock.MatchedBy(func(e event.Event) bool {
+						var body events.Impersonation
+						if err := json.Unmarshal(e.Body, &body); err != nil {
+							return false
+						}
+						return e.Type == events.UserImpersonated && body.Actor == "admin-id" &&
+							body.UserId == "test-user" && body.Reason == "bug report"
+					})).Return(nil).Once()
+					return m
+				}(),
+			},
+			args: args{
+				req: &pb.ImpersonateRequest{RefreshToken: "admin-refresh", UserId: "test-user", Reason: "bug report"},
+			},
+			want: &pb.ImpersonateResponse{
+				AccessToken: "test-access",
+				ExpiresAt:   timestamppb.New(now.Add(time.Minute)),
+			},
+		},
+		{
+			name: "Test if fails when the caller is not an admin",
+			deps: servertest.Deps{
+				AdminUserIds: []string{"other-admin-id"},
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.RefreshToken, "admin-refresh").Return(adminRefreshToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, adminRefreshToken.Id).Return(adminRefreshToken, nil).Once()
+					return m
+				}(),
+			},
+			args: args{
+				req: &pb.ImpersonateRequest{RefreshToken: "admin-refresh", UserId: "test-user", Reason: "bug report"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails without a refresh token nor a client certificate",
+			deps: servertest.Deps{
+				AdminUserIds: []string{"admin-id"},
+			},
+			args: args{
+				req: &pb.ImpersonateRequest{UserId: "test-user", Reason: "bug report"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			got, err := client.Impersonate(ctx, tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.Impersonate() error = %v, wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			opts := cmpopts.IgnoreUnexported(pb.ImpersonateResponse{}, timestamppb.Timestamp{})
+			if !cmp.Equal(got, tt.want, opts) {
+				t.Errorf("AuthServer.Impersonate():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/events"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// KeyAdminServer allows operators to manage signing keys.
+// Every call requires an mTLS client certificate valid for one of config.AdminClientNames.
+type KeyAdminServer struct {
+	pb.UnimplementedKeyAdminServiceServer
+	keys   storage.KeyAdmin
+	broker event.Broker
+	logger logging.Logger
+	tracer trace.Tracer
+	config KeyAdminConfig
+}
+
+type KeyAdminDependencies struct {
+	Keys   storage.KeyAdmin
+	Broker event.Broker
+	Logger logging.Logger
+	Tracer trace.Tracer
+}
+
+type KeyAdminConfig struct {
+	AdminClientNames []string
+}
+
+func MakeKeyAdminServer(dependencies KeyAdminDependencies, config KeyAdminConfig) KeyAdminServer {
+	return KeyAdminServer{
+		keys:   dependencies.Keys,
+		broker: dependencies.Broker,
+		logger: dependencies.Logger,
+		tracer: dependencies.Tracer,
+		config: config,
+	}
+}
+
+func (server KeyAdminServer) ListKeys(ctx context.Context, _ *empty.Empty) (_ *pb.ListKeysResponse, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.ListKeys")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if _, err := server.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	keys, err := server.keys.ListKeys(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.ListKeysResponse{
+		Keys: make([]*pb.KeyInfo, 0, len(keys)),
+	}
+
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, &pb.KeyInfo{
+			Kid:       key.Id,
+			Algorithm: string(key.Algorithm),
+			KeyType:   string(key.Type),
+			Active:    key.Active,
+			CreatedAt: timestampOrNil(key.CreatedAt),
+			Imported:  key.Imported,
+			NotBefore: timestampOrNil(key.NotBefore),
+		})
+	}
+
+	return resp, nil
+}
+
+func (server KeyAdminServer) RotateNow(ctx context.Context, _ *empty.Empty) (_ *empty.Empty, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.RotateNow")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	actor, err := server.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.keys.RotateNow(ctx); err != nil {
+		if errors.Is(err, storage.ErrNotLeader) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := server.audit(ctx, events.KeysRotated, events.KeyManagement{Actor: actor}); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (server KeyAdminServer) RevokeKey(ctx context.Context, req *pb.RevokeKeyRequest) (_ *empty.Empty, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.RevokeKey")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	actor, err := server.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetKid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "kid is required")
+	}
+
+	if err := server.keys.RevokeKey(ctx, req.GetKid()); err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		if errors.Is(err, storage.ErrNotLeader) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := server.audit(ctx, events.KeyRevoked, events.KeyManagement{Actor: actor, Kid: req.GetKid()}); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (server KeyAdminServer) ImportKey(ctx context.Context, req *pb.ImportKeyRequest) (_ *pb.ImportKeyResponse, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.ImportKey")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	actor, err := server.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetAlgorithm() == "" || req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "algorithm and key are required")
+	}
+
+	key := entity.ImportedKey{
+		Id:         req.GetKid(),
+		Algorithm:  entity.Algorithm(req.GetAlgorithm()),
+		EncodedKey: req.GetKey(),
+	}
+
+	if req.GetNotBefore() != nil {
+		key.NotBefore = req.GetNotBefore().AsTime()
+	}
+
+	kid, err := server.keys.ImportKey(ctx, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrKeyExists):
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		case errors.Is(err, storage.ErrInvalidKey):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	if err := server.audit(ctx, events.KeyImported, events.KeyManagement{Actor: actor, Kid: kid}); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.ImportKeyResponse{Kid: kid}, nil
+}
+
+func (server KeyAdminServer) GetRotationStatus(ctx context.Context, _ *empty.Empty) (_ *pb.GetRotationStatusResponse, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.GetRotationStatus")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if _, err := server.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	rotation, err := server.keys.GetRotationStatus(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.GetRotationStatusResponse{
+		LastRotatedAt:  timestampOrNil(rotation.LastRotatedAt),
+		NextRotationAt: timestampOrNil(rotation.NextRotationAt),
+		Leader:         rotation.Leader,
+		LeaseExpiresAt: timestampOrNil(rotation.LeaseExpiresAt),
+	}, nil
+}
+
+// authorize returns the name the caller's client certificate is valid for.
+// Returned errors are already converted to gRPC statuses.
+func (server KeyAdminServer) authorize(ctx context.Context) (string, error) {
+	if name, ok := verifiedClientName(ctx, server.config.AdminClientNames); ok {
+		return name, nil
+	}
+
+	return "", status.Error(codes.PermissionDenied, "client is not allowed to manage keys")
+}
+
+// audit publishes an audit event of given type.
+func (server KeyAdminServer) audit(ctx context.Context, eType event.EventType, body events.KeyManagement) error {
+	e, err := event.MakeEvent(event.AuthAggregate, eType, body, tracing.ExtractMetadataFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	return server.broker.ResilientPublish(e)
+}
+
+func timestampOrNil(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
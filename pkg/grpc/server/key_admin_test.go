@@ -0,0 +1,399 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/events"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/server"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/storagemocks"
+	"github.com/krixlion/dev_forum-lib/event"
+	libmocks "github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func makeKeyAdminServer(keys storage.KeyAdmin, broker event.Broker) server.KeyAdminServer {
+	deps := server.KeyAdminDependencies{
+		Keys:   keys,
+		Broker: broker,
+		Logger: nulls.NullLogger{},
+		Tracer: nulls.NullTracer{},
+	}
+	return server.MakeKeyAdminServer(deps, server.KeyAdminConfig{AdminClientNames: []string{"key-admin"}})
+}
+
+// withClientCert returns a context carrying a verified mTLS client certificate valid for given DNS name.
+func withClientCert(t *testing.T, ctx context.Context, dnsName string) context.Context {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	return peer.NewContext(ctx, &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		},
+	})
+}
+
+func auditBroker(eType event.EventType, want events.KeyManagement) libmocks.Broker {
+	m := libmocks.NewBroker()
+	m.On("ResilientPublish", mock.MatchedBy(func(e event.Event) bool {
+		var body events.KeyManagement
+		if err := json.Unmarshal(e.Body, &body); err != nil {
+			return false
+		}
+		return e.Type == eType && body == want
+	})).Return(nil).Once()
+	return m
+}
+
+func TestKeyAdminServer_ListKeys(t *testing.T) {
+	createdAt := time.Unix(1700000000, 0).UTC()
+
+	tests := []struct {
+		name     string
+		keys     storagemocks.KeyAdmin
+		dnsName  string
+		want     *pb.ListKeysResponse
+		wantCode codes.Code
+	}{
+		{
+			name: "Test if keys are listed on valid flow",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("ListKeys", mock.Anything).Return([]entity.KeyInfo{
+					{Id: "a", Type: entity.ECDSA, Algorithm: entity.ES256, Active: true, CreatedAt: createdAt},
+					{Id: "b", Type: entity.OKP, Algorithm: entity.EdDSA},
+				}, nil).Once()
+				return m
+			}(),
+			dnsName: "key-admin",
+			want: &pb.ListKeysResponse{Keys: []*pb.KeyInfo{
+				{Kid: "a", Algorithm: "ES256", KeyType: "ECDSA", Active: true, CreatedAt: timestamppb.New(createdAt)},
+				{Kid: "b", Algorithm: "EdDSA", KeyType: "OKP"},
+			}},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "Test if fails when the client certificate is not trusted",
+			keys:     storagemocks.NewKeyAdmin(),
+			dnsName:  "gateway",
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name: "Test if internal error is forwarded",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("ListKeys", mock.Anything).Return([]entity.KeyInfo(nil), errors.New("test err")).Once()
+				return m
+			}(),
+			dnsName:  "key-admin",
+			wantCode: codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withClientCert(t, context.Background(), tt.dnsName)
+
+			got, err := makeKeyAdminServer(tt.keys, libmocks.NewBroker()).ListKeys(ctx, &emptypb.Empty{})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("KeyAdminServer.ListKeys() code = %v, want %v, err = %v", code, tt.wantCode, err)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, protocmp.Transform()) {
+				t.Errorf("KeyAdminServer.ListKeys():\n got = %v\n want = %v", got, tt.want)
+			}
+
+			tt.keys.AssertExpectations(t)
+		})
+	}
+}
+
+func TestKeyAdminServer_RotateNow(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     storagemocks.KeyAdmin
+		broker   libmocks.Broker
+		dnsName  string
+		wantCode codes.Code
+	}{
+		{
+			name: "Test if keys are rotated and audited on valid flow",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("RotateNow", mock.Anything).Return(nil).Once()
+				return m
+			}(),
+			broker:   auditBroker(events.KeysRotated, events.KeyManagement{Actor: "key-admin"}),
+			dnsName:  "key-admin",
+			wantCode: codes.OK,
+		},
+		{
+			name: "Test if fails when another instance holds the rotation lease",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("RotateNow", mock.Anything).Return(storage.ErrNotLeader).Once()
+				return m
+			}(),
+			broker:   libmocks.NewBroker(),
+			dnsName:  "key-admin",
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Test if fails when the client certificate is not trusted",
+			keys:     storagemocks.NewKeyAdmin(),
+			broker:   libmocks.NewBroker(),
+			dnsName:  "gateway",
+			wantCode: codes.PermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withClientCert(t, context.Background(), tt.dnsName)
+
+			_, err := makeKeyAdminServer(tt.keys, tt.broker).RotateNow(ctx, &emptypb.Empty{})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("KeyAdminServer.RotateNow() code = %v, want %v, err = %v", code, tt.wantCode, err)
+				return
+			}
+
+			tt.keys.AssertExpectations(t)
+			tt.broker.AssertExpectations(t)
+		})
+	}
+}
+
+func TestKeyAdminServer_RevokeKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     storagemocks.KeyAdmin
+		broker   libmocks.Broker
+		req      *pb.RevokeKeyRequest
+		wantCode codes.Code
+	}{
+		{
+			name: "Test if key is revoked and audited on valid flow",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("RevokeKey", mock.Anything, "leaked").Return(nil).Once()
+				return m
+			}(),
+			broker:   auditBroker(events.KeyRevoked, events.KeyManagement{Actor: "key-admin", Kid: "leaked"}),
+			req:      &pb.RevokeKeyRequest{Kid: "leaked"},
+			wantCode: codes.OK,
+		},
+		{
+			name: "Test if returns NotFound on unknown kid",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("RevokeKey", mock.Anything, "unknown").Return(storage.ErrKeyNotFound).Once()
+				return m
+			}(),
+			broker:   libmocks.NewBroker(),
+			req:      &pb.RevokeKeyRequest{Kid: "unknown"},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "Test if fails when another instance holds the rotation lease",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("RevokeKey", mock.Anything, "leaked").Return(storage.ErrNotLeader).Once()
+				return m
+			}(),
+			broker:   libmocks.NewBroker(),
+			req:      &pb.RevokeKeyRequest{Kid: "leaked"},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Test if fails on empty kid",
+			keys:     storagemocks.NewKeyAdmin(),
+			broker:   libmocks.NewBroker(),
+			req:      &pb.RevokeKeyRequest{},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withClientCert(t, context.Background(), "key-admin")
+
+			_, err := makeKeyAdminServer(tt.keys, tt.broker).RevokeKey(ctx, tt.req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("KeyAdminServer.RevokeKey() code = %v, want %v, err = %v", code, tt.wantCode, err)
+				return
+			}
+
+			tt.keys.AssertExpectations(t)
+			tt.broker.AssertExpectations(t)
+		})
+	}
+}
+
+func TestKeyAdminServer_ImportKey(t *testing.T) {
+	notBefore := time.Unix(1700000000, 0).UTC()
+
+	tests := []struct {
+		name     string
+		keys     storagemocks.KeyAdmin
+		broker   libmocks.Broker
+		req      *pb.ImportKeyRequest
+		want     *pb.ImportKeyResponse
+		wantCode codes.Code
+	}{
+		{
+			name: "Test if key is imported and audited on valid flow",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("ImportKey", mock.Anything, entity.ImportedKey{
+					Algorithm:  entity.ES256,
+					EncodedKey: "key",
+					NotBefore:  notBefore,
+				}).Return("thumbprint", nil).Once()
+				return m
+			}(),
+			broker:   auditBroker(events.KeyImported, events.KeyManagement{Actor: "key-admin", Kid: "thumbprint"}),
+			req:      &pb.ImportKeyRequest{Algorithm: "ES256", Key: "key", NotBefore: timestamppb.New(notBefore)},
+			want:     &pb.ImportKeyResponse{Kid: "thumbprint"},
+			wantCode: codes.OK,
+		},
+		{
+			name: "Test if returns AlreadyExists on a stored key",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("ImportKey", mock.Anything, mock.Anything).Return("", storage.ErrKeyExists).Once()
+				return m
+			}(),
+			broker:   libmocks.NewBroker(),
+			req:      &pb.ImportKeyRequest{Kid: "hsm", Algorithm: "ES256", Key: "key"},
+			wantCode: codes.AlreadyExists,
+		},
+		{
+			name: "Test if returns InvalidArgument on invalid key",
+			keys: func() storagemocks.KeyAdmin {
+				m := storagemocks.NewKeyAdmin()
+				m.On("ImportKey", mock.Anything, mock.Anything).Return("", fmt.Errorf("%w: test err", storage.ErrInvalidKey)).Once()
+				return m
+			}(),
+			broker:   libmocks.NewBroker(),
+			req:      &pb.ImportKeyRequest{Kid: "hsm", Algorithm: "ES256", Key: "key"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Test if fails on missing key",
+			keys:     storagemocks.NewKeyAdmin(),
+			broker:   libmocks.NewBroker(),
+			req:      &pb.ImportKeyRequest{Algorithm: "ES256"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withClientCert(t, context.Background(), "key-admin")
+
+			got, err := makeKeyAdminServer(tt.keys, tt.broker).ImportKey(ctx, tt.req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("KeyAdminServer.ImportKey() code = %v, want %v, err = %v", code, tt.wantCode, err)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, protocmp.Transform()) {
+				t.Errorf("KeyAdminServer.ImportKey():\n got = %v\n want = %v", got, tt.want)
+			}
+
+			tt.keys.AssertExpectations(t)
+			tt.broker.AssertExpectations(t)
+		})
+	}
+}
+
+func TestKeyAdminServer_GetRotationStatus(t *testing.T) {
+	rotatedAt := time.Unix(1700000000, 0).UTC()
+
+	tests := []struct {
+		name     string
+		status   entity.RotationStatus
+		want     *pb.GetRotationStatusResponse
+		wantCode codes.Code
+	}{
+		{
+			name: "Test if status is returned on valid flow",
+			status: entity.RotationStatus{
+				LastRotatedAt:  rotatedAt,
+				NextRotationAt: rotatedAt.Add(time.Hour),
+				Leader:         "auth-0",
+				LeaseExpiresAt: rotatedAt.Add(time.Minute),
+			},
+			want: &pb.GetRotationStatusResponse{
+				LastRotatedAt:  timestamppb.New(rotatedAt),
+				NextRotationAt: timestamppb.New(rotatedAt.Add(time.Hour)),
+				Leader:         "auth-0",
+				LeaseExpiresAt: timestamppb.New(rotatedAt.Add(time.Minute)),
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "Test if zero times are left unset",
+			status:   entity.RotationStatus{},
+			want:     &pb.GetRotationStatusResponse{},
+			wantCode: codes.OK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withClientCert(t, context.Background(), "key-admin")
+
+			keys := storagemocks.NewKeyAdmin()
+			keys.On("GetRotationStatus", mock.Anything).Return(tt.status, nil).Once()
+
+			got, err := makeKeyAdminServer(keys, libmocks.NewBroker()).GetRotationStatus(ctx, &emptypb.Empty{})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("KeyAdminServer.GetRotationStatus() code = %v, want %v, err = %v", code, tt.wantCode, err)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, protocmp.Transform()) {
+				t.Errorf("KeyAdminServer.GetRotationStatus():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
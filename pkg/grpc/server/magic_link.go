@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/events"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-lib/str"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (server AuthServer) RequestMagicLink(ctx context.Context, req *pb.RequestMagicLinkRequest) (_ *pb.RequestMagicLinkResponse, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.RequestMagicLink")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	nonce, err := str.RandomAlphaString(32)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Requests are limited regardless of whether the user exists
+	// so that the limit does not reveal registered emails.
+	if err := server.checkMagicLinkRateLimit(ctx, req.GetEmail()); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.services.User.GetSecret(ctx, &userPb.GetUserSecretRequest{
+		Query: &userPb.GetUserSecretRequest_Email{
+			Email: req.GetEmail(),
+		},
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			// Do not reveal whether a user with given email exists.
+			return &pb.RequestMagicLinkResponse{Nonce: nonce}, nil
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	user := resp.GetUser()
+
+	token := entity.Token{
+		UserId:    user.GetId(),
+		Type:      entity.MagicLinkToken,
+		NonceHash: hashHex(nonce),
+	}
+
+	encodedOpaqueToken, token, err := server.issueSingleUseToken(ctx, tokens.MagicLinkToken, token, server.config.MagicLinkTokenValidityTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.publishTokenIssued(ctx, events.MagicLinkRequested, user.GetEmail(), encodedOpaqueToken, token); err != nil {
+		return nil, err
+	}
+
+	return &pb.RequestMagicLinkResponse{
+		Nonce: nonce,
+	}, nil
+}
+
+func (server AuthServer) RedeemMagicLink(ctx context.Context, req *pb.RedeemMagicLinkRequest) (_ *pb.RedeemMagicLinkResponse, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.RedeemMagicLink")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	token, err := server.getSingleUseToken(ctx, tokens.MagicLinkToken, entity.MagicLinkToken, req.GetMagicLinkToken())
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the nonce before deleting the token so that the link
+	// can't be invalidated by redeeming it from a different device.
+	if subtle.ConstantTimeCompare([]byte(token.NonceHash), []byte(hashHex(req.GetNonce()))) != 1 {
+		return nil, status.Error(codes.PermissionDenied, "invalid nonce")
+	}
+
+	if err := server.deleteSingleUseToken(ctx, token); err != nil {
+		return nil, err
+	}
+
+	encodedOpaqueRefreshToken, err := server.issueRefreshToken(ctx, token.UserId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.RedeemMagicLinkResponse{
+		RefreshToken: encodedOpaqueRefreshToken,
+	}, nil
+}
+
+// checkMagicLinkRateLimit returns a ResourceExhausted status if too many magic links
+// were requested for given email within the configured window. Otherwise the request
+// is recorded. Records are kept after magic links are redeemed so that redeeming
+// a link does not reset the limit, and deleted once they fall out of the window.
+func (server AuthServer) checkMagicLinkRateLimit(ctx context.Context, email string) error {
+	now := server.config.Now()
+	emailHash := hashHex(normaliseEmail(email))
+
+	requests, err := server.storage.GetMultiple(ctx, filter.Filter{
+		{
+			Attribute: "email_hash",
+			Operator:  filter.Equal,
+			Value:     emailHash,
+		},
+		{
+			Attribute: "type",
+			Operator:  filter.Equal,
+			Value:     string(entity.MagicLinkRequest),
+		},
+	})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	windowStart := now.Add(-server.config.MagicLinkRateLimitWindow)
+
+	count := 0
+	for _, request := range requests {
+		if request.IssuedAt.After(windowStart) {
+			count++
+			continue
+		}
+
+		// Concurrent checks may delete the same record.
+		if err := server.storage.Delete(ctx, request.Id); err != nil && !errors.Is(err, storage.ErrTokenNotFound) {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	if count >= server.config.MagicLinkRateLimit {
+		return status.Error(codes.ResourceExhausted, "too many magic links requested, try again later")
+	}
+
+	id, err := str.RandomAlphaString(32)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	request := entity.Token{
+		Id:        id,
+		Type:      entity.MagicLinkRequest,
+		EmailHash: emailHash,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(server.config.MagicLinkRateLimitWindow),
+	}
+
+	if err := server.storage.Create(ctx, request); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func normaliseEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func hashHex(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:])
+}
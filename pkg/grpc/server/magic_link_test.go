@@ -0,0 +1,282 @@
+package server_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/events"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/server/servertest"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/storagemocks"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/tokensmocks"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/filter"
+	libmocks "github.com/krixlion/dev_forum-lib/mocks"
+	usermocks "github.com/krixlion/dev_forum-user/pkg/grpc/mocks"
+	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthServer_RequestMagicLink(t *testing.T) {
+	now := time.Unix(7200, 0)
+	secretByEmail := &userPb.GetUserSecretRequest{Query: &userPb.GetUserSecretRequest_Email{Email: "test@example.com"}}
+	emailHash := sha256.Sum256([]byte("test@example.com"))
+	requestsFilter := filter.Filter{
+		{Attribute: "email_hash", Operator: filter.Equal, Value: hex.EncodeToString(emailHash[:])},
+		{Attribute: "type", Operator: filter.Equal, Value: string(entity.MagicLinkRequest)},
+	}
+	isRequest := mock.MatchedBy(func(tk entity.Token) bool {
+		return tk.Id != "" && tk.UserId == "" && tk.Type == entity.MagicLinkRequest && tk.EmailHash == hex.EncodeToString(emailHash[:]) &&
+			tk.IssuedAt.Equal(now) && tk.ExpiresAt.Equal(now.Add(time.Hour))
+	})
+	userClient := func() usermocks.UserClient {
+		m := usermocks.NewUserClient()
+		resp := &userPb.GetUserSecretResponse{User: &userPb.User{Id: "test-user", Email: "test@example.com"}}
+		m.On("GetSecret", mock.Anything, secretByEmail, mock.Anything).Return(resp, nil).Once()
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		deps     servertest.Deps
+		wantCode codes.Code
+	}{
+		{
+			name: "Test if token bound to a nonce is issued and published on valid flow",
+			deps: servertest.Deps{
+				Now:        func() time.Time { return now },
+				UserClient: userClient(),
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("GenerateOpaque", tokens.MagicLinkToken).Return("test-magic-link", "test-magic-link-seed", nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("GetMultiple", mock.Anything, requestsFilter).Return([]entity.Token{
+						// Issued before the rate limit window.
+						{Id: "test-request-1", IssuedAt: now.Add(-time.Hour * 2)},
+						{Id: "test-request-2", IssuedAt: now.Add(-time.Hour * 2)},
+						{Id: "test-request-3", IssuedAt: now.Add(-time.Hour)},
+					}, nil).Once()
+					m.On("Delete", mock.Anything, "test-request-1").Return(nil).Once()
+					m.On("Delete", mock.Anything, "test-request-2").Return(storage.ErrTokenNotFound).Once()
+					m.On("Delete", mock.Anything, "test-request-3").Return(nil).Once()
+					m.On("Create", mock.Anything, isRequest).Return(nil).Once()
+					m.On("Create", mock.Anything, mock.MatchedBy(func(tk entity.Token) bool {
+						return tk.Id == "test-magic-link-seed" && tk.UserId == "test-user" && tk.Type == entity.MagicLinkToken &&
+							tk.ExpiresAt.Equal(now.Add(time.Minute)) && len(tk.NonceHash) == sha256.Size*2
+					})).Return(nil).Once()
+					return m
+				}(),
+				Broker: func() event.Broker {
+					m := libmocks.NewBroker()
+					m.On("ResilientPublish", tokenIssuedEvent(events.MagicLinkRequested, events.TokenIssued{
+						UserId:    "test-user",
+						Email:     "test@example.com",
+						Token:     "test-magic-link",
+						ExpiresAt: now.Add(time.Minute),
+					})).Return(nil).Once()
+					return m
+				}(),
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name: "Test if fails when rate limit is exceeded",
+			deps: servertest.Deps{
+				Now:        func() time.Time { return now },
+				UserClient: userClient(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("GetMultiple", mock.Anything, requestsFilter).Return([]entity.Token{
+						{IssuedAt: now.Add(-time.Minute * 3)},
+						{IssuedAt: now.Add(-time.Minute * 2)},
+						{IssuedAt: now.Add(-time.Minute)},
+					}, nil).Once()
+					return m
+				}(),
+			},
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name: "Test if fails when rate limit is exceeded for an unknown email",
+			deps: servertest.Deps{
+				Now: func() time.Time { return now },
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("GetMultiple", mock.Anything, requestsFilter).Return([]entity.Token{
+						{IssuedAt: now.Add(-time.Minute * 3)},
+						{IssuedAt: now.Add(-time.Minute * 2)},
+						{IssuedAt: now.Add(-time.Minute)},
+					}, nil).Once()
+					return m
+				}(),
+			},
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name: "Test if responds with a nonce when user does not exist",
+			deps: servertest.Deps{
+				Now: func() time.Time { return now },
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("GetMultiple", mock.Anything, requestsFilter).Return([]entity.Token{}, nil).Once()
+					m.On("Create", mock.Anything, isRequest).Return(nil).Once()
+					return m
+				}(),
+				UserClient: func() usermocks.UserClient {
+					m := usermocks.NewUserClient()
+					m.On("GetSecret", mock.Anything, secretByEmail, mock.Anything).Return(&userPb.GetUserSecretResponse{}, status.Error(codes.NotFound, "not found")).Once()
+					return m
+				}(),
+			},
+			wantCode: codes.OK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			got, err := client.RequestMagicLink(ctx, &pb.RequestMagicLinkRequest{Email: "test@example.com"})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("AuthServer.RequestMagicLink() error = %v, wantCode = %v", err, tt.wantCode)
+				return
+			}
+
+			if tt.wantCode == codes.OK && got.GetNonce() == "" {
+				t.Errorf("AuthServer.RequestMagicLink() returned an empty nonce")
+			}
+
+			tt.deps.Storage.(storagemocks.Storage).AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthServer_RedeemMagicLink(t *testing.T) {
+	nonceHash := sha256.Sum256([]byte("test-nonce"))
+	magicLink := entity.Token{
+		Id:        "test-magic-link-seed",
+		UserId:    "test-user",
+		Type:      entity.MagicLinkToken,
+		ExpiresAt: time.Unix(60, 0),
+		IssuedAt:  time.Unix(0, 0),
+		NonceHash: hex.EncodeToString(nonceHash[:]),
+	}
+
+	type args struct {
+		req *pb.RedeemMagicLinkRequest
+	}
+	tests := []struct {
+		name    string
+		deps    servertest.Deps
+		args    args
+		want    *pb.RedeemMagicLinkResponse
+		wantErr bool
+	}{
+		{
+			name: "Test if refresh token is issued on valid flow",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(30, 0) },
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.MagicLinkToken, "test-magic-link").Return(magicLink.Id, nil).Once()
+					m.On("GenerateOpaque", tokens.RefreshToken).Return("test-refresh", "test-refresh-seed", nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, magicLink.Id).Return(magicLink, nil).Once()
+					m.On("Delete", mock.Anything, magicLink.Id).Return(nil).Once()
+					m.On("Create", mock.Anything, entity.Token{
+						Id:        "test-refresh-seed",
+						UserId:    "test-user",
+						Type:      entity.RefreshToken,
+						ExpiresAt: time.Unix(30, 0).Add(time.Minute),
+						IssuedAt:  time.Unix(30, 0),
+					}).Return(nil).Once()
+					return m
+				}(),
+			},
+			args: args{
+				req: &pb.RedeemMagicLinkRequest{MagicLinkToken: "test-magic-link", Nonce: "test-nonce"},
+			},
+			want: &pb.RedeemMagicLinkResponse{
+				RefreshToken: "test-refresh",
+			},
+		},
+		{
+			name: "Test if fails when redeemed with a different nonce",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(30, 0) },
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.MagicLinkToken, "test-magic-link").Return(magicLink.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					// Token is not deleted so that it can still be redeemed from the requesting device.
+					m.On("Get", mock.Anything, magicLink.Id).Return(magicLink, nil).Once()
+					return m
+				}(),
+			},
+			args: args{
+				req: &pb.RedeemMagicLinkRequest{MagicLinkToken: "test-magic-link", Nonce: "other-nonce"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails when token was already redeemed",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(30, 0) },
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.MagicLinkToken, "test-magic-link").Return(magicLink.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, magicLink.Id).Return(magicLink, nil).Once()
+					m.On("Delete", mock.Anything, magicLink.Id).Return(storage.ErrTokenNotFound).Once()
+					return m
+				}(),
+			},
+			args: args{
+				req: &pb.RedeemMagicLinkRequest{MagicLinkToken: "test-magic-link", Nonce: "test-nonce"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			got, err := client.RedeemMagicLink(ctx, tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.RedeemMagicLink() error = %v, wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, cmpopts.IgnoreUnexported(pb.RedeemMagicLinkResponse{})) {
+				t.Errorf("AuthServer.RedeemMagicLink():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
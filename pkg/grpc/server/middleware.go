@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"net/mail"
+	"strings"
 
 	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
 	"github.com/krixlion/dev_forum-lib/tracing"
@@ -22,6 +23,26 @@ func (server AuthServer) ValidateRequestInterceptor() grpc.UnaryServerIntercepto
 			return server.validateGetAccessToken(ctx, req.(*pb.GetAccessTokenRequest), handler)
 		case "/auth.AuthService/TranslateAccessToken":
 			return server.validateTranslateAccessToken(ctx, req.(*pb.TranslateAccessTokenRequest), handler)
+		case "/auth.AuthService/CreatePersonalAccessToken":
+			return server.validateCreatePersonalAccessToken(ctx, req.(*pb.CreatePersonalAccessTokenRequest), handler)
+		case "/auth.AuthService/ListPersonalAccessTokens":
+			return server.validateListPersonalAccessTokens(ctx, req.(*pb.ListPersonalAccessTokensRequest), handler)
+		case "/auth.AuthService/RevokePersonalAccessToken":
+			return server.validateRevokePersonalAccessToken(ctx, req.(*pb.RevokePersonalAccessTokenRequest), handler)
+		case "/auth.AuthService/RequestPasswordReset":
+			return server.validateRequestPasswordReset(ctx, req.(*pb.RequestPasswordResetRequest), handler)
+		case "/auth.AuthService/ResetPassword":
+			return server.validateResetPassword(ctx, req.(*pb.ResetPasswordRequest), handler)
+		case "/auth.AuthService/RequestEmailVerification":
+			return server.validateRequestEmailVerification(ctx, req.(*pb.RequestEmailVerificationRequest), handler)
+		case "/auth.AuthService/VerifyEmail":
+			return server.validateVerifyEmail(ctx, req.(*pb.VerifyEmailRequest), handler)
+		case "/auth.AuthService/RequestMagicLink":
+			return server.validateRequestMagicLink(ctx, req.(*pb.RequestMagicLinkRequest), handler)
+		case "/auth.AuthService/RedeemMagicLink":
+			return server.validateRedeemMagicLink(ctx, req.(*pb.RedeemMagicLinkRequest), handler)
+		case "/auth.AuthService/Impersonate":
+			return server.validateImpersonate(ctx, req.(*pb.ImpersonateRequest), handler)
 		default:
 			return handler(ctx, req)
 		}
@@ -79,3 +100,158 @@ func (server AuthServer) validateTranslateAccessToken(ctx context.Context, req *
 
 	return handler(ctx, req)
 }
+
+func (server AuthServer) validateCreatePersonalAccessToken(ctx context.Context, req *pb.CreatePersonalAccessTokenRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateCreatePersonalAccessToken")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid refresh token")
+	}
+
+	if req.GetName() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid name")
+	}
+
+	if len(req.GetScopes()) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "at least one scope is required")
+	}
+
+	for _, scope := range req.GetScopes() {
+		if scope == "" || strings.ContainsAny(scope, " \t\n") {
+			return nil, status.Error(codes.FailedPrecondition, "invalid scope")
+		}
+	}
+
+	if req.GetExpiresAt() != nil && !req.GetExpiresAt().AsTime().After(server.config.Now()) {
+		return nil, status.Error(codes.FailedPrecondition, "expiration time has to be in the future")
+	}
+
+	return handler(ctx, req)
+}
+
+func (server AuthServer) validateListPersonalAccessTokens(ctx context.Context, req *pb.ListPersonalAccessTokensRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateListPersonalAccessTokens")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid refresh token")
+	}
+
+	return handler(ctx, req)
+}
+
+func (server AuthServer) validateRevokePersonalAccessToken(ctx context.Context, req *pb.RevokePersonalAccessTokenRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateRevokePersonalAccessToken")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid refresh token")
+	}
+
+	if req.GetName() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid name")
+	}
+
+	return handler(ctx, req)
+}
+
+func (server AuthServer) validateRequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateRequestPasswordReset")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if _, err := mail.ParseAddress(req.GetEmail()); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return handler(ctx, req)
+}
+
+func (server AuthServer) validateResetPassword(ctx context.Context, req *pb.ResetPasswordRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateResetPassword")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if req.GetPasswordResetToken() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid password reset token")
+	}
+
+	// Same requirement as enforced by the user-service.
+	if len(req.GetNewPassword()) < 8 {
+		return nil, status.Error(codes.FailedPrecondition, "provided password is too short")
+	}
+
+	return handler(ctx, req)
+}
+
+func (server AuthServer) validateRequestEmailVerification(ctx context.Context, req *pb.RequestEmailVerificationRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateRequestEmailVerification")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid refresh token")
+	}
+
+	return handler(ctx, req)
+}
+
+func (server AuthServer) validateVerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateVerifyEmail")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if req.GetEmailVerificationToken() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid email verification token")
+	}
+
+	return handler(ctx, req)
+}
+
+func (server AuthServer) validateRequestMagicLink(ctx context.Context, req *pb.RequestMagicLinkRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateRequestMagicLink")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if _, err := mail.ParseAddress(req.GetEmail()); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return handler(ctx, req)
+}
+
+func (server AuthServer) validateRedeemMagicLink(ctx context.Context, req *pb.RedeemMagicLinkRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateRedeemMagicLink")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if req.GetMagicLinkToken() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid magic link token")
+	}
+
+	if req.GetNonce() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid nonce")
+	}
+
+	return handler(ctx, req)
+}
+
+func (server AuthServer) validateImpersonate(ctx context.Context, req *pb.ImpersonateRequest, handler grpc.UnaryHandler) (_ interface{}, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.validateImpersonate")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "invalid user id")
+	}
+
+	if req.GetReason() == "" {
+		return nil, status.Error(codes.FailedPrecondition, "reason is required")
+	}
+
+	return handler(ctx, req)
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/krixlion/dev_forum-lib/mocks"
 	"github.com/krixlion/dev_forum-lib/nulls"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func setUpStubServer() AuthServer {
 	return AuthServer{
 		tracer: nulls.NullTracer{},
 		logger: nulls.NullLogger{},
+		config: Config{Now: time.Now},
 	}
 }
 
@@ -204,3 +206,195 @@ func TestAuthServer_validateTranslateAccessToken(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthServer_validateCreatePersonalAccessToken(t *testing.T) {
+	type args struct {
+		req     *pb.CreatePersonalAccessTokenRequest
+		handler grpc.UnaryHandler
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "Test if fails on empty refresh token",
+			args: args{
+				req: &pb.CreatePersonalAccessTokenRequest{
+					Name:   "test",
+					Scopes: []string{"test"},
+				},
+				handler: mocks.NewUnaryHandler().GetMock(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on empty name",
+			args: args{
+				req: &pb.CreatePersonalAccessTokenRequest{
+					RefreshToken: "test",
+					Scopes:       []string{"test"},
+				},
+				handler: mocks.NewUnaryHandler().GetMock(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on missing scopes",
+			args: args{
+				req: &pb.CreatePersonalAccessTokenRequest{
+					RefreshToken: "test",
+					Name:         "test",
+				},
+				handler: mocks.NewUnaryHandler().GetMock(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on scope containing whitespace",
+			args: args{
+				req: &pb.CreatePersonalAccessTokenRequest{
+					RefreshToken: "test",
+					Name:         "test",
+					Scopes:       []string{"articles:read articles:write"},
+				},
+				handler: mocks.NewUnaryHandler().GetMock(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on expiration time in the past",
+			args: args{
+				req: &pb.CreatePersonalAccessTokenRequest{
+					RefreshToken: "test",
+					Name:         "test",
+					Scopes:       []string{"test"},
+					ExpiresAt:    timestamppb.New(time.Unix(0, 0)),
+				},
+				handler: mocks.NewUnaryHandler().GetMock(),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			server := setUpStubServer()
+
+			got, err := server.validateCreatePersonalAccessToken(ctx, tt.args.req, tt.args.handler)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.validateCreatePersonalAccessToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !cmp.Equal(got, tt.want) && tt.wantErr {
+				t.Errorf("AuthServer.validateCreatePersonalAccessToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthServer_validateRevokePersonalAccessToken(t *testing.T) {
+	type args struct {
+		req     *pb.RevokePersonalAccessTokenRequest
+		handler grpc.UnaryHandler
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "Test if fails on empty refresh token",
+			args: args{
+				req: &pb.RevokePersonalAccessTokenRequest{
+					Name: "test",
+				},
+				handler: mocks.NewUnaryHandler().GetMock(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on empty name",
+			args: args{
+				req: &pb.RevokePersonalAccessTokenRequest{
+					RefreshToken: "test",
+				},
+				handler: mocks.NewUnaryHandler().GetMock(),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			server := setUpStubServer()
+
+			got, err := server.validateRevokePersonalAccessToken(ctx, tt.args.req, tt.args.handler)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.validateRevokePersonalAccessToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !cmp.Equal(got, tt.want) && tt.wantErr {
+				t.Errorf("AuthServer.validateRevokePersonalAccessToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthServer_validateResetPassword(t *testing.T) {
+	type args struct {
+		req     *pb.ResetPasswordRequest
+		handler grpc.UnaryHandler
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "Test if fails on empty password reset token",
+			args: args{
+				req: &pb.ResetPasswordRequest{
+					NewPassword: "zaq1@WSX",
+				},
+				handler: mocks.NewUnaryHandler().GetMock(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on too short password",
+			args: args{
+				req: &pb.ResetPasswordRequest{
+					PasswordResetToken: "test",
+					NewPassword:        "1234567",
+				},
+				handler: mocks.NewUnaryHandler().GetMock(),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			server := setUpStubServer()
+
+			got, err := server.validateResetPassword(ctx, tt.args.req, tt.args.handler)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.validateResetPassword() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !cmp.Equal(got, tt.want) && tt.wantErr {
+				t.Errorf("AuthServer.validateResetPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-lib/str"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// lastUsedAtResolution is how often the usage of a personal access token is recorded.
+// Translations within this time after the recorded usage don't write to the storage.
+const lastUsedAtResolution = time.Minute
+
+func (server AuthServer) CreatePersonalAccessToken(ctx context.Context, req *pb.CreatePersonalAccessTokenRequest) (_ *pb.CreatePersonalAccessTokenResponse, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.CreatePersonalAccessToken")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	refreshToken, err := server.getRefreshToken(ctx, req.GetRefreshToken())
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := server.storage.GetMultiple(ctx, personalAccessTokensFilter(refreshToken.UserId, req.GetName()))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if len(existing) > 0 {
+		return nil, status.Error(codes.AlreadyExists, "personal access token with given name already exists")
+	}
+
+	encodedOpaqueToken, tokenId, err := server.tokenManager.GenerateOpaque(tokens.PersonalAccessToken)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	token := entity.Token{
+		Id:       tokenId,
+		UserId:   refreshToken.UserId,
+		Type:     entity.PersonalAccessToken,
+		IssuedAt: server.config.Now(),
+		Name:     req.GetName(),
+		Scopes:   req.GetScopes(),
+	}
+
+	if req.GetExpiresAt() != nil {
+		token.ExpiresAt = req.GetExpiresAt().AsTime()
+	}
+
+	if err := server.storage.Create(ctx, token); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.CreatePersonalAccessTokenResponse{
+		PersonalAccessToken: encodedOpaqueToken,
+	}, nil
+}
+
+func (server AuthServer) ListPersonalAccessTokens(ctx context.Context, req *pb.ListPersonalAccessTokensRequest) (_ *pb.ListPersonalAccessTokensResponse, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.ListPersonalAccessTokens")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	refreshToken, err := server.getRefreshToken(ctx, req.GetRefreshToken())
+	if err != nil {
+		return nil, err
+	}
+
+	personalAccessTokens, err := server.storage.GetMultiple(ctx, personalAccessTokensFilter(refreshToken.UserId, ""))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.ListPersonalAccessTokensResponse{
+		PersonalAccessTokens: make([]*pb.PersonalAccessToken, 0, len(personalAccessTokens)),
+	}
+
+	for _, token := range personalAccessTokens {
+		resp.PersonalAccessTokens = append(resp.PersonalAccessTokens, personalAccessTokenToPb(token))
+	}
+
+	return resp, nil
+}
+
+func (server AuthServer) RevokePersonalAccessToken(ctx context.Context, req *pb.RevokePersonalAccessTokenRequest) (_ *empty.Empty, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.RevokePersonalAccessToken")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	refreshToken, err := server.getRefreshToken(ctx, req.GetRefreshToken())
+	if err != nil {
+		return nil, err
+	}
+
+	personalAccessTokens, err := server.storage.GetMultiple(ctx, personalAccessTokensFilter(refreshToken.UserId, req.GetName()))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if len(personalAccessTokens) == 0 {
+		return nil, status.Error(codes.NotFound, "personal access token not found")
+	}
+
+	for _, token := range personalAccessTokens {
+		if err := server.storage.Delete(ctx, token.Id); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return &empty.Empty{}, nil
+}
+
+// usePersonalAccessToken decodes given personal access token, verifies it's not
+// expired and records its usage. It returns an access token to be encoded as a JWT
+// restricted to personal access token's scopes.
+func (server AuthServer) usePersonalAccessToken(ctx context.Context, encodedOpaqueToken string) (_ entity.Token, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.usePersonalAccessToken")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	tokenId, err := server.tokenManager.DecodeOpaque(tokens.PersonalAccessToken, encodedOpaqueToken)
+	if err != nil {
+		return entity.Token{}, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	token, err := server.storage.Get(ctx, tokenId)
+	if err != nil {
+		return entity.Token{}, status.Error(codes.Internal, err.Error())
+	}
+
+	if token.Type != entity.PersonalAccessToken {
+		return entity.Token{}, status.Error(codes.FailedPrecondition, tokens.ErrInvalidTokenType.Error())
+	}
+
+	now := server.config.Now()
+	if !token.ExpiresAt.IsZero() && !now.Before(token.ExpiresAt) {
+		return entity.Token{}, status.Error(codes.FailedPrecondition, "personal access token expired")
+	}
+
+	if now.Sub(token.LastUsedAt) >= lastUsedAtResolution {
+		if err := server.storage.SetLastUsed(ctx, token.Id, now); err != nil {
+			return entity.Token{}, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	expiresAt := now.Add(server.config.AccessTokenValidityTime)
+	if !token.ExpiresAt.IsZero() && token.ExpiresAt.Before(expiresAt) {
+		expiresAt = token.ExpiresAt
+	}
+
+	// The id is published as the JWT's "jti" claim. The personal access token
+	// can be rebuilt from its id, so the access token has to get a new one.
+	accessTokenId, err := str.RandomAlphaString(16)
+	if err != nil {
+		return entity.Token{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return entity.Token{
+		Id:        accessTokenId,
+		UserId:    token.UserId,
+		Type:      entity.AccessToken,
+		ExpiresAt: expiresAt,
+		IssuedAt:  now,
+		Scopes:    token.Scopes,
+	}, nil
+}
+
+// getRefreshToken decodes given opaque refresh token and returns its stored
+// counterpart. Returned errors are already converted to gRPC statuses.
+func (server AuthServer) getRefreshToken(ctx context.Context, encodedOpaqueRefreshToken string) (entity.Token, error) {
+	refreshTokenId, err := server.tokenManager.DecodeOpaque(tokens.RefreshToken, encodedOpaqueRefreshToken)
+	if err != nil {
+		return entity.Token{}, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	refreshToken, err := server.storage.Get(ctx, refreshTokenId)
+	if err != nil {
+		return entity.Token{}, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if refreshToken.Type != entity.RefreshToken {
+		return entity.Token{}, status.Error(codes.PermissionDenied, tokens.ErrInvalidTokenType.Error())
+	}
+
+	return refreshToken, nil
+}
+
+// personalAccessTokensFilter returns a filter matching user's personal access tokens.
+// If name is empty then all user's personal access tokens are matched.
+func personalAccessTokensFilter(userId, name string) filter.Filter {
+	f := filter.Filter{
+		{
+			Attribute: "user_id",
+			Operator:  filter.Equal,
+			Value:     userId,
+		},
+		{
+			Attribute: "type",
+			Operator:  filter.Equal,
+			Value:     string(entity.PersonalAccessToken),
+		},
+	}
+
+	if name != "" {
+		f = append(f, filter.Parameter{
+			Attribute: "name",
+			Operator:  filter.Equal,
+			Value:     name,
+		})
+	}
+
+	return f
+}
+
+func personalAccessTokenToPb(token entity.Token) *pb.PersonalAccessToken {
+	v := &pb.PersonalAccessToken{
+		Name:     token.Name,
+		Scopes:   token.Scopes,
+		IssuedAt: timestamppb.New(token.IssuedAt),
+	}
+
+	if !token.ExpiresAt.IsZero() {
+		v.ExpiresAt = timestamppb.New(token.ExpiresAt)
+	}
+
+	if !token.LastUsedAt.IsZero() {
+		v.LastUsedAt = timestamppb.New(token.LastUsedAt)
+	}
+
+	return v
+}
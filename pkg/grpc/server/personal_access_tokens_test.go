@@ -0,0 +1,474 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/server/servertest"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/storagemocks"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/tokensmocks"
+	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var testRefreshToken = entity.Token{
+	Id:     "test-refresh-seed",
+	UserId: "test-user",
+	Type:   entity.RefreshToken,
+}
+
+func personalAccessTokensFilter(name string) filter.Filter {
+	f := filter.Filter{
+		{Attribute: "user_id", Operator: filter.Equal, Value: testRefreshToken.UserId},
+		{Attribute: "type", Operator: filter.Equal, Value: string(entity.PersonalAccessToken)},
+	}
+	if name != "" {
+		f = append(f, filter.Parameter{Attribute: "name", Operator: filter.Equal, Value: name})
+	}
+	return f
+}
+
+func TestAuthServer_CreatePersonalAccessToken(t *testing.T) {
+	type args struct {
+		req *pb.CreatePersonalAccessTokenRequest
+	}
+	tests := []struct {
+		name    string
+		deps    servertest.Deps
+		args    args
+		want    *pb.CreatePersonalAccessTokenResponse
+		wantErr bool
+	}{
+		{
+			name: "Test if token is created on valid flow",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(0, 0) },
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.RefreshToken, "test-refresh").Return(testRefreshToken.Id, nil).Once()
+					m.On("GenerateOpaque", tokens.PersonalAccessToken).Return("test-pat", "test-pat-seed", nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, testRefreshToken.Id).Return(testRefreshToken, nil).Once()
+					m.On("GetMultiple", mock.Anything, personalAccessTokensFilter("ci")).Return([]entity.Token{}, nil).Once()
+					m.On("Create", mock.Anything, entity.Token{
+						Id:        "test-pat-seed",
+						UserId:    testRefreshToken.UserId,
+						Type:      entity.PersonalAccessToken,
+						IssuedAt:  time.Unix(0, 0),
+						ExpiresAt: time.Unix(3600, 0).UTC(),
+						Name:      "ci",
+						Scopes:    []string{"articles:read"},
+					}).Return(nil).Once()
+					return m
+				}(),
+			},
+			args: args{
+				req: &pb.CreatePersonalAccessTokenRequest{
+					RefreshToken: "test-refresh",
+					Name:         "ci",
+					Scopes:       []string{"articles:read"},
+					ExpiresAt:    timestamppb.New(time.Unix(3600, 0)),
+				},
+			},
+			want: &pb.CreatePersonalAccessTokenResponse{
+				PersonalAccessToken: "test-pat",
+			},
+		},
+		{
+			name: "Test if fails when a token with the same name already exists",
+			deps: servertest.Deps{
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.RefreshToken, "test-refresh").Return(testRefreshToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, testRefreshToken.Id).Return(testRefreshToken, nil).Once()
+					m.On("GetMultiple", mock.Anything, personalAccessTokensFilter("ci")).Return([]entity.Token{{Name: "ci"}}, nil).Once()
+					return m
+				}(),
+			},
+			args: args{
+				req: &pb.CreatePersonalAccessTokenRequest{
+					RefreshToken: "test-refresh",
+					Name:         "ci",
+					Scopes:       []string{"articles:read"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			got, err := client.CreatePersonalAccessToken(ctx, tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.CreatePersonalAccessToken() error = %v, wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, cmpopts.IgnoreUnexported(pb.CreatePersonalAccessTokenResponse{})) {
+				t.Errorf("AuthServer.CreatePersonalAccessToken():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthServer_ListPersonalAccessTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    servertest.Deps
+		want    *pb.ListPersonalAccessTokensResponse
+		wantErr bool
+	}{
+		{
+			name: "Test if tokens are listed without exposing their ids",
+			deps: servertest.Deps{
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.RefreshToken, "test-refresh").Return(testRefreshToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, testRefreshToken.Id).Return(testRefreshToken, nil).Once()
+					m.On("GetMultiple", mock.Anything, personalAccessTokensFilter("")).Return([]entity.Token{
+						{
+							Id:         "secret-seed",
+							UserId:     testRefreshToken.UserId,
+							Type:       entity.PersonalAccessToken,
+							IssuedAt:   time.Unix(0, 0),
+							LastUsedAt: time.Unix(60, 0),
+							Name:       "ci",
+							Scopes:     []string{"articles:read"},
+						},
+					}, nil).Once()
+					return m
+				}(),
+			},
+			want: &pb.ListPersonalAccessTokensResponse{
+				PersonalAccessTokens: []*pb.PersonalAccessToken{
+					{
+						Name:       "ci",
+						Scopes:     []string{"articles:read"},
+						IssuedAt:   timestamppb.New(time.Unix(0, 0)),
+						LastUsedAt: timestamppb.New(time.Unix(60, 0)),
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			got, err := client.ListPersonalAccessTokens(ctx, &pb.ListPersonalAccessTokensRequest{RefreshToken: "test-refresh"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.ListPersonalAccessTokens() error = %v, wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			opts := cmpopts.IgnoreUnexported(pb.ListPersonalAccessTokensResponse{}, pb.PersonalAccessToken{}, timestamppb.Timestamp{})
+			if !cmp.Equal(got, tt.want, opts) {
+				t.Errorf("AuthServer.ListPersonalAccessTokens():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthServer_RevokePersonalAccessToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    servertest.Deps
+		wantErr bool
+	}{
+		{
+			name: "Test if token is deleted on valid flow",
+			deps: servertest.Deps{
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.RefreshToken, "test-refresh").Return(testRefreshToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, testRefreshToken.Id).Return(testRefreshToken, nil).Once()
+					m.On("GetMultiple", mock.Anything, personalAccessTokensFilter("ci")).Return([]entity.Token{{Id: "test-pat-seed"}}, nil).Once()
+					m.On("Delete", mock.Anything, "test-pat-seed").Return(nil).Once()
+					return m
+				}(),
+			},
+		},
+		{
+			name: "Test if fails when the token does not exist",
+			deps: servertest.Deps{
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.RefreshToken, "test-refresh").Return(testRefreshToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, testRefreshToken.Id).Return(testRefreshToken, nil).Once()
+					m.On("GetMultiple", mock.Anything, personalAccessTokensFilter("ci")).Return([]entity.Token{}, nil).Once()
+					return m
+				}(),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			_, err := client.RevokePersonalAccessToken(ctx, &pb.RevokePersonalAccessTokenRequest{RefreshToken: "test-refresh", Name: "ci"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.RevokePersonalAccessToken() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// translatedFrom returns a matcher of the access token translated from given
+// personal access token. The access token's id is published as the JWT's "jti"
+// claim, so it must not reveal the personal access token's id.
+func translatedFrom(pat entity.Token, now time.Time) func(entity.Token) bool {
+	return func(got entity.Token) bool {
+		want := entity.Token{
+			UserId:    pat.UserId,
+			Type:      entity.AccessToken,
+			IssuedAt:  now,
+			ExpiresAt: now.Add(time.Minute),
+			Scopes:    pat.Scopes,
+		}
+		return got.Id != "" && got.Id != pat.Id && cmp.Equal(got, want, cmpopts.IgnoreFields(entity.Token{}, "Id"))
+	}
+}
+
+func TestAuthServer_TranslatePersonalAccessToken(t *testing.T) {
+	now := time.Unix(1000, 0)
+	pat := entity.Token{
+		Id:       "test-pat-seed",
+		UserId:   "test-user",
+		Type:     entity.PersonalAccessToken,
+		IssuedAt: time.Unix(0, 0),
+		Name:     "ci",
+		Scopes:   []string{"articles:read"},
+	}
+
+	tests := []struct {
+		name    string
+		pat     entity.Token
+		deps    func(pat entity.Token) servertest.Deps
+		wantErr bool
+	}{
+		{
+			name: "Test if a scope-restricted access token is encoded and usage is recorded",
+			pat:  pat,
+			deps: func(pat entity.Token) servertest.Deps {
+				return servertest.Deps{
+					Now: func() time.Time { return now },
+					TokenManager: func() tokens.Manager {
+						m := tokensmocks.NewTokenManager()
+						m.On("DecodeOpaque", tokens.PersonalAccessToken, "dfp_test").Return(pat.Id, nil).Once()
+						m.On("Encode", mock.AnythingOfType("entity.Key"), mock.MatchedBy(translatedFrom(pat, now))).Return([]byte("test-jwt-encoded"), nil).Once()
+						return m
+					}(),
+					Storage: func() storage.Storage {
+						m := storagemocks.NewStorage()
+						m.On("Get", mock.Anything, pat.Id).Return(pat, nil).Once()
+						m.On("SetLastUsed", mock.Anything, pat.Id, now).Return(nil).Once()
+						return m
+					}(),
+					Vault: func() storage.Vault {
+						m := storagemocks.NewVault()
+						m.On("GetActive", mock.Anything, entity.ES256).Return(entity.Key{Id: "test"}, nil).Once()
+						return m
+					}(),
+				}
+			},
+		},
+		{
+			name: "Test if usage is not recorded again within a minute",
+			pat: func() entity.Token {
+				used := pat
+				used.LastUsedAt = now.Add(-time.Second * 30)
+				return used
+			}(),
+			deps: func(pat entity.Token) servertest.Deps {
+				return servertest.Deps{
+					Now: func() time.Time { return now },
+					TokenManager: func() tokens.Manager {
+						m := tokensmocks.NewTokenManager()
+						m.On("DecodeOpaque", tokens.PersonalAccessToken, "dfp_test").Return(pat.Id, nil).Once()
+						m.On("Encode", mock.AnythingOfType("entity.Key"), mock.MatchedBy(translatedFrom(pat, now))).Return([]byte("test-jwt-encoded"), nil).Once()
+						return m
+					}(),
+					Storage: func() storage.Storage {
+						m := storagemocks.NewStorage()
+						m.On("Get", mock.Anything, pat.Id).Return(pat, nil).Once()
+						return m
+					}(),
+					Vault: func() storage.Vault {
+						m := storagemocks.NewVault()
+						m.On("GetActive", mock.Anything, entity.ES256).Return(entity.Key{Id: "test"}, nil).Once()
+						return m
+					}(),
+				}
+			},
+		},
+		{
+			name: "Test if fails on expired token",
+			pat: func() entity.Token {
+				expired := pat
+				expired.ExpiresAt = now
+				return expired
+			}(),
+			deps: func(pat entity.Token) servertest.Deps {
+				return servertest.Deps{
+					Now: func() time.Time { return now },
+					TokenManager: func() tokens.Manager {
+						m := tokensmocks.NewTokenManager()
+						m.On("DecodeOpaque", tokens.PersonalAccessToken, "dfp_test").Return(pat.Id, nil).Once()
+						return m
+					}(),
+					Storage: func() storage.Storage {
+						m := storagemocks.NewStorage()
+						m.On("Get", mock.Anything, pat.Id).Return(pat, nil).Once()
+						return m
+					}(),
+				}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps(tt.pat))
+
+			stream, err := client.TranslateAccessToken(ctx)
+			if err != nil {
+				t.Errorf("AuthServer.TranslateAccessToken() error = %v", err)
+				return
+			}
+
+			if err := stream.Send(&pb.TranslateAccessTokenRequest{OpaqueAccessToken: "dfp_test"}); err != nil {
+				t.Errorf("AuthServer.TranslateAccessToken() error = %v", err)
+				return
+			}
+
+			got, err := stream.Recv()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.TranslateAccessToken() error = %v, wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.GetAccessToken() != "test-jwt-encoded" {
+				t.Errorf("AuthServer.TranslateAccessToken():\n got = %v\n want = %v", got.GetAccessToken(), "test-jwt-encoded")
+			}
+		})
+	}
+}
+
+func TestAuthServer_SignOutKeepsPersonalAccessTokens(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	now := time.Unix(1000, 0)
+	accessToken := entity.Token{
+		Id:     "test-access-seed",
+		UserId: testRefreshToken.UserId,
+		Type:   entity.AccessToken,
+	}
+	pat := entity.Token{
+		Id:       "test-pat-seed",
+		UserId:   testRefreshToken.UserId,
+		Type:     entity.PersonalAccessToken,
+		IssuedAt: time.Unix(0, 0),
+		Name:     "ci",
+		Scopes:   []string{"articles:read"},
+	}
+	tokenManager := tokensmocks.NewTokenManager()
+	tokenManager.On("DecodeOpaque", tokens.RefreshToken, "dfr_test").Return(testRefreshToken.Id, nil).Once()
+	tokenManager.On("DecodeOpaque", tokens.PersonalAccessToken, "dfp_test").Return(pat.Id, nil).Once()
+	tokenManager.On("Encode", mock.AnythingOfType("entity.Key"), mock.AnythingOfType("entity.Token")).Return([]byte("test-jwt-encoded"), nil).Once()
+
+	db := storagemocks.NewStorage()
+	db.On("Get", mock.Anything, testRefreshToken.Id).Return(testRefreshToken, nil).Once()
+	db.On("GetMultiple", mock.Anything, filter.Filter{{
+		Attribute: "user_id",
+		Operator:  filter.Equal,
+		Value:     testRefreshToken.UserId,
+	}}).Return([]entity.Token{testRefreshToken, accessToken, pat}, nil).Once()
+	db.On("Delete", mock.Anything, testRefreshToken.Id).Return(nil).Once()
+	db.On("Delete", mock.Anything, accessToken.Id).Return(nil).Once()
+	db.On("Get", mock.Anything, pat.Id).Return(pat, nil).Once()
+	db.On("SetLastUsed", mock.Anything, pat.Id, now).Return(nil).Once()
+
+	vault := storagemocks.NewVault()
+	vault.On("GetActive", mock.Anything, entity.ES256).Return(entity.Key{Id: "test"}, nil).Once()
+
+	client := servertest.NewServer(ctx, servertest.Deps{
+		Now:          func() time.Time { return now },
+		TokenManager: tokenManager,
+		Storage:      db,
+		Vault:        vault,
+	})
+
+	if _, err := client.SignOut(ctx, &pb.SignOutRequest{RefreshToken: "dfr_test"}); err != nil {
+		t.Errorf("AuthServer.SignOut() error = %v", err)
+		return
+	}
+
+	stream, err := client.TranslateAccessToken(ctx)
+	if err != nil {
+		t.Errorf("AuthServer.TranslateAccessToken() error = %v", err)
+		return
+	}
+
+	if err := stream.Send(&pb.TranslateAccessTokenRequest{OpaqueAccessToken: "dfp_test"}); err != nil {
+		t.Errorf("AuthServer.TranslateAccessToken() error = %v", err)
+		return
+	}
+
+	got, err := stream.Recv()
+	if err != nil {
+		t.Errorf("AuthServer.TranslateAccessToken() error = %v", err)
+		return
+	}
+
+	if got.GetAccessToken() != "test-jwt-encoded" {
+		t.Errorf("AuthServer.TranslateAccessToken():\n got = %v\n want = %v", got.GetAccessToken(), "test-jwt-encoded")
+	}
+
+	db.AssertNotCalled(t, "Delete", mock.Anything, pat.Id)
+}
@@ -7,11 +7,15 @@ import (
 	"time"
 
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/auth"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
 	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
 	"github.com/krixlion/dev_forum-auth/pkg/storage"
 	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop"
 	"github.com/krixlion/dev_forum-lib/cert"
-	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-lib/event"
 	"github.com/krixlion/dev_forum-lib/logging"
 	"github.com/krixlion/dev_forum-lib/tracing"
 	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
@@ -19,6 +23,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 )
@@ -29,8 +34,10 @@ type AuthServer struct {
 	vault        storage.Vault
 	storage      storage.Storage
 	tokenManager tokens.Manager
+	broker       event.Broker
 	logger       logging.Logger
 	tracer       trace.Tracer
+	dpopVerifier *dpop.Verifier
 	config       Config
 }
 
@@ -39,19 +46,56 @@ type Dependencies struct {
 	Storage      storage.Storage
 	Vault        storage.Vault
 	TokenManager tokens.Manager
+	Broker       event.Broker
 	Logger       logging.Logger
 	Tracer       trace.Tracer
+	// DPoPReplayCache records jtis of accepted DPoP proofs. It has to be
+	// shared by all instances, otherwise a proof can be replayed against
+	// each of them. Proofs are recorded in memory if nil.
+	DPoPReplayCache dpop.ReplayCache
 }
 
 type Services struct {
 	User userPb.UserServiceClient
 }
 
+// DefaultSigningAlgorithm is used to sign JWTs if Config.SigningAlgorithm is empty.
+const DefaultSigningAlgorithm = entity.ES256
+
 type Config struct {
 	VerifyClientCert         bool
 	AccessTokenValidityTime  time.Duration
 	RefreshTokenValidityTime time.Duration
 
+	PasswordResetTokenValidityTime     time.Duration
+	EmailVerificationTokenValidityTime time.Duration
+	MagicLinkTokenValidityTime         time.Duration
+
+	// Maximum number of magic links which can be requested
+	// for a single email within MagicLinkRateLimitWindow.
+	MagicLinkRateLimit       int
+	MagicLinkRateLimitWindow time.Duration
+
+	// Users whose IDs are listed in AdminUserIds and mTLS clients whose certificates
+	// are valid for one of AdminClientNames are allowed to impersonate other users.
+	AdminUserIds                   []string
+	AdminClientNames               []string
+	ImpersonationTokenValidityTime time.Duration
+
+	// DPoPTokenEndpoint is the URI which DPoP proofs presented to GetAccessToken
+	// have to be issued for. If empty it's derived from the request's authority and method.
+	DPoPTokenEndpoint string
+	// Maximum age of accepted DPoP proofs. Defaults to dpop.DefaultMaxAge.
+	DPoPProofMaxAge time.Duration
+
+	// Access tokens issued to mTLS clients whose certificates are valid for one of
+	// CertificateBoundClientNames are bound to the client's certificate. Tokens requested
+	// by other clients, e.g. the gateway acting on behalf of users, are not.
+	CertificateBoundClientNames []string
+
+	// Algorithm of the active key translated JWTs are signed with. Defaults to DefaultSigningAlgorithm.
+	SigningAlgorithm entity.Algorithm
+
 	// Allows to override time.Now for testing purposes.
 	Now func() time.Time
 }
@@ -63,6 +107,7 @@ func MakeAuthServer(dependencies Dependencies, config Config) AuthServer {
 		storage:      dependencies.Storage,
 		vault:        dependencies.Vault,
 		tokenManager: dependencies.TokenManager,
+		broker:       dependencies.Broker,
 		logger:       dependencies.Logger,
 		tracer:       dependencies.Tracer,
 	}
@@ -71,6 +116,12 @@ func MakeAuthServer(dependencies Dependencies, config Config) AuthServer {
 		s.config.Now = time.Now
 	}
 
+	if s.config.SigningAlgorithm == "" {
+		s.config.SigningAlgorithm = DefaultSigningAlgorithm
+	}
+
+	s.dpopVerifier = dpop.NewVerifier(s.config.DPoPProofMaxAge, s.config.Now, dependencies.DPoPReplayCache)
+
 	return s
 }
 
@@ -94,27 +145,38 @@ func (server AuthServer) SignIn(ctx context.Context, req *pb.SignInRequest) (_ *
 		return nil, status.Error(codes.FailedPrecondition, err.Error())
 	}
 
-	encodedOpaqueRefreshToken, tokenId, err := server.tokenManager.GenerateOpaque(tokens.RefreshToken)
+	encodedOpaqueRefreshToken, err := server.issueRefreshToken(ctx, user.Id)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	return &pb.SignInResponse{
+		RefreshToken: encodedOpaqueRefreshToken,
+	}, nil
+}
+
+// issueRefreshToken generates and stores a new refresh token for given user.
+// It returns the encoded opaque refresh token.
+func (server AuthServer) issueRefreshToken(ctx context.Context, userId string) (string, error) {
+	encodedOpaqueRefreshToken, tokenId, err := server.tokenManager.GenerateOpaque(tokens.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+
 	now := server.config.Now()
 	token := entity.Token{
 		Id:        tokenId,
-		UserId:    user.Id,
+		UserId:    userId,
 		Type:      entity.RefreshToken,
 		ExpiresAt: now.Add(server.config.RefreshTokenValidityTime),
 		IssuedAt:  now,
 	}
 
 	if err := server.storage.Create(ctx, token); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return "", err
 	}
 
-	return &pb.SignInResponse{
-		RefreshToken: encodedOpaqueRefreshToken,
-	}, nil
+	return encodedOpaqueRefreshToken, nil
 }
 
 func (server AuthServer) SignOut(ctx context.Context, req *pb.SignOutRequest) (_ *empty.Empty, err error) {
@@ -134,19 +196,9 @@ func (server AuthServer) SignOut(ctx context.Context, req *pb.SignOutRequest) (_
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
-	tokens, err := server.storage.GetMultiple(ctx, filter.Filter{{
-		Attribute: "user_id",
-		Operator:  filter.Equal,
-		Value:     token.UserId,
-	}})
-	if err != nil {
-		return nil, status.Error(codes.PermissionDenied, err.Error())
-	}
-
-	for _, token := range tokens {
-		if err := server.storage.Delete(ctx, token.Id); err != nil {
-			return nil, status.Error(codes.Internal, err.Error())
-		}
+	// Personal access tokens outlive user's sessions and are revoked explicitly.
+	if err := server.revokeUserTokens(ctx, token.UserId, entity.RefreshToken, entity.AccessToken); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	return &empty.Empty{}, nil
@@ -157,6 +209,18 @@ func (server AuthServer) GetAccessToken(ctx context.Context, req *pb.GetAccessTo
 	defer span.End()
 	defer tracing.SetSpanErr(span, err)
 
+	confirmation, err := server.verifyDPoPProof(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bind tokens issued to machine clients to their certificate as described in RFC 8705.
+	if _, ok := verifiedClientName(ctx, server.config.CertificateBoundClientNames); ok {
+		if cert, ok := auth.PeerCertificate(ctx); ok {
+			confirmation.X5tS256 = jwkutil.CertificateThumbprint(cert)
+		}
+	}
+
 	opaqueRefreshToken := req.GetRefreshToken()
 
 	refreshTokenId, err := server.tokenManager.DecodeOpaque(tokens.RefreshToken, opaqueRefreshToken)
@@ -176,22 +240,50 @@ func (server AuthServer) GetAccessToken(ctx context.Context, req *pb.GetAccessTo
 
 	now := server.config.Now()
 	accessToken := entity.Token{
-		Id:        accessTokenId,
-		UserId:    refreshToken.UserId,
-		Type:      entity.AccessToken,
-		ExpiresAt: now.Add(server.config.AccessTokenValidityTime),
-		IssuedAt:  now,
+		Id:           accessTokenId,
+		UserId:       refreshToken.UserId,
+		Type:         entity.AccessToken,
+		ExpiresAt:    now.Add(server.config.AccessTokenValidityTime),
+		IssuedAt:     now,
+		Confirmation: confirmation,
 	}
 
 	if err := server.storage.Create(ctx, accessToken); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	tokenType := "Bearer"
+	if confirmation.JKT != "" {
+		tokenType = "DPoP"
+	}
+
 	return &pb.GetAccessTokenResponse{
 		AccessToken: opaqueAccessToken,
+		TokenType:   tokenType,
 	}, nil
 }
 
+// verifyDPoPProof returns a confirmation binding the access token to the key
+// of the DPoP proof sent in the request's metadata. If no proof was sent
+// it returns a zero confirmation. Returned errors are already converted to gRPC statuses.
+func (server AuthServer) verifyDPoPProof(ctx context.Context) (entity.Confirmation, error) {
+	proof, uri, ok := dpop.ProofFromIncomingContext(ctx)
+	if !ok {
+		return entity.Confirmation{}, nil
+	}
+
+	if server.config.DPoPTokenEndpoint != "" {
+		uri = server.config.DPoPTokenEndpoint
+	}
+
+	p, err := server.dpopVerifier.Verify(ctx, proof, dpop.GRPCMethod, uri)
+	if err != nil {
+		return entity.Confirmation{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return entity.Confirmation{JKT: p.JKT}, nil
+}
+
 func (server AuthServer) TranslateAccessToken(stream pb.AuthService_TranslateAccessTokenServer) error {
 	ctx := stream.Context()
 
@@ -230,19 +322,16 @@ func (server AuthServer) translateAccessToken(ctx context.Context, req *pb.Trans
 	defer span.End()
 	defer tracing.SetSpanErr(span, err)
 
-	encodedOpaqueAccessToken := req.GetOpaqueAccessToken()
-
-	opaqueAccessToken, err := server.tokenManager.DecodeOpaque(tokens.AccessToken, encodedOpaqueAccessToken)
+	token, err := server.getTranslatableToken(ctx, req.GetOpaqueAccessToken())
 	if err != nil {
-		return nil, status.Error(codes.FailedPrecondition, err.Error())
+		return nil, err
 	}
 
-	token, err := server.storage.Get(ctx, opaqueAccessToken)
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	if err := server.verifyTranslationProof(ctx, req, token); err != nil {
+		return nil, err
 	}
 
-	privateKey, err := server.vault.GetRandom(ctx)
+	privateKey, err := server.vault.GetActive(ctx, server.config.SigningAlgorithm)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -258,6 +347,55 @@ func (server AuthServer) translateAccessToken(ctx context.Context, req *pb.Trans
 	}, nil
 }
 
+// verifyTranslationProof verifies the DPoP proof the client presented along with
+// given opaque token if the token is bound to a DPoP key.
+// The proof's ath is verified here since the client never sees the translated JWT.
+// Returned errors are already converted to gRPC statuses.
+func (server AuthServer) verifyTranslationProof(ctx context.Context, req *pb.TranslateAccessTokenRequest, token entity.Token) error {
+	if token.Confirmation.JKT == "" {
+		return nil
+	}
+
+	if req.GetDpopProof() == "" {
+		return status.Error(codes.PermissionDenied, dpop.ErrProofRequired.Error())
+	}
+
+	p, err := server.dpopVerifier.Verify(ctx, req.GetDpopProof(), req.GetDpopMethod(), req.GetDpopUri())
+	if err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if p.AccessTokenHash != dpop.AccessTokenHash(req.GetOpaqueAccessToken()) {
+		return status.Error(codes.PermissionDenied, dpop.ErrAccessTokenMismatch.Error())
+	}
+
+	if p.JKT != token.Confirmation.JKT {
+		return status.Error(codes.PermissionDenied, dpop.ErrThumbprintMismatch.Error())
+	}
+
+	return nil
+}
+
+// getTranslatableToken returns a token to be encoded as a JWT for given opaque token.
+// Both access tokens and personal access tokens are accepted.
+func (server AuthServer) getTranslatableToken(ctx context.Context, encodedOpaqueToken string) (entity.Token, error) {
+	if tokens.PersonalAccessToken.Matches(encodedOpaqueToken) {
+		return server.usePersonalAccessToken(ctx, encodedOpaqueToken)
+	}
+
+	opaqueAccessToken, err := server.tokenManager.DecodeOpaque(tokens.AccessToken, encodedOpaqueToken)
+	if err != nil {
+		return entity.Token{}, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	token, err := server.storage.Get(ctx, opaqueAccessToken)
+	if err != nil {
+		return entity.Token{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return token, nil
+}
+
 func (server AuthServer) GetValidationKeySet(_ *empty.Empty, stream pb.AuthService_GetValidationKeySetServer) (err error) {
 	ctx := stream.Context()
 
@@ -280,6 +418,13 @@ func (server AuthServer) GetValidationKeySet(_ *empty.Empty, stream pb.AuthServi
 			return err
 		}
 
+		// Catches keys corrupted in the store or while encoding.
+		// They're dropped so that the rest of the keyset is still served.
+		if err := verifyThumbprint(key.Id, encoded); err != nil {
+			server.logger.Log(ctx, "Dropping key whose id is not its thumbprint", "kid", key.Id, "err", err)
+			continue
+		}
+
 		marshaledKey, err := anypb.New(encoded)
 		if err != nil {
 			return err
@@ -290,6 +435,14 @@ func (server AuthServer) GetValidationKeySet(_ *empty.Empty, stream pb.AuthServi
 			Kty: string(key.Type),
 			Alg: string(key.Algorithm),
 			Key: marshaledKey,
+			Use: "sig",
+		}
+
+		if len(key.Certificates) > 0 {
+			for _, certificate := range key.Certificates {
+				jwk.X5C = append(jwk.X5C, certificate.Raw)
+			}
+			jwk.X5TS256 = jwkutil.CertificateThumbprint(key.Certificates[0])
 		}
 
 		if err := stream.Send(jwk); err != nil {
@@ -299,3 +452,13 @@ func (server AuthServer) GetValidationKeySet(_ *empty.Empty, stream pb.AuthServi
 
 	return nil
 }
+
+// verifyThumbprint returns a non-nil error if kid is not the RFC 7638 thumbprint of given encoded public key.
+func verifyThumbprint(kid string, encoded proto.Message) error {
+	publicKey, err := protokey.DeserializeKey(encoded)
+	if err != nil {
+		return err
+	}
+
+	return protokey.VerifyThumbprint(kid, publicKey)
+}
@@ -6,7 +6,9 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"io"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,16 +16,28 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
 	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/server"
 	"github.com/krixlion/dev_forum-auth/pkg/grpc/server/servertest"
 	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
 	"github.com/krixlion/dev_forum-auth/pkg/storage"
 	"github.com/krixlion/dev_forum-auth/pkg/storage/storagemocks"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vault"
 	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop/dpoptest"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/manager"
 	"github.com/krixlion/dev_forum-auth/pkg/tokens/tokensmocks"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/validator"
 	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-lib/nulls"
 	usermocks "github.com/krixlion/dev_forum-user/pkg/grpc/mocks"
 	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/anypb"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -180,7 +194,24 @@ func TestAuthServer_SignOut(t *testing.T) {
 }
 
 func TestAuthServer_GetAccessToken(t *testing.T) {
+	now := time.Unix(1682517486, 0)
+	dpopKey := dpoptest.GenerateKey()
+	htu := "https://bufnet" + pb.AuthService_GetAccessToken_FullMethodName
+
+	refreshToken := entity.Token{
+		Id:     "test-opaque-seed",
+		UserId: "test",
+		Type:   entity.RefreshToken,
+	}
+	tokenManager := func() tokens.Manager {
+		manager := tokensmocks.NewTokenManager()
+		manager.On("DecodeOpaque", tokens.RefreshToken, "test-opaque").Return("test-opaque-decoded", nil).Once()
+		manager.On("GenerateOpaque", tokens.AccessToken).Return("test-opaque-generated", "test-opaque-seed", nil).Once()
+		return manager
+	}
+
 	type args struct {
+		md  metadata.MD
 		req *pb.GetAccessTokenRequest
 	}
 	tests := []struct {
@@ -193,33 +224,64 @@ func TestAuthServer_GetAccessToken(t *testing.T) {
 		{
 			name: "Test if no unexpected errors are returned on valid flow",
 			deps: servertest.Deps{
-				TokenManager: func() tokens.Manager {
-					manager := tokensmocks.NewTokenManager()
-					manager.On("DecodeOpaque", tokens.RefreshToken, "test-opaque").Return("test-opaque-decoded", nil).Once()
-					manager.On("GenerateOpaque", tokens.AccessToken).Return("test-opaque-generated", "test-opaque-seed", nil).Once()
-					return manager
+				TokenManager: tokenManager(),
+				Storage: func() storage.Storage {
+					storage := storagemocks.NewStorage()
+					storage.On("Get", mock.Anything, "test-opaque-decoded").Return(refreshToken, nil).Once()
+					storage.On("Create", mock.Anything, mock.MatchedBy(func(token entity.Token) bool {
+						return token.Confirmation.IsZero()
+					})).Return(nil).Once()
+					return storage
 				}(),
+			},
+			args: args{
+				req: &pb.GetAccessTokenRequest{
+					RefreshToken: "test-opaque",
+				},
+			},
+			want: &pb.GetAccessTokenResponse{
+				AccessToken: "test-opaque-generated",
+				TokenType:   "Bearer",
+			},
+		},
+		{
+			name: "Test if token is bound to the key of a valid DPoP proof",
+			deps: servertest.Deps{
+				Now:          func() time.Time { return now },
+				TokenManager: tokenManager(),
 				Storage: func() storage.Storage {
 					storage := storagemocks.NewStorage()
-					testToken := entity.Token{
-						Id:     "test-opaque-seed",
-						UserId: "test",
-						Type:   entity.RefreshToken,
-					}
-					storage.On("Get", mock.Anything, "test-opaque-decoded").Return(testToken, nil).Once()
-					storage.On("Create", mock.Anything, mock.AnythingOfType("entity.Token")).Return(nil).Once()
+					storage.On("Get", mock.Anything, "test-opaque-decoded").Return(refreshToken, nil).Once()
+					storage.On("Create", mock.Anything, mock.MatchedBy(func(token entity.Token) bool {
+						return token.Confirmation.JKT == dpoptest.Thumbprint(dpopKey)
+					})).Return(nil).Once()
 					return storage
 				}(),
 			},
 			args: args{
+				md: metadata.Pairs(dpop.HeaderKey, dpoptest.MakeProof(dpopKey, dpoptest.MakeClaims("POST", htu, now))),
 				req: &pb.GetAccessTokenRequest{
 					RefreshToken: "test-opaque",
 				},
 			},
 			want: &pb.GetAccessTokenResponse{
 				AccessToken: "test-opaque-generated",
+				TokenType:   "DPoP",
 			},
 		},
+		{
+			name: "Test if fails on DPoP proof issued for a different endpoint",
+			deps: servertest.Deps{
+				Now: func() time.Time { return now },
+			},
+			args: args{
+				md: metadata.Pairs(dpop.HeaderKey, dpoptest.MakeProof(dpopKey, dpoptest.MakeClaims("POST", "https://bufnet/other", now))),
+				req: &pb.GetAccessTokenRequest{
+					RefreshToken: "test-opaque",
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -228,13 +290,13 @@ func TestAuthServer_GetAccessToken(t *testing.T) {
 
 			client := servertest.NewServer(ctx, tt.deps)
 
-			got, err := client.GetAccessToken(ctx, tt.args.req)
+			got, err := client.GetAccessToken(metadata.NewOutgoingContext(ctx, tt.args.md), tt.args.req)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AuthServer.GetAccessToken() error = %v, wantErr = %v", err, tt.wantErr)
 				return
 			}
 
-			if !tt.wantErr {
+			if tt.wantErr {
 				return
 			}
 
@@ -282,7 +344,7 @@ func TestAuthServer_TranslateAccessToken(t *testing.T) {
 						Type:      "test",
 						Algorithm: "test",
 					}
-					vault.On("GetRandom", mock.Anything).Return(testKey, nil).Once()
+					vault.On("GetActive", mock.Anything, entity.ES256).Return(testKey, nil).Once()
 					return vault
 				}(),
 			},
@@ -359,30 +421,52 @@ func TestAuthServer_GetValidationKeySet(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to generate ecdsa private key: %s", err)
 	}
+	rsaId, err := protokey.Thumbprint(&rsaPrivKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to get rsa key thumbprint: %s", err)
+	}
+	ecdsaId, err := protokey.Thumbprint(&ecdsaPrivKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to get ecdsa key thumbprint: %s", err)
+	}
+	ca, err := vault.NewCA(testdata.CA.CertPem, testdata.CA.PrivPem, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make ca: %s", err)
+	}
+	ecdsaCertificates, err := ca.Issue(context.Background(), ecdsaId, &ecdsaPrivKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to issue ecdsa key certificate: %s", err)
+	}
+	ecdsaAny := func() *anypb.Any {
+		v, err := protokey.SerializeECDSA(ecdsaPrivKey.PublicKey)
+		if err != nil {
+			t.Fatalf("Failed to encode ECDSA key: %s", err)
+		}
+		msg, err := anypb.New(v)
+		if err != nil {
+			t.Fatalf("Failed to marshal ECDSA key to anypb.Any: %s", err)
+		}
+		return msg
+	}
 
 	tests := []struct {
-		name string
-		deps servertest.Deps
-		want []*pb.Jwk
+		name     string
+		deps     servertest.Deps
+		want     []*pb.Jwk
+		wantCode codes.Code
 	}{
 		{
 			name: "Test if no unexpected errors are returned on valid flow",
 			deps: servertest.Deps{
 				Vault: func() storagemocks.Vault {
 					m := storagemocks.NewVault()
-					rsaKey := entity.Key{
-						Id:         "test-rsa-id",
-						Type:       entity.RSA,
-						Algorithm:  entity.RS256,
-						Raw:        rsaPrivKey,
-						EncodeFunc: protokey.SerializeRSA,
+					rsaKey, err := entity.NewKey(rsaId, entity.RSA, entity.RS256, rsaPrivKey, protokey.SerializeRSA)
+					if err != nil {
+						t.Fatalf("Failed to make rsa key: %s", err)
 					}
-					ecsdaKey := entity.Key{
-						Id:         "test-ecdsa-id",
-						Type:       entity.ECDSA,
-						Algorithm:  entity.ES256,
-						Raw:        ecdsaPrivKey,
-						EncodeFunc: protokey.SerializeECDSA,
+					ecsdaKey, err := entity.NewKey(ecdsaId, entity.ECDSA, entity.ES256, ecdsaPrivKey, protokey.SerializeECDSA)
+					if err != nil {
+						t.Fatalf("Failed to make ecdsa key: %s", err)
 					}
 					m.On("GetKeySet", mock.Anything).Return([]entity.Key{rsaKey, ecsdaKey}, nil).Once()
 					return m
@@ -390,9 +474,10 @@ func TestAuthServer_GetValidationKeySet(t *testing.T) {
 			},
 			want: []*pb.Jwk{
 				{
-					Kid: "test-rsa-id",
+					Kid: rsaId,
 					Alg: "RS256",
 					Kty: "RSA",
+					Use: "sig",
 					Key: func() *anypb.Any {
 						v, err := protokey.SerializeRSA(rsaPrivKey.PublicKey)
 						if err != nil {
@@ -406,20 +491,64 @@ func TestAuthServer_GetValidationKeySet(t *testing.T) {
 					}(),
 				},
 				{
-					Kid: "test-ecdsa-id",
+					Kid: ecdsaId,
 					Alg: "ES256",
 					Kty: "ECDSA",
-					Key: func() *anypb.Any {
-						v, err := protokey.SerializeECDSA(ecdsaPrivKey.PublicKey)
-						if err != nil {
-							t.Fatalf("Failed to encode ECDSA key: %s", err)
-						}
-						msg, err := anypb.New(v)
-						if err != nil {
-							t.Fatalf("Failed to marshal ECDSA key to anypb.Any: %s", err)
-						}
-						return msg
-					}(),
+					Use: "sig",
+					Key: ecdsaAny(),
+				},
+			},
+		},
+		{
+			name: "Test if publishes key's certificate chain",
+			deps: servertest.Deps{
+				Vault: func() storagemocks.Vault {
+					m := storagemocks.NewVault()
+					key, err := entity.NewKey(ecdsaId, entity.ECDSA, entity.ES256, ecdsaPrivKey, protokey.SerializeECDSA)
+					if err != nil {
+						t.Fatalf("Failed to make ecdsa key: %s", err)
+					}
+					key.Certificates = ecdsaCertificates
+					m.On("GetKeySet", mock.Anything).Return([]entity.Key{key}, nil).Once()
+					return m
+				}(),
+			},
+			want: []*pb.Jwk{
+				{
+					Kid:     ecdsaId,
+					Alg:     "ES256",
+					Kty:     "ECDSA",
+					Use:     "sig",
+					Key:     ecdsaAny(),
+					X5C:     [][]byte{ecdsaCertificates[0].Raw, testdata.CA.Cert.Raw},
+					X5TS256: jwkutil.CertificateThumbprint(ecdsaCertificates[0]),
+				},
+			},
+		},
+		{
+			name: "Test if drops only a key whose kid is not its thumbprint",
+			deps: servertest.Deps{
+				Vault: func() storagemocks.Vault {
+					m := storagemocks.NewVault()
+					altered, err := entity.NewKey(rsaId, entity.ECDSA, entity.ES256, ecdsaPrivKey, protokey.SerializeECDSA)
+					if err != nil {
+						t.Fatalf("Failed to make ecdsa key: %s", err)
+					}
+					ecdsaKey, err := entity.NewKey(ecdsaId, entity.ECDSA, entity.ES256, ecdsaPrivKey, protokey.SerializeECDSA)
+					if err != nil {
+						t.Fatalf("Failed to make ecdsa key: %s", err)
+					}
+					m.On("GetKeySet", mock.Anything).Return([]entity.Key{altered, ecdsaKey}, nil).Once()
+					return m
+				}(),
+			},
+			want: []*pb.Jwk{
+				{
+					Kid: ecdsaId,
+					Alg: "ES256",
+					Kty: "ECDSA",
+					Use: "sig",
+					Key: ecdsaAny(),
 				},
 			},
 		},
@@ -443,8 +572,10 @@ func TestAuthServer_GetValidationKeySet(t *testing.T) {
 					if err == io.EOF {
 						break
 					}
-					t.Errorf("AuthServer.GetValidationKeySet() unexpected error = %v", err)
-					return
+					if code := status.Code(err); code != tt.wantCode {
+						t.Errorf("AuthServer.GetValidationKeySet() code = %v, want %v, err = %v", code, tt.wantCode, err)
+					}
+					break
 				}
 				got = append(got, jwk)
 			}
@@ -455,3 +586,245 @@ func TestAuthServer_GetValidationKeySet(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthServer_GetAccessToken_CertificateBinding(t *testing.T) {
+	refreshToken := entity.Token{
+		Id:     "test-opaque-decoded",
+		UserId: "test",
+		Type:   entity.RefreshToken,
+	}
+
+	tests := []struct {
+		name       string
+		clientName string
+		wantBound  bool
+	}{
+		{
+			name:       "Test if token is bound to the certificate of a machine client",
+			clientName: "machine-client",
+			wantBound:  true,
+		},
+		{
+			name:       "Test if token is not bound to the certificate of other clients",
+			clientName: "gateway",
+			wantBound:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			tokenManager := tokensmocks.NewTokenManager()
+			tokenManager.On("DecodeOpaque", tokens.RefreshToken, "test-opaque").Return(refreshToken.Id, nil).Once()
+			tokenManager.On("GenerateOpaque", tokens.AccessToken).Return("test-opaque-generated", "test-opaque-seed", nil).Once()
+
+			db := storagemocks.NewStorage()
+			db.On("Get", mock.Anything, refreshToken.Id).Return(refreshToken, nil).Once()
+			db.On("Create", mock.Anything, mock.MatchedBy(func(token entity.Token) bool {
+				return (token.Confirmation.X5tS256 != "") == tt.wantBound
+			})).Return(nil).Once()
+
+			s := server.MakeAuthServer(server.Dependencies{
+				Storage:      db,
+				TokenManager: tokenManager,
+				Logger:       nulls.NullLogger{},
+				Tracer:       nulls.NullTracer{},
+			}, server.Config{
+				AccessTokenValidityTime:     time.Minute,
+				CertificateBoundClientNames: []string{"machine-client"},
+			})
+
+			if _, err := s.GetAccessToken(withClientCert(t, ctx, tt.clientName), &pb.GetAccessTokenRequest{RefreshToken: "test-opaque"}); err != nil {
+				t.Errorf("AuthServer.GetAccessToken() error = %v", err)
+			}
+		})
+	}
+}
+
+// TestAuthServer_DPoPTokenFlow issues a DPoP-bound access token, translates it the way
+// the gateway does and validates the JWT the way backend services do.
+func TestAuthServer_DPoPTokenFlow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	now := time.Now().Truncate(time.Second)
+	resourceURI := "https://article-service/article.ArticleService/Create"
+	tokenManager := manager.MakeManager(manager.Config{Issuer: "auth-service"})
+	dpopKey := dpoptest.GenerateKey()
+
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ecdsa private key: %v", err)
+	}
+
+	kid, err := protokey.Thumbprint(signer.Public())
+	if err != nil {
+		t.Fatalf("protokey.Thumbprint() error = %v", err)
+	}
+
+	signingKey, err := entity.NewKey(kid, entity.ECDSA, entity.ES256, signer, protokey.SerializeECDSA)
+	if err != nil {
+		t.Fatalf("entity.NewKey() error = %v", err)
+	}
+
+	vault := storagemocks.NewVault()
+	vault.On("GetActive", mock.Anything, entity.ES256).Return(signingKey, nil)
+	vault.On("GetKeySet", mock.Anything).Return([]entity.Key{signingKey}, nil)
+
+	encodedRefreshToken, refreshTokenId, err := tokenManager.GenerateOpaque(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("TokenManager.GenerateOpaque() error = %v", err)
+	}
+
+	db := newMemoryStorage()
+	if err := db.Create(ctx, entity.Token{Id: refreshTokenId, UserId: "test-user", Type: entity.RefreshToken, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Storage.Create() error = %v", err)
+	}
+
+	client := servertest.NewServer(ctx, servertest.Deps{
+		Now:          func() time.Time { return now },
+		Storage:      db,
+		Vault:        vault,
+		TokenManager: tokenManager,
+	})
+
+	tokenProof := dpoptest.MakeProof(dpopKey, dpoptest.MakeClaims("POST", "https://bufnet"+pb.AuthService_GetAccessToken_FullMethodName, now))
+	resp, err := client.GetAccessToken(metadata.AppendToOutgoingContext(ctx, dpop.HeaderKey, tokenProof), &pb.GetAccessTokenRequest{RefreshToken: encodedRefreshToken})
+	if err != nil {
+		t.Fatalf("AuthServer.GetAccessToken() error = %v", err)
+	}
+
+	if resp.GetTokenType() != "DPoP" {
+		t.Fatalf("AuthServer.GetAccessToken() token type = %v, want = DPoP", resp.GetTokenType())
+	}
+
+	opaqueAccessToken := resp.GetAccessToken()
+
+	translate := func(proof string) (string, error) {
+		stream, err := client.TranslateAccessToken(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		err = stream.Send(&pb.TranslateAccessTokenRequest{
+			OpaqueAccessToken: opaqueAccessToken,
+			DpopProof:         proof,
+			DpopMethod:        "POST",
+			DpopUri:           resourceURI,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		got, err := stream.Recv()
+		return got.GetAccessToken(), err
+	}
+
+	makeResourceProof := func(key *ecdsa.PrivateKey, accessToken string) string {
+		claims := dpoptest.MakeClaims("POST", resourceURI, now)
+		claims.ATH = dpop.AccessTokenHash(accessToken)
+		return dpoptest.MakeProof(key, claims)
+	}
+
+	if _, err := translate(""); err == nil {
+		t.Errorf("AuthServer.TranslateAccessToken() succeeded without a proof")
+	}
+
+	if _, err := translate(makeResourceProof(dpopKey, "dfa_other-token")); err == nil {
+		t.Errorf("AuthServer.TranslateAccessToken() succeeded with a proof issued for a different token")
+	}
+
+	if _, err := translate(makeResourceProof(dpoptest.GenerateKey(), opaqueAccessToken)); err == nil {
+		t.Errorf("AuthServer.TranslateAccessToken() succeeded with a proof signed with a different key")
+	}
+
+	// Proofs are presented along with the opaque token the client received.
+	proof := makeResourceProof(dpopKey, opaqueAccessToken)
+
+	jwt, err := translate(proof)
+	if err != nil {
+		t.Fatalf("AuthServer.TranslateAccessToken() error = %v", err)
+	}
+
+	v, err := validator.NewValidator("auth-service", validator.DefaultRefreshFunc(client, nil))
+	if err != nil {
+		t.Fatalf("validator.NewValidator() error = %v", err)
+	}
+	go v.Run(ctx)
+
+	// Wait for the validator to fetch the key set.
+	time.Sleep(time.Millisecond * 100)
+
+	if err := v.ValidateTokenWithProof(ctx, jwt, proof, "POST", resourceURI); err != nil {
+		t.Errorf("JWTValidator.ValidateTokenWithProof() error = %v", err)
+	}
+
+	if err := v.ValidateToken(jwt); !errors.Is(err, dpop.ErrProofRequired) {
+		t.Errorf("JWTValidator.ValidateToken() error = %v, wantErr = %v", err, dpop.ErrProofRequired)
+	}
+}
+
+// memoryStorage is an in-memory storage.Storage used to test flows spanning multiple calls.
+type memoryStorage struct {
+	mu     *sync.Mutex
+	tokens map[string]entity.Token
+}
+
+func newMemoryStorage() memoryStorage {
+	return memoryStorage{
+		mu:     &sync.Mutex{},
+		tokens: make(map[string]entity.Token),
+	}
+}
+
+func (s memoryStorage) Get(_ context.Context, id string) (entity.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok {
+		return entity.Token{}, storage.ErrTokenNotFound
+	}
+	return token, nil
+}
+
+func (s memoryStorage) GetMultiple(context.Context, filter.Filter) ([]entity.Token, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s memoryStorage) Create(_ context.Context, token entity.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.Id] = token
+	return nil
+}
+
+func (s memoryStorage) Update(ctx context.Context, token entity.Token) error {
+	return s.Create(ctx, token)
+}
+
+func (s memoryStorage) SetLastUsed(_ context.Context, id string, lastUsedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok {
+		return storage.ErrTokenNotFound
+	}
+	token.LastUsedAt = lastUsedAt
+	s.tokens[id] = token
+	return nil
+}
+
+func (s memoryStorage) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[id]; !ok {
+		return storage.ErrTokenNotFound
+	}
+	delete(s.tokens, id)
+	return nil
+}
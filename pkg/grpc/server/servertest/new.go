@@ -10,6 +10,7 @@ import (
 	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
 	"github.com/krixlion/dev_forum-auth/pkg/storage"
 	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-lib/event"
 	"github.com/krixlion/dev_forum-lib/nulls"
 	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
 	"google.golang.org/grpc"
@@ -25,6 +26,8 @@ type Deps struct {
 	Vault            storage.Vault
 	UserClient       userPb.UserServiceClient
 	TokenManager     tokens.Manager
+	Broker           event.Broker
+	AdminUserIds     []string
 }
 
 // NewServer initializes and runs in the background a gRPC
@@ -45,6 +48,15 @@ func NewServer(ctx context.Context, d Deps) pb.AuthServiceClient {
 		AccessTokenValidityTime:  time.Minute,
 		RefreshTokenValidityTime: time.Minute,
 		Now:                      d.Now,
+
+		PasswordResetTokenValidityTime:     time.Minute,
+		EmailVerificationTokenValidityTime: time.Minute,
+		MagicLinkTokenValidityTime:         time.Minute,
+		MagicLinkRateLimit:                 3,
+		MagicLinkRateLimitWindow:           time.Hour,
+
+		AdminUserIds:                   d.AdminUserIds,
+		ImpersonationTokenValidityTime: time.Minute,
 	}
 
 	deps := server.Dependencies{
@@ -54,6 +66,7 @@ func NewServer(ctx context.Context, d Deps) pb.AuthServiceClient {
 		Vault:        d.Vault,
 		TokenManager: d.TokenManager,
 		Storage:      d.Storage,
+		Broker:       d.Broker,
 		Logger:       nulls.NullLogger{},
 		Tracer:       nulls.NullTracer{},
 	}
@@ -67,7 +80,7 @@ func NewServer(ctx context.Context, d Deps) pb.AuthServiceClient {
 		}
 	}()
 
-	conn, err := grpc.NewClient("passthrough://bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		log.Fatalf("Failed to dial bufnet: %v", err)
 	}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/events"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func (server AuthServer) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (_ *empty.Empty, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.RequestPasswordReset")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	resp, err := server.services.User.GetSecret(ctx, &userPb.GetUserSecretRequest{
+		Query: &userPb.GetUserSecretRequest_Email{
+			Email: req.GetEmail(),
+		},
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			// Do not reveal whether a user with given email exists.
+			return &empty.Empty{}, nil
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	user := resp.GetUser()
+
+	token := entity.Token{
+		UserId: user.GetId(),
+		Type:   entity.PasswordResetToken,
+	}
+
+	encodedOpaqueToken, token, err := server.issueSingleUseToken(ctx, tokens.PasswordResetToken, token, server.config.PasswordResetTokenValidityTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.publishTokenIssued(ctx, events.PasswordResetRequested, user.GetEmail(), encodedOpaqueToken, token); err != nil {
+		return nil, err
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (server AuthServer) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (_ *empty.Empty, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.ResetPassword")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	token, err := server.redeemSingleUseToken(ctx, tokens.PasswordResetToken, entity.PasswordResetToken, req.GetPasswordResetToken())
+	if err != nil {
+		return nil, err
+	}
+
+	// User-service is responsible for hashing the password.
+	_, err = server.services.User.Update(ctx, &userPb.UpdateUserRequest{
+		User: &userPb.User{
+			Id:       token.UserId,
+			Password: req.GetNewPassword(),
+		},
+		FieldMask: &fieldmaskpb.FieldMask{
+			Paths: []string{"password"},
+		},
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := server.revokeUserTokens(ctx, token.UserId); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (server AuthServer) RequestEmailVerification(ctx context.Context, req *pb.RequestEmailVerificationRequest) (_ *empty.Empty, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.RequestEmailVerification")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	refreshToken, err := server.getRefreshToken(ctx, req.GetRefreshToken())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := server.services.User.GetSecret(ctx, &userPb.GetUserSecretRequest{
+		Query: &userPb.GetUserSecretRequest_Id{
+			Id: refreshToken.UserId,
+		},
+	})
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	token := entity.Token{
+		UserId: refreshToken.UserId,
+		Type:   entity.EmailVerificationToken,
+	}
+
+	encodedOpaqueToken, token, err := server.issueSingleUseToken(ctx, tokens.EmailVerificationToken, token, server.config.EmailVerificationTokenValidityTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.publishTokenIssued(ctx, events.EmailVerificationRequested, resp.GetUser().GetEmail(), encodedOpaqueToken, token); err != nil {
+		return nil, err
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (server AuthServer) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (_ *empty.Empty, err error) {
+	ctx, span := server.tracer.Start(ctx, "server.VerifyEmail")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	token, err := server.redeemSingleUseToken(ctx, tokens.EmailVerificationToken, entity.EmailVerificationToken, req.GetEmailVerificationToken())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := server.services.User.GetSecret(ctx, &userPb.GetUserSecretRequest{
+		Query: &userPb.GetUserSecretRequest_Id{
+			Id: token.UserId,
+		},
+	})
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	body := events.UserEmailVerified{
+		UserId: token.UserId,
+		Email:  resp.GetUser().GetEmail(),
+	}
+
+	e, err := event.MakeEvent(event.AuthAggregate, events.EmailVerified, body, tracing.ExtractMetadataFromContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := server.broker.ResilientPublish(e); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &empty.Empty{}, nil
+}
+
+// issueSingleUseToken generates and stores given token which expires after given duration.
+// Token's ID and timestamps are overwritten. It returns the encoded opaque token and
+// the stored token. Returned errors are already converted to gRPC statuses.
+func (server AuthServer) issueSingleUseToken(ctx context.Context, prefix tokens.OpaqueTokenPrefix, token entity.Token, validityTime time.Duration) (string, entity.Token, error) {
+	encodedOpaqueToken, tokenId, err := server.tokenManager.GenerateOpaque(prefix)
+	if err != nil {
+		return "", entity.Token{}, status.Error(codes.Internal, err.Error())
+	}
+
+	now := server.config.Now()
+	token.Id = tokenId
+	token.IssuedAt = now
+	token.ExpiresAt = now.Add(validityTime)
+
+	if err := server.storage.Create(ctx, token); err != nil {
+		return "", entity.Token{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return encodedOpaqueToken, token, nil
+}
+
+// redeemSingleUseToken decodes given opaque token, verifies its type and expiration
+// and deletes it so that it can't be used again. Returned errors are already converted to gRPC statuses.
+func (server AuthServer) redeemSingleUseToken(ctx context.Context, prefix tokens.OpaqueTokenPrefix, typ entity.TokenType, encodedOpaqueToken string) (entity.Token, error) {
+	token, err := server.getSingleUseToken(ctx, prefix, typ, encodedOpaqueToken)
+	if err != nil {
+		return entity.Token{}, err
+	}
+
+	if err := server.deleteSingleUseToken(ctx, token); err != nil {
+		return entity.Token{}, err
+	}
+
+	return token, nil
+}
+
+// getSingleUseToken decodes given opaque token and returns its stored counterpart
+// after verifying its type. Returned errors are already converted to gRPC statuses.
+func (server AuthServer) getSingleUseToken(ctx context.Context, prefix tokens.OpaqueTokenPrefix, typ entity.TokenType, encodedOpaqueToken string) (entity.Token, error) {
+	tokenId, err := server.tokenManager.DecodeOpaque(prefix, encodedOpaqueToken)
+	if err != nil {
+		return entity.Token{}, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	token, err := server.storage.Get(ctx, tokenId)
+	if err != nil {
+		return entity.Token{}, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if token.Type != typ {
+		return entity.Token{}, status.Error(codes.PermissionDenied, tokens.ErrInvalidTokenType.Error())
+	}
+
+	return token, nil
+}
+
+// deleteSingleUseToken deletes given token so that it can't be used again and verifies
+// its expiration. Returned errors are already converted to gRPC statuses.
+func (server AuthServer) deleteSingleUseToken(ctx context.Context, token entity.Token) error {
+	// Delete the token before checking its expiration
+	// since expired tokens are of no use either way.
+	// Only one of concurrent redemptions manages to delete the token.
+	if err := server.storage.Delete(ctx, token.Id); err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return status.Error(codes.PermissionDenied, "token already used")
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if !server.config.Now().Before(token.ExpiresAt) {
+		return status.Error(codes.PermissionDenied, "token expired")
+	}
+
+	return nil
+}
+
+// publishTokenIssued publishes an event allowing other services to deliver given token to the user.
+func (server AuthServer) publishTokenIssued(ctx context.Context, eType event.EventType, email, encodedOpaqueToken string, token entity.Token) error {
+	body := events.TokenIssued{
+		UserId:    token.UserId,
+		Email:     email,
+		Token:     encodedOpaqueToken,
+		ExpiresAt: token.ExpiresAt,
+	}
+
+	e, err := event.MakeEvent(event.AuthAggregate, eType, body, tracing.ExtractMetadataFromContext(ctx))
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if err := server.broker.ResilientPublish(e); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// revokeUserTokens deletes tokens issued for given user. If any types are given
+// then only tokens of these types are deleted, otherwise all user's tokens are.
+func (server AuthServer) revokeUserTokens(ctx context.Context, userId string, types ...entity.TokenType) error {
+	userTokens, err := server.storage.GetMultiple(ctx, filter.Filter{{
+		Attribute: "user_id",
+		Operator:  filter.Equal,
+		Value:     userId,
+	}})
+	if err != nil {
+		return err
+	}
+
+	for _, token := range userTokens {
+		if len(types) > 0 && !slices.Contains(types, token.Type) {
+			continue
+		}
+
+		// Tokens deleted in the meantime are already revoked.
+		if err := server.storage.Delete(ctx, token.Id); err != nil && !errors.Is(err, storage.ErrTokenNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
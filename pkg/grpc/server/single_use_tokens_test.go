@@ -0,0 +1,378 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/events"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/server/servertest"
+	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/storagemocks"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/tokensmocks"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/filter"
+	libmocks "github.com/krixlion/dev_forum-lib/mocks"
+	usermocks "github.com/krixlion/dev_forum-user/pkg/grpc/mocks"
+	userPb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// tokenIssuedEvent returns a matcher for an event carrying given TokenIssued body.
+func tokenIssuedEvent(eType event.EventType, want events.TokenIssued) interface{} {
+	return mock.MatchedBy(func(e event.Event) bool {
+		var got events.TokenIssued
+		if err := json.Unmarshal(e.Body, &got); err != nil {
+			return false
+		}
+		return e.Type == eType && e.AggregateId == event.AuthAggregate && got.UserId == want.UserId &&
+			got.Email == want.Email && got.Token == want.Token && got.ExpiresAt.Equal(want.ExpiresAt)
+	})
+}
+
+func TestAuthServer_RequestPasswordReset(t *testing.T) {
+	secretByEmail := &userPb.GetUserSecretRequest{Query: &userPb.GetUserSecretRequest_Email{Email: "test@example.com"}}
+
+	tests := []struct {
+		name    string
+		deps    servertest.Deps
+		wantErr bool
+	}{
+		{
+			name: "Test if token is issued and published on valid flow",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(0, 0) },
+				UserClient: func() usermocks.UserClient {
+					m := usermocks.NewUserClient()
+					resp := &userPb.GetUserSecretResponse{User: &userPb.User{Id: "test-user", Email: "test@example.com"}}
+					m.On("GetSecret", mock.Anything, secretByEmail, mock.Anything).Return(resp, nil).Once()
+					return m
+				}(),
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("GenerateOpaque", tokens.PasswordResetToken).Return("test-reset", "test-reset-seed", nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Create", mock.Anything, entity.Token{
+						Id:        "test-reset-seed",
+						UserId:    "test-user",
+						Type:      entity.PasswordResetToken,
+						ExpiresAt: time.Unix(0, 0).Add(time.Minute),
+						IssuedAt:  time.Unix(0, 0),
+					}).Return(nil).Once()
+					return m
+				}(),
+				Broker: func() event.Broker {
+					m := libmocks.NewBroker()
+					m.On("ResilientPublish", tokenIssuedEvent(events.PasswordResetRequested, events.TokenIssued{
+						UserId:    "test-user",
+						Email:     "test@example.com",
+						Token:     "test-reset",
+						ExpiresAt: time.Unix(0, 0).Add(time.Minute),
+					})).Return(nil).Once()
+					return m
+				}(),
+			},
+		},
+		{
+			name: "Test if succeeds without issuing a token when user does not exist",
+			deps: servertest.Deps{
+				UserClient: func() usermocks.UserClient {
+					m := usermocks.NewUserClient()
+					m.On("GetSecret", mock.Anything, secretByEmail, mock.Anything).Return(&userPb.GetUserSecretResponse{}, status.Error(codes.NotFound, "not found")).Once()
+					return m
+				}(),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			_, err := client.RequestPasswordReset(ctx, &pb.RequestPasswordResetRequest{Email: "test@example.com"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.RequestPasswordReset() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthServer_ResetPassword(t *testing.T) {
+	resetToken := entity.Token{
+		Id:        "test-reset-seed",
+		UserId:    "test-user",
+		Type:      entity.PasswordResetToken,
+		ExpiresAt: time.Unix(60, 0),
+		IssuedAt:  time.Unix(0, 0),
+	}
+
+	tests := []struct {
+		name    string
+		deps    servertest.Deps
+		wantErr bool
+	}{
+		{
+			name: "Test if password is updated and all user's tokens are revoked on valid flow",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(30, 0) },
+				UserClient: func() usermocks.UserClient {
+					m := usermocks.NewUserClient()
+					req := &userPb.UpdateUserRequest{
+						User:      &userPb.User{Id: "test-user", Password: "new-password"},
+						FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"password"}},
+					}
+					m.On("Update", mock.Anything, mock.MatchedBy(func(r *userPb.UpdateUserRequest) bool {
+						return proto.Equal(r, req)
+					}), mock.Anything).Return(&empty.Empty{}, nil).Once()
+					return m
+				}(),
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.PasswordResetToken, "test-reset").Return(resetToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, resetToken.Id).Return(resetToken, nil).Once()
+					m.On("Delete", mock.Anything, resetToken.Id).Return(nil).Once()
+					m.On("GetMultiple", mock.Anything, filter.Filter{{
+						Attribute: "user_id",
+						Operator:  filter.Equal,
+						Value:     "test-user",
+					}}).Return([]entity.Token{{Id: "refresh-seed"}, {Id: "access-seed"}}, nil).Once()
+					m.On("Delete", mock.Anything, "refresh-seed").Return(nil).Once()
+					m.On("Delete", mock.Anything, "access-seed").Return(nil).Once()
+					return m
+				}(),
+			},
+		},
+		{
+			name: "Test if fails on expired token",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(60, 0) },
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.PasswordResetToken, "test-reset").Return(resetToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, resetToken.Id).Return(resetToken, nil).Once()
+					m.On("Delete", mock.Anything, resetToken.Id).Return(nil).Once()
+					return m
+				}(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on a token of a different type",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(30, 0) },
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.PasswordResetToken, "test-reset").Return(resetToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					tk := resetToken
+					tk.Type = entity.EmailVerificationToken
+					m.On("Get", mock.Anything, resetToken.Id).Return(tk, nil).Once()
+					return m
+				}(),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			_, err := client.ResetPassword(ctx, &pb.ResetPasswordRequest{PasswordResetToken: "test-reset", NewPassword: "new-password"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.ResetPassword() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthServer_RequestEmailVerification(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    servertest.Deps
+		wantErr bool
+	}{
+		{
+			name: "Test if token is issued and published on valid flow",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(0, 0) },
+				UserClient: func() usermocks.UserClient {
+					m := usermocks.NewUserClient()
+					req := &userPb.GetUserSecretRequest{Query: &userPb.GetUserSecretRequest_Id{Id: testRefreshToken.UserId}}
+					resp := &userPb.GetUserSecretResponse{User: &userPb.User{Id: testRefreshToken.UserId, Email: "test@example.com"}}
+					m.On("GetSecret", mock.Anything, req, mock.Anything).Return(resp, nil).Once()
+					return m
+				}(),
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.RefreshToken, "test-refresh").Return(testRefreshToken.Id, nil).Once()
+					m.On("GenerateOpaque", tokens.EmailVerificationToken).Return("test-verification", "test-verification-seed", nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, testRefreshToken.Id).Return(testRefreshToken, nil).Once()
+					m.On("Create", mock.Anything, entity.Token{
+						Id:        "test-verification-seed",
+						UserId:    testRefreshToken.UserId,
+						Type:      entity.EmailVerificationToken,
+						ExpiresAt: time.Unix(0, 0).Add(time.Minute),
+						IssuedAt:  time.Unix(0, 0),
+					}).Return(nil).Once()
+					return m
+				}(),
+				Broker: func() event.Broker {
+					m := libmocks.NewBroker()
+					m.On("ResilientPublish", tokenIssuedEvent(events.EmailVerificationRequested, events.TokenIssued{
+						UserId:    testRefreshToken.UserId,
+						Email:     "test@example.com",
+						Token:     "test-verification",
+						ExpiresAt: time.Unix(0, 0).Add(time.Minute),
+					})).Return(nil).Once()
+					return m
+				}(),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			_, err := client.RequestEmailVerification(ctx, &pb.RequestEmailVerificationRequest{RefreshToken: "test-refresh"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.RequestEmailVerification() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthServer_VerifyEmail(t *testing.T) {
+	verificationToken := entity.Token{
+		Id:        "test-verification-seed",
+		UserId:    "test-user",
+		Type:      entity.EmailVerificationToken,
+		ExpiresAt: time.Unix(60, 0),
+		IssuedAt:  time.Unix(0, 0),
+	}
+
+	tests := []struct {
+		name    string
+		deps    servertest.Deps
+		wantErr bool
+	}{
+		{
+			name: "Test if email verification is published on valid flow",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(30, 0) },
+				UserClient: func() usermocks.UserClient {
+					m := usermocks.NewUserClient()
+					req := &userPb.GetUserSecretRequest{Query: &userPb.GetUserSecretRequest_Id{Id: "test-user"}}
+					resp := &userPb.GetUserSecretResponse{User: &userPb.User{Id: "test-user", Email: "test@example.com"}}
+					m.On("GetSecret", mock.Anything, req, mock.Anything).Return(resp, nil).Once()
+					return m
+				}(),
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.EmailVerificationToken, "test-verification").Return(verificationToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, verificationToken.Id).Return(verificationToken, nil).Once()
+					m.On("Delete", mock.Anything, verificationToken.Id).Return(nil).Once()
+					return m
+				}(),
+				Broker: func() event.Broker {
+					m := libmocks.NewBroker()
+					m.On("ResilientPublish", mock.MatchedBy(func(e event.Event) bool {
+						var body events.UserEmailVerified
+						if err := json.Unmarshal(e.Body, &body); err != nil {
+							return false
+						}
+						return e.Type == events.EmailVerified && body == events.UserEmailVerified{UserId: "test-user", Email: "test@example.com"}
+					})).Return(nil).Once()
+					return m
+				}(),
+			},
+		},
+		{
+			name: "Test if fails when token was already redeemed",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(30, 0) },
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.EmailVerificationToken, "test-verification").Return(verificationToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, verificationToken.Id).Return(entity.Token{}, errors.New("test err")).Once()
+					return m
+				}(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails when token was redeemed concurrently",
+			deps: servertest.Deps{
+				Now: func() time.Time { return time.Unix(30, 0) },
+				TokenManager: func() tokens.Manager {
+					m := tokensmocks.NewTokenManager()
+					m.On("DecodeOpaque", tokens.EmailVerificationToken, "test-verification").Return(verificationToken.Id, nil).Once()
+					return m
+				}(),
+				Storage: func() storage.Storage {
+					m := storagemocks.NewStorage()
+					m.On("Get", mock.Anything, verificationToken.Id).Return(verificationToken, nil).Once()
+					m.On("Delete", mock.Anything, verificationToken.Id).Return(storage.ErrTokenNotFound).Once()
+					return m
+				}(),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client := servertest.NewServer(ctx, tt.deps)
+
+			_, err := client.VerifyEmail(ctx, &pb.VerifyEmailRequest{EmailVerificationToken: "test-verification"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthServer.VerifyEmail() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
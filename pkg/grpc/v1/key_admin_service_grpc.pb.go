@@ -0,0 +1,284 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.22.2
+// source: key_admin_service.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	KeyAdminService_ListKeys_FullMethodName          = "/auth.KeyAdminService/ListKeys"
+	KeyAdminService_RotateNow_FullMethodName         = "/auth.KeyAdminService/RotateNow"
+	KeyAdminService_RevokeKey_FullMethodName         = "/auth.KeyAdminService/RevokeKey"
+	KeyAdminService_ImportKey_FullMethodName         = "/auth.KeyAdminService/ImportKey"
+	KeyAdminService_GetRotationStatus_FullMethodName = "/auth.KeyAdminService/GetRotationStatus"
+)
+
+// KeyAdminServiceClient is the client API for KeyAdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type KeyAdminServiceClient interface {
+	// Returns all stored signing keys without their key material.
+	ListKeys(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListKeysResponse, error)
+	// Replaces all signing keys with a newly generated set.
+	// Tokens signed with previous keys stop being valid.
+	// If another instance holds the rotation lease, the rotation is requested and performed by that instance at its next lease tick.
+	// Fails with FAILED_PRECONDITION if keys are not rotated periodically.
+	RotateNow(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Deletes the key with given kid. If it was the active key another key
+	// of the same algorithm becomes active or a new one is generated.
+	// Fails with FAILED_PRECONDITION when another instance holds the rotation lease.
+	RevokeKey(ctx context.Context, in *RevokeKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Stores an externally generated private key under its RFC 7638 thumbprint.
+	// The key is published right away and used to sign tokens once not_before passes.
+	// Public keys are published for verification only and never used to sign tokens.
+	// Imported keys are not replaced on rotation, use RevokeKey to remove them.
+	ImportKey(ctx context.Context, in *ImportKeyRequest, opts ...grpc.CallOption) (*ImportKeyResponse, error)
+	// Returns when keys were last rotated and which instance rotates them.
+	GetRotationStatus(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetRotationStatusResponse, error)
+}
+
+type keyAdminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewKeyAdminServiceClient(cc grpc.ClientConnInterface) KeyAdminServiceClient {
+	return &keyAdminServiceClient{cc}
+}
+
+func (c *keyAdminServiceClient) ListKeys(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListKeysResponse, error) {
+	out := new(ListKeysResponse)
+	err := c.cc.Invoke(ctx, KeyAdminService_ListKeys_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyAdminServiceClient) RotateNow(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, KeyAdminService_RotateNow_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyAdminServiceClient) RevokeKey(ctx context.Context, in *RevokeKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, KeyAdminService_RevokeKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyAdminServiceClient) ImportKey(ctx context.Context, in *ImportKeyRequest, opts ...grpc.CallOption) (*ImportKeyResponse, error) {
+	out := new(ImportKeyResponse)
+	err := c.cc.Invoke(ctx, KeyAdminService_ImportKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyAdminServiceClient) GetRotationStatus(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetRotationStatusResponse, error) {
+	out := new(GetRotationStatusResponse)
+	err := c.cc.Invoke(ctx, KeyAdminService_GetRotationStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// KeyAdminServiceServer is the server API for KeyAdminService service.
+// All implementations must embed UnimplementedKeyAdminServiceServer
+// for forward compatibility
+type KeyAdminServiceServer interface {
+	// Returns all stored signing keys without their key material.
+	ListKeys(context.Context, *emptypb.Empty) (*ListKeysResponse, error)
+	// Replaces all signing keys with a newly generated set.
+	// Tokens signed with previous keys stop being valid.
+	// If another instance holds the rotation lease, the rotation is requested and performed by that instance at its next lease tick.
+	// Fails with FAILED_PRECONDITION if keys are not rotated periodically.
+	RotateNow(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	// Deletes the key with given kid. If it was the active key another key
+	// of the same algorithm becomes active or a new one is generated.
+	// Fails with FAILED_PRECONDITION when another instance holds the rotation lease.
+	RevokeKey(context.Context, *RevokeKeyRequest) (*emptypb.Empty, error)
+	// Stores an externally generated private key under its RFC 7638 thumbprint.
+	// The key is published right away and used to sign tokens once not_before passes.
+	// Public keys are published for verification only and never used to sign tokens.
+	// Imported keys are not replaced on rotation, use RevokeKey to remove them.
+	ImportKey(context.Context, *ImportKeyRequest) (*ImportKeyResponse, error)
+	// Returns when keys were last rotated and which instance rotates them.
+	GetRotationStatus(context.Context, *emptypb.Empty) (*GetRotationStatusResponse, error)
+	mustEmbedUnimplementedKeyAdminServiceServer()
+}
+
+// UnimplementedKeyAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedKeyAdminServiceServer struct {
+}
+
+func (UnimplementedKeyAdminServiceServer) ListKeys(context.Context, *emptypb.Empty) (*ListKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListKeys not implemented")
+}
+func (UnimplementedKeyAdminServiceServer) RotateNow(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateNow not implemented")
+}
+func (UnimplementedKeyAdminServiceServer) RevokeKey(context.Context, *RevokeKeyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeKey not implemented")
+}
+func (UnimplementedKeyAdminServiceServer) ImportKey(context.Context, *ImportKeyRequest) (*ImportKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportKey not implemented")
+}
+func (UnimplementedKeyAdminServiceServer) GetRotationStatus(context.Context, *emptypb.Empty) (*GetRotationStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRotationStatus not implemented")
+}
+func (UnimplementedKeyAdminServiceServer) mustEmbedUnimplementedKeyAdminServiceServer() {}
+
+// UnsafeKeyAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to KeyAdminServiceServer will
+// result in compilation errors.
+type UnsafeKeyAdminServiceServer interface {
+	mustEmbedUnimplementedKeyAdminServiceServer()
+}
+
+func RegisterKeyAdminServiceServer(s grpc.ServiceRegistrar, srv KeyAdminServiceServer) {
+	s.RegisterService(&KeyAdminService_ServiceDesc, srv)
+}
+
+func _KeyAdminService_ListKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyAdminServiceServer).ListKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyAdminService_ListKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyAdminServiceServer).ListKeys(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyAdminService_RotateNow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyAdminServiceServer).RotateNow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyAdminService_RotateNow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyAdminServiceServer).RotateNow(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyAdminService_RevokeKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyAdminServiceServer).RevokeKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyAdminService_RevokeKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyAdminServiceServer).RevokeKey(ctx, req.(*RevokeKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyAdminService_ImportKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyAdminServiceServer).ImportKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyAdminService_ImportKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyAdminServiceServer).ImportKey(ctx, req.(*ImportKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyAdminService_GetRotationStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyAdminServiceServer).GetRotationStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyAdminService_GetRotationStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyAdminServiceServer).GetRotationStatus(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// KeyAdminService_ServiceDesc is the grpc.ServiceDesc for KeyAdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var KeyAdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.KeyAdminService",
+	HandlerType: (*KeyAdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListKeys",
+			Handler:    _KeyAdminService_ListKeys_Handler,
+		},
+		{
+			MethodName: "RotateNow",
+			Handler:    _KeyAdminService_RotateNow_Handler,
+		},
+		{
+			MethodName: "RevokeKey",
+			Handler:    _KeyAdminService_RevokeKey_Handler,
+		},
+		{
+			MethodName: "ImportKey",
+			Handler:    _KeyAdminService_ImportKey_Handler,
+		},
+		{
+			MethodName: "GetRotationStatus",
+			Handler:    _KeyAdminService_GetRotationStatus_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "key_admin_service.proto",
+}
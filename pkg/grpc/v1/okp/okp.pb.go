@@ -0,0 +1,209 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.33.0
+// 	protoc        v4.22.2
+// source: okp.proto
+
+package okppb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type OKPType int32
+
+const (
+	OKPType_OKP_UNDEFINED OKPType = 0
+	OKPType_Ed25519       OKPType = 1
+)
+
+// Enum value maps for OKPType.
+var (
+	OKPType_name = map[int32]string{
+		0: "OKP_UNDEFINED",
+		1: "Ed25519",
+	}
+	OKPType_value = map[string]int32{
+		"OKP_UNDEFINED": 0,
+		"Ed25519":       1,
+	}
+)
+
+func (x OKPType) Enum() *OKPType {
+	p := new(OKPType)
+	*p = x
+	return p
+}
+
+func (x OKPType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OKPType) Descriptor() protoreflect.EnumDescriptor {
+	return file_okp_proto_enumTypes[0].Descriptor()
+}
+
+func (OKPType) Type() protoreflect.EnumType {
+	return &file_okp_proto_enumTypes[0]
+}
+
+func (x OKPType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OKPType.Descriptor instead.
+func (OKPType) EnumDescriptor() ([]byte, []int) {
+	return file_okp_proto_rawDescGZIP(), []int{0}
+}
+
+// OKP (Octet Key Pair) Public Key message type as described in RFC 8037.
+// X is the public key Base64Url-encoded with no padding.
+type OKP struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Curve.
+	Crv OKPType `protobuf:"varint,1,opt,name=crv,proto3,enum=auth.OKPType" json:"crv,omitempty"`
+	X   string  `protobuf:"bytes,2,opt,name=x,proto3" json:"x,omitempty"`
+}
+
+func (x *OKP) Reset() {
+	*x = OKP{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_okp_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OKP) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OKP) ProtoMessage() {}
+
+func (x *OKP) ProtoReflect() protoreflect.Message {
+	mi := &file_okp_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OKP.ProtoReflect.Descriptor instead.
+func (*OKP) Descriptor() ([]byte, []int) {
+	return file_okp_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *OKP) GetCrv() OKPType {
+	if x != nil {
+		return x.Crv
+	}
+	return OKPType_OKP_UNDEFINED
+}
+
+func (x *OKP) GetX() string {
+	if x != nil {
+		return x.X
+	}
+	return ""
+}
+
+var File_okp_proto protoreflect.FileDescriptor
+
+var file_okp_proto_rawDesc = []byte{
+	0x0a, 0x09, 0x6f, 0x6b, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x04, 0x61, 0x75, 0x74,
+	0x68, 0x22, 0x34, 0x0a, 0x03, 0x4f, 0x4b, 0x50, 0x12, 0x1f, 0x0a, 0x03, 0x63, 0x72, 0x76, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0d, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x2e, 0x4f, 0x4b, 0x50,
+	0x54, 0x79, 0x70, 0x65, 0x52, 0x03, 0x63, 0x72, 0x76, 0x12, 0x0c, 0x0a, 0x01, 0x78, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x01, 0x78, 0x2a, 0x29, 0x0a, 0x07, 0x4f, 0x4b, 0x50, 0x54, 0x79,
+	0x70, 0x65, 0x12, 0x11, 0x0a, 0x0d, 0x4f, 0x4b, 0x50, 0x5f, 0x55, 0x4e, 0x44, 0x45, 0x46, 0x49,
+	0x4e, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x45, 0x64, 0x32, 0x35, 0x35, 0x31, 0x39,
+	0x10, 0x01, 0x42, 0x3a, 0x5a, 0x38, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x6b, 0x72, 0x69, 0x78, 0x6c, 0x69, 0x6f, 0x6e, 0x2f, 0x64, 0x65, 0x76, 0x5f, 0x66, 0x6f,
+	0x72, 0x75, 0x6d, 0x2d, 0x61, 0x75, 0x74, 0x68, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x67, 0x72, 0x70,
+	0x63, 0x2f, 0x76, 0x31, 0x2f, 0x6f, 0x6b, 0x70, 0x3b, 0x6f, 0x6b, 0x70, 0x70, 0x62, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_okp_proto_rawDescOnce sync.Once
+	file_okp_proto_rawDescData = file_okp_proto_rawDesc
+)
+
+func file_okp_proto_rawDescGZIP() []byte {
+	file_okp_proto_rawDescOnce.Do(func() {
+		file_okp_proto_rawDescData = protoimpl.X.CompressGZIP(file_okp_proto_rawDescData)
+	})
+	return file_okp_proto_rawDescData
+}
+
+var file_okp_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_okp_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_okp_proto_goTypes = []interface{}{
+	(OKPType)(0), // 0: auth.OKPType
+	(*OKP)(nil),  // 1: auth.OKP
+}
+var file_okp_proto_depIdxs = []int32{
+	0, // 0: auth.OKP.crv:type_name -> auth.OKPType
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_okp_proto_init() }
+func file_okp_proto_init() {
+	if File_okp_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_okp_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OKP); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_okp_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_okp_proto_goTypes,
+		DependencyIndexes: file_okp_proto_depIdxs,
+		EnumInfos:         file_okp_proto_enumTypes,
+		MessageInfos:      file_okp_proto_msgTypes,
+	}.Build()
+	File_okp_proto = out.File
+	file_okp_proto_rawDesc = nil
+	file_okp_proto_goTypes = nil
+	file_okp_proto_depIdxs = nil
+}
@@ -21,7 +21,8 @@ const (
 )
 
 // RSA Public Key message type.
-// All fields are positive big endian integers Base64Url-encoded with no padding.
+// All fields are positive big endian integers Base64Url-encoded with no padding,
+// using the minimum number of octets as required by RFC 7518, e.g. "AQAB" for 65537.
 type RSA struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -0,0 +1,149 @@
+// Package jwks serves the validation keyset over HTTP as a JWK Set
+// as described in RFC 7517, for clients which cannot use gRPC.
+package jwks
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/lestrrat-go/jwx/jwk"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Path is the conventional path the keyset is served on.
+const Path = "/.well-known/jwks.json"
+
+// ContentType is the media type of a JWK Set.
+const ContentType = "application/jwk-set+json"
+
+// Handler serves the keyset returned by storage.Vault.GetKeySet.
+type Handler struct {
+	vault  storage.Vault
+	tracer trace.Tracer
+	logger logging.Logger
+}
+
+// NewHandler returns a Handler serving keys from given vault.
+// Tracing and logging are disabled if no tracer or logger is provided.
+func NewHandler(vault storage.Vault, tracer trace.Tracer, logger logging.Logger) Handler {
+	if tracer == nil {
+		tracer = nulls.NullTracer{}
+	}
+
+	if logger == nil {
+		logger = nulls.NullLogger{}
+	}
+
+	return Handler{
+		vault:  vault,
+		tracer: tracer,
+		logger: logger,
+	}
+}
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	encoded, err := h.encodedKeySet(r.Context())
+	if err != nil {
+		h.logger.Log(r.Context(), "failed to serve keyset", "transport", "http", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodGet {
+		w.Write(encoded)
+	}
+}
+
+func (h Handler) encodedKeySet(ctx context.Context) (_ []byte, err error) {
+	ctx, span := h.tracer.Start(ctx, "jwks.ServeHTTP")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	keys, err := h.vault.GetKeySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keySet, err := KeySet(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(keySet)
+}
+
+// KeySet returns given keys' public parts as a JWK Set.
+// Keys' ids have to be their thumbprints, otherwise protokey.ErrThumbprintMismatch is returned.
+// Symmetric keys are skipped.
+func KeySet(keys []entity.Key) (jwk.Set, error) {
+	keySet := jwk.NewSet()
+
+	for _, key := range keys {
+		if key.Type == entity.HMAC {
+			continue
+		}
+
+		encoded, err := key.Encode()
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey, err := protokey.DeserializeKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := protokey.VerifyThumbprint(key.Id, publicKey); err != nil {
+			return nil, err
+		}
+
+		jwKey, err := jwk.New(publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		headers := map[string]interface{}{
+			jwk.KeyIDKey:     key.Id,
+			jwk.AlgorithmKey: string(key.Algorithm),
+			jwk.KeyUsageKey:  string(jwk.ForSignature),
+		}
+
+		if len(key.Certificates) > 0 {
+			chain := make([]string, 0, len(key.Certificates))
+			for _, certificate := range key.Certificates {
+				chain = append(chain, base64.StdEncoding.EncodeToString(certificate.Raw))
+			}
+
+			headers[jwk.X509CertChainKey] = chain
+			headers[jwk.X509CertThumbprintS256Key] = jwkutil.CertificateThumbprint(key.Certificates[0])
+		}
+
+		for name, value := range headers {
+			if err := jwKey.Set(name, value); err != nil {
+				return nil, err
+			}
+		}
+
+		keySet.Add(jwKey)
+	}
+
+	return keySet, nil
+}
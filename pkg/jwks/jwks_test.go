@@ -0,0 +1,128 @@
+package jwks_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/jwks"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/storagemocks"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vault"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	ca, err := vault.NewCA(testdata.CA.CertPem, testdata.CA.PrivPem, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make ca: %v", err)
+	}
+	certificates, err := ca.Issue(context.Background(), testdata.ECDSA.Id, testdata.ECDSA.PubKey)
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	ecdsaKey, err := entity.NewKey(testdata.ECDSA.Id, entity.ECDSA, entity.ES256, testdata.ECDSA.PrivKey, protokey.SerializeECDSA)
+	if err != nil {
+		t.Fatalf("Failed to make ecdsa key: %v", err)
+	}
+	ecdsaKey.Certificates = certificates
+
+	ed25519Key, err := entity.NewVerificationKey(testdata.Ed25519.Id, entity.OKP, entity.EdDSA, testdata.Ed25519.PubKey, protokey.SerializeEd25519)
+	if err != nil {
+		t.Fatalf("Failed to make ed25519 key: %v", err)
+	}
+
+	mismatchedKey, err := entity.NewVerificationKey(testdata.ECDSA.Id, entity.OKP, entity.EdDSA, testdata.Ed25519.PubKey, protokey.SerializeEd25519)
+	if err != nil {
+		t.Fatalf("Failed to make ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		keys     []entity.Key
+		keysErr  error
+		wantCode int
+		// wantX5c maps expected kids to the expected lengths of their certificate chains.
+		wantX5c map[string]int
+	}{
+		{
+			name:     "Test if serves public keys with their certificate chains",
+			method:   http.MethodGet,
+			keys:     []entity.Key{ecdsaKey, ed25519Key, entity.NewSymmetricKey("hmac", entity.HS256, []byte("secret"))},
+			wantCode: http.StatusOK,
+			wantX5c:  map[string]int{testdata.ECDSA.Id: 2, testdata.Ed25519.Id: 0},
+		},
+		{
+			name:     "Test if fails on a key whose kid is not its thumbprint",
+			method:   http.MethodGet,
+			keys:     []entity.Key{mismatchedKey},
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "Test if fails when keys cannot be fetched",
+			method:   http.MethodGet,
+			keysErr:  errors.New("test err"),
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "Test if rejects other methods",
+			method:   http.MethodPost,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := storagemocks.NewVault()
+			m.On("GetKeySet", mock.Anything).Return(tt.keys, tt.keysErr).Once()
+
+			recorder := httptest.NewRecorder()
+			jwks.NewHandler(m, nulls.NullTracer{}, nulls.NullLogger{}).ServeHTTP(recorder, httptest.NewRequest(tt.method, jwks.Path, nil))
+
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("Handler.ServeHTTP() code = %d, want %d", recorder.Code, tt.wantCode)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			if got := recorder.Header().Get("Content-Type"); got != jwks.ContentType {
+				t.Errorf("Handler.ServeHTTP() Content-Type = %s, want %s", got, jwks.ContentType)
+			}
+
+			keySet, err := jwk.Parse(recorder.Body.Bytes())
+			if err != nil {
+				t.Fatalf("Failed to parse served keyset: %v", err)
+			}
+
+			if keySet.Len() != len(tt.wantX5c) {
+				t.Errorf("Handler.ServeHTTP() served %d keys, want %d", keySet.Len(), len(tt.wantX5c))
+			}
+
+			for kid, wantX5c := range tt.wantX5c {
+				key, ok := keySet.LookupKeyID(kid)
+				if !ok {
+					t.Errorf("Handler.ServeHTTP() did not serve key %s", kid)
+					continue
+				}
+
+				if key.KeyUsage() != string(jwk.ForSignature) {
+					t.Errorf("Handler.ServeHTTP() use = %s, want %s", key.KeyUsage(), jwk.ForSignature)
+				}
+
+				if x5c := key.X509CertChain(); len(x5c) != wantX5c {
+					t.Errorf("Handler.ServeHTTP() x5c length = %d, want %d", len(x5c), wantX5c)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+// Package jwkutil computes thumbprints identifying keys and certificates
+// published in JWKs and bound to tokens.
+// It doesn't depend on other packages of the service so any of them can use it.
+package jwkutil
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// Thumbprint returns a Base64URL encoded RFC 7638 SHA-256 thumbprint of given key.
+func Thumbprint(key jwk.Key) (string, error) {
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// CertificateThumbprint returns a Base64URL encoded SHA-256 thumbprint
+// of given certificate as described in RFC 8705.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"net"
+	"net/http"
+	"os"
 
 	"fmt"
 
@@ -16,6 +19,7 @@ import (
 type AuthService struct {
 	grpcPort   int
 	grpcServer *grpc.Server
+	httpServer *http.Server
 
 	broker     event.Broker
 	dispatcher *dispatcher.Dispatcher
@@ -24,9 +28,11 @@ type AuthService struct {
 }
 
 type Dependencies struct {
-	Logger       logging.Logger
-	Broker       event.Broker
-	GRPCServer   *grpc.Server
+	Logger     logging.Logger
+	Broker     event.Broker
+	GRPCServer *grpc.Server
+	// HTTPServer is optional. It's started along with the gRPC server if not nil.
+	HTTPServer   *http.Server
 	Storage      storage.Storage
 	Dispatcher   *dispatcher.Dispatcher
 	ShutdownFunc func() error
@@ -37,6 +43,7 @@ func NewAuthService(grpcPort int, d Dependencies) *AuthService {
 		grpcPort:   grpcPort,
 		dispatcher: d.Dispatcher,
 		grpcServer: d.GRPCServer,
+		httpServer: d.HTTPServer,
 		broker:     d.Broker,
 		logger:     d.Logger,
 		shutdown:   d.ShutdownFunc,
@@ -65,6 +72,10 @@ func (s *AuthService) Run(ctx context.Context) {
 	s.dispatcher.AddEventProviders(providers...)
 	go s.dispatcher.Run(ctx)
 
+	if s.httpServer != nil {
+		go s.serveHTTP(ctx)
+	}
+
 	s.logger.Log(ctx, "listening", "transport", "grpc", "port", s.grpcPort)
 
 	if err := s.grpcServer.Serve(lis); err != nil {
@@ -72,9 +83,32 @@ func (s *AuthService) Run(ctx context.Context) {
 	}
 }
 
+// serveHTTP serves HTTP over TLS if the server has a TLS config.
+func (s *AuthService) serveHTTP(ctx context.Context) {
+	s.logger.Log(ctx, "listening", "transport", "http", "addr", s.httpServer.Addr)
+
+	var err error
+	if s.httpServer.TLSConfig != nil {
+		err = s.httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.logger.Log(ctx, "failed to serve", "transport", "http", "err", err)
+	}
+}
+
 func (s *AuthService) eventProviders(ctx context.Context) ([]<-chan event.Event, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
 	eTypes := map[string]event.EventType{
 		"DeleteStaleTokens": event.UserDeleted,
+		// Every instance caches keys so each one consumes from its own queue.
+		"ReloadKeys-" + hostname: event.KeySetUpdated,
 	}
 
 	chans := make([]<-chan event.Event, 0, len(eTypes))
@@ -0,0 +1,475 @@
+// Package filestore implements keystore.Store keeping keys as PEM files in a directory.
+// It's meant for local development and small deployments which don't run a Vault server.
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keycrypt"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+)
+
+const (
+	keysDir      = "keys"
+	keyExt       = ".pem"
+	saltFile     = "salt"
+	leaseFile    = "lease.json"
+	lockFile     = "lease.lock"
+	scheduleFile = "schedule.json"
+
+	// encryption is the value of the Encryption header of encrypted keys.
+	encryption = "scrypt-aes-256-gcm"
+
+	// staleLockAge is the age after which a lock left by a crashed instance is removed.
+	staleLockAge = time.Second * 10
+)
+
+var (
+	ErrInvalidKeyId = errors.New("invalid key id")
+	ErrEncrypted    = errors.New("key is encrypted but no passphrase was provided")
+)
+
+var _ keystore.Store = (*Store)(nil)
+
+// Store keeps each key in a separate PEM file with the key's metadata
+// in PEM headers. Files can be shared by instances through a common volume.
+type Store struct {
+	dir string
+	// nil if keys are not encrypted.
+	sealer *keycrypt.Sealer
+	// mu serializes lease writes within the process.
+	// The lock file serializes them across processes.
+	mu sync.Mutex
+}
+
+type Config struct {
+	// Dir is created if it does not exist.
+	Dir string
+	// Keys are encrypted with a key derived from Passphrase if it's not empty.
+	Passphrase string
+}
+
+// Make returns a Store keeping keys in config.Dir or a non nil error.
+func Make(config Config) (*Store, error) {
+	if config.Dir == "" {
+		return nil, errors.New("key store directory cannot be empty")
+	}
+
+	if err := os.MkdirAll(filepath.Join(config.Dir, keysDir), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create key store directory: %w", err)
+	}
+
+	store := &Store{dir: config.Dir}
+
+	if config.Passphrase == "" {
+		return store, nil
+	}
+
+	salt, err := store.salt()
+	if err != nil {
+		return nil, err
+	}
+
+	sealer, err := keycrypt.NewSealer(config.Passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	store.sealer = &sealer
+
+	return store, nil
+}
+
+// salt returns the salt used to derive the encryption key, creating it on first use.
+func (s *Store) salt() ([]byte, error) {
+	path := filepath.Join(s.dir, saltFile)
+
+	salt, err := os.ReadFile(path)
+	if err == nil {
+		return salt, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read salt: %w", err)
+	}
+
+	salt, err = keycrypt.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := path + ".tmp-" + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(tmp, salt, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write salt: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	// Unlike rename, link fails if the salt already exists.
+	if err := os.Link(tmp, path); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			// Another instance created it in the meantime.
+			return os.ReadFile(path)
+		}
+		return nil, fmt.Errorf("failed to create salt: %w", err)
+	}
+
+	return salt, nil
+}
+
+func (s *Store) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, keysDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), keyExt) {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(entry.Name(), keyExt))
+	}
+
+	return ids, nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (keystore.StoredKey, error) {
+	path, err := s.keyPath(id)
+	if err != nil {
+		return keystore.StoredKey{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return keystore.StoredKey{}, fmt.Errorf("%w: %s", storage.ErrKeyNotFound, id)
+	}
+	if err != nil {
+		return keystore.StoredKey{}, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	return s.decode(id, data)
+}
+
+// Put writes the key to a temporary file and renames it, so that readers
+// never see a partially written key.
+func (s *Store) Put(ctx context.Context, id string, key keystore.StoredKey) error {
+	path, err := s.keyPath(id)
+	if err != nil {
+		return err
+	}
+
+	key.CreatedAt = time.Now()
+
+	// Keep the creation time of replaced keys.
+	if existing, err := s.Get(ctx, id); err == nil {
+		key.CreatedAt = existing.CreatedAt
+	}
+
+	data, err := s.encode(id, key)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, data)
+}
+
+// Delete removes the key. Deleting a missing key is not an error.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	path, err := s.keyPath(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+
+	return nil
+}
+
+// leaseDocument is the JSON encoded content of the lease file.
+type leaseDocument struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Version   int       `json:"version"`
+}
+
+func (s *Store) GetLease(ctx context.Context) (lease.Lease, error) {
+	doc := leaseDocument{}
+	if err := readJSON(filepath.Join(s.dir, leaseFile), &doc); err != nil {
+		return lease.Lease{}, fmt.Errorf("failed to get lease: %w", err)
+	}
+
+	return lease.Lease{
+		Holder:    doc.Holder,
+		ExpiresAt: doc.ExpiresAt,
+		Version:   doc.Version,
+	}, nil
+}
+
+// PutLease writes the lease while holding a lock file so that
+// concurrent writers sharing the directory cannot both succeed.
+func (s *Store) PutLease(ctx context.Context, l lease.Lease) (lease.Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock(ctx)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	defer unlock()
+
+	current, err := s.GetLease(ctx)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+
+	if current.Version != l.Version {
+		return lease.Lease{}, lease.ErrConflict
+	}
+
+	l.Version++
+
+	doc := leaseDocument{
+		Holder:    l.Holder,
+		ExpiresAt: l.ExpiresAt,
+		Version:   l.Version,
+	}
+
+	if err := writeJSON(filepath.Join(s.dir, leaseFile), doc); err != nil {
+		return lease.Lease{}, fmt.Errorf("failed to put lease: %w", err)
+	}
+
+	return l, nil
+}
+
+// scheduleDocument is the JSON encoded content of the schedule file.
+type scheduleDocument struct {
+	LastRotatedAt  time.Time `json:"last_rotated_at"`
+	NextRotationAt time.Time `json:"next_rotation_at"`
+	// Omitted by older versions.
+	RotationRequestedAt time.Time `json:"rotation_requested_at"`
+}
+
+func (s *Store) GetSchedule(ctx context.Context) (keystore.Schedule, error) {
+	doc := scheduleDocument{}
+	if err := readJSON(filepath.Join(s.dir, scheduleFile), &doc); err != nil {
+		return keystore.Schedule{}, fmt.Errorf("failed to get rotation schedule: %w", err)
+	}
+
+	return keystore.Schedule{
+		LastRotatedAt:       doc.LastRotatedAt,
+		NextRotationAt:      doc.NextRotationAt,
+		RotationRequestedAt: doc.RotationRequestedAt,
+	}, nil
+}
+
+func (s *Store) PutSchedule(ctx context.Context, schedule keystore.Schedule) error {
+	doc := scheduleDocument{
+		LastRotatedAt:       schedule.LastRotatedAt,
+		NextRotationAt:      schedule.NextRotationAt,
+		RotationRequestedAt: schedule.RotationRequestedAt,
+	}
+
+	if err := writeJSON(filepath.Join(s.dir, scheduleFile), doc); err != nil {
+		return fmt.Errorf("failed to put rotation schedule: %w", err)
+	}
+
+	return nil
+}
+
+// keyPath returns the path of the key's file.
+// Ids which could point outside of the keys directory are rejected.
+func (s *Store) keyPath(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKeyId, id)
+	}
+
+	return filepath.Join(s.dir, keysDir, id+keyExt), nil
+}
+
+// encode returns given key as a PEM block with its metadata in headers,
+// followed by the key's certificates if any.
+// The block's content is encrypted if the store has a passphrase.
+func (s *Store) encode(id string, key keystore.StoredKey) ([]byte, error) {
+	block, _ := pem.Decode([]byte(key.EncodedKey))
+	if block == nil {
+		return nil, keystore.ErrInvalidKeyFormat
+	}
+
+	block.Headers = map[string]string{
+		"Algorithm":  string(key.Algorithm),
+		"Key-Type":   string(key.KeyType),
+		"Active":     strconv.FormatBool(key.Active),
+		"Created-At": key.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if key.Imported {
+		block.Headers["Imported"] = "true"
+		block.Headers["Not-Before"] = key.NotBefore.UTC().Format(time.RFC3339Nano)
+	}
+
+	if !key.RetiredAt.IsZero() {
+		block.Headers["Retired-At"] = key.RetiredAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	if s.sealer != nil {
+		sealed, err := s.sealer.Seal(block.Bytes, additionalData(id, block.Type))
+		if err != nil {
+			return nil, err
+		}
+
+		block.Bytes = sealed
+		block.Headers["Encryption"] = encryption
+	}
+
+	return append(pem.EncodeToMemory(block), key.Certificates...), nil
+}
+
+// decode parses PEM blocks returned by encode.
+func (s *Store) decode(id string, data []byte) (keystore.StoredKey, error) {
+	block, rest := pem.Decode(data)
+	if block == nil {
+		return keystore.StoredKey{}, fmt.Errorf("%w: %s", keystore.ErrInvalidKeyFormat, id)
+	}
+
+	key := keystore.StoredKey{
+		Algorithm: entity.Algorithm(block.Headers["Algorithm"]),
+		KeyType:   entity.KeyType(block.Headers["Key-Type"]),
+		Active:    block.Headers["Active"] == "true",
+		Imported:  block.Headers["Imported"] == "true",
+		// Certificates are public so they're never encrypted.
+		Certificates: string(rest),
+	}
+
+	if encoded := block.Headers["Created-At"]; encoded != "" {
+		createdAt, err := time.Parse(time.RFC3339Nano, encoded)
+		if err != nil {
+			return keystore.StoredKey{}, fmt.Errorf("failed to parse key's creation time: %w", err)
+		}
+		key.CreatedAt = createdAt
+	}
+
+	if encoded := block.Headers["Not-Before"]; encoded != "" {
+		notBefore, err := time.Parse(time.RFC3339Nano, encoded)
+		if err != nil {
+			return keystore.StoredKey{}, fmt.Errorf("failed to parse key's not before time: %w", err)
+		}
+		key.NotBefore = notBefore
+	}
+
+	if encoded := block.Headers["Retired-At"]; encoded != "" {
+		retiredAt, err := time.Parse(time.RFC3339Nano, encoded)
+		if err != nil {
+			return keystore.StoredKey{}, fmt.Errorf("failed to parse key's retirement time: %w", err)
+		}
+		key.RetiredAt = retiredAt
+	}
+
+	switch block.Headers["Encryption"] {
+	case "":
+	case encryption:
+		if s.sealer == nil {
+			return keystore.StoredKey{}, fmt.Errorf("%w: %s", ErrEncrypted, id)
+		}
+
+		opened, err := s.sealer.Open(block.Bytes, additionalData(id, block.Type))
+		if err != nil {
+			return keystore.StoredKey{}, fmt.Errorf("failed to decrypt key %s: %w", id, err)
+		}
+		block.Bytes = opened
+	default:
+		return keystore.StoredKey{}, fmt.Errorf("unsupported key encryption %q", block.Headers["Encryption"])
+	}
+
+	key.EncodedKey = string(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes}))
+
+	return key, nil
+}
+
+// additionalData binds encrypted keys to their ids and PEM types.
+func additionalData(id, blockType string) []byte {
+	return []byte(id + "\x00" + blockType)
+}
+
+// lock creates the lock file and returns a func removing it.
+// It waits until the lock is released or the context is cancelled.
+func (s *Store) lock(ctx context.Context) (func(), error) {
+	path := filepath.Join(s.dir, lockFile)
+
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to lock lease: %w", err)
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+
+		select {
+		case <-time.After(time.Millisecond * 10):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// readJSON decodes the file into dst. dst is left untouched if the file does not exist.
+func readJSON(path string, dst interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, dst)
+}
+
+func writeJSON(path string, src interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, data)
+}
+
+// writeFile atomically replaces the file's content.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,308 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/filestore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keycrypt"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vault"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/stretchr/testify/mock"
+)
+
+var testKeyPolicy = []vault.KeyPolicy{
+	{Algorithm: entity.ES256, Count: 3},
+	{Algorithm: entity.EdDSA, Count: 2},
+}
+
+func makeStore(t *testing.T, dir, passphrase string) *filestore.Store {
+	store, err := filestore.Make(filestore.Config{Dir: dir, Passphrase: passphrase})
+	if err != nil {
+		t.Fatalf("filestore.Make() error = %v", err)
+	}
+	return store
+}
+
+func makeVault(t *testing.T, store keystore.Store) vault.Vault {
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.Anything).Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	db, err := vault.MakeWithStore(ctx, store, vault.Config{KeyPolicy: testKeyPolicy}, broker, nulls.NullTracer{}, nulls.NullLogger{})
+	if err != nil {
+		t.Fatalf("vault.MakeWithStore() error = %v", err)
+	}
+	return db
+}
+
+func TestStore_keys(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+		imported   bool
+		certified  bool
+	}{
+		{
+			name: "Test if keys are stored in plain PEM files",
+		},
+		{
+			name:       "Test if keys are stored in encrypted PEM files",
+			passphrase: "passphrase",
+		},
+		{
+			name:       "Test if imported keys keep their not before time",
+			passphrase: "passphrase",
+			imported:   true,
+		},
+		{
+			name:       "Test if certificates are stored along with encrypted keys",
+			passphrase: "passphrase",
+			certified:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancel()
+
+			dir := t.TempDir()
+			store := makeStore(t, dir, tt.passphrase)
+
+			want := keystore.StoredKey{
+				Algorithm:  entity.ES256,
+				KeyType:    entity.ECDSA,
+				EncodedKey: testdata.ECDSA.PrivPem,
+				Active:     true,
+			}
+
+			if tt.imported {
+				want.Imported = true
+				want.NotBefore = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+			}
+
+			if tt.certified {
+				want.Certificates = testdata.CA.CertPem + testdata.CA.CertPem
+			}
+
+			if err := store.Put(ctx, "kid", want); err != nil {
+				t.Fatalf("Store.Put() error = %v", err)
+			}
+
+			got, err := store.Get(ctx, "kid")
+			if err != nil {
+				t.Fatalf("Store.Get() error = %v", err)
+			}
+
+			if got.CreatedAt.IsZero() {
+				t.Errorf("Store.Get() returned a key without creation time")
+			}
+
+			// PEM encoding always ends with a new line.
+			got.CreatedAt = time.Time{}
+			got.EncodedKey = strings.TrimSpace(got.EncodedKey)
+			if got != want {
+				t.Errorf("Store.Get():\n got = %+v\n want = %+v", got, want)
+			}
+
+			file, err := os.ReadFile(filepath.Join(dir, "keys", "kid.pem"))
+			if err != nil {
+				t.Fatalf("Failed to read key file: %v", err)
+			}
+
+			encrypted := !strings.Contains(string(file), strings.Split(strings.TrimSpace(testdata.ECDSA.PrivPem), "\n")[1])
+			if encrypted != (tt.passphrase != "") {
+				t.Errorf("Key file encrypted = %v, want %v", encrypted, tt.passphrase != "")
+			}
+
+			ids, err := store.List(ctx)
+			if err != nil {
+				t.Fatalf("Store.List() error = %v", err)
+			}
+
+			if len(ids) != 1 || ids[0] != "kid" {
+				t.Errorf("Store.List() = %v, want [kid]", ids)
+			}
+
+			if err := store.Delete(ctx, "kid"); err != nil {
+				t.Fatalf("Store.Delete() error = %v", err)
+			}
+
+			if _, err := store.Get(ctx, "kid"); !errors.Is(err, storage.ErrKeyNotFound) {
+				t.Errorf("Store.Get() after delete error = %v, want %v", err, storage.ErrKeyNotFound)
+			}
+		})
+	}
+}
+
+func TestStore_passphrase(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	dir := t.TempDir()
+
+	key := keystore.StoredKey{
+		Algorithm:  entity.EdDSA,
+		KeyType:    entity.OKP,
+		EncodedKey: testdata.Ed25519.PrivPem,
+	}
+
+	if err := makeStore(t, dir, "passphrase").Put(ctx, "kid", key); err != nil {
+		t.Fatalf("Store.Put() error = %v", err)
+	}
+
+	if _, err := makeStore(t, dir, "passphrase").Get(ctx, "kid"); err != nil {
+		t.Errorf("Store.Get() with the same passphrase error = %v", err)
+	}
+
+	if _, err := makeStore(t, dir, "wrong").Get(ctx, "kid"); !errors.Is(err, keycrypt.ErrDecryptionFailed) {
+		t.Errorf("Store.Get() with wrong passphrase error = %v, want %v", err, keycrypt.ErrDecryptionFailed)
+	}
+
+	if _, err := makeStore(t, dir, "").Get(ctx, "kid"); !errors.Is(err, filestore.ErrEncrypted) {
+		t.Errorf("Store.Get() without passphrase error = %v, want %v", err, filestore.ErrEncrypted)
+	}
+}
+
+func TestStore_keyPath(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	store := makeStore(t, t.TempDir(), "")
+
+	for _, id := range []string{"", "..", "../kid", "keys/kid"} {
+		if err := store.Put(ctx, id, keystore.StoredKey{EncodedKey: testdata.ECDSA.PrivPem}); !errors.Is(err, filestore.ErrInvalidKeyId) {
+			t.Errorf("Store.Put(%q) error = %v, want %v", id, err, filestore.ErrInvalidKeyId)
+		}
+	}
+}
+
+func TestStore_PutLease(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	dir := t.TempDir()
+
+	// Separate stores sharing a directory behave like separate processes.
+	stores := make([]*filestore.Store, 5)
+	for i := range stores {
+		stores[i] = makeStore(t, dir, "")
+	}
+
+	var wg sync.WaitGroup
+	acquired := make([]bool, len(stores))
+
+	for i, store := range stores {
+		wg.Add(1)
+		go func(i int, store *filestore.Store) {
+			defer wg.Done()
+
+			_, err := store.PutLease(ctx, lease.Lease{Holder: "instance", ExpiresAt: time.Now().Add(time.Minute)})
+			if err != nil && !errors.Is(err, lease.ErrConflict) {
+				t.Errorf("Store.PutLease() error = %v", err)
+			}
+			acquired[i] = err == nil
+		}(i, store)
+	}
+	wg.Wait()
+
+	count := 0
+	for _, ok := range acquired {
+		if ok {
+			count++
+		}
+	}
+
+	if count != 1 {
+		t.Errorf("Store.PutLease() succeeded %d times, want 1", count)
+	}
+
+	got, err := stores[0].GetLease(ctx)
+	if err != nil {
+		t.Fatalf("Store.GetLease() error = %v", err)
+	}
+
+	if got.Version != 1 || got.Holder != "instance" {
+		t.Errorf("Store.GetLease() = %+v, want version 1 held by instance", got)
+	}
+}
+
+func TestStore_rotation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	dir := t.TempDir()
+	store := makeStore(t, dir, "passphrase")
+	db := makeVault(t, store)
+
+	if err := db.RotateNow(ctx); err != nil {
+		t.Fatalf("Vault.RotateNow() error = %v", err)
+	}
+
+	active, err := db.GetActive(ctx, entity.ES256)
+	if err != nil {
+		t.Fatalf("Vault.GetActive() error = %v", err)
+	}
+
+	// A restarted instance reads the same keys and schedule.
+	restarted := makeVault(t, makeStore(t, dir, "passphrase"))
+
+	got, err := restarted.GetActive(ctx, entity.ES256)
+	if err != nil {
+		t.Fatalf("Vault.GetActive() error = %v", err)
+	}
+
+	if got.Id != active.Id {
+		t.Errorf("Vault.GetActive() after restart = %s, want %s", got.Id, active.Id)
+	}
+
+	keys, err := restarted.GetKeySet(ctx)
+	if err != nil {
+		t.Fatalf("Vault.GetKeySet() error = %v", err)
+	}
+
+	if want := 5; len(keys) != want {
+		t.Errorf("Vault.GetKeySet() returned %d keys, want %d", len(keys), want)
+	}
+
+	status, err := restarted.GetRotationStatus(ctx)
+	if err != nil {
+		t.Fatalf("Vault.GetRotationStatus() error = %v", err)
+	}
+
+	if time.Since(status.LastRotatedAt) > time.Minute {
+		t.Errorf("Vault.GetRotationStatus() LastRotatedAt = %v, want about now", status.LastRotatedAt)
+	}
+
+	// The stopped instance releases the lease so the restarted one can revoke keys.
+	if err := lease.Release(ctx, store, status.Leader); err != nil {
+		t.Fatalf("lease.Release() error = %v", err)
+	}
+
+	if err := restarted.RevokeKey(ctx, active.Id); err != nil {
+		t.Fatalf("Vault.RevokeKey() error = %v", err)
+	}
+
+	promoted, err := restarted.GetActive(ctx, entity.ES256)
+	if err != nil {
+		t.Fatalf("Vault.GetActive() error = %v", err)
+	}
+
+	if promoted.Id == active.Id {
+		t.Errorf("Vault.GetActive() returned revoked key %s", promoted.Id)
+	}
+}
@@ -2,21 +2,53 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
 	"github.com/krixlion/dev_forum-lib/filter"
 )
 
+var (
+	// ErrTokenNotFound is returned by token stores when a token to delete does not exist.
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrKeyNotFound is returned by key stores when a requested key does not exist.
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyExists is returned by KeyAdmin.ImportKey when the key is already stored.
+	ErrKeyExists = errors.New("key already exists")
+	// ErrInvalidKey is returned by KeyAdmin.ImportKey when the key cannot be used with its algorithm.
+	ErrInvalidKey = errors.New("invalid key")
+	// ErrNotLeader is returned by KeyAdmin when another instance holds the rotation lease.
+	ErrNotLeader = errors.New("rotation lease is held by another instance")
+)
+
 type Storage interface {
 	Getter
 	Writer
 }
 
 type Vault interface {
-	GetRandom(ctx context.Context) (entity.Key, error)
+	// GetActive returns the key currently used to sign tokens with given algorithm.
+	GetActive(ctx context.Context, algorithm entity.Algorithm) (entity.Key, error)
 	GetKeySet(ctx context.Context) ([]entity.Key, error)
 }
 
+// KeyAdmin is implemented by key stores which allow operators to manage keys.
+type KeyAdmin interface {
+	ListKeys(ctx context.Context) ([]entity.KeyInfo, error)
+	// RotateNow replaces all keys with a new set or requests the instance
+	// responsible for rotation to replace them.
+	// It returns ErrNotLeader if no instance can act on the request.
+	RotateNow(ctx context.Context) error
+	// RevokeKey deletes the key with given id or returns ErrKeyNotFound.
+	// It returns ErrNotLeader if another instance is responsible for rotation.
+	RevokeKey(ctx context.Context, kid string) error
+	// ImportKey stores a key generated outside of the service and returns its id.
+	// It returns ErrKeyExists if the key is already stored and ErrInvalidKey if the key is rejected.
+	ImportKey(ctx context.Context, key entity.ImportedKey) (string, error)
+	GetRotationStatus(ctx context.Context) (entity.RotationStatus, error)
+}
+
 type Getter interface {
 	// Token's id is its corresponding opaque token.
 	Get(ctx context.Context, id string) (entity.Token, error)
@@ -26,5 +58,12 @@ type Getter interface {
 
 type Writer interface {
 	Create(ctx context.Context, token entity.Token) error
+	// Update replaces an existing token with given token.
+	Update(ctx context.Context, token entity.Token) error
+	// SetLastUsed records when the token with given id was last used
+	// without replacing the rest of the token.
+	SetLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error
+	// Delete deletes the token with given id or returns ErrTokenNotFound.
+	// Only one of concurrent calls for the same token succeeds.
 	Delete(ctx context.Context, id string) error
 }
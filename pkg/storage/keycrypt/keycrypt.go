@@ -0,0 +1,94 @@
+// Package keycrypt encrypts private keys at rest using a key derived from a passphrase.
+package keycrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// SaltSize is the length of salts returned by NewSalt.
+const SaltSize = 16
+
+// Cost parameters recommended for interactive logins as of 2017.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var ErrDecryptionFailed = errors.New("failed to decrypt, the passphrase is wrong or the data was modified")
+
+// Sealer encrypts and authenticates data using AES-256-GCM.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSalt returns a random salt. It's not secret and should be stored
+// along with the encrypted data.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// NewSealer derives an encryption key from given passphrase and salt using scrypt.
+func NewSealer(passphrase string, salt []byte) (Sealer, error) {
+	if passphrase == "" {
+		return Sealer{}, errors.New("passphrase cannot be empty")
+	}
+
+	if len(salt) < SaltSize {
+		return Sealer{}, errors.New("salt is too short")
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return Sealer{}, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return Sealer{}, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return Sealer{}, err
+	}
+
+	return Sealer{aead: aead}, nil
+}
+
+// Seal encrypts given plaintext and returns it prefixed with a random nonce.
+// Additional data, e.g. the key's id, is authenticated but not encrypted,
+// so the ciphertext cannot be moved to another key.
+func (s Sealer) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plaintext)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return s.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts ciphertext returned by Seal called with the same additional data.
+func (s Sealer) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < s.aead.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+
+	nonce, sealed := ciphertext[:s.aead.NonceSize()], ciphertext[s.aead.NonceSize():]
+
+	plaintext, err := s.aead.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
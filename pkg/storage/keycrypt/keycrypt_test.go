@@ -0,0 +1,104 @@
+package keycrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSealer(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatalf("NewSalt() error = %v", err)
+	}
+
+	sealer, err := NewSealer("passphrase", salt)
+	if err != nil {
+		t.Fatalf("NewSealer() error = %v", err)
+	}
+
+	plaintext := []byte("private key")
+
+	ciphertext, err := sealer.Seal(plaintext, []byte("kid"))
+	if err != nil {
+		t.Fatalf("Sealer.Seal() error = %v", err)
+	}
+
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Fatalf("Sealer.Seal() returned plaintext")
+	}
+
+	tests := []struct {
+		name           string
+		passphrase     string
+		ciphertext     []byte
+		additionalData []byte
+		wantErr        error
+	}{
+		{
+			name:           "Test if decrypts with the same passphrase and additional data",
+			passphrase:     "passphrase",
+			ciphertext:     ciphertext,
+			additionalData: []byte("kid"),
+		},
+		{
+			name:           "Test if fails on wrong passphrase",
+			passphrase:     "wrong",
+			ciphertext:     ciphertext,
+			additionalData: []byte("kid"),
+			wantErr:        ErrDecryptionFailed,
+		},
+		{
+			name:           "Test if fails on different additional data",
+			passphrase:     "passphrase",
+			ciphertext:     ciphertext,
+			additionalData: []byte("other"),
+			wantErr:        ErrDecryptionFailed,
+		},
+		{
+			name:       "Test if fails on modified ciphertext",
+			passphrase: "passphrase",
+			ciphertext: func() []byte {
+				modified := bytes.Clone(ciphertext)
+				modified[len(modified)-1] ^= 1
+				return modified
+			}(),
+			additionalData: []byte("kid"),
+			wantErr:        ErrDecryptionFailed,
+		},
+		{
+			name:           "Test if fails on truncated ciphertext",
+			passphrase:     "passphrase",
+			ciphertext:     ciphertext[:4],
+			additionalData: []byte("kid"),
+			wantErr:        ErrDecryptionFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealer, err := NewSealer(tt.passphrase, salt)
+			if err != nil {
+				t.Fatalf("NewSealer() error = %v", err)
+			}
+
+			got, err := sealer.Open(tt.ciphertext, tt.additionalData)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Sealer.Open() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && !bytes.Equal(got, plaintext) {
+				t.Errorf("Sealer.Open() = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestNewSealer(t *testing.T) {
+	if _, err := NewSealer("", make([]byte, SaltSize)); err == nil {
+		t.Errorf("NewSealer() with empty passphrase error = %v, wantErr true", err)
+	}
+
+	if _, err := NewSealer("passphrase", make([]byte, SaltSize-1)); err == nil {
+		t.Errorf("NewSealer() with short salt error = %v, wantErr true", err)
+	}
+}
@@ -0,0 +1,237 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+)
+
+// DecodeKey decodes provided key with specified algorithm and returns it as a crypto.Signer
+// along with a callback that should be used to encode its public key to proto message format.
+// PKCS #1, SEC 1 and PKCS #8 private keys are detected regardless of the PEM block's type.
+// If decode func for specified algorithm is not found it returns an ErrAlgorithmNotSupported.
+// If the algorithm is not recognized it returns an ErrInvalidAlgorithm.
+// If the key does not match the algorithm it returns an ErrKeyAlgorithmMismatch.
+// Public keys are rejected with an ErrKeyMissing, use DecodePublicKey to decode them.
+func DecodeKey(algorithm entity.Algorithm, encodedKey string) (crypto.Signer, entity.KeyEncodeFunc, error) {
+	encodeFunc, err := encodeFuncFor(algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := DecodePrivateKey(encodedKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, ErrInvalidKeyType
+	}
+
+	if err := matchAlgorithm(algorithm, signer.Public()); err != nil {
+		return nil, nil, err
+	}
+
+	return signer, encodeFunc, nil
+}
+
+// DecodePublicKey works like DecodeKey but returns only the public part of provided key.
+// Besides private keys it accepts PKIX (PUBLIC KEY) and PKCS #1 (RSA PUBLIC KEY) public keys,
+// which are used to publish keys for verification only.
+func DecodePublicKey(algorithm entity.Algorithm, encodedKey string) (crypto.PublicKey, entity.KeyEncodeFunc, error) {
+	encodeFunc, err := encodeFuncFor(algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, err := decodePem(encodedKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var publicKey crypto.PublicKey
+
+	if isPublicKeyBlock(block.Type) {
+		publicKey, err = parsePublicKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		signer, _, err := DecodeKey(algorithm, encodedKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey = signer.Public()
+	}
+
+	if err := matchAlgorithm(algorithm, publicKey); err != nil {
+		return nil, nil, err
+	}
+
+	return publicKey, encodeFunc, nil
+}
+
+// DecodeRSA decodes RSA PEM block and returns a non-nil err on failure.
+func DecodeRSA(rsaPem string) (*rsa.PrivateKey, error) {
+	key, err := DecodePrivateKey(rsaPem)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKeyType
+	}
+
+	return privateKey, nil
+}
+
+// DecodeECDSA decodes EC PEM block and returns a non-nil err on failure.
+func DecodeECDSA(ecPem string) (*ecdsa.PrivateKey, error) {
+	key, err := DecodePrivateKey(ecPem)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKeyType
+	}
+
+	return privateKey, nil
+}
+
+// DecodeEd25519 decodes PKCS #8 PEM block containing an Ed25519 key and returns a non-nil err on failure.
+func DecodeEd25519(edPem string) (ed25519.PrivateKey, error) {
+	key, err := DecodePrivateKey(edPem)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKeyType
+	}
+
+	return privateKey, nil
+}
+
+// IsVerifyOnly returns true if provided PEM contains a public key only.
+func IsVerifyOnly(encodedKey string) bool {
+	block, _ := pem.Decode([]byte(encodedKey))
+	return block != nil && isPublicKeyBlock(block.Type)
+}
+
+func isPublicKeyBlock(blockType string) bool {
+	return blockType == "PUBLIC KEY" || blockType == "RSA PUBLIC KEY"
+}
+
+func decodePem(encodedKey string) (*pem.Block, error) {
+	block, _ := pem.Decode([]byte(encodedKey))
+	if block == nil {
+		return nil, fmt.Errorf("%w: failed to decode pem block", ErrInvalidKeyFormat)
+	}
+
+	return block, nil
+}
+
+// DecodePrivateKey decodes a PEM encoded private key in any of PKCS #8, PKCS #1 and SEC 1 containers.
+func DecodePrivateKey(encodedKey string) (crypto.PrivateKey, error) {
+	block, err := decodePem(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if isPublicKeyBlock(block.Type) {
+		return nil, ErrKeyMissing
+	}
+
+	if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return nil, ErrInvalidKeyType
+	}
+
+	// Tools disagree on block types, e.g. some write PKCS #8 keys
+	// as "RSA PRIVATE KEY", so the DER is probed instead.
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, ErrFailedToParseKey
+}
+
+// parsePublicKey parses a DER encoded PKIX or PKCS #1 public key.
+func parsePublicKey(der []byte) (crypto.PublicKey, error) {
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return key, nil
+	}
+
+	return nil, ErrFailedToParseKey
+}
+
+// encodeFuncFor returns the func used to encode public keys used with given algorithm.
+func encodeFuncFor(algorithm entity.Algorithm) (entity.KeyEncodeFunc, error) {
+	switch algorithm {
+	case entity.RS256, entity.RS384, entity.RS512, entity.PS256, entity.PS384, entity.PS512:
+		return protokey.SerializeRSA, nil
+	case entity.ES256, entity.ES384, entity.ES512:
+		return protokey.SerializeECDSA, nil
+	case entity.EdDSA:
+		return protokey.SerializeEd25519, nil
+	case entity.HS256:
+		return nil, ErrAlgorithmNotSupported
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+}
+
+// curveAlgorithms maps curves to the only algorithms they can be used with as defined in RFC 7518.
+var curveAlgorithms = map[elliptic.Curve]entity.Algorithm{
+	elliptic.P256(): entity.ES256,
+	elliptic.P384(): entity.ES384,
+	elliptic.P521(): entity.ES512,
+}
+
+// matchAlgorithm returns an ErrKeyAlgorithmMismatch if given key cannot be used with the algorithm.
+func matchAlgorithm(algorithm entity.Algorithm, publicKey crypto.PublicKey) error {
+	ok := false
+
+	switch public := publicKey.(type) {
+	case *rsa.PublicKey:
+		switch algorithm {
+		case entity.RS256, entity.RS384, entity.RS512, entity.PS256, entity.PS384, entity.PS512:
+			ok = true
+		}
+	case *ecdsa.PublicKey:
+		ok = curveAlgorithms[public.Curve] == algorithm
+	case ed25519.PublicKey:
+		ok = algorithm == entity.EdDSA
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: %T cannot be used with %s", ErrKeyAlgorithmMismatch, publicKey, algorithm)
+	}
+
+	return nil
+}
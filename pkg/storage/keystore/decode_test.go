@@ -0,0 +1,419 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+)
+
+func TestDecodeKey(t *testing.T) {
+	type args struct {
+		algorithm  entity.Algorithm
+		encodedKey string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "Test if returns an error on unknown algorithm",
+			args: args{
+				algorithm: "",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if returns an error on unsupported algorithm",
+			args: args{
+				algorithm:  entity.HS256,
+				encodedKey: "test",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := DecodeKey(tt.args.algorithm, tt.args.encodedKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodeKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+		})
+	}
+}
+
+func TestDecodeRSA(t *testing.T) {
+	type args struct {
+		encodedKey string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    rsa.PrivateKey
+		wantErr bool
+	}{
+		{
+			name: "Test if returns an error on invalid key",
+			args: args{
+				encodedKey: "",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeRSA(tt.args.encodedKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodeRSA() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			public, ok := got.Public().(*rsa.PublicKey)
+			if !ok {
+				t.Errorf("DecodeRSA(): public key is not *rsa.PublicKey")
+				return
+			}
+
+			if !got.Equal(tt.want) || !public.Equal(tt.want.Public()) {
+				t.Errorf("DecodeRSA(): keys are not equal\n got = %v\n want = %v\n %v", got, tt.want, cmp.Diff(got, tt.want))
+			}
+		})
+	}
+}
+
+func TestDecodeECDSA(t *testing.T) {
+	type args struct {
+		encodedKey string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    ecdsa.PrivateKey
+		wantErr bool
+	}{
+		{
+			name: "Test if returns an error on invalid key",
+			args: args{
+				encodedKey: "",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeECDSA(tt.args.encodedKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodeECDSA() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			public, ok := got.Public().(*ecdsa.PublicKey)
+			if !ok {
+				t.Errorf("DecodeECDSA(): public key is not *ecdsa.PublicKey")
+				return
+			}
+
+			if !got.Equal(tt.want) || !public.Equal(tt.want.Public()) {
+				t.Errorf("DecodeECDSA(): keys are not equal\n got = %v\n want = %v\n %v", got, tt.want, cmp.Diff(got, tt.want))
+			}
+		})
+	}
+}
+
+func TestDecodeEd25519(t *testing.T) {
+	type args struct {
+		encodedKey string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    ed25519.PrivateKey
+		wantErr bool
+	}{
+		{
+			name: "Test if decodes a valid PKCS #8 key",
+			args: args{
+				encodedKey: testdata.Ed25519.PrivPem,
+			},
+			want: testdata.Ed25519.PrivKey,
+		},
+		{
+			name: "Test if returns an error on invalid key",
+			args: args{
+				encodedKey: "",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if returns an error on a key of different type",
+			args: args{
+				encodedKey: testdata.RSA.PrivPem,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeEd25519(tt.args.encodedKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodeEd25519() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !got.Equal(tt.want) {
+				t.Errorf("DecodeEd25519(): keys are not equal\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// encodePem returns given DER bytes as a PEM block of given type.
+func encodePem(blockType string, der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+}
+
+func TestDecodeKey_formats(t *testing.T) {
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	rsaPKCS8, err := x509.MarshalPKCS8PrivateKey(testdata.RSA.PrivKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	ecPKCS8, err := x509.MarshalPKCS8PrivateKey(testdata.ECDSA.PrivKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	p384SEC1, err := x509.MarshalECPrivateKey(p384Key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	rsaPKIX, err := x509.MarshalPKIXPublicKey(testdata.RSA.PubKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		algorithm  entity.Algorithm
+		encodedKey string
+		want       crypto.PublicKey
+		wantErr    error
+	}{
+		{
+			name:       "Test if decodes a PKCS #1 RSA key",
+			algorithm:  entity.RS256,
+			encodedKey: testdata.RSA.PrivPem,
+			want:       testdata.RSA.PubKey,
+		},
+		{
+			name:       "Test if decodes a PKCS #8 RSA key",
+			algorithm:  entity.PS384,
+			encodedKey: encodePem("PRIVATE KEY", rsaPKCS8),
+			want:       testdata.RSA.PubKey,
+		},
+		{
+			name:       "Test if decodes a PKCS #8 key in a mislabeled block",
+			algorithm:  entity.RS512,
+			encodedKey: encodePem("RSA PRIVATE KEY", rsaPKCS8),
+			want:       testdata.RSA.PubKey,
+		},
+		{
+			name:       "Test if decodes a SEC 1 ECDSA key",
+			algorithm:  entity.ES256,
+			encodedKey: testdata.ECDSA.PrivPem,
+			want:       testdata.ECDSA.PubKey,
+		},
+		{
+			name:       "Test if decodes a PKCS #8 ECDSA key",
+			algorithm:  entity.ES256,
+			encodedKey: encodePem("PRIVATE KEY", ecPKCS8),
+			want:       testdata.ECDSA.PubKey,
+		},
+		{
+			name:       "Test if decodes a PKCS #8 Ed25519 key",
+			algorithm:  entity.EdDSA,
+			encodedKey: testdata.Ed25519.PrivPem,
+			want:       testdata.Ed25519.PubKey,
+		},
+		{
+			name:       "Test if rejects an RSA key for an ECDSA algorithm",
+			algorithm:  entity.ES256,
+			encodedKey: encodePem("PRIVATE KEY", rsaPKCS8),
+			wantErr:    ErrKeyAlgorithmMismatch,
+		},
+		{
+			name:       "Test if rejects an ECDSA key on a curve not matching the algorithm",
+			algorithm:  entity.ES256,
+			encodedKey: encodePem("EC PRIVATE KEY", p384SEC1),
+			wantErr:    ErrKeyAlgorithmMismatch,
+		},
+		{
+			name:       "Test if rejects an Ed25519 key for an RSA algorithm",
+			algorithm:  entity.RS256,
+			encodedKey: testdata.Ed25519.PrivPem,
+			wantErr:    ErrKeyAlgorithmMismatch,
+		},
+		{
+			name:       "Test if rejects a public key",
+			algorithm:  entity.RS256,
+			encodedKey: encodePem("PUBLIC KEY", rsaPKIX),
+			wantErr:    ErrKeyMissing,
+		},
+		{
+			name:       "Test if rejects a block of unknown type",
+			algorithm:  entity.RS256,
+			encodedKey: encodePem("CERTIFICATE", rsaPKIX),
+			wantErr:    ErrInvalidKeyType,
+		},
+		{
+			name:       "Test if rejects a corrupted key",
+			algorithm:  entity.RS256,
+			encodedKey: encodePem("PRIVATE KEY", []byte("corrupted")),
+			wantErr:    ErrFailedToParseKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, encodeFunc, err := DecodeKey(tt.algorithm, tt.encodedKey)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DecodeKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !cmp.Equal(got.Public(), tt.want) {
+				t.Errorf("DecodeKey(): public keys are not equal\n got = %v\n want = %v", got.Public(), tt.want)
+			}
+
+			if _, err := encodeFunc(got.Public()); err != nil {
+				t.Errorf("DecodeKey(): failed to encode public key: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodePublicKey(t *testing.T) {
+	rsaPKIX, err := x509.MarshalPKIXPublicKey(testdata.RSA.PubKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	ecPKIX, err := x509.MarshalPKIXPublicKey(testdata.ECDSA.PubKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	edPKIX, err := x509.MarshalPKIXPublicKey(testdata.Ed25519.PubKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		algorithm  entity.Algorithm
+		encodedKey string
+		want       crypto.PublicKey
+		wantErr    error
+	}{
+		{
+			name:       "Test if decodes a PKIX RSA key",
+			algorithm:  entity.RS256,
+			encodedKey: encodePem("PUBLIC KEY", rsaPKIX),
+			want:       testdata.RSA.PubKey,
+		},
+		{
+			name:       "Test if decodes a PKCS #1 RSA key",
+			algorithm:  entity.RS256,
+			encodedKey: encodePem("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(testdata.RSA.PubKey)),
+			want:       testdata.RSA.PubKey,
+		},
+		{
+			name:       "Test if decodes a PKIX ECDSA key",
+			algorithm:  entity.ES256,
+			encodedKey: encodePem("PUBLIC KEY", ecPKIX),
+			want:       testdata.ECDSA.PubKey,
+		},
+		{
+			name:       "Test if decodes a PKIX Ed25519 key",
+			algorithm:  entity.EdDSA,
+			encodedKey: encodePem("PUBLIC KEY", edPKIX),
+			want:       testdata.Ed25519.PubKey,
+		},
+		{
+			name:       "Test if returns the public part of a private key",
+			algorithm:  entity.ES256,
+			encodedKey: testdata.ECDSA.PrivPem,
+			want:       testdata.ECDSA.PubKey,
+		},
+		{
+			name:       "Test if rejects a key not matching the algorithm",
+			algorithm:  entity.ES384,
+			encodedKey: encodePem("PUBLIC KEY", ecPKIX),
+			wantErr:    ErrKeyAlgorithmMismatch,
+		},
+		{
+			name:       "Test if rejects a corrupted key",
+			algorithm:  entity.RS256,
+			encodedKey: encodePem("PUBLIC KEY", []byte("corrupted")),
+			wantErr:    ErrFailedToParseKey,
+		},
+		{
+			name:       "Test if rejects an unsupported algorithm",
+			algorithm:  entity.HS256,
+			encodedKey: encodePem("PUBLIC KEY", rsaPKIX),
+			wantErr:    ErrAlgorithmNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, encodeFunc, err := DecodePublicKey(tt.algorithm, tt.encodedKey)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DecodePublicKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("DecodePublicKey(): keys are not equal\n got = %v\n want = %v", got, tt.want)
+			}
+
+			if _, err := encodeFunc(got); err != nil {
+				t.Errorf("DecodePublicKey(): failed to encode public key: %v", err)
+			}
+		})
+	}
+}
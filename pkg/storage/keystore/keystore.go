@@ -0,0 +1,78 @@
+// Package keystore defines the storage of signing keys shared by key store
+// backends, along with decoding of the PEM encoded keys they keep.
+package keystore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+)
+
+var (
+	ErrInvalidKeyType        = errors.New("invalid key type")
+	ErrInvalidKeyFormat      = errors.New("invalid key format")
+	ErrAlgorithmNotSupported = errors.New("key's algorithm is not supported")
+	ErrInvalidAlgorithm      = errors.New("key's algorithm is missing or invalid")
+	ErrKeyMissing            = errors.New("key does not contain a private key")
+	ErrFailedToParseKey      = errors.New("failed to parse key")
+	ErrKeyAlgorithmMismatch  = errors.New("key does not match its algorithm")
+)
+
+// Store persists keys along with the state shared by instances rotating them.
+// Implementations only store keys, rotation is implemented on top of them
+// by the vault package.
+type Store interface {
+	// List returns ids of all stored keys.
+	List(ctx context.Context) ([]string, error)
+	// Get returns the key with given id or an error wrapping storage.ErrKeyNotFound.
+	Get(ctx context.Context, id string) (StoredKey, error)
+	// Put creates or replaces the key with given id.
+	Put(ctx context.Context, id string, key StoredKey) error
+	// Delete removes the key with given id.
+	Delete(ctx context.Context, id string) error
+
+	// Instances compete for the rotation lease kept along with keys.
+	lease.Store
+
+	// GetSchedule returns the rotation schedule.
+	// A zero schedule is returned if keys were never rotated.
+	GetSchedule(ctx context.Context) (Schedule, error)
+	PutSchedule(ctx context.Context, schedule Schedule) error
+}
+
+// StoredKey is a key as kept in a Store.
+type StoredKey struct {
+	Algorithm entity.Algorithm
+	KeyType   entity.KeyType
+	// PEM encoded private key.
+	EncodedKey string
+	// PEM encoded certificate chain certifying the key, leaf first. Empty if not issued.
+	Certificates string
+	// Active is true for the key used to sign tokens with the algorithm.
+	Active bool
+	// Imported is true for keys generated outside of the service.
+	// Imported keys are never purged on rotation.
+	Imported bool
+	// NotBefore is the time an imported key starts to be used for signing.
+	NotBefore time.Time
+	// RetiredAt is the time a generated key was replaced on rotation. Retired keys are
+	// kept as verify-only keys and purged once their retention has passed.
+	RetiredAt time.Time
+	// CreatedAt is set by the Store and ignored by Put.
+	CreatedAt time.Time
+}
+
+// Schedule is persisted so that it survives restarts.
+type Schedule struct {
+	// Zero if keys were never rotated.
+	LastRotatedAt time.Time
+	// Zero if keys are not rotated periodically.
+	NextRotationAt time.Time
+	// RotationRequestedAt is set when an instance not holding the lease
+	// is asked to rotate keys. The leader rotates them at its next tick
+	// if it's after LastRotatedAt.
+	RotationRequestedAt time.Time
+}
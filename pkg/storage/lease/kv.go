@@ -0,0 +1,82 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+var _ Store = KVStore{}
+
+// KVStore keeps the lease in a secret of Vault's KVv2 engine.
+type KVStore struct {
+	vault *vault.KVv2
+	path  string
+}
+
+// NewKVStore returns a KVStore keeping the lease at given path
+// in the KVv2 engine mounted at mountPath.
+func NewKVStore(client *vault.Client, mountPath, path string) KVStore {
+	return KVStore{
+		vault: client.KVv2(mountPath),
+		path:  path,
+	}
+}
+
+func (s KVStore) GetLease(ctx context.Context) (Lease, error) {
+	secret, err := s.vault.Get(ctx, s.path)
+	if errors.Is(err, vault.ErrSecretNotFound) {
+		return Lease{}, nil
+	}
+	if err != nil {
+		return Lease{}, fmt.Errorf("failed to get lease: %w", err)
+	}
+
+	return parseSecret(secret)
+}
+
+// PutLease writes the lease with check-and-set.
+func (s KVStore) PutLease(ctx context.Context, lease Lease) (Lease, error) {
+	data := map[string]interface{}{
+		"holder":     lease.Holder,
+		"expires_at": lease.ExpiresAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	secret, err := s.vault.Put(ctx, s.path, data, vault.WithCheckAndSet(lease.Version))
+	if err != nil {
+		var respErr *vault.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusBadRequest {
+			return Lease{}, ErrConflict
+		}
+		return Lease{}, fmt.Errorf("failed to put lease: %w", err)
+	}
+
+	if secret.VersionMetadata != nil {
+		lease.Version = secret.VersionMetadata.Version
+	}
+
+	return lease, nil
+}
+
+func parseSecret(secret *vault.KVSecret) (Lease, error) {
+	if secret == nil || secret.VersionMetadata == nil {
+		return Lease{}, errors.New("lease is missing version metadata")
+	}
+
+	lease := Lease{Version: secret.VersionMetadata.Version}
+	lease.Holder, _ = secret.Data["holder"].(string)
+
+	if encoded, _ := secret.Data["expires_at"].(string); encoded != "" {
+		expiresAt, err := time.Parse(time.RFC3339Nano, encoded)
+		if err != nil {
+			return Lease{}, fmt.Errorf("failed to parse lease's expiration time: %w", err)
+		}
+		lease.ExpiresAt = expiresAt
+	}
+
+	return lease, nil
+}
@@ -0,0 +1,106 @@
+// Package lease elects the single instance which rotates keys
+// among instances sharing a key store.
+package lease
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/str"
+)
+
+// DefaultTTL is used by key stores whose config doesn't set a lease TTL.
+const DefaultTTL = time.Second * 30
+
+// ErrConflict is returned by Store.PutLease when the lease
+// was modified since it was read.
+var ErrConflict = errors.New("lease was modified concurrently")
+
+// Lease elects the instance which rotates keys.
+type Lease struct {
+	// Holder is the id of the instance holding the lease.
+	Holder    string
+	ExpiresAt time.Time
+	// Version is used to update the lease with check-and-set.
+	// It's 0 for a lease that was never written.
+	Version int
+}
+
+// Store persists the lease shared by competing instances.
+type Store interface {
+	// GetLease returns the rotation lease.
+	// A zero lease is returned if no instance has ever held it.
+	GetLease(ctx context.Context) (Lease, error)
+	// PutLease writes given lease if it wasn't modified since it was read
+	// and returns it with an updated version. Otherwise it returns ErrConflict.
+	PutLease(ctx context.Context, lease Lease) (Lease, error)
+}
+
+// Acquire acquires the lease for holder or renews it if it's already held
+// by the holder. It returns false if the lease is held by another instance.
+// Writes use check-and-set so at most one instance holds the lease at a time.
+func Acquire(ctx context.Context, store Store, holder string, ttl time.Duration) (Lease, bool, error) {
+	lease, err := store.GetLease(ctx)
+	if err != nil {
+		return Lease{}, false, err
+	}
+
+	now := time.Now()
+
+	if lease.Holder != holder && now.Before(lease.ExpiresAt) {
+		return lease, false, nil
+	}
+
+	lease.Holder = holder
+	lease.ExpiresAt = now.Add(ttl)
+
+	lease, err = store.PutLease(ctx, lease)
+	if errors.Is(err, ErrConflict) {
+		// Another instance acquired the lease in the meantime.
+		return Lease{}, false, nil
+	}
+	if err != nil {
+		return Lease{}, false, err
+	}
+
+	return lease, true, nil
+}
+
+// Release expires the lease if it's held by holder so that another
+// instance can take over without waiting for it to expire.
+func Release(ctx context.Context, store Store, holder string) error {
+	lease, err := store.GetLease(ctx)
+	if err != nil {
+		return err
+	}
+
+	if lease.Holder != holder {
+		return nil
+	}
+
+	lease.ExpiresAt = time.Now()
+
+	if _, err := store.PutLease(ctx, lease); err != nil && !errors.Is(err, ErrConflict) {
+		return err
+	}
+
+	return nil
+}
+
+// HolderId returns a random id prefixed with the hostname
+// identifying the instance competing for the lease.
+func HolderId() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+
+	suffix, err := str.RandomAlphaString(8)
+	if err != nil {
+		return "", err
+	}
+
+	return hostname + "-" + suffix, nil
+}
@@ -0,0 +1,116 @@
+package lease_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+)
+
+// memoryStore is a Store writing the lease with check-and-set.
+type memoryStore struct {
+	mu    sync.Mutex
+	lease lease.Lease
+}
+
+func (s *memoryStore) GetLease(context.Context) (lease.Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lease, nil
+}
+
+func (s *memoryStore) PutLease(_ context.Context, l lease.Lease) (lease.Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l.Version != s.lease.Version {
+		return lease.Lease{}, lease.ErrConflict
+	}
+
+	l.Version++
+	s.lease = l
+	return l, nil
+}
+
+func TestAcquire(t *testing.T) {
+	tests := []struct {
+		desc    string
+		current lease.Lease
+		holder  string
+		want    bool
+	}{
+		{
+			desc:   "Test if a lease never held is acquired",
+			holder: "instance-1",
+			want:   true,
+		},
+		{
+			desc:    "Test if the holder renews its lease",
+			current: lease.Lease{Holder: "instance-1", ExpiresAt: time.Now().Add(time.Minute), Version: 1},
+			holder:  "instance-1",
+			want:    true,
+		},
+		{
+			desc:    "Test if a lease held by another instance is not acquired",
+			current: lease.Lease{Holder: "instance-2", ExpiresAt: time.Now().Add(time.Minute), Version: 1},
+			holder:  "instance-1",
+			want:    false,
+		},
+		{
+			desc:    "Test if an expired lease is taken over",
+			current: lease.Lease{Holder: "instance-2", ExpiresAt: time.Now().Add(-time.Second), Version: 1},
+			holder:  "instance-1",
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			store := &memoryStore{lease: tt.current}
+
+			got, ok, err := lease.Acquire(ctx, store, tt.holder, time.Minute)
+			if err != nil {
+				t.Fatalf("Acquire() error = %v", err)
+			}
+
+			if ok != tt.want {
+				t.Errorf("Acquire() = %v, want %v", ok, tt.want)
+			}
+
+			if ok && (got.Holder != tt.holder || got.Version != tt.current.Version+1) {
+				t.Errorf("Acquire() = %+v, want version %d held by %s", got, tt.current.Version+1, tt.holder)
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	store := &memoryStore{}
+
+	if _, ok, err := lease.Acquire(ctx, store, "instance-1", time.Minute); err != nil || !ok {
+		t.Fatalf("Acquire() = %v, error = %v", ok, err)
+	}
+
+	if err := lease.Release(ctx, store, "instance-2"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	if _, ok, _ := lease.Acquire(ctx, store, "instance-2", time.Minute); ok {
+		t.Errorf("Acquire() after release by another instance = true, want false")
+	}
+
+	if err := lease.Release(ctx, store, "instance-1"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	if _, ok, err := lease.Acquire(ctx, store, "instance-2", time.Minute); err != nil || !ok {
+		t.Errorf("Acquire() after release by the holder = %v, error = %v, want true", ok, err)
+	}
+}
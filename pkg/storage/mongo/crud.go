@@ -2,8 +2,10 @@ package mongo
 
 import (
 	"context"
+	"time"
 
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
 	"github.com/krixlion/dev_forum-lib/filter"
 	"go.mongodb.org/mongo-driver/bson"
 )
@@ -61,12 +63,42 @@ func (db Mongo) Create(ctx context.Context, token entity.Token) error {
 	return err
 }
 
+func (db Mongo) Update(ctx context.Context, token entity.Token) error {
+	ctx, span := db.tracer.Start(ctx, "db.Update")
+	defer span.End()
+
+	filter := bson.M{"_id": bson.M{"$eq": token.Id}}
+	tokenDoc := makeDocumentFromToken(token)
+
+	_, err := db.tokens.ReplaceOne(ctx, filter, tokenDoc)
+	return err
+}
+
+func (db Mongo) SetLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error {
+	ctx, span := db.tracer.Start(ctx, "db.SetLastUsed")
+	defer span.End()
+
+	filter := bson.M{"_id": bson.M{"$eq": id}}
+	update := bson.M{"$set": bson.M{"last_used_at": lastUsedAt}}
+
+	_, err := db.tokens.UpdateOne(ctx, filter, update)
+	return err
+}
+
 func (db Mongo) Delete(ctx context.Context, id string) error {
 	ctx, span := db.tracer.Start(ctx, "db.Delete")
 	defer span.End()
 
 	filter := bson.M{"_id": bson.M{"$eq": id}}
 
-	_, err := db.tokens.DeleteOne(ctx, filter)
-	return err
+	result, err := db.tokens.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return storage.ErrTokenNotFound
+	}
+
+	return nil
 }
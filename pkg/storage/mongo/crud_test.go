@@ -165,6 +165,44 @@ func TestDB_GetMultiple(t *testing.T) {
 	}
 }
 
+func TestDB_SetLastUsed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping db.SetLastUsed integration test...")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	db, err := mongotest.NewMongo(ctx)
+	if err != nil {
+		t.Fatalf("mongotest.NewMongo() error = %v", err)
+	}
+
+	token := testdata.Token
+	token.Id = gentest.RandomString(50)
+
+	if err := db.Create(ctx, token); err != nil {
+		t.Fatalf("DB.Create() error = %v", err)
+	}
+
+	lastUsedAt := time.Now().Truncate(time.Millisecond)
+	if err := db.SetLastUsed(ctx, token.Id, lastUsedAt); err != nil {
+		t.Fatalf("DB.SetLastUsed() error = %v", err)
+	}
+
+	got, err := db.Get(ctx, token.Id)
+	if err != nil {
+		t.Fatalf("DB.Get() error = %v", err)
+	}
+
+	want := token
+	want.LastUsedAt = lastUsedAt
+
+	if !cmp.Equal(got, want, cmpopts.EquateApproxTime(time.Second*5)) {
+		t.Errorf("DB.Get() after DB.SetLastUsed():\n got = %v\n want = %v\n %v", got, want, cmp.Diff(got, want))
+	}
+}
+
 func TestDB_Delete(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping db.Delete integration test...")
@@ -184,6 +222,13 @@ func TestDB_Delete(t *testing.T) {
 				id: testdata.Token.Id,
 			},
 		},
+		{
+			name: "Test if fails when token does not exist.",
+			args: args{
+				id: "not-existing-token",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
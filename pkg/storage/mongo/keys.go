@@ -0,0 +1,312 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keycrypt"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	keysCollectionName     = "keys"
+	keyStateCollectionName = "key_state"
+
+	// Ids of documents in the key state collection.
+	saltId     = "salt"
+	leaseId    = "lease"
+	scheduleId = "schedule"
+)
+
+var _ keystore.Store = KeyStore{}
+
+// KeyStore implements keystore.Store keeping keys in a Mongo collection.
+// Private keys are encrypted with a key derived from a passphrase.
+type KeyStore struct {
+	keys   *mongo.Collection
+	state  *mongo.Collection
+	sealer keycrypt.Sealer
+	tracer trace.Tracer
+}
+
+type keyDocument struct {
+	Id        string    `bson:"_id"`
+	Algorithm string    `bson:"algorithm"`
+	KeyType   string    `bson:"key_type"`
+	Private   []byte    `bson:"private"`
+	Active    bool      `bson:"active"`
+	CreatedAt time.Time `bson:"created_at"`
+	Imported  bool      `bson:"imported,omitempty"`
+	NotBefore time.Time `bson:"not_before,omitempty"`
+	RetiredAt time.Time `bson:"retired_at,omitempty"`
+	// PEM encoded certificate chain. Certificates are public so they're not sealed.
+	Certificates string `bson:"certificates,omitempty"`
+}
+
+type saltDocument struct {
+	Id   string `bson:"_id"`
+	Salt []byte `bson:"salt"`
+}
+
+type leaseDocument struct {
+	Id        string    `bson:"_id"`
+	Holder    string    `bson:"holder"`
+	ExpiresAt time.Time `bson:"expires_at"`
+	Version   int       `bson:"version"`
+}
+
+type scheduleDocument struct {
+	Id                  string    `bson:"_id"`
+	LastRotatedAt       time.Time `bson:"last_rotated_at,omitempty"`
+	NextRotationAt      time.Time `bson:"next_rotation_at,omitempty"`
+	RotationRequestedAt time.Time `bson:"rotation_requested_at,omitempty"`
+}
+
+// MakeKeyStore returns a KeyStore using the same database as given Mongo.
+// The passphrase is required and has to be the same for all instances.
+func MakeKeyStore(ctx context.Context, db Mongo, passphrase string) (KeyStore, error) {
+	store := KeyStore{
+		keys:   db.tokens.Database().Collection(keysCollectionName),
+		state:  db.tokens.Database().Collection(keyStateCollectionName),
+		tracer: db.tracer,
+	}
+
+	salt, err := store.salt(ctx)
+	if err != nil {
+		return KeyStore{}, err
+	}
+
+	store.sealer, err = keycrypt.NewSealer(passphrase, salt)
+	if err != nil {
+		return KeyStore{}, err
+	}
+
+	return store, nil
+}
+
+// salt returns the salt used to derive the encryption key, creating it on first use.
+func (s KeyStore) salt(ctx context.Context) ([]byte, error) {
+	salt, err := keycrypt.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.state.InsertOne(ctx, saltDocument{Id: saltId, Salt: salt})
+	if err == nil {
+		return salt, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("failed to create salt: %w", err)
+	}
+
+	doc := saltDocument{}
+	if err := s.state.FindOne(ctx, bson.M{"_id": saltId}).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to get salt: %w", err)
+	}
+
+	return doc.Salt, nil
+}
+
+func (s KeyStore) List(ctx context.Context) ([]string, error) {
+	ctx, span := s.tracer.Start(ctx, "db.ListKeys")
+	defer span.End()
+
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+
+	result, err := s.keys.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := []keyDocument{}
+	if err := result.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.Id)
+	}
+
+	return ids, nil
+}
+
+func (s KeyStore) Get(ctx context.Context, id string) (keystore.StoredKey, error) {
+	ctx, span := s.tracer.Start(ctx, "db.GetKey")
+	defer span.End()
+
+	doc := keyDocument{}
+	err := s.keys.FindOne(ctx, bson.M{"_id": bson.M{"$eq": id}}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return keystore.StoredKey{}, fmt.Errorf("%w: %s", storage.ErrKeyNotFound, id)
+	}
+	if err != nil {
+		return keystore.StoredKey{}, err
+	}
+
+	private, err := s.sealer.Open(doc.Private, []byte(id))
+	if err != nil {
+		return keystore.StoredKey{}, fmt.Errorf("failed to decrypt key %s: %w", id, err)
+	}
+
+	return keystore.StoredKey{
+		Algorithm:  entity.Algorithm(doc.Algorithm),
+		KeyType:    entity.KeyType(doc.KeyType),
+		EncodedKey: string(private),
+		Active:     doc.Active,
+		CreatedAt:  doc.CreatedAt,
+		Imported:   doc.Imported,
+		NotBefore:  doc.NotBefore,
+		RetiredAt:  doc.RetiredAt,
+		// Certificates are public so they're not sealed.
+		Certificates: doc.Certificates,
+	}, nil
+}
+
+func (s KeyStore) Put(ctx context.Context, id string, key keystore.StoredKey) error {
+	ctx, span := s.tracer.Start(ctx, "db.PutKey")
+	defer span.End()
+
+	// Bind the ciphertext to the id so that it cannot be moved to another key.
+	private, err := s.sealer.Seal([]byte(key.EncodedKey), []byte(id))
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"algorithm":    string(key.Algorithm),
+			"key_type":     string(key.KeyType),
+			"private":      private,
+			"active":       key.Active,
+			"imported":     key.Imported,
+			"certificates": key.Certificates,
+		},
+		"$setOnInsert": bson.M{
+			"created_at": time.Now(),
+		},
+	}
+
+	if key.Imported {
+		update["$set"].(bson.M)["not_before"] = key.NotBefore
+	}
+
+	if !key.RetiredAt.IsZero() {
+		update["$set"].(bson.M)["retired_at"] = key.RetiredAt
+	}
+
+	_, err = s.keys.UpdateOne(ctx, bson.M{"_id": bson.M{"$eq": id}}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (s KeyStore) Delete(ctx context.Context, id string) error {
+	ctx, span := s.tracer.Start(ctx, "db.DeleteKey")
+	defer span.End()
+
+	_, err := s.keys.DeleteOne(ctx, bson.M{"_id": bson.M{"$eq": id}})
+	return err
+}
+
+func (s KeyStore) GetLease(ctx context.Context) (lease.Lease, error) {
+	ctx, span := s.tracer.Start(ctx, "db.GetLease")
+	defer span.End()
+
+	doc := leaseDocument{}
+	err := s.state.FindOne(ctx, bson.M{"_id": leaseId}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return lease.Lease{}, nil
+	}
+	if err != nil {
+		return lease.Lease{}, fmt.Errorf("failed to get lease: %w", err)
+	}
+
+	return lease.Lease{
+		Holder:    doc.Holder,
+		ExpiresAt: doc.ExpiresAt,
+		Version:   doc.Version,
+	}, nil
+}
+
+// PutLease updates the lease only if its version did not change.
+// A lease with version 0 is inserted, which fails if another instance did it first.
+func (s KeyStore) PutLease(ctx context.Context, l lease.Lease) (lease.Lease, error) {
+	ctx, span := s.tracer.Start(ctx, "db.PutLease")
+	defer span.End()
+
+	doc := leaseDocument{
+		Id:        leaseId,
+		Holder:    l.Holder,
+		ExpiresAt: l.ExpiresAt,
+		Version:   l.Version + 1,
+	}
+
+	if l.Version == 0 {
+		_, err := s.state.InsertOne(ctx, doc)
+		if mongo.IsDuplicateKeyError(err) {
+			return lease.Lease{}, lease.ErrConflict
+		}
+		if err != nil {
+			return lease.Lease{}, fmt.Errorf("failed to put lease: %w", err)
+		}
+	} else {
+		result, err := s.state.ReplaceOne(ctx, bson.M{"_id": leaseId, "version": l.Version}, doc)
+		if err != nil {
+			return lease.Lease{}, fmt.Errorf("failed to put lease: %w", err)
+		}
+
+		if result.MatchedCount == 0 {
+			return lease.Lease{}, lease.ErrConflict
+		}
+	}
+
+	l.Version = doc.Version
+
+	return l, nil
+}
+
+func (s KeyStore) GetSchedule(ctx context.Context) (keystore.Schedule, error) {
+	ctx, span := s.tracer.Start(ctx, "db.GetSchedule")
+	defer span.End()
+
+	doc := scheduleDocument{}
+	err := s.state.FindOne(ctx, bson.M{"_id": scheduleId}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return keystore.Schedule{}, nil
+	}
+	if err != nil {
+		return keystore.Schedule{}, fmt.Errorf("failed to get rotation schedule: %w", err)
+	}
+
+	return keystore.Schedule{
+		LastRotatedAt:       doc.LastRotatedAt,
+		NextRotationAt:      doc.NextRotationAt,
+		RotationRequestedAt: doc.RotationRequestedAt,
+	}, nil
+}
+
+func (s KeyStore) PutSchedule(ctx context.Context, schedule keystore.Schedule) error {
+	ctx, span := s.tracer.Start(ctx, "db.PutSchedule")
+	defer span.End()
+
+	doc := scheduleDocument{
+		Id:                  scheduleId,
+		LastRotatedAt:       schedule.LastRotatedAt,
+		NextRotationAt:      schedule.NextRotationAt,
+		RotationRequestedAt: schedule.RotationRequestedAt,
+	}
+
+	if _, err := s.state.ReplaceOne(ctx, bson.M{"_id": scheduleId}, doc, options.Replace().SetUpsert(true)); err != nil {
+		return fmt.Errorf("failed to put rotation schedule: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/internal/gentest"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keycrypt"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/mongo"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/mongo/mongotest"
+)
+
+func TestKeyStore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping KeyStore integration test...")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	db, err := mongotest.NewMongo(ctx)
+	if err != nil {
+		t.Fatalf("mongotest.NewMongo() error = %v", err)
+	}
+
+	store, err := mongo.MakeKeyStore(ctx, db, "passphrase")
+	if err != nil {
+		t.Fatalf("mongo.MakeKeyStore() error = %v", err)
+	}
+
+	t.Run("Test if a key is encrypted and decrypted", func(t *testing.T) {
+		id := gentest.RandomString(50)
+		want := keystore.StoredKey{
+			Algorithm:  entity.ES256,
+			KeyType:    entity.ECDSA,
+			EncodedKey: testdata.ECDSA.PrivPem,
+			Active:     true,
+		}
+
+		if err := store.Put(ctx, id, want); err != nil {
+			t.Fatalf("KeyStore.Put() error = %v", err)
+		}
+
+		got, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("KeyStore.Get() error = %v", err)
+		}
+
+		got.CreatedAt = time.Time{}
+		if got != want {
+			t.Errorf("KeyStore.Get():\n got = %+v\n want = %+v", got, want)
+		}
+
+		other, err := mongo.MakeKeyStore(ctx, db, "wrong")
+		if err != nil {
+			t.Fatalf("mongo.MakeKeyStore() error = %v", err)
+		}
+
+		if _, err := other.Get(ctx, id); !errors.Is(err, keycrypt.ErrDecryptionFailed) {
+			t.Errorf("KeyStore.Get() with wrong passphrase error = %v, want %v", err, keycrypt.ErrDecryptionFailed)
+		}
+
+		if err := store.Delete(ctx, id); err != nil {
+			t.Fatalf("KeyStore.Delete() error = %v", err)
+		}
+
+		if _, err := store.Get(ctx, id); !errors.Is(err, storage.ErrKeyNotFound) {
+			t.Errorf("KeyStore.Get() after delete error = %v, want %v", err, storage.ErrKeyNotFound)
+		}
+	})
+
+	t.Run("Test if a stale lease is not written", func(t *testing.T) {
+		current, err := store.GetLease(ctx)
+		if err != nil {
+			t.Fatalf("KeyStore.GetLease() error = %v", err)
+		}
+
+		current.Holder = "instance"
+		current.ExpiresAt = time.Now().Add(time.Minute)
+
+		if _, err := store.PutLease(ctx, current); err != nil {
+			t.Fatalf("KeyStore.PutLease() error = %v", err)
+		}
+
+		if _, err := store.PutLease(ctx, current); !errors.Is(err, lease.ErrConflict) {
+			t.Errorf("KeyStore.PutLease() with stale version error = %v, want %v", err, lease.ErrConflict)
+		}
+	})
+}
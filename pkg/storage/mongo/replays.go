@@ -0,0 +1,64 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const dpopProofsCollectionName = "dpop_proofs"
+
+var _ dpop.ReplayCache = ReplayCache{}
+
+// ReplayCache implements dpop.ReplayCache keeping jtis in a Mongo collection,
+// so that a proof accepted by one instance is rejected by all others.
+// Entries are removed by a TTL index once they can be forgotten.
+type ReplayCache struct {
+	proofs *mongo.Collection
+	tracer trace.Tracer
+}
+
+type proofDocument struct {
+	JTI         string    `bson:"_id"`
+	ForgetAfter time.Time `bson:"forget_after"`
+}
+
+// MakeReplayCache returns a ReplayCache using the same database as given Mongo.
+func MakeReplayCache(ctx context.Context, db Mongo) (ReplayCache, error) {
+	cache := ReplayCache{
+		proofs: db.tokens.Database().Collection(dpopProofsCollectionName),
+		tracer: db.tracer,
+	}
+
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "forget_after", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	if _, err := cache.proofs.Indexes().CreateOne(ctx, index); err != nil {
+		return ReplayCache{}, fmt.Errorf("failed to create dpop proofs index: %w", err)
+	}
+
+	return cache, nil
+}
+
+// MarkUsed records given jti or returns dpop.ErrProofReplayed if it's already recorded.
+// Mongo removes expired documents periodically, so an entry may outlive forgetAfter.
+// It does not matter since a proof is rejected as expired after that time anyway.
+func (c ReplayCache) MarkUsed(ctx context.Context, jti string, forgetAfter time.Time) error {
+	ctx, span := c.tracer.Start(ctx, "db.MarkUsed")
+	defer span.End()
+
+	_, err := c.proofs.InsertOne(ctx, proofDocument{JTI: jti, ForgetAfter: forgetAfter})
+	if mongo.IsDuplicateKeyError(err) {
+		return dpop.ErrProofReplayed
+	}
+
+	return err
+}
@@ -0,0 +1,49 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/internal/gentest"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/mongo"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/mongo/mongotest"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop"
+)
+
+func TestReplayCache_MarkUsed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping ReplayCache integration test...")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	db, err := mongotest.NewMongo(ctx)
+	if err != nil {
+		t.Fatalf("mongotest.NewMongo() error = %v", err)
+	}
+
+	// Caches of two instances sharing the database.
+	first, err := mongo.MakeReplayCache(ctx, db)
+	if err != nil {
+		t.Fatalf("mongo.MakeReplayCache() error = %v", err)
+	}
+
+	second, err := mongo.MakeReplayCache(ctx, db)
+	if err != nil {
+		t.Fatalf("mongo.MakeReplayCache() error = %v", err)
+	}
+
+	jti := gentest.RandomString(32)
+	forgetAfter := time.Now().Add(time.Minute)
+
+	if err := first.MarkUsed(ctx, jti, forgetAfter); err != nil {
+		t.Fatalf("ReplayCache.MarkUsed() first use error = %v", err)
+	}
+
+	if err := second.MarkUsed(ctx, jti, forgetAfter); !errors.Is(err, dpop.ErrProofReplayed) {
+		t.Errorf("ReplayCache.MarkUsed() replay error = %v, wantErr = %v", err, dpop.ErrProofReplayed)
+	}
+}
@@ -12,6 +12,17 @@ type tokenDocument struct {
 	Type      string    `bson:"type,omitempty"`
 	ExpiresAt time.Time `bson:"expires_at,omitempty"`
 	IssuedAt  time.Time `bson:"issued_at,omitempty"`
+
+	Name       string    `bson:"name,omitempty"`
+	Scopes     []string  `bson:"scopes,omitempty"`
+	LastUsedAt time.Time `bson:"last_used_at,omitempty"`
+
+	Actor     string `bson:"actor,omitempty"`
+	NonceHash string `bson:"nonce_hash,omitempty"`
+	EmailHash string `bson:"email_hash,omitempty"`
+
+	ConfirmationJKT     string `bson:"cnf_jkt,omitempty"`
+	ConfirmationX5tS256 string `bson:"cnf_x5t_s256,omitempty"`
 }
 
 func makeDocumentFromToken(token entity.Token) tokenDocument {
@@ -21,6 +32,17 @@ func makeDocumentFromToken(token entity.Token) tokenDocument {
 		Type:      string(token.Type),
 		ExpiresAt: token.ExpiresAt,
 		IssuedAt:  token.IssuedAt,
+
+		Name:       token.Name,
+		Scopes:     token.Scopes,
+		LastUsedAt: token.LastUsedAt,
+
+		Actor:     token.Actor,
+		NonceHash: token.NonceHash,
+		EmailHash: token.EmailHash,
+
+		ConfirmationJKT:     token.Confirmation.JKT,
+		ConfirmationX5tS256: token.Confirmation.X5tS256,
 	}
 }
 
@@ -31,5 +53,18 @@ func makeTokenFromDocument(v tokenDocument) entity.Token {
 		Type:      entity.TokenType(v.Type),
 		ExpiresAt: v.ExpiresAt,
 		IssuedAt:  v.IssuedAt,
+
+		Name:       v.Name,
+		Scopes:     v.Scopes,
+		LastUsedAt: v.LastUsedAt,
+
+		Actor:     v.Actor,
+		NonceHash: v.NonceHash,
+		EmailHash: v.EmailHash,
+
+		Confirmation: entity.Confirmation{
+			JKT:     v.ConfirmationJKT,
+			X5tS256: v.ConfirmationX5tS256,
+		},
 	}
 }
@@ -0,0 +1,43 @@
+package storagemocks
+
+import (
+	"context"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type KeyAdmin struct {
+	*mock.Mock
+}
+
+func NewKeyAdmin() KeyAdmin {
+	return KeyAdmin{
+		Mock: new(mock.Mock),
+	}
+}
+
+func (m KeyAdmin) ListKeys(ctx context.Context) ([]entity.KeyInfo, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.KeyInfo), args.Error(1)
+}
+
+func (m KeyAdmin) RotateNow(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m KeyAdmin) RevokeKey(ctx context.Context, kid string) error {
+	args := m.Called(ctx, kid)
+	return args.Error(0)
+}
+
+func (m KeyAdmin) ImportKey(ctx context.Context, key entity.ImportedKey) (string, error) {
+	args := m.Called(ctx, key)
+	return args.String(0), args.Error(1)
+}
+
+func (m KeyAdmin) GetRotationStatus(ctx context.Context) (entity.RotationStatus, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(entity.RotationStatus), args.Error(1)
+}
@@ -2,6 +2,7 @@ package storagemocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
 	"github.com/krixlion/dev_forum-lib/filter"
@@ -34,6 +35,16 @@ func (m Storage) Create(ctx context.Context, token entity.Token) error {
 	return args.Error(0)
 }
 
+func (m Storage) Update(ctx context.Context, token entity.Token) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m Storage) SetLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error {
+	args := m.Called(ctx, id, lastUsedAt)
+	return args.Error(0)
+}
+
 func (m Storage) Delete(ctx context.Context, id string) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
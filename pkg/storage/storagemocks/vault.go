@@ -17,8 +17,8 @@ func NewVault() Vault {
 	}
 }
 
-func (m Vault) GetRandom(ctx context.Context) (entity.Key, error) {
-	args := m.Called(ctx)
+func (m Vault) GetActive(ctx context.Context, algorithm entity.Algorithm) (entity.Key, error) {
+	args := m.Called(ctx, algorithm)
 	return args.Get(0).(entity.Key), args.Error(1)
 }
 
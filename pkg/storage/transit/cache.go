@@ -0,0 +1,63 @@
+package transit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+)
+
+// activeKeys caches active keys so that signing a token doesn't require reading them from Vault.
+type activeKeys struct {
+	mu   sync.Mutex
+	keys map[entity.Algorithm]cachedKey
+}
+
+type cachedKey struct {
+	key       entity.Key
+	expiresAt time.Time
+}
+
+func newActiveKeys() *activeKeys {
+	return &activeKeys{
+		keys: map[entity.Algorithm]cachedKey{},
+	}
+}
+
+// get returns the cached key with given algorithm if it didn't expire yet.
+func (c *activeKeys) get(algorithm entity.Algorithm, now time.Time) (entity.Key, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.keys[algorithm]
+	if !ok || !now.Before(cached.expiresAt) {
+		return entity.Key{}, false
+	}
+
+	return cached.key, true
+}
+
+func (c *activeKeys) put(algorithm entity.Algorithm, key entity.Key, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.keys[algorithm] = cachedKey{key: key, expiresAt: expiresAt}
+}
+
+// clear removes all cached keys, e.g. after a rotation.
+func (c *activeKeys) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.keys)
+}
+
+// withContext returns a copy of the key whose Signer signs within given context.
+func withContext(ctx context.Context, key entity.Key) entity.Key {
+	if signer, ok := key.Signer.(Signer); ok {
+		signer.ctx = ctx
+		key.Signer = signer
+	}
+	return key
+}
@@ -0,0 +1,475 @@
+package transit
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/tracing"
+)
+
+// keyInfo describes a key in the Transit engine.
+type keyInfo struct {
+	name      string
+	algorithm entity.Algorithm
+	// 0 if the key was never trimmed.
+	minAvailableVersion  int
+	minDecryptionVersion int
+	// Available versions sorted by version, so the last one is the latest.
+	versions []keyVersion
+}
+
+type keyVersion struct {
+	version   int
+	createdAt time.Time
+	publicKey crypto.PublicKey
+}
+
+// GetActive returns the latest version of the key used to sign tokens with given algorithm.
+// Returned key's Signer signs remotely within provided context.
+// Keys are cached for config.ActiveKeyTTL or until the keyset is updated.
+func (t Transit) GetActive(ctx context.Context, algorithm entity.Algorithm) (_ entity.Key, err error) {
+	ctx, span := t.tracer.Start(ctx, "transit.GetActive")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	if _, ok := keyTypes[algorithm]; !ok {
+		return entity.Key{}, ErrAlgorithmNotSupported
+	}
+
+	now := time.Now()
+
+	if key, ok := t.active.get(algorithm, now); ok {
+		return withContext(ctx, key), nil
+	}
+
+	// The first key of each algorithm is used for signing, others only for verification.
+	keys, err := t.read(ctx, keyName(algorithm, 0))
+	if err != nil {
+		return entity.Key{}, err
+	}
+
+	key := keys[len(keys)-1]
+	// Don't keep the request's context alive in the cache.
+	t.active.put(algorithm, withContext(context.Background(), key), now.Add(t.config.activeKeyTTL()))
+
+	return key, nil
+}
+
+// GetKeySet returns all available versions of all keys in the Transit engine.
+// Older versions are returned until they are trimmed so that tokens
+// signed before a rotation can still be verified.
+func (t Transit) GetKeySet(ctx context.Context) (_ []entity.Key, err error) {
+	ctx, span := t.tracer.Start(ctx, "transit.GetKeySet")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	names, err := t.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []entity.Key{}
+
+	for _, name := range names {
+		versions, err := t.read(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, versions...)
+	}
+
+	return keys, nil
+}
+
+// list returns names of all keys in the Transit engine.
+func (t Transit) list(ctx context.Context) (_ []string, err error) {
+	ctx, span := t.tracer.Start(ctx, "transit.list")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	secret, err := t.client.Logical().ListWithContext(ctx, t.config.MountPath+"/keys")
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+
+	rawNames, ok := secret.Data["keys"].([]interface{})
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	names := make([]string, 0, len(rawNames))
+	for _, name := range rawNames {
+		name, ok := name.(string)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// read returns all available versions of the key with given name sorted by version.
+// Their Signers sign remotely within provided context.
+func (t Transit) read(ctx context.Context, name string) ([]entity.Key, error) {
+	info, err := t.describe(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]entity.Key, 0, len(info.versions))
+	for _, version := range info.versions {
+		key, err := t.makeKey(ctx, name, info.algorithm, version)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// describe reads the key with given name and its available versions.
+func (t Transit) describe(ctx context.Context, name string) (_ keyInfo, err error) {
+	ctx, span := t.tracer.Start(ctx, "transit.describe")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	secret, err := t.client.Logical().ReadWithContext(ctx, t.config.MountPath+"/keys/"+name)
+	if err != nil {
+		return keyInfo{}, err
+	}
+
+	if secret == nil || secret.Data == nil {
+		return keyInfo{}, ErrKeyNotFound
+	}
+
+	keyType, ok := secret.Data["type"].(string)
+	if !ok {
+		return keyInfo{}, ErrInvalidKey
+	}
+
+	algorithm, err := algorithmFromKeyType(keyType)
+	if err != nil {
+		return keyInfo{}, err
+	}
+
+	info := keyInfo{
+		name:      name,
+		algorithm: algorithm,
+	}
+
+	for field, dst := range map[string]*int{
+		"min_available_version":  &info.minAvailableVersion,
+		"min_decryption_version": &info.minDecryptionVersion,
+	} {
+		if *dst, err = parseVersion(secret.Data[field]); err != nil {
+			return keyInfo{}, err
+		}
+	}
+
+	versions, ok := secret.Data["keys"].(map[string]interface{})
+	if !ok || len(versions) == 0 {
+		return keyInfo{}, ErrInvalidKey
+	}
+
+	for rawVersion, versionData := range versions {
+		version, err := strconv.Atoi(rawVersion)
+		if err != nil {
+			return keyInfo{}, ErrInvalidKey
+		}
+
+		keyVersion, err := parseKeyVersion(version, versionData)
+		if err != nil {
+			return keyInfo{}, err
+		}
+
+		info.versions = append(info.versions, keyVersion)
+	}
+
+	sort.Slice(info.versions, func(i, j int) bool {
+		return info.versions[i].version < info.versions[j].version
+	})
+
+	return info, nil
+}
+
+// parseKeyVersion parses a version as returned by Vault's read key endpoint.
+func parseKeyVersion(version int, versionData interface{}) (keyVersion, error) {
+	data, ok := versionData.(map[string]interface{})
+	if !ok {
+		return keyVersion{}, ErrInvalidKey
+	}
+
+	encodedKey, ok := data["public_key"].(string)
+	if !ok {
+		return keyVersion{}, ErrInvalidKey
+	}
+
+	block, _ := pem.Decode([]byte(encodedKey))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return keyVersion{}, ErrInvalidKey
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return keyVersion{}, err
+	}
+
+	encodedTime, ok := data["creation_time"].(string)
+	if !ok {
+		return keyVersion{}, ErrInvalidKey
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, encodedTime)
+	if err != nil {
+		return keyVersion{}, fmt.Errorf("%w: invalid creation time: %v", ErrInvalidKey, err)
+	}
+
+	return keyVersion{
+		version:   version,
+		createdAt: createdAt,
+		publicKey: publicKey,
+	}, nil
+}
+
+// parseVersion parses a version number which is encoded as a JSON number.
+// Missing versions are returned as 0.
+func parseVersion(v interface{}) (int, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case interface{ Int64() (int64, error) }:
+		version, err := v.Int64()
+		if err != nil {
+			return 0, ErrInvalidKey
+		}
+		return int(version), nil
+	case float64:
+		return int(v), nil
+	default:
+		return 0, ErrInvalidKey
+	}
+}
+
+// makeKey is a convenience func used to make an entity.Key
+// with a remote Signer and its public key encoded.
+func (t Transit) makeKey(ctx context.Context, name string, algorithm entity.Algorithm, version keyVersion) (entity.Key, error) {
+	signer := Signer{
+		ctx:       ctx,
+		client:    t.client,
+		mountPath: t.config.MountPath,
+		name:      name,
+		version:   version.version,
+		publicKey: version.publicKey,
+	}
+
+	// Transit derives key material from the key's name and version
+	// but the key is published under its thumbprint like any other key.
+	id, err := protokey.Thumbprint(version.publicKey)
+	if err != nil {
+		return entity.Key{}, err
+	}
+
+	switch algorithm {
+	case entity.RS256:
+		return entity.NewKey(id, entity.RSA, algorithm, signer, protokey.SerializeRSA)
+	case entity.ES256:
+		return entity.NewKey(id, entity.ECDSA, algorithm, signer, protokey.SerializeECDSA)
+	default:
+		return entity.Key{}, ErrAlgorithmNotSupported
+	}
+}
+
+// maintainKeys creates missing keys, rotates keys whose latest version is older than
+// config.KeyRotationInterval and trims versions replaced more than config.RetiredKeyTTL ago.
+// Validators are notified if the keyset has changed.
+// It returns the time keys have to be maintained again.
+func (t Transit) maintainKeys(ctx context.Context, now time.Time) (_ time.Time, err error) {
+	ctx, span := t.tracer.Start(ctx, "transit.maintainKeys")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	changed, err := t.ensureKeys(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	names, err := t.list(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := now.Add(t.config.KeyRotationInterval)
+
+	for _, name := range names {
+		info, err := t.describe(ctx, name)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		trimmed, trimAt, err := t.trimKey(ctx, info, now)
+		if err != nil {
+			return time.Time{}, err
+		}
+		changed = changed || trimmed
+
+		rotateAt := info.versions[len(info.versions)-1].createdAt.Add(t.config.KeyRotationInterval)
+
+		if !now.Before(rotateAt) {
+			if err := t.rotateKey(ctx, name); err != nil {
+				return time.Time{}, err
+			}
+			changed = true
+
+			rotateAt = now.Add(t.config.KeyRotationInterval)
+			// The replaced version is retired from now on.
+			if trimAt.IsZero() {
+				trimAt = now.Add(t.config.RetiredKeyTTL)
+			}
+		}
+
+		next = earliest(next, rotateAt, trimAt)
+	}
+
+	if !changed {
+		return next, nil
+	}
+
+	if err := t.publishKeySetUpdated(ctx); err != nil {
+		return time.Time{}, err
+	}
+
+	return next, nil
+}
+
+// trimKey deletes versions of the key replaced more than config.RetiredKeyTTL ago.
+// It returns true if any version was deleted and the time the oldest remaining
+// replaced version has to be trimmed, which is zero if there is none.
+func (t Transit) trimKey(ctx context.Context, info keyInfo, now time.Time) (_ bool, _ time.Time, err error) {
+	ctx, span := t.tracer.Start(ctx, "transit.trimKey")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	// A version is retired once the next one is created and
+	// versions are created in order, so expired ones form a prefix.
+	minAvailable := info.versions[0].version
+	var trimAt time.Time
+
+	for i := 1; i < len(info.versions); i++ {
+		expiresAt := info.versions[i].createdAt.Add(t.config.RetiredKeyTTL)
+		if now.Before(expiresAt) {
+			trimAt = expiresAt
+			break
+		}
+		minAvailable = info.versions[i].version
+	}
+
+	if minAvailable <= info.versions[0].version {
+		return false, trimAt, nil
+	}
+
+	path := t.config.MountPath + "/keys/" + info.name
+
+	// Vault rejects trimming versions which can still be used for verification.
+	if info.minDecryptionVersion < minAvailable {
+		if _, err := t.client.Logical().WriteWithContext(ctx, path+"/config", map[string]interface{}{
+			"min_decryption_version": minAvailable,
+		}); err != nil {
+			return false, time.Time{}, fmt.Errorf("failed to configure key %s: %w", info.name, err)
+		}
+	}
+
+	if _, err := t.client.Logical().WriteWithContext(ctx, path+"/trim", map[string]interface{}{
+		"min_available_version": minAvailable,
+	}); err != nil {
+		return false, time.Time{}, fmt.Errorf("failed to trim key %s: %w", info.name, err)
+	}
+
+	return true, trimAt, nil
+}
+
+// ensureKeys creates missing keys so that config.KeyCount keys exist for each supported algorithm.
+// It returns true if any key was created.
+func (t Transit) ensureKeys(ctx context.Context) (_ bool, err error) {
+	ctx, span := t.tracer.Start(ctx, "transit.ensureKeys")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	names, err := t.list(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[name] = true
+	}
+
+	created := false
+
+	for algorithm, keyType := range keyTypes {
+		for i := 0; i < t.config.KeyCount; i++ {
+			name := keyName(algorithm, i)
+			if existing[name] {
+				continue
+			}
+
+			if _, err := t.client.Logical().WriteWithContext(ctx, t.config.MountPath+"/keys/"+name, map[string]interface{}{
+				"type": keyType,
+			}); err != nil {
+				return false, fmt.Errorf("failed to create key %s: %w", name, err)
+			}
+			created = true
+		}
+	}
+
+	return created, nil
+}
+
+// rotateKey adds a new version of the key with given name.
+func (t Transit) rotateKey(ctx context.Context, name string) error {
+	if _, err := t.client.Logical().WriteWithContext(ctx, t.config.MountPath+"/keys/"+name+"/rotate", nil); err != nil {
+		return fmt.Errorf("failed to rotate key %s: %w", name, err)
+	}
+	return nil
+}
+
+func (t Transit) publishKeySetUpdated(ctx context.Context) error {
+	e, err := event.MakeEvent(event.AuthAggregate, event.KeySetUpdated, nil, tracing.ExtractMetadataFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	return t.broker.ResilientPublish(e)
+}
+
+func keyName(algorithm entity.Algorithm, i int) string {
+	return strings.ToLower(string(algorithm)) + "-" + strconv.Itoa(i)
+}
+
+// earliest returns the earliest of given non-zero times.
+func earliest(first time.Time, others ...time.Time) time.Time {
+	for _, t := range others {
+		if !t.IsZero() && t.Before(first) {
+			first = t
+		}
+	}
+	return first
+}
@@ -0,0 +1,318 @@
+package transit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vault/vaulttest"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/manager"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/stretchr/testify/mock"
+)
+
+func setUpTransit(t *testing.T, keyCount int) Transit {
+	transit := makeTransit(t, newTransitServer(t), keyCount)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if _, err := transit.ensureKeys(ctx); err != nil {
+		t.Fatalf("Failed to create keys: %v", err)
+	}
+
+	return transit
+}
+
+func newTransitServer(t *testing.T) *vaulttest.Server {
+	server := vaulttest.NewServer("test-token")
+	t.Cleanup(server.Close)
+	server.EnableTransit("transit")
+	server.EnableKVv2("secret")
+	return server
+}
+
+// makeTransit returns a Transit which doesn't maintain keys on its own
+// but is configured to rotate keys daily and trim them two days after.
+func makeTransit(t *testing.T, server *vaulttest.Server, keyCount int) Transit {
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.Anything).Return(nil)
+
+	host, port := server.HostPort()
+	clientConfig := vaultclient.Config{Host: host, Port: port, Auth: vaultclient.AuthConfig{Token: server.Token}}
+
+	client, err := vaultclient.New(context.Background(), clientConfig, nulls.NullLogger{})
+	if err != nil {
+		t.Fatalf("Failed to make vault client: %v", err)
+	}
+
+	transit, err := MakeWithClient(context.Background(), client.Client, Config{MountPath: "/transit", KeyCount: keyCount}, broker, nulls.NullTracer{}, nulls.NullLogger{})
+	if err != nil {
+		t.Fatalf("Failed to make transit: %v", err)
+	}
+
+	transit.config.KeyRotationInterval = time.Hour * 24
+	transit.config.RetiredKeyTTL = time.Hour * 48
+	transit.config.LeaseStore = lease.NewKVStore(client.Client, "secret", "leases/rotation")
+
+	return transit
+}
+
+// availableVersions returns available versions of the first key of given algorithm.
+func availableVersions(ctx context.Context, t *testing.T, transit Transit, algorithm entity.Algorithm) []int {
+	info, err := transit.describe(ctx, keyName(algorithm, 0))
+	if err != nil {
+		t.Fatalf("Transit.describe() error = %v", err)
+	}
+
+	versions := []int{}
+	for _, version := range info.versions {
+		versions = append(versions, version.version)
+	}
+	return versions
+}
+
+func TestTransit_GetKeySet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	transit := setUpTransit(t, 2)
+
+	keys, err := transit.GetKeySet(ctx)
+	if err != nil {
+		t.Fatalf("Transit.GetKeySet() error = %v", err)
+	}
+
+	if want := 2 * len(keyTypes); len(keys) != want {
+		t.Errorf("Transit.GetKeySet() returned %d keys, want %d", len(keys), want)
+	}
+
+	if _, err := transit.maintainKeys(ctx, time.Now().Add(transit.config.KeyRotationInterval)); err != nil {
+		t.Fatalf("Transit.maintainKeys() error = %v", err)
+	}
+
+	keys, err = transit.GetKeySet(ctx)
+	if err != nil {
+		t.Fatalf("Transit.GetKeySet() error = %v", err)
+	}
+
+	// Previous versions have to remain available for verification.
+	if want := 4 * len(keyTypes); len(keys) != want {
+		t.Errorf("Transit.GetKeySet() after rotation returned %d keys, want %d", len(keys), want)
+	}
+
+	for _, key := range keys {
+		if _, err := key.Encode(); err != nil {
+			t.Errorf("Key.Encode() error = %v, key = %v", err, key.Id)
+		}
+	}
+}
+
+func TestTransit_maintainKeys(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	transit := setUpTransit(t, 1)
+	start := time.Now()
+
+	// Steps run in order against the same engine. Each new version
+	// is created at the current time, which is close to start.
+	steps := []struct {
+		desc string
+		now  time.Time
+		want []int
+	}{
+		{
+			desc: "Test if fresh keys are not rotated",
+			now:  start,
+			want: []int{1},
+		},
+		{
+			desc: "Test if keys are rotated once the rotation interval passed",
+			now:  start.Add(time.Hour * 24),
+			want: []int{1, 2},
+		},
+		{
+			desc: "Test if replaced versions are kept until the retired key TTL passes",
+			now:  start.Add(time.Hour * 47),
+			want: []int{1, 2, 3},
+		},
+		{
+			desc: "Test if versions replaced more than the retired key TTL ago are trimmed",
+			now:  start.Add(time.Hour * 49),
+			want: []int{3, 4},
+		},
+	}
+	for _, tt := range steps {
+		t.Run(tt.desc, func(t *testing.T) {
+			next, err := transit.maintainKeys(ctx, tt.now)
+			if err != nil {
+				t.Fatalf("Transit.maintainKeys() error = %v", err)
+			}
+
+			if !next.After(tt.now) {
+				t.Errorf("Transit.maintainKeys() next = %v, want after %v", next, tt.now)
+			}
+
+			for algorithm := range keyTypes {
+				if got := availableVersions(ctx, t, transit, algorithm); !cmp.Equal(got, tt.want) {
+					t.Errorf("Transit.maintainKeys() left %s versions %v, want %v", algorithm, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransit_maintainIfLeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	server := newTransitServer(t)
+
+	leader := makeTransit(t, server, 1)
+	follower := makeTransit(t, server, 1)
+
+	if next := leader.maintainIfLeader(ctx, time.Time{}); next.IsZero() {
+		t.Fatalf("Transit.maintainIfLeader() did not maintain keys as the leader")
+	}
+
+	if next := follower.maintainIfLeader(ctx, time.Time{}); !next.IsZero() {
+		t.Errorf("Transit.maintainIfLeader() = %v, want zero time for a follower", next)
+	}
+
+	for algorithm := range keyTypes {
+		if got := availableVersions(ctx, t, leader, algorithm); !cmp.Equal(got, []int{1}) {
+			t.Errorf("Transit.maintainIfLeader() created %s versions %v, want [1]", algorithm, got)
+		}
+	}
+
+	if err := leader.releaseLease(ctx); err != nil {
+		t.Fatalf("Transit.releaseLease() error = %v", err)
+	}
+
+	if next := follower.maintainIfLeader(ctx, time.Time{}); next.IsZero() {
+		t.Errorf("Transit.maintainIfLeader() did not take over the released lease")
+	}
+}
+
+func TestTransit_GetActive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	server := newTransitServer(t)
+	transit := makeTransit(t, server, 2)
+
+	if _, err := transit.maintainKeys(ctx, time.Now()); err != nil {
+		t.Fatalf("Transit.maintainKeys() error = %v", err)
+	}
+
+	if _, err := transit.maintainKeys(ctx, time.Now().Add(transit.config.KeyRotationInterval)); err != nil {
+		t.Fatalf("Transit.maintainKeys() error = %v", err)
+	}
+
+	for algorithm := range keyTypes {
+		t.Run("Test if returns the latest version of the first "+string(algorithm)+" key", func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				key, err := transit.GetActive(ctx, algorithm)
+				if err != nil {
+					t.Fatalf("Transit.GetActive() error = %v", err)
+				}
+
+				signer, ok := key.Signer.(Signer)
+				if !ok {
+					t.Fatalf("Transit.GetActive() returned key with signer %T", key.Signer)
+				}
+
+				if want := keyName(algorithm, 0); signer.name != want || signer.version != 2 {
+					t.Errorf("Transit.GetActive() returned key %s:%d, want %s:2", signer.name, signer.version, want)
+				}
+
+				if signer.ctx != ctx {
+					t.Errorf("Transit.GetActive() returned key which does not sign within the request's context")
+				}
+
+				if err := protokey.VerifyThumbprint(key.Id, signer.Public()); err != nil {
+					t.Errorf("Transit.GetActive() returned key with invalid id: %v", err)
+				}
+			}
+		})
+	}
+
+	t.Run("Test if the cached key is returned until the keyset is updated", func(t *testing.T) {
+		before := server.Requests()
+		cached, err := transit.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Transit.GetActive() error = %v", err)
+		}
+
+		if requests := server.Requests() - before; requests != 0 {
+			t.Errorf("Transit.GetActive() sent %d requests to Vault, want 0", requests)
+		}
+
+		if err := transit.rotateKey(ctx, keyName(entity.ES256, 0)); err != nil {
+			t.Fatalf("Transit.rotateKey() error = %v", err)
+		}
+
+		transit.ClearActiveKeysOnUpdate().Handle(event.Event{Type: event.KeySetUpdated})
+
+		got, err := transit.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Transit.GetActive() error = %v", err)
+		}
+
+		if got.Id == cached.Id {
+			t.Errorf("Transit.GetActive() returned the replaced key %s after the keyset was updated", got.Id)
+		}
+	})
+
+	t.Run("Test if returns an error on unsupported algorithm", func(t *testing.T) {
+		if _, err := transit.GetActive(ctx, entity.HS256); !errors.Is(err, ErrAlgorithmNotSupported) {
+			t.Errorf("Transit.GetActive() error = %v, want %v", err, ErrAlgorithmNotSupported)
+		}
+	})
+}
+
+func TestTransit_Sign(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	transit := setUpTransit(t, 1)
+
+	keys, err := transit.GetKeySet(ctx)
+	if err != nil {
+		t.Fatalf("Transit.GetKeySet() error = %v", err)
+	}
+
+	m := manager.MakeManager(manager.Config{Issuer: "test"})
+	token := entity.Token{
+		Id:        "test",
+		UserId:    "test-id",
+		Type:      entity.AccessToken,
+		ExpiresAt: time.Now().Add(time.Minute),
+		IssuedAt:  time.Now(),
+	}
+
+	for _, key := range keys {
+		t.Run("Test if token signed remotely with "+string(key.Algorithm)+" verifies with the exported public key", func(t *testing.T) {
+			signed, err := m.Encode(key, token)
+			if err != nil {
+				t.Fatalf("TokenManager.Encode() error = %v", err)
+			}
+
+			publicKey := key.Signer.Public()
+			if _, err := jws.Verify(signed, jwa.SignatureAlgorithm(key.Algorithm), publicKey); err != nil {
+				t.Errorf("Failed to verify remotely signed token: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package transit
+
+import (
+	"context"
+
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"github.com/krixlion/dev_forum-lib/tracing"
+)
+
+// acquireLease acquires the rotation lease or renews it if it's already held
+// by this instance. It returns false if the lease is held by another instance.
+func (t Transit) acquireLease(ctx context.Context) (_ lease.Lease, _ bool, err error) {
+	ctx, span := t.tracer.Start(ctx, "transit.acquireLease")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	return lease.Acquire(ctx, t.config.LeaseStore, t.id, t.config.leaseTTL())
+}
+
+// releaseLease expires the rotation lease if it's held by this instance
+// so that another instance can take over without waiting for it to expire.
+func (t Transit) releaseLease(ctx context.Context) (err error) {
+	ctx, span := t.tracer.Start(ctx, "transit.releaseLease")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	return lease.Release(ctx, t.config.LeaseStore, t.id)
+}
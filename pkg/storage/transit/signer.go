@@ -0,0 +1,105 @@
+package transit
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// Signer implements crypto.Signer by signing digests with
+// a specific version of a key stored in the Transit engine.
+type Signer struct {
+	// ctx is the context of the request the key was returned for.
+	// crypto.Signer doesn't accept a context so it's bound to the Signer.
+	ctx       context.Context
+	client    *vault.Client
+	mountPath string
+	name      string
+	version   int
+	publicKey crypto.PublicKey
+}
+
+// Public returns the public part of the Transit key. It never calls Vault.
+func (s Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs given digest remotely. ECDSA signatures are ASN.1 encoded
+// and RSA keys use PSS if opts is an *rsa.PSSOptions, PKCS #1 v1.5 otherwise.
+// Provided io.Reader is ignored as randomness is provided by Vault.
+// The request is cancelled along with the context the key was returned for.
+func (s Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hashAlgorithm, err := hashAlgorithmName(opts.HashFunc())
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"input":                base64.StdEncoding.EncodeToString(digest),
+		"prehashed":            true,
+		"key_version":          s.version,
+		"marshaling_algorithm": "asn1",
+	}
+
+	if _, ok := s.publicKey.(*rsa.PublicKey); ok {
+		data["signature_algorithm"] = "pkcs1v15"
+
+		if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+			data["signature_algorithm"] = "pss"
+
+			if pssOpts.SaltLength == rsa.PSSSaltLengthEqualsHash {
+				data["salt_length"] = "hash"
+			}
+		}
+	}
+
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	secret, err := s.client.Logical().WriteWithContext(ctx, s.mountPath+"/sign/"+s.name+"/"+hashAlgorithm, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == nil || secret.Data == nil {
+		return nil, ErrInvalidSignature
+	}
+
+	signature, ok := secret.Data["signature"].(string)
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+
+	return decodeSignature(signature)
+}
+
+// decodeSignature decodes a signature in Vault's "vault:v<version>:<base64>" format.
+func decodeSignature(signature string) ([]byte, error) {
+	parts := strings.SplitN(signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, ErrInvalidSignature
+	}
+
+	return base64.StdEncoding.DecodeString(parts[2])
+}
+
+func hashAlgorithmName(hash crypto.Hash) (string, error) {
+	switch hash {
+	case crypto.SHA256:
+		return "sha2-256", nil
+	case crypto.SHA384:
+		return "sha2-384", nil
+	case crypto.SHA512:
+		return "sha2-512", nil
+	default:
+		return "", fmt.Errorf("unsupported hash function: %v", hash)
+	}
+}
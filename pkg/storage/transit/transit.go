@@ -0,0 +1,272 @@
+// Package transit implements storage.Vault using Vault's Transit secrets engine.
+// Private keys never leave Vault - JWTs are signed remotely and only
+// public keys are exported.
+package transit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ storage.Vault = (*Transit)(nil)
+
+var (
+	ErrKeyNotFound           = errors.New("key not found")
+	ErrAlgorithmNotSupported = errors.New("key's algorithm is not supported")
+	ErrInvalidKey            = errors.New("invalid transit key")
+	ErrInvalidSignature      = errors.New("invalid transit signature")
+)
+
+type Transit struct {
+	// id identifies the instance holding the rotation lease.
+	id     string
+	client *vault.Client
+	active *activeKeys
+	config Config
+	broker event.Broker
+	tracer trace.Tracer
+	logger logging.Logger
+}
+
+type Config struct {
+	// Path in the Vault that the Transit engine is mounted on.
+	MountPath string
+	// Number of keys per supported algorithm to maintain.
+	KeyCount int
+	// How often all maintained keys are rotated. Disabled if 0.
+	KeyRotationInterval time.Duration
+	// Versions replaced by a rotation remain published for this long and are then trimmed.
+	// It has to outlive tokens signed with them, including the ActiveKeyTTL they
+	// can still be signed for. Required if KeyRotationInterval is set.
+	RetiredKeyTTL time.Duration
+	// Only the instance holding the lease kept in LeaseStore creates, rotates and trims keys.
+	// Required if KeyRotationInterval is set.
+	LeaseStore lease.Store
+	// The lease is renewed every third of its TTL and taken over
+	// by another instance once it expires. lease.DefaultTTL is used if zero.
+	LeaseTTL time.Duration
+	// Active keys are cached for this long or until the keyset is updated.
+	// DefaultActiveKeyTTL is used if zero.
+	ActiveKeyTTL time.Duration
+}
+
+// DefaultActiveKeyTTL is used when Config.ActiveKeyTTL is not set.
+const DefaultActiveKeyTTL = time.Minute
+
+// Make connects to Vault with a vaultclient.Client configured with clientConfig
+// and returns a Transit instance or a non nil error. The client is connected over HTTPS
+// if clientConfig.TLS is set and its token is renewed until provided context is cancelled.
+//
+// If config.KeyRotationInterval is greater than 0, Transit starts to compete
+// with other instances for the rotation lease. The instance holding it makes sure
+// that config.KeyCount keys for each supported algorithm exist, periodically rotates
+// them and trims versions replaced more than config.RetiredKeyTTL ago.
+// Transit stops rotating keys and releases the lease when provided context is cancelled.
+func Make(ctx context.Context, clientConfig vaultclient.Config, config Config, broker event.Broker, tracer trace.Tracer, logger logging.Logger) (Transit, error) {
+	client, err := vaultclient.New(ctx, clientConfig, logger)
+	if err != nil {
+		return Transit{}, err
+	}
+
+	return MakeWithClient(ctx, client.Client, config, broker, tracer, logger)
+}
+
+// MakeWithClient works like Make but uses given, already authenticated client.
+func MakeWithClient(ctx context.Context, client *vault.Client, config Config, broker event.Broker, tracer trace.Tracer, logger logging.Logger) (Transit, error) {
+	if tracer == nil {
+		tracer = nulls.NullTracer{}
+	}
+
+	if logger == nil {
+		logger = nulls.NullLogger{}
+	}
+
+	if broker == nil {
+		return Transit{}, errors.New("no broker was provided")
+	}
+
+	if err := config.validate(); err != nil {
+		return Transit{}, fmt.Errorf("failed to validate transit config: %w", err)
+	}
+
+	if client == nil {
+		return Transit{}, errors.New("no vault client was provided")
+	}
+
+	id, err := lease.HolderId()
+	if err != nil {
+		return Transit{}, err
+	}
+
+	transit := Transit{
+		id:     id,
+		client: client,
+		active: newActiveKeys(),
+		config: config,
+		broker: broker,
+		tracer: tracer,
+		logger: logger,
+	}
+
+	if config.KeyRotationInterval > 0 {
+		go transit.run(ctx)
+	}
+
+	return transit, nil
+}
+
+// run blocks until provided context is cancelled.
+// When invoked Transit starts to periodically acquire or renew the rotation
+// lease and, while holding it, maintain keys.
+func (t Transit) run(ctx context.Context) {
+	ticker := time.NewTicker(t.config.leaseTTL() / 3)
+	defer ticker.Stop()
+
+	// Keys are only read once they are due to be maintained.
+	var next time.Time
+
+	for {
+		next = t.maintainIfLeader(ctx, next)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			// Let another instance take over right away.
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+			defer cancel()
+
+			if err := t.releaseLease(ctx); err != nil {
+				t.logger.Log(ctx, "failed to release lease", "err", err)
+			}
+			return
+		}
+	}
+}
+
+// maintainIfLeader maintains keys if this instance holds the rotation lease
+// and given time has passed. It returns the time keys have to be maintained next.
+func (t Transit) maintainIfLeader(ctx context.Context, next time.Time) time.Time {
+	_, ok, err := t.acquireLease(ctx)
+	if err != nil {
+		t.logger.Log(ctx, "failed to acquire lease", "err", err)
+		return next
+	}
+
+	if !ok {
+		// Another instance maintains keys until this one takes over.
+		return time.Time{}
+	}
+
+	now := time.Now()
+	if now.Before(next) {
+		return next
+	}
+
+	next, err = t.maintainKeys(ctx, now)
+	if err != nil {
+		t.logger.Log(ctx, "failed to maintain keys", "err", err)
+		return time.Time{}
+	}
+
+	return next
+}
+
+// EventHandlers returns handlers dropping cached active keys
+// whenever any instance rotates them.
+func (t Transit) EventHandlers() map[event.EventType][]event.Handler {
+	return map[event.EventType][]event.Handler{
+		event.KeySetUpdated: {t.ClearActiveKeysOnUpdate()},
+	}
+}
+
+// ClearActiveKeysOnUpdate drops cached active keys
+// so that they are read again on the next GetActive.
+func (t Transit) ClearActiveKeysOnUpdate() event.Handler {
+	return event.HandlerFunc(func(event.Event) {
+		t.active.clear()
+	})
+}
+
+func (config Config) validate() error {
+	if config.MountPath == "" {
+		return errors.New("mount path cannot be empty")
+	}
+
+	if config.KeyCount < 0 {
+		return errors.New("key count has to be a non-negative number")
+	}
+
+	if config.KeyRotationInterval < 0 {
+		return errors.New("key rotation interval has to be a non-negative time duration")
+	}
+
+	if config.LeaseTTL < 0 {
+		return errors.New("lease TTL has to be a non-negative time duration")
+	}
+
+	if config.ActiveKeyTTL < 0 {
+		return errors.New("active key TTL has to be a non-negative time duration")
+	}
+
+	if config.KeyRotationInterval > 0 {
+		if config.LeaseStore == nil {
+			return errors.New("lease store is required to rotate keys")
+		}
+
+		if config.RetiredKeyTTL <= config.activeKeyTTL() {
+			return errors.New("retired key TTL has to be greater than the active key TTL")
+		}
+	}
+
+	return nil
+}
+
+// leaseTTL returns the duration of the rotation lease.
+func (config Config) leaseTTL() time.Duration {
+	if config.LeaseTTL == 0 {
+		return lease.DefaultTTL
+	}
+	return config.LeaseTTL
+}
+
+// activeKeyTTL returns how long active keys are cached.
+func (config Config) activeKeyTTL() time.Duration {
+	if config.ActiveKeyTTL == 0 {
+		return DefaultActiveKeyTTL
+	}
+	return config.ActiveKeyTTL
+}
+
+// keyTypes maps supported algorithms to Transit key types.
+var keyTypes = map[entity.Algorithm]string{
+	entity.ES256: "ecdsa-p256",
+	entity.RS256: "rsa-2048",
+}
+
+// SupportedAlgorithms returns algorithms keys are maintained for, sorted by name.
+func SupportedAlgorithms() []entity.Algorithm {
+	return slices.Sorted(maps.Keys(keyTypes))
+}
+
+func algorithmFromKeyType(keyType string) (entity.Algorithm, error) {
+	for algorithm, typ := range keyTypes {
+		if typ == keyType {
+			return algorithm, nil
+		}
+	}
+	return "", ErrAlgorithmNotSupported
+}
@@ -0,0 +1,65 @@
+package transit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+)
+
+func Test_Make(t *testing.T) {
+	t.Run("Test default logger and tracer are correctly assigned when not provided", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		got, err := Make(ctx, vaultclient.Config{Host: "host", Port: "8888", Auth: vaultclient.AuthConfig{Token: "token"}}, Config{MountPath: "transit"}, mocks.NewBroker(), nil, nil)
+		if err != nil {
+			t.Errorf("Make(): error = %v", err)
+			return
+		}
+
+		if !cmp.Equal(got.logger, nulls.NullLogger{}) {
+			t.Errorf("Make(): default logger not assigned:\n = %+v, want %+v", got.logger, nulls.NullLogger{})
+		}
+
+		if !cmp.Equal(got.tracer, nulls.NullTracer{}) {
+			t.Errorf("Make(): default tracer not assigned:\n = %+v, want %+v", got.tracer, nulls.NullTracer{})
+		}
+	})
+
+	t.Run("Test invalid config is rejected", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		configs := []Config{
+			{MountPath: ""},
+			{MountPath: "transit", KeyCount: -1},
+			{MountPath: "transit", KeyRotationInterval: -time.Hour},
+			{MountPath: "transit", LeaseTTL: -time.Second},
+			{MountPath: "transit", KeyRotationInterval: time.Hour, RetiredKeyTTL: time.Hour * 2},
+			{MountPath: "transit", KeyRotationInterval: time.Hour, RetiredKeyTTL: time.Second, LeaseStore: lease.KVStore{}},
+		}
+
+		for _, config := range configs {
+			if _, err := Make(ctx, vaultclient.Config{Host: "host", Port: "8888", Auth: vaultclient.AuthConfig{Token: "token"}}, config, mocks.NewBroker(), nil, nil); err == nil {
+				t.Errorf("Make(): expected an error for config %+v", config)
+			}
+		}
+	})
+
+	t.Run("Test returns an error when the vault client config is invalid", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clientConfig := vaultclient.Config{Host: "host", Port: "8888", Auth: vaultclient.AuthConfig{Method: vaultclient.TokenAuth}}
+
+		if _, err := Make(ctx, clientConfig, Config{MountPath: "transit"}, mocks.NewBroker(), nil, nil); err == nil {
+			t.Errorf("Make(): error = %v, wantErr = true", err)
+		}
+	})
+}
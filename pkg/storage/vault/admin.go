@@ -0,0 +1,208 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-lib/tracing"
+)
+
+// ListKeys returns all stored keys sorted by their ids.
+// Unlike GetKeySet it always reads from the store.
+func (db Vault) ListKeys(ctx context.Context) (_ []entity.KeyInfo, err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.ListKeys")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	keyPaths, err := db.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keyPaths)
+
+	keys := make([]entity.KeyInfo, 0, len(keyPaths))
+	states := make([]keyState, 0, len(keyPaths))
+
+	for _, path := range keyPaths {
+		stored, err := db.store.Get(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, entity.KeyInfo{
+			Id:        path,
+			Type:      stored.KeyType,
+			Algorithm: stored.Algorithm,
+			CreatedAt: stored.CreatedAt,
+			Imported:  stored.Imported,
+			NotBefore: stored.NotBefore,
+		})
+		states = append(states, stateOf(path, stored))
+	}
+
+	for _, i := range pickActive(states, time.Now()) {
+		keys[i].Active = true
+	}
+
+	return keys, nil
+}
+
+// RotateNow replaces all keys with a new set and restarts the rotation schedule.
+// It acquires the rotation lease first so that it never races with scheduled
+// rotation on another instance. If another instance holds the lease the
+// rotation is requested in the schedule and performed by that instance at
+// its next tick. storage.ErrNotLeader is returned only if config.KeyRefreshInterval
+// is 0, since then no instance would act on the request.
+func (db Vault) RotateNow(ctx context.Context) (err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.RotateNow")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	_, ok, err := db.acquireLease(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if db.config.KeyRefreshInterval == 0 {
+			return storage.ErrNotLeader
+		}
+		return db.requestRotation(ctx, time.Now())
+	}
+
+	if err := db.refreshKeys(ctx); err != nil {
+		return err
+	}
+
+	return db.recordRotation(ctx, time.Now())
+}
+
+// RevokeKey deletes the key with given id. If it was the active key the next
+// key of the same algorithm is marked as active. If there is no such key
+// a new one is generated so that tokens can still be signed.
+// Like RotateNow it acquires the rotation lease so that a rotation on another
+// instance can't write the revoked key back. It returns storage.ErrNotLeader
+// if another instance holds the lease.
+func (db Vault) RevokeKey(ctx context.Context, kid string) (err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.RevokeKey")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	_, ok, err := db.acquireLease(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return storage.ErrNotLeader
+	}
+
+	db.keys.loading.Lock()
+	defer db.keys.loading.Unlock()
+
+	revoked, err := db.store.Get(ctx, kid)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := db.loadSnapshotLocked(ctx)
+	if err != nil {
+		return err
+	}
+	active, _ := snapshot.activeKey(revoked.Algorithm, time.Now())
+	wasActive := active.Id == kid
+
+	if err := db.store.Delete(ctx, kid); err != nil {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+
+	if wasActive {
+		if err := db.promote(ctx, revoked.Algorithm); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.loadSnapshotLocked(ctx); err != nil {
+		return err
+	}
+
+	return db.publishKeySetUpdated(ctx)
+}
+
+// promote marks the lexicographically first key of given algorithm as active
+// or creates a new active key if there is none. Imported keys are active
+// based on their not before time, so they are never marked.
+func (db Vault) promote(ctx context.Context, algorithm entity.Algorithm) error {
+	snapshot, err := db.loadSnapshotLocked(ctx)
+	if err != nil {
+		return err
+	}
+
+	if next, ok := snapshot.activeKey(algorithm, time.Now()); ok {
+		stored, err := db.store.Get(ctx, next.Id)
+		if err != nil {
+			return err
+		}
+
+		if stored.Imported {
+			return nil
+		}
+
+		stored.Active = true
+
+		if err := db.store.Put(ctx, next.Id, stored); err != nil {
+			return fmt.Errorf("failed to activate key: %w", err)
+		}
+		return nil
+	}
+
+	policy := KeyPolicy{Algorithm: algorithm}
+	for _, p := range db.config.keyPolicy() {
+		if p.Algorithm == algorithm {
+			policy = p
+		}
+	}
+
+	encodedKey, err := db.newPem(ctx, policy)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.create(ctx, keystore.StoredKey{
+		Algorithm:  algorithm,
+		KeyType:    keyTypes[algorithm],
+		EncodedKey: encodedKey,
+		Active:     true,
+	})
+	return err
+}
+
+// GetRotationStatus returns the rotation schedule and the state of the rotation lease.
+func (db Vault) GetRotationStatus(ctx context.Context) (_ entity.RotationStatus, err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.GetRotationStatus")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	lease, err := db.store.GetLease(ctx)
+	if err != nil {
+		return entity.RotationStatus{}, err
+	}
+
+	schedule, err := db.store.GetSchedule(ctx)
+	if err != nil {
+		return entity.RotationStatus{}, err
+	}
+
+	status := entity.RotationStatus{
+		LastRotatedAt:  schedule.LastRotatedAt,
+		NextRotationAt: schedule.NextRotationAt,
+		Leader:         lease.Holder,
+		LeaseExpiresAt: lease.ExpiresAt,
+	}
+
+	return status, nil
+}
@@ -0,0 +1,311 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+)
+
+func TestVault_ListKeys(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	db := setUpStandInVault(t, newStandInServer(t))
+	if err := db.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	keys, err := db.ListKeys(ctx)
+	if err != nil {
+		t.Fatalf("Vault.ListKeys() error = %v", err)
+	}
+
+	if want := 5; len(keys) != want {
+		t.Fatalf("Vault.ListKeys() returned %d keys, want %d", len(keys), want)
+	}
+
+	active := map[entity.Algorithm]string{}
+	for i, key := range keys {
+		if i > 0 && keys[i-1].Id >= key.Id {
+			t.Errorf("Vault.ListKeys() keys are not sorted: %s >= %s", keys[i-1].Id, key.Id)
+		}
+
+		if key.CreatedAt.IsZero() {
+			t.Errorf("Vault.ListKeys() key %s has no creation time", key.Id)
+		}
+
+		if key.Active {
+			if _, ok := active[key.Algorithm]; ok {
+				t.Errorf("Vault.ListKeys() returned more than one active %s key", key.Algorithm)
+			}
+			active[key.Algorithm] = key.Id
+		}
+	}
+
+	for _, policy := range testKeyPolicy {
+		key, err := db.GetActive(ctx, policy.Algorithm)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if active[policy.Algorithm] != key.Id {
+			t.Errorf("Vault.ListKeys() active %s key = %s, want %s", policy.Algorithm, active[policy.Algorithm], key.Id)
+		}
+	}
+}
+
+func TestVault_RevokeKey(t *testing.T) {
+	t.Run("Test if another key becomes active when the active key is revoked", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		server := newStandInServer(t)
+		db := setUpStandInVault(t, server)
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		revoked, err := db.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if err := db.RevokeKey(ctx, revoked.Id); err != nil {
+			t.Fatalf("Vault.RevokeKey() error = %v", err)
+		}
+
+		got, err := db.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if got.Id == revoked.Id {
+			t.Errorf("Vault.GetActive() returned revoked key %s", got.Id)
+		}
+
+		// A fresh instance has to agree on the promoted key.
+		other, err := setUpStandInVault(t, server).GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if other.Id != got.Id {
+			t.Errorf("Vault.GetActive() on another instance = %s, want %s", other.Id, got.Id)
+		}
+
+		keys, err := db.GetKeySet(ctx)
+		if err != nil {
+			t.Fatalf("Vault.GetKeySet() error = %v", err)
+		}
+
+		for _, key := range keys {
+			if key.Id == revoked.Id {
+				t.Errorf("Vault.GetKeySet() returned revoked key %s", key.Id)
+			}
+		}
+	})
+
+	t.Run("Test if a new key is generated when the last key of an algorithm is revoked", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		db := setUpStandInVault(t, newStandInServer(t))
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		revoked := map[string]bool{}
+
+		// Revoke all EdDSA keys.
+		for i := 0; i < 2; i++ {
+			key, err := db.GetActive(ctx, entity.EdDSA)
+			if err != nil {
+				t.Fatalf("Vault.GetActive() error = %v", err)
+			}
+
+			if err := db.RevokeKey(ctx, key.Id); err != nil {
+				t.Fatalf("Vault.RevokeKey() error = %v", err)
+			}
+			revoked[key.Id] = true
+		}
+
+		got, err := db.GetActive(ctx, entity.EdDSA)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if revoked[got.Id] {
+			t.Errorf("Vault.GetActive() returned revoked key %s", got.Id)
+		}
+	})
+
+	t.Run("Test if returns storage.ErrKeyNotFound on unknown kid", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		db := setUpStandInVault(t, newStandInServer(t))
+
+		if err := db.RevokeKey(ctx, "unknown"); !errors.Is(err, storage.ErrKeyNotFound) {
+			t.Errorf("Vault.RevokeKey() error = %v, want %v", err, storage.ErrKeyNotFound)
+		}
+	})
+}
+
+func TestVault_RotateNow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	db := setUpStandInVault(t, newStandInServer(t))
+	db.config.KeyRefreshInterval = time.Hour
+
+	status, err := db.GetRotationStatus(ctx)
+	if err != nil {
+		t.Fatalf("Vault.GetRotationStatus() error = %v", err)
+	}
+
+	if !status.LastRotatedAt.IsZero() || !status.NextRotationAt.IsZero() {
+		t.Errorf("Vault.GetRotationStatus() before rotation = %+v, want zero times", status)
+	}
+
+	if err := db.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	before, err := db.GetActive(ctx, entity.ES256)
+	if err != nil {
+		t.Fatalf("Vault.GetActive() error = %v", err)
+	}
+
+	if err := db.RotateNow(ctx); err != nil {
+		t.Fatalf("Vault.RotateNow() error = %v", err)
+	}
+
+	after, err := db.GetActive(ctx, entity.ES256)
+	if err != nil {
+		t.Fatalf("Vault.GetActive() error = %v", err)
+	}
+
+	if after.Id == before.Id {
+		t.Errorf("Vault.GetActive() returned the same key %s after rotation", after.Id)
+	}
+
+	status, err = db.GetRotationStatus(ctx)
+	if err != nil {
+		t.Fatalf("Vault.GetRotationStatus() error = %v", err)
+	}
+
+	if time.Since(status.LastRotatedAt) > time.Minute {
+		t.Errorf("Vault.GetRotationStatus() LastRotatedAt = %v, want about now", status.LastRotatedAt)
+	}
+
+	if want := status.LastRotatedAt.Add(time.Hour); !status.NextRotationAt.Equal(want) {
+		t.Errorf("Vault.GetRotationStatus() NextRotationAt = %v, want %v", status.NextRotationAt, want)
+	}
+}
+
+func TestVault_RotateNow_NotLeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	server := newStandInServer(t)
+	leader := setUpStandInVault(t, server)
+	leader.config.KeyRefreshInterval = time.Hour
+	follower := setUpStandInVault(t, server)
+	follower.config.KeyRefreshInterval = time.Hour
+
+	if _, ok, err := leader.acquireLease(ctx); err != nil || !ok {
+		t.Fatalf("Vault.acquireLease() = %v, error = %v", ok, err)
+	}
+
+	if err := leader.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	if err := leader.recordRotation(ctx, time.Now()); err != nil {
+		t.Fatalf("Vault.recordRotation() error = %v", err)
+	}
+
+	before, err := leader.GetActive(ctx, entity.ES256)
+	if err != nil {
+		t.Fatalf("Vault.GetActive() error = %v", err)
+	}
+
+	if err := follower.RotateNow(ctx); err != nil {
+		t.Fatalf("Vault.RotateNow() error = %v", err)
+	}
+
+	after, err := leader.GetActive(ctx, entity.ES256)
+	if err != nil {
+		t.Fatalf("Vault.GetActive() error = %v", err)
+	}
+
+	if after.Id != before.Id {
+		t.Errorf("Vault.GetActive() = %s right after rotation was requested, want %s", after.Id, before.Id)
+	}
+
+	if err := leader.rotateIfLeader(ctx); err != nil {
+		t.Fatalf("Vault.rotateIfLeader() error = %v", err)
+	}
+
+	after, err = leader.GetActive(ctx, entity.ES256)
+	if err != nil {
+		t.Fatalf("Vault.GetActive() error = %v", err)
+	}
+
+	if after.Id == before.Id {
+		t.Errorf("Vault.GetActive() returned the same key %s after the leader's tick", after.Id)
+	}
+
+	due, err := leader.rotationDue(ctx)
+	if err != nil {
+		t.Fatalf("Vault.rotationDue() error = %v", err)
+	}
+
+	if due {
+		t.Errorf("Vault.rotationDue() = true after the requested rotation, want false")
+	}
+
+	follower.config.KeyRefreshInterval = 0
+
+	if err := follower.RotateNow(ctx); !errors.Is(err, storage.ErrNotLeader) {
+		t.Errorf("Vault.RotateNow() without periodic rotation error = %v, want %v", err, storage.ErrNotLeader)
+	}
+}
+
+func TestVault_RevokeKey_NotLeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	server := newStandInServer(t)
+	leader := setUpStandInVault(t, server)
+	follower := setUpStandInVault(t, server)
+
+	if _, ok, err := leader.acquireLease(ctx); err != nil || !ok {
+		t.Fatalf("Vault.acquireLease() = %v, error = %v", ok, err)
+	}
+
+	if err := leader.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	active, err := leader.GetActive(ctx, entity.ES256)
+	if err != nil {
+		t.Fatalf("Vault.GetActive() error = %v", err)
+	}
+
+	if err := follower.RevokeKey(ctx, active.Id); !errors.Is(err, storage.ErrNotLeader) {
+		t.Fatalf("Vault.RevokeKey() error = %v, want %v", err, storage.ErrNotLeader)
+	}
+
+	if _, err := leader.store.Get(ctx, active.Id); err != nil {
+		t.Errorf("Vault.RevokeKey() by a follower deleted the key, Get() error = %v", err)
+	}
+
+	if err := leader.RevokeKey(ctx, active.Id); err != nil {
+		t.Errorf("Vault.RevokeKey() by the leader error = %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package vault
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+)
+
+// DefaultCertificateValidity is used when CAConfig.Validity is not set.
+const DefaultCertificateValidity = time.Hour * 24 * 30
+
+var ErrCertificateMismatch = errors.New("certificate does not certify the key")
+
+// CertificateIssuer issues certificates for signing keys.
+type CertificateIssuer interface {
+	// Issue returns a chain certifying given public key, leaf first.
+	Issue(ctx context.Context, kid string, publicKey crypto.PublicKey) ([]*x509.Certificate, error)
+}
+
+type CAConfig struct {
+	// Path of the KVv2 secret holding the CA, relative to its mount.
+	// The secret has a "certificate" field with the PEM encoded CA certificate
+	// followed by its intermediates, if any, and a "private" field with its key.
+	Path      string
+	MountPath string
+	// How long issued certificates are valid for. DefaultCertificateValidity is used if zero.
+	// It should exceed the key refresh interval since certificates are not renewed.
+	Validity time.Duration
+}
+
+// CA issues certificates with a key kept in Vault.
+type CA struct {
+	// chain starts with the CA's certificate.
+	chain    []*x509.Certificate
+	signer   crypto.Signer
+	validity time.Duration
+}
+
+// LoadCA reads the CA from Vault's KVv2 engine and returns it or a non-nil error.
+func LoadCA(ctx context.Context, client *vault.Client, config CAConfig) (*CA, error) {
+	if client == nil {
+		return nil, errors.New("no vault client was provided")
+	}
+
+	if config.MountPath == "" || config.Path == "" {
+		return nil, errors.New("failed to validate ca config: mount path and path cannot be empty")
+	}
+
+	secret, err := client.KVv2(config.MountPath).Get(ctx, config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ca: %w", err)
+	}
+
+	certificate, _ := secret.Data["certificate"].(string)
+	private, _ := secret.Data["private"].(string)
+
+	return NewCA(certificate, private, config.Validity)
+}
+
+// NewCA returns a CA issuing certificates with given PEM encoded certificate chain and key.
+func NewCA(encodedChain, encodedKey string, validity time.Duration) (*CA, error) {
+	chain, err := DecodeCertificates(encodedChain)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("ca certificate is missing")
+	}
+
+	if !chain[0].IsCA {
+		return nil, errors.New("ca certificate is not a ca")
+	}
+
+	key, err := keystore.DecodePrivateKey(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ca key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, keystore.ErrInvalidKeyType
+	}
+
+	if !publicKeysEqual(chain[0].PublicKey, signer.Public()) {
+		return nil, fmt.Errorf("%w: ca key does not match its certificate", ErrCertificateMismatch)
+	}
+
+	if validity == 0 {
+		validity = DefaultCertificateValidity
+	}
+
+	return &CA{
+		chain:    chain,
+		signer:   signer,
+		validity: validity,
+	}, nil
+}
+
+// Issue returns a certificate for given key followed by the CA's chain.
+// The certificate's subject is the kid and it's only valid for signatures.
+func (ca *CA) Issue(_ context.Context, kid string, publicKey crypto.PublicKey) ([]*x509.Certificate, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: kid},
+		// Tolerates clock skew between the service and verifiers.
+		NotBefore: now.Add(-time.Minute * 5),
+		NotAfter:  now.Add(ca.validity),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.chain[0], publicKey, ca.signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue certificate: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*x509.Certificate{leaf}, ca.chain...), nil
+}
+
+// EncodeCertificates returns given certificates as concatenated PEM blocks.
+func EncodeCertificates(certificates []*x509.Certificate) string {
+	var encoded strings.Builder
+	for _, certificate := range certificates {
+		encoded.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}))
+	}
+	return encoded.String()
+}
+
+// DecodeCertificates parses concatenated PEM encoded certificates.
+// It returns nil if there are none.
+func DecodeCertificates(encoded string) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+
+	rest := []byte(encoded)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("%w: unexpected %s block", keystore.ErrInvalidKeyFormat, block.Type)
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		certificates = append(certificates, certificate)
+	}
+
+	return certificates, nil
+}
+
+// issueCertificates returns PEM encoded certificates for given key
+// or an empty string if no issuer is configured.
+func (db Vault) issueCertificates(ctx context.Context, kid string, key keystore.StoredKey) (string, error) {
+	if db.config.CertificateIssuer == nil {
+		return "", nil
+	}
+
+	publicKey, _, err := keystore.DecodePublicKey(key.Algorithm, key.EncodedKey)
+	if err != nil {
+		return "", err
+	}
+
+	certificates, err := db.config.CertificateIssuer.Issue(ctx, kid, publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	return EncodeCertificates(certificates), nil
+}
+
+// publicKeysEqual returns true if both keys are the same.
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
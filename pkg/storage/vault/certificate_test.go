@@ -0,0 +1,173 @@
+package vault
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vault/vaulttest"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/stretchr/testify/mock"
+)
+
+// newStandInClient returns a client connected to given in-memory Vault stand-in.
+func newStandInClient(t *testing.T, server *vaulttest.Server) *vault.Client {
+	config := vault.DefaultConfig()
+	config.Address = server.URL
+
+	client, err := vault.NewClient(config)
+	if err != nil {
+		t.Fatalf("Failed to make vault client: %v", err)
+	}
+	client.SetToken(server.Token)
+
+	return client
+}
+
+func TestLoadCA(t *testing.T) {
+	tests := []struct {
+		desc string
+		// data is nil if the secret is not written.
+		data    map[string]interface{}
+		wantErr error
+	}{
+		{
+			desc: "Test if loads a CA",
+			data: map[string]interface{}{"certificate": testdata.CA.CertPem, "private": testdata.CA.PrivPem},
+		},
+		{
+			desc:    "Test if returns an error when the CA is missing",
+			wantErr: vault.ErrSecretNotFound,
+		},
+		{
+			desc:    "Test if rejects a key not matching the certificate",
+			data:    map[string]interface{}{"certificate": testdata.CA.CertPem, "private": testdata.ECDSA.PrivPem},
+			wantErr: ErrCertificateMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancel()
+
+			server := newStandInServer(t)
+			server.EnableKVv2("ca")
+			client := newStandInClient(t, server)
+
+			if tt.data != nil {
+				if _, err := client.KVv2("ca").Put(ctx, "signing", tt.data); err != nil {
+					t.Fatalf("Failed to put ca: %v", err)
+				}
+			}
+
+			ca, err := LoadCA(ctx, client, CAConfig{MountPath: "ca", Path: "signing"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("LoadCA() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadCA() error = %v", err)
+			}
+
+			if ca.validity != DefaultCertificateValidity {
+				t.Errorf("LoadCA() validity = %v, want %v", ca.validity, DefaultCertificateValidity)
+			}
+		})
+	}
+}
+
+func TestVault_certificates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	ca, err := NewCA(testdata.CA.CertPem, testdata.CA.PrivPem, time.Hour)
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.Anything).Return(nil)
+
+	server := newStandInServer(t)
+	host, port := server.HostPort()
+	config := Config{
+		MountPath:         "secret",
+		KeyPolicy:         testKeyPolicy,
+		CertificateIssuer: ca,
+	}
+
+	db, err := Make(ctx, vaultclient.Config{Host: host, Port: port, Auth: vaultclient.AuthConfig{Token: server.Token}}, config, broker, nulls.NullTracer{}, nulls.NullLogger{})
+	if err != nil {
+		t.Fatalf("Failed to make vault: %v", err)
+	}
+
+	if err := db.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	if _, err := db.ImportKey(ctx, entity.ImportedKey{Algorithm: entity.ES256, EncodedKey: testdata.ECDSA.PrivPem}); err != nil {
+		t.Fatalf("Vault.ImportKey() error = %v", err)
+	}
+
+	keys, err := db.GetKeySet(ctx)
+	if err != nil {
+		t.Fatalf("Vault.GetKeySet() error = %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(testdata.CA.Cert)
+
+	for _, key := range keys {
+		if len(key.Certificates) != 2 {
+			t.Errorf("Vault.GetKeySet() returned key %s with %d certificates, want 2", key.Id, len(key.Certificates))
+			continue
+		}
+
+		leaf := key.Certificates[0]
+		if leaf.Subject.CommonName != key.Id {
+			t.Errorf("Certificate subject = %s, want %s", leaf.Subject.CommonName, key.Id)
+		}
+
+		if !publicKeysEqual(leaf.PublicKey, key.Signer.Public()) {
+			t.Errorf("Certificate of key %s certifies another key", key.Id)
+		}
+
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+			t.Errorf("Certificate of key %s failed to verify: %v", key.Id, err)
+		}
+	}
+}
+
+func Test_makeKey_certificates(t *testing.T) {
+	ca, err := NewCA(testdata.CA.CertPem, testdata.CA.PrivPem, time.Hour)
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+
+	// Certifies the Ed25519 key instead of the stored ECDSA key.
+	certificates, err := ca.Issue(context.Background(), testdata.Ed25519.Id, testdata.Ed25519.PubKey)
+	if err != nil {
+		t.Fatalf("CA.Issue() error = %v", err)
+	}
+
+	stored := keystore.StoredKey{
+		Algorithm:    entity.ES256,
+		KeyType:      entity.ECDSA,
+		EncodedKey:   testdata.ECDSA.PrivPem,
+		Certificates: EncodeCertificates(certificates),
+	}
+
+	if _, err := makeKey(testdata.ECDSA.Id, stored); !errors.Is(err, ErrCertificateMismatch) {
+		t.Errorf("makeKey() error = %v, want %v", err, ErrCertificateMismatch)
+	}
+}
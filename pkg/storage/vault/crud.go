@@ -3,6 +3,7 @@ package vault
 import (
 	"context"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -10,123 +11,129 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"math/big"
+	"time"
 
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
 	"github.com/krixlion/dev_forum-lib/event"
-	"github.com/krixlion/dev_forum-lib/str"
 	"github.com/krixlion/dev_forum-lib/tracing"
 )
 
-// GetRandom returns a random existing private key from the Vault.
-func (db Vault) GetRandom(ctx context.Context) (_ entity.Key, err error) {
-	ctx, span := db.tracer.Start(ctx, "vault.GetRandom")
+// GetActive returns the key used to sign tokens with given algorithm.
+// Other keys are used only to verify tokens. Keys are served from an in-memory
+// snapshot, so the active key changes only when keys are rotated.
+func (db Vault) GetActive(ctx context.Context, algorithm entity.Algorithm) (_ entity.Key, err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.GetActive")
 	defer span.End()
 	defer tracing.SetSpanErr(span, err)
 
-	keyPaths, err := db.list(ctx, db.config.MountPath)
+	snapshot, err := db.snapshot(ctx)
 	if err != nil {
 		return entity.Key{}, err
 	}
 
-	if len(keyPaths) <= 0 {
-		return entity.Key{}, errors.New("key not found")
+	key, ok := snapshot.activeKey(algorithm, time.Now())
+	if !ok {
+		return entity.Key{}, fmt.Errorf("%w: no %s key", storage.ErrKeyNotFound, algorithm)
 	}
 
-	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(keyPaths))))
-	if err != nil {
-		return entity.Key{}, err
-	}
-
-	randomPath := keyPaths[n.Int64()]
-
-	secret, err := db.vault.Get(ctx, randomPath)
-	if err != nil {
-		return entity.Key{}, err
-	}
-
-	parsed, err := parseSecret(secret)
-	if err != nil {
-		return entity.Key{}, err
-	}
-
-	return makeKey(randomPath, parsed)
+	return key, nil
 }
 
 // GetKeySet returns a slice of keys present in the Vault.
+// Keys are served from an in-memory snapshot.
 func (db Vault) GetKeySet(ctx context.Context) (_ []entity.Key, err error) {
 	ctx, span := db.tracer.Start(ctx, "vault.GetKeySet")
 	defer span.End()
 	defer tracing.SetSpanErr(span, err)
 
-	keyPaths, err := db.list(ctx, db.config.MountPath)
+	snapshot, err := db.snapshot(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	keys := []entity.Key{}
+	keys := make([]entity.Key, len(snapshot.keys))
+	copy(keys, snapshot.keys)
 
-	for _, path := range keyPaths {
-		secret, err := db.vault.Get(ctx, path)
-		if err != nil {
-			return nil, err
-		}
+	return keys, nil
+}
 
-		parsed, err := parseSecret(secret)
-		if err != nil {
-			return nil, err
-		}
+// snapshot returns the current key snapshot, loading it from the store
+// if none was loaded yet.
+func (db Vault) snapshot(ctx context.Context) (*keySnapshot, error) {
+	if snapshot := db.keys.get(); snapshot != nil {
+		return snapshot, nil
+	}
 
-		key, err := makeKey(path, parsed)
-		if err != nil {
-			return nil, err
-		}
+	db.keys.loading.Lock()
+	defer db.keys.loading.Unlock()
 
-		keys = append(keys, key)
+	// Another caller might have loaded the snapshot in the meantime.
+	if snapshot := db.keys.get(); snapshot != nil {
+		return snapshot, nil
 	}
 
-	return keys, nil
+	return db.loadSnapshotLocked(ctx)
+}
+
+// reloadSnapshot replaces the current key snapshot with keys fetched from the store.
+func (db Vault) reloadSnapshot(ctx context.Context) (err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.reloadSnapshot")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	db.keys.loading.Lock()
+	defer db.keys.loading.Unlock()
+
+	_, err = db.loadSnapshotLocked(ctx)
+	return err
 }
 
-// list returns a slice containing all available paths in the Vault.
-// They can be used to retrieve a key from the Vault.
-func (db Vault) list(ctx context.Context, mountPath string) (_ []string, err error) {
-	ctx, span := db.tracer.Start(ctx, "vault.list")
+// loadSnapshotLocked fetches and decodes all keys from the store and saves
+// them as the current snapshot. Caller has to hold db.keys.loading.
+func (db Vault) loadSnapshotLocked(ctx context.Context) (_ *keySnapshot, err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.loadSnapshot")
 	defer span.End()
 	defer tracing.SetSpanErr(span, err)
 
-	secret, err := db.client.Logical().ListWithContext(ctx, mountPath+"/metadata/")
+	keyPaths, err := db.store.List(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check early in order to avoid unnecessary loop.
-	if secret == nil || secret.Data == nil {
-		return nil, nil
-	}
-
-	paths := make([]string, 0, len(secret.Data))
+	keys := make([]entity.Key, 0, len(keyPaths))
+	states := map[string]keyState{}
 
-	for _, pathLists := range secret.Data {
-		pathList, ok := pathLists.([]interface{})
-		if !ok {
-			return nil, ErrFailedToParseKey
+	for _, path := range keyPaths {
+		stored, err := db.store.Get(ctx, path)
+		if isMalformed(err) {
+			// Serve remaining keys rather than none.
+			db.logger.Log(ctx, "skipping malformed key", "id", path, "err", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
 		}
 
-		for _, path := range pathList {
-			path, ok := path.(string)
-			if !ok {
-				return nil, ErrFailedToParseKey
-			}
-
-			paths = append(paths, path)
+		key, err := makeKey(path, stored)
+		if err != nil {
+			db.logger.Log(ctx, "skipping malformed key", "id", path, "err", err)
+			continue
 		}
+
+		keys = append(keys, key)
+		states[path] = stateOf(path, stored)
 	}
 
-	return paths, nil
+	snapshot := newKeySnapshot(keys, states, time.Now())
+	db.keys.store(snapshot)
+
+	return snapshot, nil
 }
 
-// refreshKeys wipes out all keys from the Vault and inserts new randomly
+// refreshKeys retires all generated keys in the store and inserts new randomly
 // generated valid keys in amount specified in config.
 func (db Vault) refreshKeys(ctx context.Context) (err error) {
 	ctx, span := db.tracer.Start(ctx, "vault.refreshKeys")
@@ -139,42 +146,65 @@ func (db Vault) refreshKeys(ctx context.Context) (err error) {
 		}
 	}()
 
-	if err := db.purge(ctx); err != nil {
+	// Hold off snapshot loads until the new key set is complete.
+	db.keys.loading.Lock()
+	defer db.keys.loading.Unlock()
+
+	retained, err := db.purge(ctx, time.Now())
+	if err != nil {
 		return err
 	}
 
-	for i := 0; i < db.config.KeyCount; i++ {
-		ECPem, err := db.newECDSAPem(ctx)
+	keys := []entity.Key{}
+	states := map[string]keyState{}
+
+	for id, stored := range retained {
+		key, err := makeKey(id, stored)
 		if err != nil {
-			return err
+			db.logger.Log(ctx, "skipping malformed key", "id", id, "err", err)
+			continue
 		}
 
-		secretECDSA := secretData{
-			algorithm:  entity.ES256,
-			keyType:    entity.ECDSA,
-			encodedKey: ECPem,
-		}
+		keys = append(keys, key)
+		states[id] = stateOf(id, stored)
+	}
 
-		if err := db.create(ctx, secretECDSA); err != nil {
-			return err
-		}
+	for _, policy := range db.config.keyPolicy() {
+		for i := 0; i < policy.count(db.config.KeyCount); i++ {
+			encodedKey, err := db.newPem(ctx, policy)
+			if err != nil {
+				return err
+			}
 
-		RSAPem, err := db.newRSAPem(ctx)
-		if err != nil {
-			return err
-		}
+			stored := keystore.StoredKey{
+				Algorithm:  policy.Algorithm,
+				KeyType:    keyTypes[policy.Algorithm],
+				EncodedKey: encodedKey,
+				// The first key of each algorithm is used for signing.
+				Active: i == 0,
+			}
 
-		secretRSA := secretData{
-			algorithm:  entity.RS256,
-			keyType:    entity.RSA,
-			encodedKey: RSAPem,
-		}
+			id, err := db.create(ctx, stored)
+			if err != nil {
+				return err
+			}
+
+			key, err := makeKey(id, stored)
+			if err != nil {
+				return err
+			}
 
-		if err := db.create(ctx, secretRSA); err != nil {
-			return err
+			keys = append(keys, key)
+			states[id] = stateOf(id, stored)
 		}
 	}
 
+	db.keys.store(newKeySnapshot(keys, states, time.Now()))
+
+	return db.publishKeySetUpdated(ctx)
+}
+
+func (db Vault) publishKeySetUpdated(ctx context.Context) error {
 	e, err := event.MakeEvent(event.AuthAggregate, event.KeySetUpdated, nil, tracing.ExtractMetadataFromContext(ctx))
 	if err != nil {
 		return err
@@ -183,55 +213,155 @@ func (db Vault) refreshKeys(ctx context.Context) (err error) {
 	return db.broker.ResilientPublish(e)
 }
 
-// purge deletes all versions and metadata of all keys in the vault.
-func (db Vault) purge(ctx context.Context) (err error) {
+// purge retires generated keys so that tokens signed with them can still be verified
+// and deletes keys retired more than config.RetiredKeyTTL plus config.KeySnapshotTTL ago.
+// Other verify-only and imported keys can only be removed with RevokeKey.
+// It returns the retained keys by their ids.
+func (db Vault) purge(ctx context.Context, now time.Time) (_ map[string]keystore.StoredKey, err error) {
 	ctx, span := db.tracer.Start(ctx, "vault.purge")
 	defer span.End()
 	defer tracing.SetSpanErr(span, err)
 
-	paths, err := db.list(ctx, db.config.MountPath)
+	paths, err := db.store.List(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to list keys: %w", err)
+		return nil, fmt.Errorf("failed to list keys: %w", err)
 	}
 
+	retained := map[string]keystore.StoredKey{}
+
 	for _, path := range paths {
-		if err := db.vault.DeleteMetadata(ctx, path); err != nil {
-			return fmt.Errorf("failed to delete metadata: %w", err)
+		stored, err := db.store.Get(ctx, path)
+		if isMalformed(err) {
+			db.logger.Log(ctx, "skipping malformed key", "id", path, "err", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case !stored.RetiredAt.IsZero():
+			if now.Before(stored.RetiredAt.Add(db.config.retiredKeyRetention())) {
+				retained[path] = stored
+				continue
+			}
+
+		case stored.Imported || keystore.IsVerifyOnly(stored.EncodedKey):
+			retained[path] = stored
+			continue
+
+		default:
+			retired, err := retire(stored, now)
+			if err == nil {
+				if err := db.store.Put(ctx, path, retired); err != nil {
+					return nil, fmt.Errorf("failed to retire key: %w", err)
+				}
+
+				retained[path] = retired
+				continue
+			}
+
+			// A key which cannot be decoded cannot verify tokens either.
+			db.logger.Log(ctx, "deleting malformed key", "id", path, "err", err)
+		}
+
+		if err := db.store.Delete(ctx, path); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	return retained, nil
+}
+
+// retire returns given key with its private part dropped
+// so that it's kept as a verify-only key.
+func retire(stored keystore.StoredKey, now time.Time) (keystore.StoredKey, error) {
+	publicKey, _, err := keystore.DecodePublicKey(stored.Algorithm, stored.EncodedKey)
+	if err != nil {
+		return keystore.StoredKey{}, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return keystore.StoredKey{}, err
+	}
+
+	stored.EncodedKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	stored.Active = false
+	stored.RetiredAt = now
+
+	return stored, nil
+}
+
+// isMalformed returns true for errors returned by a Store
+// for keys which exist but cannot be parsed.
+func isMalformed(err error) bool {
+	for _, target := range []error{keystore.ErrInvalidAlgorithm, keystore.ErrInvalidKeyType, keystore.ErrKeyMissing, keystore.ErrInvalidKeyFormat, keystore.ErrFailedToParseKey} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
-func (db Vault) create(ctx context.Context, secret secretData) (err error) {
+// create stores a new key under its thumbprint and returns the thumbprint.
+// The key is certified if config.CertificateIssuer is set.
+func (db Vault) create(ctx context.Context, key keystore.StoredKey) (_ string, err error) {
 	ctx, span := db.tracer.Start(ctx, "vault.create")
 	defer span.End()
 	defer tracing.SetSpanErr(span, err)
 
-	keyData := map[string]interface{}{
-		"private":   secret.encodedKey,
-		"algorithm": string(secret.algorithm),
-		"keyType":   string(secret.keyType),
+	id, err := thumbprintOf(key)
+	if err != nil {
+		return "", err
 	}
 
-	id, err := str.RandomAlphaString(50)
+	key.Certificates, err = db.issueCertificates(ctx, id, key)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	if err := db.store.Put(ctx, id, key); err != nil {
+		return "", fmt.Errorf("failed to create key: %w", err)
 	}
 
-	if _, err := db.vault.Put(ctx, id, keyData); err != nil {
-		return fmt.Errorf("failed to create key: %w", err)
+	return id, nil
+}
+
+// thumbprintOf returns the RFC 7638 thumbprint of given key's public key.
+func thumbprintOf(key keystore.StoredKey) (string, error) {
+	publicKey, _, err := keystore.DecodePublicKey(key.Algorithm, key.EncodedKey)
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	return protokey.Thumbprint(publicKey)
+}
+
+// newPem generates a new PEM encoded private key according to given policy.
+func (db Vault) newPem(ctx context.Context, policy KeyPolicy) (string, error) {
+	switch keyTypes[policy.Algorithm] {
+	case entity.RSA:
+		return db.newRSAPem(ctx, policy.rsaBits())
+	case entity.ECDSA:
+		curve, ok := ecdsaCurves[algorithmCurves[policy.Algorithm]]
+		if !ok {
+			return "", keystore.ErrAlgorithmNotSupported
+		}
+		return db.newECDSAPem(ctx, curve)
+	case entity.OKP:
+		return db.newEd25519Pem(ctx)
+	default:
+		return "", keystore.ErrAlgorithmNotSupported
+	}
 }
 
-func (db Vault) newRSAPem(ctx context.Context) (_ string, err error) {
+func (db Vault) newRSAPem(ctx context.Context, bits int) (_ string, err error) {
 	_, span := db.tracer.Start(ctx, "vault.newRSAPem")
 	defer span.End()
 	defer tracing.SetSpanErr(span, err)
 
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	key, err := rsa.GenerateKey(rand.Reader, bits)
 	if err != nil {
 		return "", err
 	}
@@ -244,12 +374,12 @@ func (db Vault) newRSAPem(ctx context.Context) (_ string, err error) {
 	return string(pemData), nil
 }
 
-func (db Vault) newECDSAPem(ctx context.Context) (_ string, err error) {
+func (db Vault) newECDSAPem(ctx context.Context, curve elliptic.Curve) (_ string, err error) {
 	_, span := db.tracer.Start(ctx, "vault.newECDSAPem")
 	defer span.End()
 	defer tracing.SetSpanErr(span, err)
 
-	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
 	if err != nil {
 		return "", err
 	}
@@ -266,3 +396,26 @@ func (db Vault) newECDSAPem(ctx context.Context) (_ string, err error) {
 
 	return string(pemData), nil
 }
+
+func (db Vault) newEd25519Pem(ctx context.Context) (_ string, err error) {
+	_, span := db.tracer.Start(ctx, "vault.newEd25519Pem")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	marshaled, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	pemData := pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: marshaled,
+	})
+
+	return string(pemData), nil
+}
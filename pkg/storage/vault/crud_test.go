@@ -11,9 +11,10 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
-	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
 	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
 	vaultdata "github.com/krixlion/dev_forum-auth/pkg/storage/vault/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
 	"github.com/krixlion/dev_forum-lib/env"
 	"github.com/krixlion/dev_forum-lib/mocks"
 	"github.com/krixlion/dev_forum-lib/nulls"
@@ -32,9 +33,10 @@ func setUpVault(ctx context.Context) Vault {
 	token := os.Getenv("VAULT_TOKEN")
 	config := Config{
 		MountPath: mountPath,
+		KeyCount:  10,
 	}
 
-	vault, err := Make(ctx, host, port, token, config, mocks.NewBroker(), nulls.NullTracer{}, nulls.NullLogger{})
+	vault, err := Make(ctx, vaultclient.Config{Host: host, Port: port, Auth: vaultclient.AuthConfig{Token: token}}, config, mocks.NewBroker(), nulls.NullTracer{}, nulls.NullLogger{})
 	if err != nil {
 		panic(err)
 	}
@@ -57,27 +59,33 @@ func TestVault_GetKeySet(t *testing.T) {
 			want: []entity.Key{
 				{
 					Id:        testdata.ECDSA.Id,
+					Type:      entity.ECDSA,
 					Algorithm: entity.ES256,
-					Raw: func() *ecdsa.PrivateKey {
-						key, err := DecodeECDSA(testdata.ECDSA.PrivPem)
+					Signer: func() *ecdsa.PrivateKey {
+						key, err := keystore.DecodeECDSA(testdata.ECDSA.PrivPem)
 						if err != nil {
 							panic(err)
 						}
 						return key
 					}(),
-					EncodeFunc: protokey.SerializeECDSA,
+				},
+				{
+					Id:        testdata.Ed25519.Id,
+					Type:      entity.OKP,
+					Algorithm: entity.EdDSA,
+					Signer:    testdata.Ed25519.PrivKey,
 				},
 				{
 					Id:        testdata.RSA.Id,
+					Type:      entity.RSA,
 					Algorithm: entity.RS256,
-					Raw: func() *rsa.PrivateKey {
-						key, err := DecodeRSA(testdata.RSA.PrivPem)
+					Signer: func() *rsa.PrivateKey {
+						key, err := keystore.DecodeRSA(testdata.RSA.PrivPem)
 						if err != nil {
 							panic(err)
 						}
 						return key
 					}(),
-					EncodeFunc: protokey.SerializeRSA,
 				},
 			},
 			wantErr: false,
@@ -97,7 +105,7 @@ func TestVault_GetKeySet(t *testing.T) {
 				return
 			}
 
-			if !cmp.Equal(got, tt.want, cmpopts.IgnoreFields(entity.Key{}, "EncodeFunc")) {
+			if !cmp.Equal(got, tt.want, cmpopts.IgnoreUnexported(entity.Key{})) {
 				t.Errorf("Vault.GetKeySet():\n got = %v\n want = %v\n %v", got, tt.want, cmp.Diff(got, tt.want))
 			}
 		})
@@ -121,7 +129,7 @@ func TestVault_list(t *testing.T) {
 		{
 			name:    "Test if retrieves beforehand seeded path",
 			args:    args{path: "secret"},
-			want:    []string{testdata.ECDSA.Id, testdata.RSA.Id},
+			want:    []string{testdata.ECDSA.Id, testdata.Ed25519.Id, testdata.RSA.Id},
 			wantErr: false,
 		},
 	}
@@ -132,7 +140,7 @@ func TestVault_list(t *testing.T) {
 
 			db := setUpVault(ctx)
 
-			got, err := db.list(ctx, tt.args.path)
+			got, err := db.store.(kvStore).list(ctx, tt.args.path)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Vault.list() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -150,18 +158,24 @@ func TestVault_purge(t *testing.T) {
 		t.Skip("Skipping Vault.purge integration test...")
 	}
 
-	t.Run("Test if Vault.list() returns an empty slice after purge", func(t *testing.T) {
+	t.Run("Test if Vault.list() returns an empty slice once retired keys expire", func(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
 		defer cancel()
 
 		db := setUpVault(ctx)
+		now := time.Now()
+
+		if _, err := db.purge(ctx, now); err != nil {
+			t.Errorf("Vault.purge() error = %v", err)
+			return
+		}
 
-		if err := db.purge(ctx); err != nil {
+		if _, err := db.purge(ctx, now.Add(db.config.retiredKeyRetention())); err != nil {
 			t.Errorf("Vault.purge() error = %v", err)
 			return
 		}
 
-		paths, err := db.list(ctx, db.config.MountPath)
+		paths, err := db.store.(kvStore).list(ctx, db.config.MountPath)
 		if err != nil {
 			t.Errorf("Vault.purge(): failed to list: error = %v", err)
 			return
@@ -179,20 +193,22 @@ func TestVault_create(t *testing.T) {
 	}
 
 	type args struct {
-		secret secretData
+		secret keystore.StoredKey
 	}
 	tests := []struct {
 		name    string
 		args    args
+		want    string
 		wantErr bool
 	}{
 		{
-			name: "Test if a key is created without errors.",
-			args: args{secret: secretData{
-				algorithm:  entity.ES256,
-				keyType:    entity.ECDSA,
-				encodedKey: testdata.ECDSA.PrivPem,
+			name: "Test if a key is created under its thumbprint without errors.",
+			args: args{secret: keystore.StoredKey{
+				Algorithm:  entity.ES256,
+				KeyType:    entity.ECDSA,
+				EncodedKey: testdata.ECDSA.PrivPem,
 			}},
+			want:    testdata.ECDSA.Id,
 			wantErr: false,
 		},
 	}
@@ -203,9 +219,59 @@ func TestVault_create(t *testing.T) {
 
 			db := setUpVault(ctx)
 
-			if err := db.create(ctx, tt.args.secret); (err != nil) != tt.wantErr {
+			got, err := db.create(ctx, tt.args.secret)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("Vault.create() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("Vault.create() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVault_newPem(t *testing.T) {
+	db := Vault{tracer: nulls.NullTracer{}}
+
+	for algorithm, keyType := range keyTypes {
+		t.Run("Test if generated "+string(algorithm)+" key decodes into a valid key", func(t *testing.T) {
+			encodedKey, err := db.newPem(context.Background(), KeyPolicy{Algorithm: algorithm})
+			if err != nil {
+				t.Fatalf("Vault.newPem() error = %v", err)
+			}
+
+			key, err := makeKey("test", keystore.StoredKey{Algorithm: algorithm, KeyType: keyType, EncodedKey: encodedKey})
+			if err != nil {
+				t.Fatalf("makeKey() error = %v", err)
+			}
+
+			if key.Type != keyType || key.Algorithm != algorithm {
+				t.Errorf("makeKey() = %v %v, want %v %v", key.Type, key.Algorithm, keyType, algorithm)
 			}
 		})
 	}
+
+	t.Run("Test if RSA modulus size follows the policy", func(t *testing.T) {
+		encodedKey, err := db.newPem(context.Background(), KeyPolicy{Algorithm: entity.PS256, RSABits: 3072})
+		if err != nil {
+			t.Fatalf("Vault.newPem() error = %v", err)
+		}
+
+		key, err := keystore.DecodeRSA(encodedKey)
+		if err != nil {
+			t.Fatalf("keystore.DecodeRSA() error = %v", err)
+		}
+
+		if got := key.N.BitLen(); got != 3072 {
+			t.Errorf("Vault.newPem() generated a %d-bit key, want 3072", got)
+		}
+	})
+
+	t.Run("Test if returns an error on unsupported algorithm", func(t *testing.T) {
+		if _, err := db.newPem(context.Background(), KeyPolicy{Algorithm: entity.HS256}); err == nil {
+			t.Errorf("Vault.newPem() error = %v, wantErr true", err)
+		}
+	})
 }
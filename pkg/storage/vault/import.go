@@ -0,0 +1,137 @@
+package vault
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// ImportKey stores a key generated outside of the service under its
+// RFC 7638 thumbprint and returns the thumbprint. If key.Id is set it has to match it.
+// The key is accepted as a PEM or as a JWK and is published right away,
+// but it's not used to sign tokens before key.NotBefore.
+// Public keys are stored as verify-only keys which are published but never used to sign tokens.
+// Imported keys are never replaced during rotation, use RevokeKey to remove them.
+// It returns an error wrapping storage.ErrKeyExists if the key is already stored
+// and an error wrapping storage.ErrInvalidKey if the key cannot be used with its algorithm.
+func (db Vault) ImportKey(ctx context.Context, key entity.ImportedKey) (_ string, err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.ImportKey")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	encodedKey, kid, err := importedPem(key)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", storage.ErrInvalidKey, err)
+	}
+
+	if keystore.IsVerifyOnly(encodedKey) {
+		_, _, err = keystore.DecodePublicKey(key.Algorithm, encodedKey)
+	} else {
+		_, _, err = keystore.DecodeKey(key.Algorithm, encodedKey)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", storage.ErrInvalidKey, err)
+	}
+
+	notBefore := key.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now()
+	}
+
+	stored := keystore.StoredKey{
+		Algorithm:  key.Algorithm,
+		KeyType:    keyTypes[key.Algorithm],
+		EncodedKey: encodedKey,
+		Imported:   true,
+		NotBefore:  notBefore.UTC(),
+	}
+
+	thumbprint, err := thumbprintOf(stored)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", storage.ErrInvalidKey, err)
+	}
+
+	if kid != "" && kid != thumbprint {
+		return "", fmt.Errorf("%w: %w: kid %q, thumbprint %q", storage.ErrInvalidKey, protokey.ErrThumbprintMismatch, kid, thumbprint)
+	}
+
+	stored.Certificates, err = db.issueCertificates(ctx, thumbprint, stored)
+	if err != nil {
+		return "", err
+	}
+
+	db.keys.loading.Lock()
+	defer db.keys.loading.Unlock()
+
+	if _, err := db.store.Get(ctx, thumbprint); err == nil {
+		return "", fmt.Errorf("%w: %s", storage.ErrKeyExists, thumbprint)
+	} else if !errors.Is(err, storage.ErrKeyNotFound) {
+		return "", err
+	}
+
+	if err := db.store.Put(ctx, thumbprint, stored); err != nil {
+		return "", fmt.Errorf("failed to import key: %w", err)
+	}
+
+	if _, err := db.loadSnapshotLocked(ctx); err != nil {
+		return "", err
+	}
+
+	if err := db.publishKeySetUpdated(ctx); err != nil {
+		return "", err
+	}
+
+	return thumbprint, nil
+}
+
+// importedPem returns the imported key as a PEM along with the kid it was imported with, if any.
+// JWKs are converted to PKCS #8, or PKIX for public keys, after checking their "kid" and "alg" against the key.
+func importedPem(key entity.ImportedKey) (string, string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(key.EncodedKey), "{") {
+		return key.EncodedKey, key.Id, nil
+	}
+
+	parsed, err := jwk.ParseKey([]byte(key.EncodedKey))
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", keystore.ErrInvalidKeyFormat, err)
+	}
+
+	kid := key.Id
+	if jwkKid := parsed.KeyID(); kid == "" {
+		kid = jwkKid
+	} else if jwkKid != "" && jwkKid != kid {
+		return "", "", fmt.Errorf("%w: jwk kid %q does not match %q", keystore.ErrInvalidKeyFormat, jwkKid, kid)
+	}
+
+	if alg := parsed.Algorithm(); alg != "" && alg != string(key.Algorithm) {
+		return "", "", fmt.Errorf("%w: jwk alg %s cannot be used with %s", keystore.ErrKeyAlgorithmMismatch, alg, key.Algorithm)
+	}
+
+	var raw interface{}
+	if err := parsed.Raw(&raw); err != nil {
+		return "", "", fmt.Errorf("%w: %w", keystore.ErrFailedToParseKey, err)
+	}
+
+	if der, err := x509.MarshalPKCS8PrivateKey(raw); err == nil {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), kid, nil
+	}
+
+	// Public keys cannot be marshaled as private keys.
+	der, err := x509.MarshalPKIXPublicKey(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", keystore.ErrFailedToParseKey, err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), kid, nil
+}
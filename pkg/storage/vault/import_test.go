@@ -0,0 +1,285 @@
+package vault
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+func encodePem(blockType string, der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+}
+
+// privateJwk returns the testdata ECDSA key as a private JWK with given headers.
+func privateJwk(t *testing.T, headers map[string]string) string {
+	t.Helper()
+	return encodeJwk(t, testdata.ECDSA.PrivKey, headers)
+}
+
+// encodeJwk returns given raw key as a JWK with given headers.
+func encodeJwk(t *testing.T, raw interface{}, headers map[string]string) string {
+	t.Helper()
+
+	key, err := jwk.New(raw)
+	if err != nil {
+		t.Fatalf("Failed to create jwk: %v", err)
+	}
+
+	for name, value := range headers {
+		if err := key.Set(name, value); err != nil {
+			t.Fatalf("Failed to set jwk header: %v", err)
+		}
+	}
+
+	encoded, err := json.Marshal(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal jwk: %v", err)
+	}
+
+	return string(encoded)
+}
+
+func TestVault_ImportKey(t *testing.T) {
+	tests := []struct {
+		desc string
+		key  func(t *testing.T) entity.ImportedKey
+		// stored is true if the key is stored before being imported.
+		stored bool
+		// verifyOnly is true if the key is published but never used to sign tokens.
+		verifyOnly bool
+		wantErr    error
+	}{
+		{
+			desc: "Test if imports a PEM",
+			key: func(t *testing.T) entity.ImportedKey {
+				return entity.ImportedKey{Algorithm: entity.ES256, EncodedKey: testdata.ECDSA.PrivPem}
+			},
+		},
+		{
+			desc: "Test if imports a PEM with its thumbprint",
+			key: func(t *testing.T) entity.ImportedKey {
+				return entity.ImportedKey{Id: testdata.ECDSA.Id, Algorithm: entity.ES256, EncodedKey: testdata.ECDSA.PrivPem}
+			},
+		},
+		{
+			desc: "Test if imports a JWK",
+			key: func(t *testing.T) entity.ImportedKey {
+				encoded := privateJwk(t, map[string]string{jwk.KeyIDKey: testdata.ECDSA.Id, jwk.AlgorithmKey: "ES256"})
+				return entity.ImportedKey{Algorithm: entity.ES256, EncodedKey: encoded}
+			},
+		},
+		{
+			desc: "Test if rejects a key not matching the algorithm",
+			key: func(t *testing.T) entity.ImportedKey {
+				return entity.ImportedKey{Id: testdata.ECDSA.Id, Algorithm: entity.EdDSA, EncodedKey: testdata.ECDSA.PrivPem}
+			},
+			wantErr: keystore.ErrKeyAlgorithmMismatch,
+		},
+		{
+			desc: "Test if rejects a JWK with a different alg",
+			key: func(t *testing.T) entity.ImportedKey {
+				encoded := privateJwk(t, map[string]string{jwk.AlgorithmKey: "ES384"})
+				return entity.ImportedKey{Id: testdata.ECDSA.Id, Algorithm: entity.ES256, EncodedKey: encoded}
+			},
+			wantErr: keystore.ErrKeyAlgorithmMismatch,
+		},
+		{
+			desc: "Test if rejects a JWK with a kid other than its thumbprint",
+			key: func(t *testing.T) entity.ImportedKey {
+				encoded := privateJwk(t, map[string]string{jwk.KeyIDKey: "other"})
+				return entity.ImportedKey{Algorithm: entity.ES256, EncodedKey: encoded}
+			},
+			wantErr: protokey.ErrThumbprintMismatch,
+		},
+		{
+			desc: "Test if rejects a kid other than the thumbprint",
+			key: func(t *testing.T) entity.ImportedKey {
+				return entity.ImportedKey{Id: "other", Algorithm: entity.ES256, EncodedKey: testdata.ECDSA.PrivPem}
+			},
+			wantErr: protokey.ErrThumbprintMismatch,
+		},
+		{
+			desc: "Test if imports a public key as a verify-only key",
+			key: func(t *testing.T) entity.ImportedKey {
+				public, err := x509.MarshalPKIXPublicKey(testdata.ECDSA.PubKey)
+				if err != nil {
+					t.Fatalf("Failed to marshal key: %v", err)
+				}
+				return entity.ImportedKey{Id: testdata.ECDSA.Id, Algorithm: entity.ES256, EncodedKey: encodePem("PUBLIC KEY", public)}
+			},
+			verifyOnly: true,
+		},
+		{
+			desc: "Test if imports a public JWK as a verify-only key",
+			key: func(t *testing.T) entity.ImportedKey {
+				encoded := encodeJwk(t, testdata.ECDSA.PubKey, map[string]string{jwk.KeyIDKey: testdata.ECDSA.Id})
+				return entity.ImportedKey{Algorithm: entity.ES256, EncodedKey: encoded}
+			},
+			verifyOnly: true,
+		},
+		{
+			desc: "Test if rejects a public key not matching the algorithm",
+			key: func(t *testing.T) entity.ImportedKey {
+				public, err := x509.MarshalPKIXPublicKey(testdata.ECDSA.PubKey)
+				if err != nil {
+					t.Fatalf("Failed to marshal key: %v", err)
+				}
+				return entity.ImportedKey{Algorithm: entity.RS256, EncodedKey: encodePem("PUBLIC KEY", public)}
+			},
+			wantErr: keystore.ErrKeyAlgorithmMismatch,
+		},
+		{
+			desc: "Test if rejects a stored key",
+			key: func(t *testing.T) entity.ImportedKey {
+				return entity.ImportedKey{Algorithm: entity.ES256, EncodedKey: testdata.ECDSA.PrivPem}
+			},
+			stored:  true,
+			wantErr: storage.ErrKeyExists,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancel()
+
+			db := setUpStandInVault(t, newStandInServer(t))
+			if err := db.refreshKeys(ctx); err != nil {
+				t.Fatalf("Vault.refreshKeys() error = %v", err)
+			}
+
+			if tt.stored {
+				if _, err := db.create(ctx, keystore.StoredKey{Algorithm: entity.ES256, KeyType: entity.ECDSA, EncodedKey: testdata.ECDSA.PrivPem}); err != nil {
+					t.Fatalf("Vault.create() error = %v", err)
+				}
+			}
+
+			kid, err := db.ImportKey(ctx, tt.key(t))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Vault.ImportKey() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Vault.ImportKey() error = %v", err)
+			}
+
+			if kid != testdata.ECDSA.Id {
+				t.Errorf("Vault.ImportKey() = %v, want %v", kid, testdata.ECDSA.Id)
+			}
+
+			got, err := db.GetActive(ctx, entity.ES256)
+			if err != nil {
+				t.Fatalf("Vault.GetActive() error = %v", err)
+			}
+
+			if tt.verifyOnly {
+				if got.Id == kid {
+					t.Errorf("Vault.GetActive() = %s, want a key other than the verify-only key", got.Id)
+				}
+
+				keys, err := db.GetKeySet(ctx)
+				if err != nil {
+					t.Fatalf("Vault.GetKeySet() error = %v", err)
+				}
+
+				for _, key := range keys {
+					if key.Id == kid {
+						return
+					}
+				}
+				t.Errorf("Vault.GetKeySet() does not contain verify-only key %s", kid)
+				return
+			}
+
+			if got.Id != kid {
+				t.Errorf("Vault.GetActive() = %s, want imported key", got.Id)
+			}
+		})
+	}
+}
+
+func TestVault_ImportKey_notBefore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	db := setUpStandInVault(t, newStandInServer(t))
+	if err := db.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	notBefore := time.Now().Add(time.Millisecond * 300)
+	key := entity.ImportedKey{Algorithm: entity.ES256, EncodedKey: testdata.ECDSA.PrivPem, NotBefore: notBefore}
+	if _, err := db.ImportKey(ctx, key); err != nil {
+		t.Fatalf("Vault.ImportKey() error = %v", err)
+	}
+
+	assertActive := func(t *testing.T, want bool) {
+		t.Helper()
+
+		got, err := db.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if (got.Id == testdata.ECDSA.Id) != want {
+			t.Errorf("Vault.GetActive() = %s, imported key active = %v, want %v", got.Id, !want, want)
+		}
+	}
+
+	assertPublished := func(t *testing.T) {
+		t.Helper()
+
+		keys, err := db.GetKeySet(ctx)
+		if err != nil {
+			t.Fatalf("Vault.GetKeySet() error = %v", err)
+		}
+
+		for _, key := range keys {
+			if key.Id == testdata.ECDSA.Id {
+				return
+			}
+		}
+		t.Errorf("Vault.GetKeySet(): imported key not found")
+	}
+
+	assertPublished(t)
+	assertActive(t, false)
+
+	t.Run("Test if is retained on rotation", func(t *testing.T) {
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		assertPublished(t)
+		assertActive(t, false)
+
+		infos, err := db.ListKeys(ctx)
+		if err != nil {
+			t.Fatalf("Vault.ListKeys() error = %v", err)
+		}
+
+		for _, info := range infos {
+			if info.Id == testdata.ECDSA.Id && (!info.Imported || !info.NotBefore.Equal(notBefore)) {
+				t.Errorf("Vault.ListKeys() = %+v, want imported key with not before %v", info, notBefore)
+			}
+		}
+	})
+
+	t.Run("Test if becomes active after not before", func(t *testing.T) {
+		time.Sleep(time.Until(notBefore))
+
+		assertActive(t, true)
+	})
+}
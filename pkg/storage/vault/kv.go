@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// leasePath is the KVv2 path of the lease electing the instance which rotates keys.
+// It's nested so that it's listed as a folder and never mistaken for a key.
+const leasePath = "leases/rotation"
+
+// schedulePath is the KVv2 path whose custom metadata holds the rotation schedule.
+// It's nested so that it's listed as a folder and never mistaken for a key.
+const schedulePath = "schedules/rotation"
+
+var _ keystore.Store = kvStore{}
+
+// kvStore keeps keys in Vault's KVv2 engine.
+type kvStore struct {
+	lease.KVStore
+	vault     *vault.KVv2
+	client    *vault.Client
+	mountPath string
+	tracer    trace.Tracer
+}
+
+func (s kvStore) List(ctx context.Context) ([]string, error) {
+	return s.list(ctx, s.mountPath)
+}
+
+// list returns a slice containing all available paths in the Vault.
+// They can be used to retrieve a key from the Vault.
+func (s kvStore) list(ctx context.Context, mountPath string) (_ []string, err error) {
+	ctx, span := s.tracer.Start(ctx, "vault.list")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	secret, err := s.client.Logical().ListWithContext(ctx, mountPath+"/metadata/")
+	if err != nil {
+		return nil, err
+	}
+
+	// Check early in order to avoid unnecessary loop.
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+
+	paths := make([]string, 0, len(secret.Data))
+
+	for _, pathLists := range secret.Data {
+		pathList, ok := pathLists.([]interface{})
+		if !ok {
+			return nil, keystore.ErrFailedToParseKey
+		}
+
+		for _, path := range pathList {
+			path, ok := path.(string)
+			if !ok {
+				return nil, keystore.ErrFailedToParseKey
+			}
+
+			// Folders contain internal state, e.g. the rotation lease.
+			if strings.HasSuffix(path, "/") {
+				continue
+			}
+
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+func (s kvStore) Get(ctx context.Context, id string) (keystore.StoredKey, error) {
+	secret, err := s.vault.Get(ctx, id)
+	if errors.Is(err, vault.ErrSecretNotFound) {
+		return keystore.StoredKey{}, fmt.Errorf("%w: %s", storage.ErrKeyNotFound, id)
+	}
+	if err != nil {
+		return keystore.StoredKey{}, err
+	}
+
+	key, err := parseSecret(secret)
+	if err != nil {
+		return keystore.StoredKey{}, err
+	}
+
+	if secret.VersionMetadata != nil {
+		key.CreatedAt = secret.VersionMetadata.CreatedTime
+	}
+
+	return key, nil
+}
+
+func (s kvStore) Put(ctx context.Context, id string, key keystore.StoredKey) error {
+	keyData := map[string]interface{}{
+		"private":   key.EncodedKey,
+		"algorithm": string(key.Algorithm),
+		"keyType":   string(key.KeyType),
+		"active":    key.Active,
+	}
+
+	if key.Certificates != "" {
+		keyData["certificates"] = key.Certificates
+	}
+
+	if key.Imported {
+		keyData["imported"] = true
+		keyData["notBefore"] = key.NotBefore.UTC().Format(time.RFC3339Nano)
+	}
+
+	if !key.RetiredAt.IsZero() {
+		keyData["retiredAt"] = key.RetiredAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	if _, err := s.vault.Put(ctx, id, keyData); err != nil {
+		return fmt.Errorf("failed to put key: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes all versions and metadata of the key.
+func (s kvStore) Delete(ctx context.Context, id string) error {
+	if err := s.vault.DeleteMetadata(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete metadata: %w", err)
+	}
+	return nil
+}
+
+// GetSchedule reads the schedule from custom metadata.
+func (s kvStore) GetSchedule(ctx context.Context) (keystore.Schedule, error) {
+	metadata, err := s.vault.GetMetadata(ctx, schedulePath)
+	if errors.Is(err, vault.ErrSecretNotFound) {
+		return keystore.Schedule{}, nil
+	}
+	if err != nil {
+		return keystore.Schedule{}, fmt.Errorf("failed to get rotation schedule: %w", err)
+	}
+
+	return parseSchedule(metadata.CustomMetadata)
+}
+
+// PutSchedule writes the schedule to custom metadata.
+func (s kvStore) PutSchedule(ctx context.Context, schedule keystore.Schedule) error {
+	customMetadata := map[string]interface{}{}
+
+	if !schedule.LastRotatedAt.IsZero() {
+		customMetadata["last_rotated_at"] = schedule.LastRotatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	if !schedule.NextRotationAt.IsZero() {
+		customMetadata["next_rotation_at"] = schedule.NextRotationAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	if !schedule.RotationRequestedAt.IsZero() {
+		customMetadata["rotation_requested_at"] = schedule.RotationRequestedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	if err := s.vault.PutMetadata(ctx, schedulePath, vault.KVMetadataPutInput{CustomMetadata: customMetadata}); err != nil {
+		return fmt.Errorf("failed to put rotation schedule: %w", err)
+	}
+
+	return nil
+}
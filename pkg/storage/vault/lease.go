@@ -0,0 +1,31 @@
+package vault
+
+import (
+	"context"
+
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"github.com/krixlion/dev_forum-lib/tracing"
+)
+
+// DefaultLeaseTTL is used when Config.LeaseTTL is not set.
+const DefaultLeaseTTL = lease.DefaultTTL
+
+// acquireLease acquires the rotation lease or renews it if it's already held
+// by this instance. It returns false if the lease is held by another instance.
+func (db Vault) acquireLease(ctx context.Context) (_ lease.Lease, _ bool, err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.acquireLease")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	return lease.Acquire(ctx, db.store, db.id, db.config.leaseTTL())
+}
+
+// releaseLease expires the rotation lease if it's held by this instance
+// so that another instance can take over without waiting for it to expire.
+func (db Vault) releaseLease(ctx context.Context) (err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.releaseLease")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	return lease.Release(ctx, db.store, db.id)
+}
@@ -0,0 +1,195 @@
+package vault
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestVault_acquireLease(t *testing.T) {
+	t.Run("Test if only one of concurrent instances acquires the lease", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		server := newStandInServer(t)
+
+		instances := make([]Vault, 5)
+		for i := range instances {
+			instances[i] = setUpStandInVault(t, server)
+		}
+
+		var wg sync.WaitGroup
+		acquired := make([]bool, len(instances))
+
+		for i, db := range instances {
+			wg.Add(1)
+			go func(i int, db Vault) {
+				defer wg.Done()
+
+				_, ok, err := db.acquireLease(ctx)
+				if err != nil {
+					t.Errorf("Vault.acquireLease() error = %v", err)
+				}
+				acquired[i] = ok
+			}(i, db)
+		}
+		wg.Wait()
+
+		leaders := 0
+		for _, ok := range acquired {
+			if ok {
+				leaders++
+			}
+		}
+
+		if leaders != 1 {
+			t.Errorf("Vault.acquireLease() acquired by %d instances, want 1", leaders)
+		}
+	})
+
+	t.Run("Test if lease is renewed by the holder and taken over once expired", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		server := newStandInServer(t)
+		leader := setUpStandInVault(t, server)
+		follower := setUpStandInVault(t, server)
+		leader.config.LeaseTTL = time.Millisecond * 200
+		follower.config.LeaseTTL = time.Millisecond * 200
+
+		assertAcquired := func(db Vault, want bool) {
+			t.Helper()
+
+			_, ok, err := db.acquireLease(ctx)
+			if err != nil {
+				t.Fatalf("Vault.acquireLease() error = %v", err)
+			}
+
+			if ok != want {
+				t.Errorf("Vault.acquireLease() = %v, want %v", ok, want)
+			}
+		}
+
+		assertAcquired(leader, true)
+		assertAcquired(follower, false)
+		assertAcquired(leader, true)
+
+		time.Sleep(time.Millisecond * 250)
+
+		assertAcquired(follower, true)
+		assertAcquired(leader, false)
+	})
+
+	t.Run("Test if released lease is taken over immediately", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		server := newStandInServer(t)
+		leader := setUpStandInVault(t, server)
+		follower := setUpStandInVault(t, server)
+
+		if _, ok, err := leader.acquireLease(ctx); err != nil || !ok {
+			t.Fatalf("Vault.acquireLease() = %v, error = %v", ok, err)
+		}
+
+		if err := leader.releaseLease(ctx); err != nil {
+			t.Fatalf("Vault.releaseLease() error = %v", err)
+		}
+
+		if _, ok, err := follower.acquireLease(ctx); err != nil || !ok {
+			t.Errorf("Vault.acquireLease() = %v, error = %v, want true", ok, err)
+		}
+	})
+}
+
+func TestVault_rotateIfLeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	server := newStandInServer(t)
+	host, port := server.HostPort()
+
+	brokers := make([]mocks.Broker, 3)
+	instances := make([]Vault, 3)
+	for i := range instances {
+		broker := mocks.NewBroker()
+		broker.On("ResilientPublish", mock.Anything).Return(nil)
+		brokers[i] = broker
+
+		config := Config{
+			MountPath: "secret",
+			KeyPolicy: testKeyPolicy,
+		}
+
+		db, err := Make(ctx, vaultclient.Config{Host: host, Port: port, Auth: vaultclient.AuthConfig{Token: server.Token}}, config, broker, nulls.NullTracer{}, nulls.NullLogger{})
+		if err != nil {
+			t.Fatalf("Failed to make vault: %v", err)
+		}
+
+		// Set after Make so that only this test drives rotation.
+		db.config.KeyRefreshInterval = time.Hour
+		instances[i] = db
+	}
+
+	rotations := func() int {
+		count := 0
+		for _, broker := range brokers {
+			for _, call := range broker.Calls {
+				if call.Method == "ResilientPublish" {
+					count++
+				}
+			}
+		}
+		return count
+	}
+
+	for round := 0; round < 2; round++ {
+		for _, db := range instances {
+			if err := db.rotateIfLeader(ctx); err != nil {
+				t.Fatalf("Vault.rotateIfLeader() error = %v", err)
+			}
+		}
+	}
+
+	if got := rotations(); got != 1 {
+		t.Errorf("Keys were rotated %d times, want 1", got)
+	}
+
+	for _, db := range instances {
+		if err := db.reloadSnapshot(ctx); err != nil {
+			t.Fatalf("Vault.reloadSnapshot() error = %v", err)
+		}
+
+		keys, err := db.GetKeySet(ctx)
+		if err != nil {
+			t.Fatalf("Vault.GetKeySet() error = %v", err)
+		}
+
+		if want := 5; len(keys) != want {
+			t.Errorf("Vault.GetKeySet() returned %d keys, want %d", len(keys), want)
+		}
+	}
+
+	// A new leader does not rotate keys again before the interval passes.
+	for _, db := range instances {
+		if err := db.releaseLease(ctx); err != nil {
+			t.Fatalf("Vault.releaseLease() error = %v", err)
+		}
+	}
+
+	for _, db := range instances {
+		if err := db.rotateIfLeader(ctx); err != nil {
+			t.Fatalf("Vault.rotateIfLeader() error = %v", err)
+		}
+	}
+
+	if got := rotations(); got != 1 {
+		t.Errorf("Keys were rotated %d times after failover, want 1", got)
+	}
+}
@@ -1,71 +1,152 @@
 package vault
 
 import (
+	"fmt"
+	"time"
+
 	vault "github.com/hashicorp/vault/api"
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
 )
 
-// secretData is a convenience struct used to contain parsed KVV2 keys.
-type secretData struct {
-	algorithm  entity.Algorithm
-	keyType    entity.KeyType
-	encodedKey string
-}
-
-func parseSecret(secret *vault.KVSecret) (secretData, error) {
+func parseSecret(secret *vault.KVSecret) (keystore.StoredKey, error) {
 	if secret == nil {
-		return secretData{}, ErrKeyMissing
+		return keystore.StoredKey{}, keystore.ErrKeyMissing
 	}
 
 	algo, ok := secret.Data["algorithm"]
 	if !ok {
-		return secretData{}, ErrInvalidAlgorithm
+		return keystore.StoredKey{}, keystore.ErrInvalidAlgorithm
 	}
 
 	algorithm, ok := algo.(string)
 	if !ok {
-		return secretData{}, ErrInvalidAlgorithm
+		return keystore.StoredKey{}, keystore.ErrInvalidAlgorithm
 	}
 
 	keyTyp, ok := secret.Data["keyType"]
 	if !ok {
-		return secretData{}, ErrInvalidKeyType
+		return keystore.StoredKey{}, keystore.ErrInvalidKeyType
 	}
 
 	keyType, ok := keyTyp.(string)
 	if !ok {
-		return secretData{}, ErrInvalidKeyType
+		return keystore.StoredKey{}, keystore.ErrInvalidKeyType
 	}
 
 	key, ok := secret.Data["private"]
 	if !ok {
-		return secretData{}, ErrKeyMissing
+		return keystore.StoredKey{}, keystore.ErrKeyMissing
 	}
 
 	encodedKey, ok := key.(string)
 	if !ok {
-		return secretData{}, ErrInvalidKeyFormat
+		return keystore.StoredKey{}, keystore.ErrInvalidKeyFormat
+	}
+
+	// Keys created before active keys were tracked are not marked.
+	active, _ := secret.Data["active"].(bool)
+	imported, _ := secret.Data["imported"].(bool)
+	certificates, _ := secret.Data["certificates"].(string)
+
+	var notBefore time.Time
+	if encoded, ok := secret.Data["notBefore"].(string); ok {
+		var err error
+		notBefore, err = time.Parse(time.RFC3339Nano, encoded)
+		if err != nil {
+			return keystore.StoredKey{}, fmt.Errorf("failed to parse key's not before time: %w", err)
+		}
+	}
+
+	var retiredAt time.Time
+	if encoded, ok := secret.Data["retiredAt"].(string); ok {
+		var err error
+		retiredAt, err = time.Parse(time.RFC3339Nano, encoded)
+		if err != nil {
+			return keystore.StoredKey{}, fmt.Errorf("failed to parse key's retirement time: %w", err)
+		}
 	}
 
-	return secretData{
-		algorithm:  entity.Algorithm(algorithm),
-		keyType:    entity.KeyType(keyType),
-		encodedKey: encodedKey,
+	return keystore.StoredKey{
+		Algorithm:    entity.Algorithm(algorithm),
+		KeyType:      entity.KeyType(keyType),
+		EncodedKey:   encodedKey,
+		Certificates: certificates,
+		Active:       active,
+		Imported:     imported,
+		NotBefore:    notBefore,
+		RetiredAt:    retiredAt,
 	}, nil
 }
 
+func parseSchedule(customMetadata map[string]interface{}) (keystore.Schedule, error) {
+	var schedule keystore.Schedule
+
+	for field, dst := range map[string]*time.Time{
+		"last_rotated_at":       &schedule.LastRotatedAt,
+		"next_rotation_at":      &schedule.NextRotationAt,
+		"rotation_requested_at": &schedule.RotationRequestedAt,
+	} {
+		encoded, _ := customMetadata[field].(string)
+		if encoded == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339Nano, encoded)
+		if err != nil {
+			return keystore.Schedule{}, fmt.Errorf("failed to parse rotation schedule's %s: %w", field, err)
+		}
+		*dst = parsed
+	}
+
+	return schedule, nil
+}
+
 // makeKey is a convenience func used to make an entity.Key
-// correctly decoded with correct encodeFunc assigned.
-func makeKey(id string, validated secretData) (entity.Key, error) {
-	privateKey, encodeFunc, err := DecodeKey(validated.algorithm, validated.encodedKey)
+// correctly decoded with its public key encoded.
+// Public keys are made into verify-only keys.
+// Certificates have to certify the key or ErrCertificateMismatch is returned.
+func makeKey(id string, stored keystore.StoredKey) (entity.Key, error) {
+	key, err := decodeStoredKey(id, stored)
 	if err != nil {
 		return entity.Key{}, err
 	}
 
-	return entity.Key{
-		Id:         id,
-		Algorithm:  validated.algorithm,
-		Raw:        privateKey,
-		EncodeFunc: encodeFunc,
-	}, nil
+	certificates, err := DecodeCertificates(stored.Certificates)
+	if err != nil {
+		return entity.Key{}, fmt.Errorf("failed to decode key's certificates: %w", err)
+	}
+
+	if len(certificates) > 0 {
+		publicKey, _, err := keystore.DecodePublicKey(stored.Algorithm, stored.EncodedKey)
+		if err != nil {
+			return entity.Key{}, err
+		}
+
+		if !publicKeysEqual(certificates[0].PublicKey, publicKey) {
+			return entity.Key{}, fmt.Errorf("%w: %s", ErrCertificateMismatch, id)
+		}
+
+		key.Certificates = certificates
+	}
+
+	return key, nil
+}
+
+func decodeStoredKey(id string, stored keystore.StoredKey) (entity.Key, error) {
+	if keystore.IsVerifyOnly(stored.EncodedKey) {
+		publicKey, encodeFunc, err := keystore.DecodePublicKey(stored.Algorithm, stored.EncodedKey)
+		if err != nil {
+			return entity.Key{}, err
+		}
+
+		return entity.NewVerificationKey(id, stored.KeyType, stored.Algorithm, publicKey, encodeFunc)
+	}
+
+	signer, encodeFunc, err := keystore.DecodeKey(stored.Algorithm, stored.EncodedKey)
+	if err != nil {
+		return entity.Key{}, err
+	}
+
+	return entity.NewKey(id, stored.KeyType, stored.Algorithm, signer, encodeFunc)
 }
@@ -7,6 +7,7 @@ import (
 	vault "github.com/hashicorp/vault/api"
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
 	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
 )
 
 func Test_parseSecret(t *testing.T) {
@@ -16,7 +17,7 @@ func Test_parseSecret(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
-		want    secretData
+		want    keystore.StoredKey
 		wantErr bool
 	}{
 		{
@@ -30,10 +31,29 @@ func Test_parseSecret(t *testing.T) {
 					},
 				},
 			},
-			want: secretData{
-				keyType:    entity.RSA,
-				algorithm:  entity.RS256,
-				encodedKey: testdata.RSA.PrivPem,
+			want: keystore.StoredKey{
+				KeyType:    entity.RSA,
+				Algorithm:  entity.RS256,
+				EncodedKey: testdata.RSA.PrivPem,
+			},
+		},
+		{
+			name: "Test if parses 'active' field",
+			args: args{
+				secret: &vault.KVSecret{
+					Data: map[string]interface{}{
+						"keyType":   string(entity.ECDSA),
+						"algorithm": string(entity.ES256),
+						"private":   testdata.ECDSA.PrivPem,
+						"active":    true,
+					},
+				},
+			},
+			want: keystore.StoredKey{
+				KeyType:    entity.ECDSA,
+				Algorithm:  entity.ES256,
+				EncodedKey: testdata.ECDSA.PrivPem,
+				Active:     true,
 			},
 		},
 		{
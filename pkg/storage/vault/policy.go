@@ -0,0 +1,130 @@
+package vault
+
+import (
+	"crypto/elliptic"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+)
+
+// MinRSABits is the smallest RSA modulus size a KeyPolicy accepts.
+const MinRSABits = 2048
+
+// KeyPolicy declares how many keys are maintained for an algorithm and how they are generated.
+type KeyPolicy struct {
+	Algorithm entity.Algorithm `json:"algorithm"`
+	// Number of keys to generate on every refresh. Config.KeyCount is used if 0.
+	Count int `json:"count,omitempty"`
+	// Modulus size of generated RSA keys. MinRSABits is used if 0.
+	// Allowed only for RS* and PS* algorithms.
+	RSABits int `json:"rsa_bits,omitempty"`
+	// Name of the curve of generated keys, e.g. P-384.
+	// Defaults to the only curve the algorithm can be used with according to RFC 7518 and RFC 8037.
+	// Allowed only for ES* and EdDSA algorithms.
+	Curve string `json:"curve,omitempty"`
+}
+
+// DefaultKeyPolicy is used when Config.KeyPolicy is empty.
+// EdDSA keys are generated only if listed in the configured policy
+// as not every validator supports them.
+var DefaultKeyPolicy = []KeyPolicy{
+	{Algorithm: entity.ES256},
+	{Algorithm: entity.RS256},
+}
+
+// ParseKeyPolicy decodes a JSON encoded list of key policies,
+// e.g. `[{"algorithm": "ES256", "count": 3}]`.
+// It returns nil if the encoded policy is empty.
+func ParseKeyPolicy(encoded string) ([]KeyPolicy, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	policy := []KeyPolicy{}
+	if err := json.Unmarshal([]byte(encoded), &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse key policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+func (policy KeyPolicy) validate() error {
+	keyType, ok := keyTypes[policy.Algorithm]
+	if !ok {
+		return fmt.Errorf("%w: %q", keystore.ErrAlgorithmNotSupported, policy.Algorithm)
+	}
+
+	if policy.Count < 0 {
+		return errors.New("key count has to be a non-negative number")
+	}
+
+	if policy.RSABits != 0 {
+		if keyType != entity.RSA {
+			return fmt.Errorf("rsa bits cannot be set for %s", policy.Algorithm)
+		}
+
+		if policy.RSABits < MinRSABits {
+			return fmt.Errorf("rsa bits has to be at least %d", MinRSABits)
+		}
+	}
+
+	if policy.Curve != "" {
+		if keyType == entity.RSA {
+			return fmt.Errorf("curve cannot be set for %s", policy.Algorithm)
+		}
+
+		if policy.Curve != algorithmCurves[policy.Algorithm] {
+			return fmt.Errorf("curve %q cannot be used with %s", policy.Curve, policy.Algorithm)
+		}
+	}
+
+	return nil
+}
+
+// count returns the number of keys to generate.
+func (policy KeyPolicy) count(defaultCount int) int {
+	if policy.Count == 0 {
+		return defaultCount
+	}
+	return policy.Count
+}
+
+// rsaBits returns the modulus size of generated RSA keys.
+func (policy KeyPolicy) rsaBits() int {
+	if policy.RSABits == 0 {
+		return MinRSABits
+	}
+	return policy.RSABits
+}
+
+// keyTypes maps algorithms which keys can be generated for to their key types.
+var keyTypes = map[entity.Algorithm]entity.KeyType{
+	entity.RS256: entity.RSA,
+	entity.RS384: entity.RSA,
+	entity.RS512: entity.RSA,
+	entity.PS256: entity.RSA,
+	entity.PS384: entity.RSA,
+	entity.PS512: entity.RSA,
+	entity.ES256: entity.ECDSA,
+	entity.ES384: entity.ECDSA,
+	entity.ES512: entity.ECDSA,
+	entity.EdDSA: entity.OKP,
+}
+
+// algorithmCurves maps elliptic curve algorithms to names of the curves they use.
+var algorithmCurves = map[entity.Algorithm]string{
+	entity.ES256: "P-256",
+	entity.ES384: "P-384",
+	entity.ES512: "P-521",
+	entity.EdDSA: "Ed25519",
+}
+
+// ecdsaCurves maps curve names to ECDSA curves.
+var ecdsaCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
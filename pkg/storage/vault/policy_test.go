@@ -0,0 +1,130 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+)
+
+func TestParseKeyPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		want    []KeyPolicy
+		wantErr bool
+	}{
+		{
+			name:    "Test if returns nil on empty policy",
+			encoded: "",
+			want:    nil,
+		},
+		{
+			name:    "Test if parses a valid policy",
+			encoded: `[{"algorithm": "ES256", "count": 3}, {"algorithm": "RS256", "rsa_bits": 4096}, {"algorithm": "ES384", "curve": "P-384"}]`,
+			want: []KeyPolicy{
+				{Algorithm: entity.ES256, Count: 3},
+				{Algorithm: entity.RS256, RSABits: 4096},
+				{Algorithm: entity.ES384, Curve: "P-384"},
+			},
+		},
+		{
+			name:    "Test if returns an error on malformed policy",
+			encoded: `{"algorithm": "ES256"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKeyPolicy(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseKeyPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("ParseKeyPolicy():\n got = %v\n want = %v\n %v", got, tt.want, cmp.Diff(got, tt.want))
+			}
+		})
+	}
+}
+
+func TestKeyPolicy_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  KeyPolicy
+		wantErr bool
+	}{
+		{
+			name:   "Test no error is returned on algorithm only",
+			policy: KeyPolicy{Algorithm: entity.ES256},
+		},
+		{
+			name:   "Test no error is returned on matching curve",
+			policy: KeyPolicy{Algorithm: entity.EdDSA, Curve: "Ed25519"},
+		},
+		{
+			name:    "Test returns an error on unsupported algorithm",
+			policy:  KeyPolicy{Algorithm: entity.HS256},
+			wantErr: true,
+		},
+		{
+			name:    "Test returns an error on negative count",
+			policy:  KeyPolicy{Algorithm: entity.ES256, Count: -1},
+			wantErr: true,
+		},
+		{
+			name:    "Test returns an error on too small RSA modulus",
+			policy:  KeyPolicy{Algorithm: entity.RS256, RSABits: 1024},
+			wantErr: true,
+		},
+		{
+			name:    "Test returns an error on RSA modulus set for an EC algorithm",
+			policy:  KeyPolicy{Algorithm: entity.ES256, RSABits: 2048},
+			wantErr: true,
+		},
+		{
+			name:    "Test returns an error on curve not matching the algorithm",
+			policy:  KeyPolicy{Algorithm: entity.ES256, Curve: "P-384"},
+			wantErr: true,
+		},
+		{
+			name:    "Test returns an error on curve set for an RSA algorithm",
+			policy:  KeyPolicy{Algorithm: entity.RS256, Curve: "P-256"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("KeyPolicy.validate():\n error = %v\n wantErr = %v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Algorithms(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   []entity.Algorithm
+	}{
+		{
+			name:   "Test if returns algorithms of the default policy",
+			config: Config{},
+			want:   []entity.Algorithm{entity.ES256, entity.RS256},
+		},
+		{
+			name:   "Test if preserves the order of the configured policy",
+			config: Config{KeyPolicy: []KeyPolicy{{Algorithm: entity.EdDSA}, {Algorithm: entity.ES384}}},
+			want:   []entity.Algorithm{entity.EdDSA, entity.ES384},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.Algorithms(); !cmp.Equal(got, tt.want) {
+				t.Errorf("Config.Algorithms():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package vault
+
+import (
+	"context"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/tracing"
+
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+)
+
+// recordRotation persists given time of the last rotation along with
+// the time the next rotation is due. Pending rotation requests are cleared.
+func (db Vault) recordRotation(ctx context.Context, rotatedAt time.Time) error {
+	schedule := keystore.Schedule{LastRotatedAt: rotatedAt}
+
+	if db.config.KeyRefreshInterval > 0 {
+		schedule.NextRotationAt = rotatedAt.Add(db.config.KeyRefreshInterval)
+	}
+
+	return db.store.PutSchedule(ctx, schedule)
+}
+
+// rotationDue returns true if config.KeyRefreshInterval has passed since
+// keys were last rotated. If no rotation was recorded, e.g. because keys
+// were written by an older version, the age of the newest key is used instead.
+// Rotation is also due if it was requested on another instance since keys
+// were last rotated.
+func (db Vault) rotationDue(ctx context.Context) (_ bool, err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.rotationDue")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	schedule, err := db.store.GetSchedule(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if schedule.RotationRequestedAt.After(schedule.LastRotatedAt) {
+		return true, nil
+	}
+
+	lastRotatedAt := schedule.LastRotatedAt
+
+	if lastRotatedAt.IsZero() {
+		keys, err := db.ListKeys(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		for _, key := range keys {
+			if key.CreatedAt.After(lastRotatedAt) {
+				lastRotatedAt = key.CreatedAt
+			}
+		}
+	}
+
+	// No keys were ever written.
+	if lastRotatedAt.IsZero() {
+		return true, nil
+	}
+
+	return time.Since(lastRotatedAt) >= db.config.KeyRefreshInterval, nil
+}
+
+// requestRotation records that keys should be rotated so that the instance
+// holding the rotation lease rotates them at its next tick.
+func (db Vault) requestRotation(ctx context.Context, requestedAt time.Time) error {
+	schedule, err := db.store.GetSchedule(ctx)
+	if err != nil {
+		return err
+	}
+
+	schedule.RotationRequestedAt = requestedAt
+
+	return db.store.PutSchedule(ctx, schedule)
+}
+
+// migrateLegacyIds replaces all keys if any generated key is not stored under
+// its thumbprint. It returns false if another instance holds the rotation lease,
+// in which case it has to be retried. It's run once at startup since it reads all keys.
+func (db Vault) migrateLegacyIds(ctx context.Context) (_ bool, err error) {
+	ctx, span := db.tracer.Start(ctx, "vault.migrateLegacyIds")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	_, ok, err := db.acquireLease(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	legacy, err := db.hasLegacyIds(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if !legacy {
+		return true, nil
+	}
+
+	db.logger.Log(ctx, "replacing keys with legacy ids")
+
+	if err := db.refreshKeys(ctx); err != nil {
+		return false, err
+	}
+
+	if err := db.recordRotation(ctx, time.Now()); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// hasLegacyIds returns true if any generated key is stored under an id other
+// than its thumbprint. Older versions used random ids, which validators reject.
+// Imported and verify-only keys are skipped since rotation does not replace them.
+func (db Vault) hasLegacyIds(ctx context.Context) (bool, error) {
+	ids, err := db.store.List(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range ids {
+		stored, err := db.store.Get(ctx, id)
+		if err != nil {
+			return false, err
+		}
+
+		if stored.Imported || keystore.IsVerifyOnly(stored.EncodedKey) {
+			continue
+		}
+
+		thumbprint, err := thumbprintOf(stored)
+		if err != nil {
+			return false, err
+		}
+
+		if id != thumbprint {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,208 @@
+package vault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+)
+
+func TestVault_rotationDue(t *testing.T) {
+	t.Run("Test if rotation is due when there are no keys", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		db := setUpStandInVault(t, newStandInServer(t))
+		db.config.KeyRefreshInterval = time.Hour
+
+		due, err := db.rotationDue(ctx)
+		if err != nil {
+			t.Fatalf("Vault.rotationDue() error = %v", err)
+		}
+
+		if !due {
+			t.Errorf("Vault.rotationDue() = %v, want true", due)
+		}
+	})
+
+	t.Run("Test if keys younger than the interval are not rotated without a schedule", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		db := setUpStandInVault(t, newStandInServer(t))
+		db.config.KeyRefreshInterval = time.Hour
+
+		// Keys written without recording a rotation, e.g. by an older version.
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		due, err := db.rotationDue(ctx)
+		if err != nil {
+			t.Fatalf("Vault.rotationDue() error = %v", err)
+		}
+
+		if due {
+			t.Errorf("Vault.rotationDue() = %v, want false", due)
+		}
+	})
+
+	t.Run("Test if rotation is due once the schedule has passed", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		db := setUpStandInVault(t, newStandInServer(t))
+		db.config.KeyRefreshInterval = time.Hour
+
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		if err := db.recordRotation(ctx, time.Now().Add(-time.Hour*2)); err != nil {
+			t.Fatalf("Vault.recordRotation() error = %v", err)
+		}
+
+		due, err := db.rotationDue(ctx)
+		if err != nil {
+			t.Fatalf("Vault.rotationDue() error = %v", err)
+		}
+
+		if !due {
+			t.Errorf("Vault.rotationDue() = %v, want true", due)
+		}
+	})
+}
+
+func TestVault_migrateLegacyIds(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	server := newStandInServer(t)
+	db := setUpStandInVault(t, server)
+	db.config.KeyRefreshInterval = time.Hour
+
+	if err := db.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	// Keys written by older versions have random ids.
+	legacy := keystore.StoredKey{Algorithm: entity.ES256, KeyType: entity.ECDSA, EncodedKey: testdata.ECDSA.PrivPem}
+	if err := db.store.Put(ctx, "legacy", legacy); err != nil {
+		t.Fatalf("Failed to put key: %v", err)
+	}
+
+	follower := setUpStandInVault(t, server)
+	if _, ok, err := db.acquireLease(ctx); err != nil || !ok {
+		t.Fatalf("Vault.acquireLease() = %v, error = %v", ok, err)
+	}
+
+	if migrated, err := follower.migrateLegacyIds(ctx); err != nil || migrated {
+		t.Fatalf("Vault.migrateLegacyIds() by a follower = %v, error = %v, want false", migrated, err)
+	}
+
+	migrated, err := db.migrateLegacyIds(ctx)
+	if err != nil {
+		t.Fatalf("Vault.migrateLegacyIds() error = %v", err)
+	}
+
+	if !migrated {
+		t.Errorf("Vault.migrateLegacyIds() = false, want true")
+	}
+
+	// The legacy key is retired along with the other generated keys.
+	retired, err := db.store.Get(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("Store.Get() error = %v", err)
+	}
+
+	if !keystore.IsVerifyOnly(retired.EncodedKey) {
+		t.Errorf("Vault.migrateLegacyIds() did not retire the legacy key")
+	}
+
+	legacyIds, err := db.hasLegacyIds(ctx)
+	if err != nil {
+		t.Fatalf("Vault.hasLegacyIds() error = %v", err)
+	}
+
+	if legacyIds {
+		t.Errorf("Vault.hasLegacyIds() after migration = true, want false")
+	}
+}
+
+func TestVault_recordRotation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	server := newStandInServer(t)
+	db := setUpStandInVault(t, server)
+	db.config.KeyRefreshInterval = time.Hour
+
+	rotatedAt := time.Unix(1700000000, 0).UTC()
+	if err := db.recordRotation(ctx, rotatedAt); err != nil {
+		t.Fatalf("Vault.recordRotation() error = %v", err)
+	}
+
+	// A restarted instance reads the same schedule.
+	got, err := setUpStandInVault(t, server).store.GetSchedule(ctx)
+	if err != nil {
+		t.Fatalf("Store.GetSchedule() error = %v", err)
+	}
+
+	want := keystore.Schedule{
+		LastRotatedAt:  rotatedAt,
+		NextRotationAt: rotatedAt.Add(time.Hour),
+	}
+
+	if !got.LastRotatedAt.Equal(want.LastRotatedAt) || !got.NextRotationAt.Equal(want.NextRotationAt) {
+		t.Errorf("Store.GetSchedule():\n got = %+v\n want = %+v", got, want)
+	}
+}
+
+func TestVault_rotateIfLeader_restart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	server := newStandInServer(t)
+
+	db := setUpStandInVault(t, server)
+	db.config.KeyRefreshInterval = time.Hour
+
+	if err := db.rotateIfLeader(ctx); err != nil {
+		t.Fatalf("Vault.rotateIfLeader() error = %v", err)
+	}
+
+	before, err := db.ListKeys(ctx)
+	if err != nil {
+		t.Fatalf("Vault.ListKeys() error = %v", err)
+	}
+
+	if err := db.releaseLease(ctx); err != nil {
+		t.Fatalf("Vault.releaseLease() error = %v", err)
+	}
+
+	// Simulate a restart with a new instance id.
+	restarted := setUpStandInVault(t, server)
+	restarted.config.KeyRefreshInterval = time.Hour
+
+	if err := restarted.rotateIfLeader(ctx); err != nil {
+		t.Fatalf("Vault.rotateIfLeader() error = %v", err)
+	}
+
+	after, err := restarted.ListKeys(ctx)
+	if err != nil {
+		t.Fatalf("Vault.ListKeys() error = %v", err)
+	}
+
+	if len(after) != len(before) {
+		t.Fatalf("Vault.ListKeys() returned %d keys after restart, want %d", len(after), len(before))
+	}
+
+	for i := range before {
+		if after[i].Id != before[i].Id {
+			t.Errorf("Keys were rotated after restart: %s != %s", after[i].Id, before[i].Id)
+		}
+	}
+}
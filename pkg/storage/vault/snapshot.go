@@ -0,0 +1,163 @@
+package vault
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+)
+
+// keySnapshot is an immutable, decoded view of the keys stored in the Vault.
+// It must not be modified once stored in a keyCache.
+type keySnapshot struct {
+	// keys are sorted by their ids.
+	keys []entity.Key
+	// active contains the key used to sign tokens for each algorithm
+	// at the time the snapshot was loaded.
+	active map[entity.Algorithm]entity.Key
+	// scheduled contains imported keys which start to sign tokens
+	// after the snapshot was loaded, sorted by their not before time.
+	scheduled []scheduledKey
+	loadedAt  time.Time
+}
+
+type scheduledKey struct {
+	key       entity.Key
+	notBefore time.Time
+}
+
+// keyState contains properties of a stored key deciding whether it's active.
+type keyState struct {
+	id         string
+	algorithm  entity.Algorithm
+	marked     bool
+	verifyOnly bool
+	imported   bool
+	notBefore  time.Time
+}
+
+func stateOf(id string, stored keystore.StoredKey) keyState {
+	return keyState{
+		id:         id,
+		algorithm:  stored.Algorithm,
+		marked:     stored.Active,
+		verifyOnly: keystore.IsVerifyOnly(stored.EncodedKey),
+		imported:   stored.Imported,
+		notBefore:  stored.NotBefore,
+	}
+}
+
+// newKeySnapshot returns a snapshot of given keys loaded at given time.
+// states contains the state of each key by its id.
+func newKeySnapshot(keys []entity.Key, states map[string]keyState, now time.Time) *keySnapshot {
+	sorted := make([]entity.Key, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+
+	sortedStates := make([]keyState, len(sorted))
+	for i, key := range sorted {
+		sortedStates[i] = states[key.Id]
+		sortedStates[i].id = key.Id
+		sortedStates[i].algorithm = key.Algorithm
+	}
+
+	active := map[entity.Algorithm]entity.Key{}
+	for _, i := range pickActive(sortedStates, now) {
+		active[sorted[i].Algorithm] = sorted[i]
+	}
+
+	scheduled := []scheduledKey{}
+	for i, state := range sortedStates {
+		if state.imported && !state.verifyOnly && state.notBefore.After(now) {
+			scheduled = append(scheduled, scheduledKey{key: sorted[i], notBefore: state.notBefore})
+		}
+	}
+	sort.SliceStable(scheduled, func(i, j int) bool { return scheduled[i].notBefore.Before(scheduled[j].notBefore) })
+
+	return &keySnapshot{
+		keys:      sorted,
+		active:    active,
+		scheduled: scheduled,
+		loadedAt:  now,
+	}
+}
+
+// activeKey returns the key used to sign tokens with given algorithm at given time.
+func (s *keySnapshot) activeKey(algorithm entity.Algorithm, now time.Time) (entity.Key, bool) {
+	key, ok := s.active[algorithm]
+
+	for _, scheduled := range s.scheduled {
+		if scheduled.key.Algorithm == algorithm && !now.Before(scheduled.notBefore) {
+			key, ok = scheduled.key, true
+		}
+	}
+
+	return key, ok
+}
+
+// pickActive returns indexes of active keys for each algorithm at given time
+// given states of keys sorted by their ids in ascending order.
+// Imported keys take precedence once their not before time passes,
+// the one which passed last is used. Otherwise keys marked as active are used.
+// Algorithms without either fall back to the key with the lexicographically first id.
+// Verify-only keys are never active.
+func pickActive(keys []keyState, now time.Time) map[entity.Algorithm]int {
+	active := map[entity.Algorithm]int{}
+
+	for i, key := range keys {
+		if !key.imported || key.verifyOnly || now.Before(key.notBefore) {
+			continue
+		}
+
+		if j, ok := active[key.algorithm]; !ok || key.notBefore.After(keys[j].notBefore) {
+			active[key.algorithm] = i
+		}
+	}
+
+	imported := map[entity.Algorithm]bool{}
+	for algorithm := range active {
+		imported[algorithm] = true
+	}
+
+	for i, key := range keys {
+		if key.marked && !key.imported && !key.verifyOnly && !imported[key.algorithm] {
+			active[key.algorithm] = i
+		}
+	}
+
+	for i, key := range keys {
+		if key.imported || key.verifyOnly {
+			continue
+		}
+
+		if _, ok := active[key.algorithm]; !ok {
+			active[key.algorithm] = i
+		}
+	}
+
+	return active
+}
+
+// keyCache holds the current key snapshot.
+// Readers never block while a new snapshot is being loaded.
+type keyCache struct {
+	snapshot atomic.Pointer[keySnapshot]
+	// loading serializes snapshot loads.
+	loading sync.Mutex
+}
+
+func newKeyCache() *keyCache {
+	return &keyCache{}
+}
+
+// get returns the current snapshot or nil if none was stored yet.
+func (c *keyCache) get() *keySnapshot {
+	return c.snapshot.Load()
+}
+
+func (c *keyCache) store(snapshot *keySnapshot) {
+	c.snapshot.Store(snapshot)
+}
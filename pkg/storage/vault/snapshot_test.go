@@ -0,0 +1,727 @@
+package vault
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
+	"github.com/krixlion/dev_forum-auth/pkg/storage"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vault/vaulttest"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/stretchr/testify/mock"
+)
+
+var testKeyPolicy = []KeyPolicy{
+	{Algorithm: entity.ES256, Count: 3},
+	{Algorithm: entity.EdDSA, Count: 2},
+}
+
+// setUpStandInVault returns a Vault connected to given in-memory Vault stand-in.
+func setUpStandInVault(t *testing.T, server *vaulttest.Server) Vault {
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.Anything).Return(nil)
+
+	host, port := server.HostPort()
+	config := Config{
+		MountPath: "secret",
+		KeyPolicy: testKeyPolicy,
+	}
+
+	db, err := Make(context.Background(), vaultclient.Config{Host: host, Port: port, Auth: vaultclient.AuthConfig{Token: server.Token}}, config, broker, nulls.NullTracer{}, nulls.NullLogger{})
+	if err != nil {
+		t.Fatalf("Failed to make vault: %v", err)
+	}
+
+	return db
+}
+
+func newStandInServer(t *testing.T) *vaulttest.Server {
+	server := vaulttest.NewServer("test-token")
+	t.Cleanup(server.Close)
+	server.EnableKVv2("secret")
+	return server
+}
+
+func TestVault_GetActive(t *testing.T) {
+	t.Run("Test if returns the same key until rotation", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		db := setUpStandInVault(t, newStandInServer(t))
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		for _, policy := range testKeyPolicy {
+			first, err := db.GetActive(ctx, policy.Algorithm)
+			if err != nil {
+				t.Fatalf("Vault.GetActive() error = %v", err)
+			}
+
+			if first.Algorithm != policy.Algorithm {
+				t.Errorf("Vault.GetActive() returned %s key, want %s", first.Algorithm, policy.Algorithm)
+			}
+
+			for i := 0; i < 5; i++ {
+				got, err := db.GetActive(ctx, policy.Algorithm)
+				if err != nil {
+					t.Fatalf("Vault.GetActive() error = %v", err)
+				}
+
+				if got.Id != first.Id {
+					t.Errorf("Vault.GetActive() returned key %s, want %s", got.Id, first.Id)
+				}
+			}
+		}
+
+		before, err := db.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		after, err := db.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if after.Id == before.Id {
+			t.Errorf("Vault.GetActive() returned the same key %s after rotation", after.Id)
+		}
+	})
+
+	t.Run("Test if instances sharing a backend agree on the active key", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		server := newStandInServer(t)
+		rotating := setUpStandInVault(t, server)
+		other := setUpStandInVault(t, server)
+
+		if err := rotating.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		assertSameActive := func() {
+			t.Helper()
+
+			want, err := rotating.GetActive(ctx, entity.ES256)
+			if err != nil {
+				t.Fatalf("Vault.GetActive() error = %v", err)
+			}
+
+			got, err := other.GetActive(ctx, entity.ES256)
+			if err != nil {
+				t.Fatalf("Vault.GetActive() error = %v", err)
+			}
+
+			if got.Id != want.Id {
+				t.Errorf("Vault.GetActive() returned key %s, want %s", got.Id, want.Id)
+			}
+		}
+
+		assertSameActive()
+
+		stale, err := other.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if err := rotating.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		// The other instance serves its snapshot until notified about the rotation.
+		got, err := other.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if got.Id != stale.Id {
+			t.Errorf("Vault.GetActive() returned key %s before KeySetUpdated, want %s", got.Id, stale.Id)
+		}
+
+		e, err := event.MakeEvent(event.AuthAggregate, event.KeySetUpdated, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to make event: %v", err)
+		}
+		other.ReloadKeysOnUpdate().Handle(e)
+
+		assertSameActive()
+	})
+
+	t.Run("Test if returns an error when no key uses the algorithm", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		db := setUpStandInVault(t, newStandInServer(t))
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		if _, err := db.GetActive(ctx, entity.RS256); !errors.Is(err, storage.ErrKeyNotFound) {
+			t.Errorf("Vault.GetActive() error = %v, want %v", err, storage.ErrKeyNotFound)
+		}
+	})
+}
+
+func TestVault_snapshot(t *testing.T) {
+	t.Run("Test if keys are served without requests to the Vault", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		server := newStandInServer(t)
+		db := setUpStandInVault(t, server)
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		before := server.Requests()
+
+		for i := 0; i < 10; i++ {
+			if _, err := db.GetActive(ctx, entity.ES256); err != nil {
+				t.Fatalf("Vault.GetActive() error = %v", err)
+			}
+
+			keys, err := db.GetKeySet(ctx)
+			if err != nil {
+				t.Fatalf("Vault.GetKeySet() error = %v", err)
+			}
+
+			if want := 5; len(keys) != want {
+				t.Errorf("Vault.GetKeySet() returned %d keys, want %d", len(keys), want)
+			}
+		}
+
+		if got := server.Requests() - before; got != 0 {
+			t.Errorf("Vault made %d requests, want 0", got)
+		}
+	})
+
+	t.Run("Test if snapshot is loaded lazily and reloaded on TTL", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		server := newStandInServer(t)
+		if err := setUpStandInVault(t, server).refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		broker := mocks.NewBroker()
+		broker.On("ResilientPublish", mock.Anything).Return(nil)
+
+		host, port := server.HostPort()
+		config := Config{MountPath: "secret", KeyCount: 2, KeySnapshotTTL: time.Millisecond * 50}
+
+		db, err := Make(ctx, vaultclient.Config{Host: host, Port: port, Auth: vaultclient.AuthConfig{Token: server.Token}}, config, broker, nulls.NullTracer{}, nulls.NullLogger{})
+		if err != nil {
+			t.Fatalf("Failed to make vault: %v", err)
+		}
+
+		if _, err := db.GetActive(ctx, entity.EdDSA); err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		loadedAt := db.keys.get().loadedAt
+
+		deadline := time.After(time.Second * 2)
+		for db.keys.get().loadedAt.Equal(loadedAt) {
+			select {
+			case <-deadline:
+				t.Fatal("Snapshot was not reloaded after TTL")
+			case <-time.After(time.Millisecond * 10):
+			}
+		}
+	})
+}
+
+func BenchmarkVault_GetActive(b *testing.B) {
+	ctx := context.Background()
+
+	server := vaulttest.NewServer("test-token")
+	defer server.Close()
+	server.EnableKVv2("secret")
+
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.Anything).Return(nil)
+
+	host, port := server.HostPort()
+	db, err := Make(ctx, vaultclient.Config{Host: host, Port: port, Auth: vaultclient.AuthConfig{Token: server.Token}}, Config{MountPath: "secret", KeyCount: 10}, broker, nulls.NullTracer{}, nulls.NullLogger{})
+	if err != nil {
+		b.Fatalf("Failed to make vault: %v", err)
+	}
+
+	if err := db.refreshKeys(ctx); err != nil {
+		b.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	b.Run("snapshot", func(b *testing.B) {
+		before := server.Requests()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := db.GetActive(ctx, entity.ES256); err != nil {
+				b.Fatalf("Vault.GetActive() error = %v", err)
+			}
+		}
+
+		b.ReportMetric(float64(server.Requests()-before)/float64(b.N), "vault-requests/op")
+	})
+
+	b.Run("reload", func(b *testing.B) {
+		before := server.Requests()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if err := db.reloadSnapshot(ctx); err != nil {
+				b.Fatalf("Vault.reloadSnapshot() error = %v", err)
+			}
+
+			if _, err := db.GetActive(ctx, entity.ES256); err != nil {
+				b.Fatalf("Vault.GetActive() error = %v", err)
+			}
+		}
+
+		b.ReportMetric(float64(server.Requests()-before)/float64(b.N), "vault-requests/op")
+	})
+}
+
+func BenchmarkVault_GetKeySet(b *testing.B) {
+	ctx := context.Background()
+
+	server := vaulttest.NewServer("test-token")
+	defer server.Close()
+	server.EnableKVv2("secret")
+
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.Anything).Return(nil)
+
+	host, port := server.HostPort()
+	db, err := Make(ctx, vaultclient.Config{Host: host, Port: port, Auth: vaultclient.AuthConfig{Token: server.Token}}, Config{MountPath: "secret", KeyCount: 10}, broker, nulls.NullTracer{}, nulls.NullLogger{})
+	if err != nil {
+		b.Fatalf("Failed to make vault: %v", err)
+	}
+
+	if err := db.refreshKeys(ctx); err != nil {
+		b.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	before := server.Requests()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := db.GetKeySet(ctx); err != nil {
+			b.Fatalf("Vault.GetKeySet() error = %v", err)
+		}
+	}
+
+	b.ReportMetric(float64(server.Requests()-before)/float64(b.N), "vault-requests/op")
+}
+
+func TestVault_verifyOnlyKeys(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	db := setUpStandInVault(t, newStandInServer(t))
+	if err := db.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	public, err := x509.MarshalPKIXPublicKey(testdata.ECDSA.PubKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	// Marked as active to make sure verify-only keys are never used for signing.
+	external := keystore.StoredKey{
+		Algorithm:  entity.ES256,
+		KeyType:    entity.ECDSA,
+		EncodedKey: encodePem("PUBLIC KEY", public),
+		Active:     true,
+	}
+	if err := db.store.Put(ctx, "external", external); err != nil {
+		t.Fatalf("Failed to put key: %v", err)
+	}
+
+	if err := db.reloadSnapshot(ctx); err != nil {
+		t.Fatalf("Vault.reloadSnapshot() error = %v", err)
+	}
+
+	assertPublished := func(t *testing.T) {
+		t.Helper()
+
+		keys, err := db.GetKeySet(ctx)
+		if err != nil {
+			t.Fatalf("Vault.GetKeySet() error = %v", err)
+		}
+
+		for _, key := range keys {
+			if key.Id == "external" {
+				if !key.VerifyOnly() {
+					t.Errorf("Vault.GetKeySet(): external key is not verify-only")
+				}
+				if _, err := key.Encode(); err != nil {
+					t.Errorf("Vault.GetKeySet(): external key not encoded: %v", err)
+				}
+				return
+			}
+		}
+		t.Errorf("Vault.GetKeySet(): external key not found")
+	}
+
+	assertNotActive := func(t *testing.T) {
+		t.Helper()
+
+		active, err := db.GetActive(ctx, entity.ES256)
+		if err != nil {
+			t.Fatalf("Vault.GetActive() error = %v", err)
+		}
+
+		if active.Id == "external" || active.VerifyOnly() {
+			t.Errorf("Vault.GetActive() returned a verify-only key")
+		}
+
+		infos, err := db.ListKeys(ctx)
+		if err != nil {
+			t.Fatalf("Vault.ListKeys() error = %v", err)
+		}
+
+		for _, info := range infos {
+			if info.Id == "external" && info.Active {
+				t.Errorf("Vault.ListKeys(): verify-only key is listed as active")
+			}
+		}
+	}
+
+	assertPublished(t)
+	assertNotActive(t)
+
+	t.Run("Test if is retained on rotation", func(t *testing.T) {
+		if err := db.refreshKeys(ctx); err != nil {
+			t.Fatalf("Vault.refreshKeys() error = %v", err)
+		}
+
+		assertPublished(t)
+		assertNotActive(t)
+	})
+
+	t.Run("Test if is removed on revocation", func(t *testing.T) {
+		if err := db.RevokeKey(ctx, "external"); err != nil {
+			t.Fatalf("Vault.RevokeKey() error = %v", err)
+		}
+
+		if _, err := db.store.Get(ctx, "external"); !errors.Is(err, storage.ErrKeyNotFound) {
+			t.Errorf("Vault.RevokeKey(): key was not deleted, err = %v", err)
+		}
+	})
+}
+
+func TestVault_retiredKeys(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	db := setUpStandInVault(t, newStandInServer(t))
+	if err := db.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	replaced, err := db.GetKeySet(ctx)
+	if err != nil {
+		t.Fatalf("Vault.GetKeySet() error = %v", err)
+	}
+
+	if err := db.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	t.Run("Test if replaced keys are published as verify-only keys", func(t *testing.T) {
+		keys, err := db.GetKeySet(ctx)
+		if err != nil {
+			t.Fatalf("Vault.GetKeySet() error = %v", err)
+		}
+
+		published := map[string]entity.Key{}
+		for _, key := range keys {
+			published[key.Id] = key
+		}
+
+		for _, old := range replaced {
+			key, ok := published[old.Id]
+			if !ok {
+				t.Errorf("Vault.GetKeySet(): replaced key %s is not published", old.Id)
+				continue
+			}
+
+			if !key.VerifyOnly() {
+				t.Errorf("Vault.GetKeySet(): replaced key %s is not verify-only", old.Id)
+			}
+
+			stored, err := db.store.Get(ctx, old.Id)
+			if err != nil {
+				t.Fatalf("Store.Get() error = %v", err)
+			}
+
+			if stored.RetiredAt.IsZero() || !keystore.IsVerifyOnly(stored.EncodedKey) {
+				t.Errorf("Vault.refreshKeys(): replaced key %s is stored with a private key or without retirement time", old.Id)
+			}
+		}
+
+		for _, policy := range testKeyPolicy {
+			active, err := db.GetActive(ctx, policy.Algorithm)
+			if err != nil {
+				t.Fatalf("Vault.GetActive() error = %v", err)
+			}
+
+			if _, ok := published[active.Id]; !ok || active.VerifyOnly() {
+				t.Errorf("Vault.GetActive() returned a retired key %s", active.Id)
+			}
+		}
+	})
+
+	t.Run("Test if retired keys are kept until the retention passes", func(t *testing.T) {
+		if _, err := db.purge(ctx, time.Now().Add(db.config.retiredKeyRetention()-time.Minute)); err != nil {
+			t.Fatalf("Vault.purge() error = %v", err)
+		}
+
+		for _, old := range replaced {
+			if _, err := db.store.Get(ctx, old.Id); err != nil {
+				t.Errorf("Vault.purge(): retired key %s was deleted early, err = %v", old.Id, err)
+			}
+		}
+	})
+
+	t.Run("Test if retired keys are deleted once the retention passes", func(t *testing.T) {
+		if _, err := db.purge(ctx, time.Now().Add(db.config.retiredKeyRetention()+time.Minute)); err != nil {
+			t.Fatalf("Vault.purge() error = %v", err)
+		}
+
+		for _, old := range replaced {
+			if _, err := db.store.Get(ctx, old.Id); !errors.Is(err, storage.ErrKeyNotFound) {
+				t.Errorf("Vault.purge(): retired key %s was not deleted, err = %v", old.Id, err)
+			}
+		}
+	})
+}
+
+func TestVault_loadSnapshot_malformedKey(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	db := setUpStandInVault(t, newStandInServer(t))
+	if err := db.refreshKeys(ctx); err != nil {
+		t.Fatalf("Vault.refreshKeys() error = %v", err)
+	}
+
+	want, err := db.GetKeySet(ctx)
+	if err != nil {
+		t.Fatalf("Vault.GetKeySet() error = %v", err)
+	}
+
+	malformed := keystore.StoredKey{
+		Algorithm:  entity.ES256,
+		KeyType:    entity.ECDSA,
+		EncodedKey: "not a pem",
+	}
+	if err := db.store.Put(ctx, "malformed", malformed); err != nil {
+		t.Fatalf("Failed to put key: %v", err)
+	}
+
+	if err := db.reloadSnapshot(ctx); err != nil {
+		t.Fatalf("Vault.reloadSnapshot() error = %v", err)
+	}
+
+	got, err := db.GetKeySet(ctx)
+	if err != nil {
+		t.Fatalf("Vault.GetKeySet() error = %v", err)
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("Vault.GetKeySet() returned %d keys, want %d without the malformed key", len(got), len(want))
+	}
+
+	for _, key := range got {
+		if key.Id == "malformed" {
+			t.Errorf("Vault.GetKeySet() published the malformed key")
+		}
+	}
+}
+
+func Test_pickActive(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name string
+		// Sorted by their ids.
+		keys []keyState
+		want map[entity.Algorithm]int
+	}{
+		{
+			name: "Test if falls back to the lexicographically first key",
+			keys: []keyState{
+				{id: "a", algorithm: entity.ES256},
+				{id: "b", algorithm: entity.ES256},
+				{id: "c", algorithm: entity.EdDSA},
+			},
+			want: map[entity.Algorithm]int{entity.ES256: 0, entity.EdDSA: 2},
+		},
+		{
+			name: "Test if marked key takes precedence over the fallback",
+			keys: []keyState{
+				{id: "a", algorithm: entity.ES256},
+				{id: "b", algorithm: entity.ES256, marked: true},
+				{id: "c", algorithm: entity.EdDSA},
+			},
+			want: map[entity.Algorithm]int{entity.ES256: 1, entity.EdDSA: 2},
+		},
+		{
+			name: "Test if imported key takes precedence over the marked key",
+			keys: []keyState{
+				{id: "a", algorithm: entity.ES256, marked: true},
+				{id: "b", algorithm: entity.ES256},
+				{id: "c", algorithm: entity.ES256, imported: true},
+			},
+			want: map[entity.Algorithm]int{entity.ES256: 2},
+		},
+		{
+			name: "Test if imported key whose not before time passed last is used",
+			keys: []keyState{
+				{id: "a", algorithm: entity.ES256, imported: true, notBefore: now.Add(-time.Hour)},
+				{id: "b", algorithm: entity.ES256, imported: true, notBefore: now.Add(-time.Minute)},
+				{id: "c", algorithm: entity.ES256, imported: true, notBefore: now.Add(-time.Hour * 2)},
+			},
+			want: map[entity.Algorithm]int{entity.ES256: 1},
+		},
+		{
+			name: "Test if imported key is not used before its not before time",
+			keys: []keyState{
+				{id: "a", algorithm: entity.ES256},
+				{id: "b", algorithm: entity.ES256, marked: true},
+				{id: "c", algorithm: entity.ES256, imported: true, notBefore: now.Add(time.Minute)},
+			},
+			want: map[entity.Algorithm]int{entity.ES256: 1},
+		},
+		{
+			name: "Test if imported keys do not fall back to the lexicographically first key",
+			keys: []keyState{
+				{id: "a", algorithm: entity.ES256, imported: true, notBefore: now.Add(time.Minute)},
+				{id: "b", algorithm: entity.ES256},
+			},
+			want: map[entity.Algorithm]int{entity.ES256: 1},
+		},
+		{
+			name: "Test if verify-only keys are never active",
+			keys: []keyState{
+				{id: "a", algorithm: entity.ES256, verifyOnly: true},
+				{id: "b", algorithm: entity.ES256, verifyOnly: true, marked: true},
+				{id: "c", algorithm: entity.ES256, verifyOnly: true, imported: true},
+				{id: "d", algorithm: entity.ES256},
+				{id: "e", algorithm: entity.EdDSA, verifyOnly: true},
+			},
+			want: map[entity.Algorithm]int{entity.ES256: 3},
+		},
+		{
+			name: "Test if returns no key for an algorithm without a signing key",
+			keys: []keyState{
+				{id: "a", algorithm: entity.ES256, imported: true, notBefore: now.Add(time.Minute)},
+			},
+			want: map[entity.Algorithm]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickActive(tt.keys, now); !cmp.Equal(got, tt.want) {
+				t.Errorf("pickActive():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newKeySnapshot(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	keys := []entity.Key{
+		{Id: "d", Algorithm: entity.ES256},
+		{Id: "b", Algorithm: entity.ES256},
+		{Id: "c", Algorithm: entity.ES256},
+		{Id: "a", Algorithm: entity.ES256},
+		{Id: "e", Algorithm: entity.EdDSA},
+	}
+
+	tests := []struct {
+		name   string
+		states map[string]keyState
+		at     time.Time
+		want   map[entity.Algorithm]string
+	}{
+		{
+			name:   "Test if falls back to the lexicographically first key regardless of the order keys are loaded in",
+			states: map[string]keyState{},
+			at:     now,
+			want:   map[entity.Algorithm]string{entity.ES256: "a", entity.EdDSA: "e"},
+		},
+		{
+			name: "Test if marked key is active",
+			states: map[string]keyState{
+				"c": {marked: true},
+			},
+			at:   now,
+			want: map[entity.Algorithm]string{entity.ES256: "c", entity.EdDSA: "e"},
+		},
+		{
+			name: "Test if imported key is active over the marked key",
+			states: map[string]keyState{
+				"c": {marked: true},
+				"d": {imported: true, notBefore: now.Add(-time.Minute)},
+			},
+			at:   now,
+			want: map[entity.Algorithm]string{entity.ES256: "d", entity.EdDSA: "e"},
+		},
+		{
+			name: "Test if scheduled imported key becomes active once its not before time passes",
+			states: map[string]keyState{
+				"c": {marked: true},
+				"d": {imported: true, notBefore: now.Add(time.Minute)},
+			},
+			at:   now.Add(time.Minute),
+			want: map[entity.Algorithm]string{entity.ES256: "d", entity.EdDSA: "e"},
+		},
+		{
+			name: "Test if verify-only keys are skipped",
+			states: map[string]keyState{
+				"a": {verifyOnly: true},
+				"c": {verifyOnly: true, marked: true},
+				"e": {verifyOnly: true},
+			},
+			at:   now,
+			want: map[entity.Algorithm]string{entity.ES256: "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := newKeySnapshot(keys, tt.states, now)
+
+			got := map[entity.Algorithm]string{}
+			for _, algorithm := range []entity.Algorithm{entity.ES256, entity.EdDSA} {
+				if key, ok := snapshot.activeKey(algorithm, tt.at); ok {
+					got[algorithm] = key.Id
+				}
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("keySnapshot.activeKey():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -69,6 +69,16 @@ func Seed() error {
 		return fmt.Errorf("failed to put key: %w", err)
 	}
 
+	testKeyData = map[string]interface{}{
+		"private":   testdata.Ed25519.PrivPem,
+		"algorithm": string(entity.EdDSA),
+		"keyType":   string(entity.OKP),
+	}
+
+	if _, err := kvv2.Put(ctx, testdata.Ed25519.Id, testKeyData); err != nil {
+		return fmt.Errorf("failed to put key: %w", err)
+	}
+
 	return nil
 }
 
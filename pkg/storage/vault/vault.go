@@ -7,25 +7,26 @@ import (
 	"time"
 
 	vault "github.com/hashicorp/vault/api"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/keystore"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/lease"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
 	"github.com/krixlion/dev_forum-lib/event"
 	"github.com/krixlion/dev_forum-lib/logging"
 	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/krixlion/dev_forum-lib/tracing"
 	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	ErrInvalidKeyType        = errors.New("invalid key type")
-	ErrInvalidKeyFormat      = errors.New("invalid key format")
-	ErrAlgorithmNotSupported = errors.New("key's algorithm is not supported")
-	ErrInvalidAlgorithm      = errors.New("key's algorithm is missing or invalid")
-	ErrKeyMissing            = errors.New("key does not contain a private key")
-	ErrFailedToParseKey      = errors.New("failed to parse key")
-)
+// DefaultKeySnapshotTTL is used when Config.KeySnapshotTTL is not set.
+const DefaultKeySnapshotTTL = time.Minute * 5
 
 type Vault struct {
-	vault  *vault.KVv2
-	client *vault.Client
+	// id identifies the instance holding the rotation lease.
+	id     string
+	store  keystore.Store
 	config Config
+	keys   *keyCache
 	broker event.Broker
 	tracer trace.Tracer
 	logger logging.Logger
@@ -33,17 +34,79 @@ type Vault struct {
 
 type Config struct {
 	// Path in the Vault that the client will mount on.
+	// Required only by Make.
 	MountPath          string
 	KeyCount           int
 	KeyRefreshInterval time.Duration
+	// Keys to generate on every refresh. DefaultKeyPolicy is used if empty.
+	KeyPolicy []KeyPolicy
+	// Keys are served from an in-memory snapshot reloaded from the store
+	// in this interval. DefaultKeySnapshotTTL is used if zero.
+	KeySnapshotTTL time.Duration
+	// Keys replaced on rotation are kept as verify-only keys for RetiredKeyTTL
+	// plus KeySnapshotTTL, as instances sign with them until their snapshot is reloaded.
+	// It has to outlive tokens signed with the keys. Retired keys are purged
+	// by the first rotation after that.
+	RetiredKeyTTL time.Duration
+	// Only the instance holding the rotation lease rotates keys.
+	// The lease is renewed every third of its TTL and taken over
+	// by another instance once it expires. DefaultLeaseTTL is used if zero.
+	LeaseTTL time.Duration
+	// CertificateIssuer certifies newly generated and imported keys
+	// so that they are published along with a certificate chain.
+	// Keys are published without certificates if nil.
+	CertificateIssuer CertificateIssuer
+}
+
+// Make connects to Vault with a vaultclient.Client configured with clientConfig
+// and returns a DB instance or a non nil error. The client is connected over HTTPS
+// if clientConfig.TLS is set and its token is renewed until provided context is cancelled.
+// Keys are kept in the KVv2 engine mounted at config.MountPath.
+// See MakeWithStore for details on rotation.
+func Make(ctx context.Context, clientConfig vaultclient.Config, config Config, broker event.Broker, tracer trace.Tracer, logger logging.Logger) (Vault, error) {
+	client, err := vaultclient.New(ctx, clientConfig, logger)
+	if err != nil {
+		return Vault{}, err
+	}
+
+	return MakeWithClient(ctx, client.Client, config, broker, tracer, logger)
+}
+
+// MakeWithClient works like Make but uses given, already authenticated client.
+func MakeWithClient(ctx context.Context, client *vault.Client, config Config, broker event.Broker, tracer trace.Tracer, logger logging.Logger) (Vault, error) {
+	if tracer == nil {
+		tracer = nulls.NullTracer{}
+	}
+
+	if client == nil {
+		return Vault{}, errors.New("no vault client was provided")
+	}
+
+	if config.MountPath == "" {
+		return Vault{}, errors.New("failed to validate vault config: mount path cannot be empty")
+	}
+
+	store := kvStore{
+		KVStore:   lease.NewKVStore(client, config.MountPath, leasePath),
+		vault:     client.KVv2(config.MountPath),
+		client:    client,
+		mountPath: config.MountPath,
+		tracer:    tracer,
+	}
+
+	return MakeWithStore(ctx, store, config, broker, tracer, logger)
 }
 
-// Make takes in a Token used to connect to Vault and returns a DB instance or a non nil error.
+// MakeWithStore returns a DB instance keeping keys in given store or a non nil error.
+//
+// If config.KeyRefreshInterval is greater than 0, Vault starts to compete
+// with other instances for the rotation lease. The instance holding it
+// periodically purges the store and writes a new set of keys.
+// Vault stops refreshing keyset and releases the lease when provided context is cancelled.
 //
-// If config.KeyRefreshInterval is greater than 0, Vault starts to
-// periodically purge the vault and write a new set of keys.
-// Vault stops refreshing keyset when provided context is cancelled.
-func Make(ctx context.Context, host, port, token string, config Config, broker event.Broker, tracer trace.Tracer, logger logging.Logger) (Vault, error) {
+// Keys are cached in memory and reloaded from the store every
+// config.KeySnapshotTTL, on rotation and on event.KeySetUpdated.
+func MakeWithStore(ctx context.Context, store keystore.Store, config Config, broker event.Broker, tracer trace.Tracer, logger logging.Logger) (Vault, error) {
 	if tracer == nil {
 		tracer = nulls.NullTracer{}
 	}
@@ -56,23 +119,23 @@ func Make(ctx context.Context, host, port, token string, config Config, broker e
 		return Vault{}, errors.New("no broker was provided")
 	}
 
+	if store == nil {
+		return Vault{}, errors.New("no store was provided")
+	}
+
 	if err := config.validate(); err != nil {
 		return Vault{}, fmt.Errorf("failed to validate vault config: %w", err)
 	}
 
-	c := vault.DefaultConfig()
-	c.Address = "http://" + host + ":" + port
-
-	client, err := vault.NewClient(c)
+	id, err := lease.HolderId()
 	if err != nil {
 		return Vault{}, err
 	}
 
-	client.SetToken(token)
-
 	vault := Vault{
-		client: client,
-		vault:  client.KVv2(config.MountPath),
+		id:     id,
+		store:  store,
+		keys:   newKeyCache(),
 		tracer: tracer,
 		broker: broker,
 		config: config,
@@ -83,47 +146,195 @@ func Make(ctx context.Context, host, port, token string, config Config, broker e
 		go vault.run(ctx)
 	}
 
+	go vault.runSnapshotReload(ctx)
+
 	return vault, nil
 }
 
 // Run blocks until provided context is cancelled.
-// When invoked Vault starts to periodically purge the vault and write a new
-// set of keys in amount specified in the config.
+// When invoked Vault starts to periodically acquire or renew the rotation
+// lease and, while holding it, rotate keys in the interval specified in the config.
+// Keys with legacy ids are replaced by the first tick holding the lease.
 func (db *Vault) run(ctx context.Context) {
-	// Refresh the vault on start.
+	ticker := time.NewTicker(db.config.leaseTTL() / 3)
+	defer ticker.Stop()
+
+	migrated := false
+
+	for {
+		if !migrated {
+			var err error
+			if migrated, err = db.migrateLegacyIds(ctx); err != nil {
+				db.logger.Log(ctx, "failed to migrate legacy key ids", "err", err)
+			}
+		}
+
+		if err := db.rotateIfLeader(ctx); err != nil {
+			db.logger.Log(ctx, "failed to rotate keys", "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			// Let another instance take over right away.
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+			defer cancel()
+
+			if err := db.releaseLease(ctx); err != nil {
+				db.logger.Log(ctx, "failed to release lease", "err", err)
+			}
+			return
+		}
+	}
+}
+
+// rotateIfLeader rotates keys if this instance holds the rotation lease and
+// config.KeyRefreshInterval has passed since keys were last rotated by any instance.
+// The schedule is persisted so restarted instances resume it instead of rotating.
+func (db Vault) rotateIfLeader(ctx context.Context) error {
+	_, ok, err := db.acquireLease(ctx)
+	if err != nil || !ok {
+		return err
+	}
+
+	due, err := db.rotationDue(ctx)
+	if err != nil || !due {
+		return err
+	}
+
 	db.logger.Log(ctx, "refreshing keys")
 
 	if err := db.refreshKeys(ctx); err != nil {
-		db.logger.Log(ctx, "failed to refresh keys", "err", err)
+		return err
 	}
 
-	ticker := time.NewTicker(db.config.KeyRefreshInterval)
+	return db.recordRotation(ctx, time.Now())
+}
+
+// runSnapshotReload blocks until provided context is cancelled.
+// When invoked Vault starts to periodically reload the key snapshot
+// in order to pick up keys rotated by other instances.
+func (db *Vault) runSnapshotReload(ctx context.Context) {
+	ticker := time.NewTicker(db.config.keySnapshotTTL())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			db.logger.Log(ctx, "refreshing keys")
-
-			if err := db.refreshKeys(ctx); err != nil {
-				db.logger.Log(ctx, "failed to refresh keys", "err", err)
+			if err := db.reloadSnapshot(ctx); err != nil {
+				db.logger.Log(ctx, "failed to reload keys", "err", err)
 			}
 
 		case <-ctx.Done():
 			return
 		}
-
 	}
 }
 
-func (config Config) validate() error {
-	if config.MountPath == "" {
-		return errors.New("mount path cannot be empty")
+// EventHandlers returns handlers reloading the key snapshot
+// when keys are rotated by any instance.
+func (db Vault) EventHandlers() map[event.EventType][]event.Handler {
+	return map[event.EventType][]event.Handler{
+		event.KeySetUpdated: {db.ReloadKeysOnUpdate()},
 	}
+}
+
+// ReloadKeysOnUpdate reloads the key snapshot from the store.
+func (db Vault) ReloadKeysOnUpdate() event.Handler {
+	return event.HandlerFunc(func(e event.Event) {
+		ctx, span := db.tracer.Start(tracing.InjectMetadataIntoContext(context.Background(), e.Metadata), "vault.ReloadKeysOnUpdate")
+		defer span.End()
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+
+		if err := db.reloadSnapshot(ctx); err != nil {
+			tracing.SetSpanErr(span, err)
+			db.logger.Log(ctx, "failed to reload keys", "err", err)
+		}
+	})
+}
 
+func (config Config) validate() error {
 	if config.KeyRefreshInterval < 0 {
 		return errors.New("key refresh interval has to be a non-negative time duration")
 	}
 
+	if config.LeaseTTL < 0 {
+		return errors.New("lease TTL has to be a non-negative time duration")
+	}
+
+	if config.KeySnapshotTTL < 0 {
+		return errors.New("key snapshot TTL has to be a non-negative time duration")
+	}
+
+	if config.KeyCount < 0 {
+		return errors.New("key count has to be a non-negative number")
+	}
+
+	if config.RetiredKeyTTL < 0 {
+		return errors.New("retired key TTL has to be a non-negative time duration")
+	}
+
+	algorithms := make(map[entity.Algorithm]bool, len(config.KeyPolicy))
+	for _, policy := range config.KeyPolicy {
+		if err := policy.validate(); err != nil {
+			return fmt.Errorf("invalid key policy: %w", err)
+		}
+
+		if algorithms[policy.Algorithm] {
+			return fmt.Errorf("invalid key policy: %s is declared more than once", policy.Algorithm)
+		}
+		algorithms[policy.Algorithm] = true
+	}
+
+	// A refresh would otherwise purge all keys of the algorithm and leave none to sign with.
+	for _, policy := range config.keyPolicy() {
+		if policy.count(config.KeyCount) == 0 {
+			return fmt.Errorf("invalid key policy: key count of %s cannot be 0", policy.Algorithm)
+		}
+	}
+
 	return nil
 }
+
+// Algorithms returns algorithms keys are maintained for, in the order of the key policy.
+func (config Config) Algorithms() []entity.Algorithm {
+	policy := config.keyPolicy()
+
+	algorithms := make([]entity.Algorithm, 0, len(policy))
+	for _, p := range policy {
+		algorithms = append(algorithms, p.Algorithm)
+	}
+
+	return algorithms
+}
+
+// keyPolicy returns the active key policy.
+func (config Config) keyPolicy() []KeyPolicy {
+	if len(config.KeyPolicy) == 0 {
+		return DefaultKeyPolicy
+	}
+	return config.KeyPolicy
+}
+
+// keySnapshotTTL returns the interval in which the key snapshot is reloaded.
+func (config Config) keySnapshotTTL() time.Duration {
+	if config.KeySnapshotTTL == 0 {
+		return DefaultKeySnapshotTTL
+	}
+	return config.KeySnapshotTTL
+}
+
+// retiredKeyRetention returns how long keys replaced on rotation are kept.
+func (config Config) retiredKeyRetention() time.Duration {
+	return config.RetiredKeyTTL + config.keySnapshotTTL()
+}
+
+// leaseTTL returns the duration of the rotation lease.
+func (config Config) leaseTTL() time.Duration {
+	if config.LeaseTTL == 0 {
+		return DefaultLeaseTTL
+	}
+	return config.LeaseTTL
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vaultclient"
 	"github.com/krixlion/dev_forum-lib/mocks"
 	"github.com/krixlion/dev_forum-lib/nulls"
 )
@@ -15,7 +17,7 @@ func Test_Make(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		got, err := Make(ctx, "host", "8888", "token", Config{MountPath: "path", KeyRefreshInterval: 0}, mocks.NewBroker(), nil, nil)
+		got, err := Make(ctx, vaultclient.Config{Host: "host", Port: "8888", Auth: vaultclient.AuthConfig{Token: "token"}}, Config{MountPath: "path", KeyCount: 1, KeyRefreshInterval: 0}, mocks.NewBroker(), nil, nil)
 		if err != nil {
 			t.Errorf("Make(): error = %v", err)
 			return
@@ -40,7 +42,7 @@ func Test_Make(t *testing.T) {
 			KeyRefreshInterval: time.Hour,
 		}
 
-		got, err := Make(ctx, "host", "8888", "token", want, mocks.NewBroker(), nil, nil)
+		got, err := Make(ctx, vaultclient.Config{Host: "host", Port: "8888", Auth: vaultclient.AuthConfig{Token: "token"}}, want, mocks.NewBroker(), nil, nil)
 		if err != nil {
 			t.Errorf("Make(): error = %v", err)
 			return
@@ -61,11 +63,22 @@ func Test_Make(t *testing.T) {
 			KeyRefreshInterval: time.Hour,
 		}
 
-		if _, err := Make(ctx, "host", "8888", "token", config, nil, nil, nil); err == nil {
+		if _, err := Make(ctx, vaultclient.Config{Host: "host", Port: "8888", Auth: vaultclient.AuthConfig{Token: "token"}}, config, nil, nil, nil); err == nil {
 			t.Errorf("Make(): error = %v, wantErr = true", err)
 			return
 		}
 	})
+
+	t.Run("Test returns an error when the vault client config is invalid", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clientConfig := vaultclient.Config{Host: "host", Port: "8888", Auth: vaultclient.AuthConfig{Method: vaultclient.TokenAuth}}
+
+		if _, err := Make(ctx, clientConfig, Config{MountPath: "path", KeyCount: 1}, mocks.NewBroker(), nil, nil); err == nil {
+			t.Errorf("Make(): error = %v, wantErr = true", err)
+		}
+	})
 }
 
 func TestConfig_validate(t *testing.T) {
@@ -73,6 +86,7 @@ func TestConfig_validate(t *testing.T) {
 		MountPath          string
 		KeyCount           int
 		KeyRefreshInterval time.Duration
+		KeyPolicy          []KeyPolicy
 	}
 	tests := []struct {
 		name    string
@@ -106,6 +120,66 @@ func TestConfig_validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Test returns an error on negative key count",
+			fields: fields{
+				MountPath: "/secret",
+				KeyCount:  -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test no error is returned on valid key policy",
+			fields: fields{
+				MountPath: "/secret",
+				KeyCount:  10,
+				KeyPolicy: []KeyPolicy{
+					{Algorithm: entity.ES256, Count: 3},
+					{Algorithm: entity.PS384, RSABits: 3072},
+					{Algorithm: entity.ES512, Curve: "P-521"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Test returns an error on zero key count with the default key policy",
+			fields: fields{
+				MountPath: "/secret",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test returns an error on an algorithm without a key count",
+			fields: fields{
+				MountPath: "/secret",
+				KeyPolicy: []KeyPolicy{{Algorithm: entity.ES256, Count: 3}, {Algorithm: entity.RS256}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test no error is returned on zero key count if every algorithm sets its own",
+			fields: fields{
+				MountPath: "/secret",
+				KeyPolicy: []KeyPolicy{{Algorithm: entity.ES256, Count: 3}, {Algorithm: entity.RS256, Count: 1}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Test returns an error on invalid key policy",
+			fields: fields{
+				MountPath: "/secret",
+				KeyPolicy: []KeyPolicy{{Algorithm: entity.HS256}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test returns an error on algorithm declared more than once",
+			fields: fields{
+				MountPath: "/secret",
+				KeyPolicy: []KeyPolicy{{Algorithm: entity.ES256}, {Algorithm: entity.ES256, Count: 2}},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -113,6 +187,7 @@ func TestConfig_validate(t *testing.T) {
 				MountPath:          tt.fields.MountPath,
 				KeyCount:           tt.fields.KeyCount,
 				KeyRefreshInterval: tt.fields.KeyRefreshInterval,
+				KeyPolicy:          tt.fields.KeyPolicy,
 			}
 			if err := config.validate(); (err != nil) != tt.wantErr {
 				t.Errorf("Config.validate():\n error = %v\n wantErr = %v\n", err, tt.wantErr)
@@ -0,0 +1,226 @@
+package vaulttest
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenOptions describe tokens issued by the server.
+type TokenOptions struct {
+	// TTL of the token and of each renewal. The token never expires if 0.
+	TTL time.Duration
+	// The token cannot be renewed past MaxTTL since it was issued. Unlimited if 0.
+	MaxTTL    time.Duration
+	Renewable bool
+}
+
+type issuedToken struct {
+	options  TokenOptions
+	issuedAt time.Time
+	// Zero if the token never expires.
+	expiresAt time.Time
+}
+
+type loginMethod struct {
+	// credentials maps login request's fields to their expected values.
+	credentials map[string]string
+	// clientCert requires the login request to present a verified client certificate.
+	clientCert bool
+	options    TokenOptions
+}
+
+// IssueToken returns a new token accepted by the server.
+func (s *Server) IssueToken(options TokenOptions) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.issueToken(options)
+}
+
+// EnableKubernetesAuth mounts the Kubernetes auth method at given path.
+// Logging in with given role and service account token returns a token with given options.
+func (s *Server) EnableKubernetesAuth(mountPath, role, jwt string, options TokenOptions) {
+	s.enableLogin(mountPath, map[string]string{"role": role, "jwt": jwt}, options)
+}
+
+// EnableAppRoleAuth mounts the AppRole auth method at given path.
+// Logging in with given credentials returns a token with given options.
+func (s *Server) EnableAppRoleAuth(mountPath, roleId, secretId string, options TokenOptions) {
+	s.enableLogin(mountPath, map[string]string{"role_id": roleId, "secret_id": secretId}, options)
+}
+
+// EnableCertAuth mounts the TLS certificate auth method at given path.
+// Logging in with given role name and a client certificate verified
+// by a server started with NewTLSServer returns a token with given options.
+func (s *Server) EnableCertAuth(mountPath, name string, options TokenOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auth[strings.Trim(mountPath, "/")] = loginMethod{credentials: map[string]string{"name": name}, clientCert: true, options: options}
+}
+
+// Logins returns the number of successful logins.
+func (s *Server) Logins() int64 {
+	return s.logins.Load()
+}
+
+func (s *Server) enableLogin(mountPath string, credentials map[string]string, options TokenOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auth[strings.Trim(mountPath, "/")] = loginMethod{credentials: credentials, options: options}
+}
+
+// issueToken must be called with s.mu held.
+func (s *Server) issueToken(options TokenOptions) string {
+	s.tokenCount++
+	token := "s.issued-" + strconv.Itoa(s.tokenCount)
+
+	issued := &issuedToken{options: options, issuedAt: time.Now()}
+	if options.TTL > 0 {
+		issued.expiresAt = issued.issuedAt.Add(options.TTL)
+	}
+
+	s.tokens[token] = issued
+	return token
+}
+
+// authorized returns true if given token is the root token or an unexpired issued token.
+// It must be called with s.mu held.
+func (s *Server) authorized(token string) bool {
+	if token == s.Token {
+		return true
+	}
+
+	issued, ok := s.tokens[token]
+	return ok && !issued.expired()
+}
+
+// serveAuth handles requests to paths under auth/.
+// It must be called with s.mu held.
+func (s *Server) serveAuth(w http.ResponseWriter, r *http.Request, path string) {
+	switch path {
+	case "token/lookup-self":
+		s.lookupSelf(w, r)
+		return
+	case "token/renew-self":
+		s.renewSelf(w, r)
+		return
+	}
+
+	for mountPath, method := range s.auth {
+		if path == mountPath+"/login" {
+			s.login(w, r, method)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "no handler for route auth/"+path)
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request, method loginMethod) {
+	body := map[string]string{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if method.clientCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+		writeError(w, http.StatusBadRequest, "client certificate must be supplied")
+		return
+	}
+
+	for field, want := range method.credentials {
+		if body[field] != want {
+			writeError(w, http.StatusBadRequest, "invalid credentials")
+			return
+		}
+	}
+
+	s.logins.Add(1)
+	token := s.issueToken(method.options)
+	writeAuth(w, token, s.tokens[token])
+}
+
+func (s *Server) lookupSelf(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Vault-Token")
+	if !s.authorized(token) {
+		writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	data := map[string]interface{}{
+		"id":          token,
+		"ttl":         0,
+		"renewable":   false,
+		"expire_time": nil,
+	}
+
+	if issued, ok := s.tokens[token]; ok {
+		data["renewable"] = issued.options.Renewable
+		if !issued.expiresAt.IsZero() {
+			data["ttl"] = issued.ttlSeconds()
+			data["expire_time"] = issued.expiresAt.UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	writeData(w, data)
+}
+
+func (s *Server) renewSelf(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Vault-Token")
+	issued, ok := s.tokens[token]
+	if !ok || issued.expired() {
+		writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	if !issued.options.Renewable {
+		writeError(w, http.StatusBadRequest, "lease is not renewable")
+		return
+	}
+
+	if !issued.expiresAt.IsZero() {
+		issued.expiresAt = time.Now().Add(issued.options.TTL)
+
+		if issued.options.MaxTTL > 0 {
+			if maxExpiresAt := issued.issuedAt.Add(issued.options.MaxTTL); issued.expiresAt.After(maxExpiresAt) {
+				issued.expiresAt = maxExpiresAt
+			}
+		}
+	}
+
+	writeAuth(w, token, issued)
+}
+
+func (t *issuedToken) expired() bool {
+	return !t.expiresAt.IsZero() && !time.Now().Before(t.expiresAt)
+}
+
+// ttlSeconds returns the remaining TTL rounded down like Vault does.
+func (t *issuedToken) ttlSeconds() int {
+	return int(math.Max(0, time.Until(t.expiresAt).Seconds()))
+}
+
+func writeAuth(w http.ResponseWriter, token string, issued *issuedToken) {
+	leaseDuration := 0
+	if !issued.expiresAt.IsZero() {
+		leaseDuration = issued.ttlSeconds()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"auth": map[string]interface{}{
+			"client_token":   token,
+			"lease_duration": leaseDuration,
+			"renewable":      issued.options.Renewable,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
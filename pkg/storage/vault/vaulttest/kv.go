@@ -0,0 +1,192 @@
+package vaulttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type kvEngine struct {
+	secrets map[string]*kvSecret
+}
+
+type kvSecret struct {
+	versions       []kvVersion
+	customMetadata map[string]string
+	created        time.Time
+	updated        time.Time
+}
+
+type kvVersion struct {
+	data    map[string]interface{}
+	created time.Time
+}
+
+func newKVEngine() *kvEngine {
+	return &kvEngine{
+		secrets: map[string]*kvSecret{},
+	}
+}
+
+// serve handles requests to paths relative to the engine's mount path.
+func (e *kvEngine) serve(w http.ResponseWriter, r *http.Request, method string, path []string) {
+	if len(path) == 0 {
+		writeError(w, http.StatusNotFound, "unsupported path")
+		return
+	}
+
+	name := strings.Join(path[1:], "/")
+
+	switch {
+	case path[0] == "data" && name != "" && method == http.MethodGet:
+		e.readSecret(w, name)
+	case path[0] == "data" && name != "" && (method == http.MethodPost || method == http.MethodPut):
+		e.writeSecret(w, r, name)
+	case path[0] == "metadata" && name == "" && method == "LIST":
+		e.list(w)
+	case path[0] == "metadata" && name != "" && method == http.MethodGet:
+		e.readMetadata(w, name)
+	case path[0] == "metadata" && name != "" && (method == http.MethodPost || method == http.MethodPut):
+		e.writeMetadata(w, r, name)
+	case path[0] == "metadata" && name != "" && method == http.MethodDelete:
+		delete(e.secrets, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "unsupported path")
+	}
+}
+
+func (e *kvEngine) list(w http.ResponseWriter) {
+	if len(e.secrets) == 0 {
+		writeError(w, http.StatusNotFound, "")
+		return
+	}
+
+	// Nested secrets are listed as folders.
+	unique := map[string]bool{}
+	for name := range e.secrets {
+		if folder, _, ok := strings.Cut(name, "/"); ok {
+			name = folder + "/"
+		}
+		unique[name] = true
+	}
+
+	names := make([]string, 0, len(unique))
+	for name := range unique {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writeData(w, map[string]interface{}{"keys": names})
+}
+
+func (e *kvEngine) readSecret(w http.ResponseWriter, name string) {
+	secret, ok := e.secrets[name]
+	if !ok || len(secret.versions) == 0 {
+		writeError(w, http.StatusNotFound, "")
+		return
+	}
+
+	version := len(secret.versions)
+	writeData(w, map[string]interface{}{
+		"data":     secret.versions[version-1].data,
+		"metadata": secret.versionMetadata(version),
+	})
+}
+
+func (e *kvEngine) writeSecret(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		Data    map[string]interface{} `json:"data"`
+		Options struct {
+			CAS *int `json:"cas"`
+		} `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if cas := req.Options.CAS; cas != nil {
+		current := 0
+		if secret, ok := e.secrets[name]; ok {
+			current = len(secret.versions)
+		}
+
+		if *cas != current {
+			writeError(w, http.StatusBadRequest, "check-and-set parameter did not match the current version")
+			return
+		}
+	}
+
+	secret := e.secret(name)
+	now := time.Now().UTC()
+	secret.versions = append(secret.versions, kvVersion{data: req.Data, created: now})
+	secret.updated = now
+
+	writeData(w, secret.versionMetadata(len(secret.versions)))
+}
+
+func (e *kvEngine) readMetadata(w http.ResponseWriter, name string) {
+	secret, ok := e.secrets[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "")
+		return
+	}
+
+	versions := map[string]interface{}{}
+	for i := range secret.versions {
+		versions[strconv.Itoa(i+1)] = secret.versionMetadata(i + 1)
+	}
+
+	writeData(w, map[string]interface{}{
+		"cas_required":         false,
+		"created_time":         secret.created.Format(time.RFC3339Nano),
+		"current_version":      len(secret.versions),
+		"custom_metadata":      secret.customMetadata,
+		"delete_version_after": "0s",
+		"max_versions":         0,
+		"oldest_version":       1,
+		"updated_time":         secret.updated.Format(time.RFC3339Nano),
+		"versions":             versions,
+	})
+}
+
+func (e *kvEngine) writeMetadata(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		CustomMetadata map[string]string `json:"custom_metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	secret := e.secret(name)
+	secret.customMetadata = req.CustomMetadata
+	secret.updated = time.Now().UTC()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// secret returns an existing secret with given name or creates a new one.
+func (e *kvEngine) secret(name string) *kvSecret {
+	secret, ok := e.secrets[name]
+	if !ok {
+		now := time.Now().UTC()
+		secret = &kvSecret{created: now, updated: now}
+		e.secrets[name] = secret
+	}
+	return secret
+}
+
+func (secret *kvSecret) versionMetadata(version int) map[string]interface{} {
+	return map[string]interface{}{
+		"created_time":    secret.versions[version-1].created.Format(time.RFC3339Nano),
+		"custom_metadata": secret.customMetadata,
+		"deletion_time":   "",
+		"destroyed":       false,
+		"version":         version,
+	}
+}
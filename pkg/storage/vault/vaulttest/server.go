@@ -0,0 +1,161 @@
+// Package vaulttest provides an in-memory stand-in for a Vault server
+// running in dev mode. It implements only the subset of the HTTP API used
+// by this repository and is meant to be used in tests only.
+package vaulttest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Server is a Vault dev-mode stand-in listening on a local address.
+type Server struct {
+	*httptest.Server
+
+	// Token is the root token required to authenticate requests.
+	Token string
+
+	requests atomic.Int64
+	logins   atomic.Int64
+
+	mu         sync.Mutex
+	transit    map[string]*transitEngine
+	kv         map[string]*kvEngine
+	auth       map[string]loginMethod
+	tokens     map[string]*issuedToken
+	tokenCount int
+}
+
+// NewServer starts and returns a new Server accepting given root token.
+// It should be closed using Close() when no longer needed.
+func NewServer(token string) *Server {
+	s := newServer(token)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewTLSServer works like NewServer but serves HTTPS using httptest's certificate,
+// which is valid for 127.0.0.1 and example.com. Client certificates issued
+// by given CAs are verified and can be used to log in with the cert auth method.
+func NewTLSServer(token string, clientCAs *x509.CertPool) *Server {
+	s := newServer(token)
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	s.Server.TLS = &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientCAs,
+	}
+	s.Server.StartTLS()
+	return s
+}
+
+func newServer(token string) *Server {
+	return &Server{
+		Token:   token,
+		transit: map[string]*transitEngine{},
+		kv:      map[string]*kvEngine{},
+		auth:    map[string]loginMethod{},
+		tokens:  map[string]*issuedToken{},
+	}
+}
+
+// HostPort returns the host and port the server is listening on.
+func (s *Server) HostPort() (host, port string) {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	host, port, err = net.SplitHostPort(u.Host)
+	if err != nil {
+		panic(err)
+	}
+
+	return host, port
+}
+
+// Requests returns the number of requests the server has received.
+func (s *Server) Requests() int64 {
+	return s.requests.Load()
+}
+
+// EnableTransit mounts a Transit secrets engine at given path.
+func (s *Server) EnableTransit(mountPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.transit[strings.Trim(mountPath, "/")] = newTransitEngine()
+}
+
+// EnableKVv2 mounts a KVv2 secrets engine at given path.
+func (s *Server) EnableKVv2(mountPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.kv[strings.Trim(mountPath, "/")] = newKVEngine()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+	// Clients may send mount paths with a leading slash.
+	path = strings.TrimLeft(path, "/")
+
+	method := r.Method
+	if method == http.MethodGet && r.URL.Query().Get("list") == "true" {
+		method = "LIST"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Auth endpoints authenticate requests on their own.
+	if rest, ok := strings.CutPrefix(path, "auth/"); ok {
+		s.serveAuth(w, r, rest)
+		return
+	}
+
+	if !s.authorized(r.Header.Get("X-Vault-Token")) {
+		writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	for mountPath, engine := range s.transit {
+		if rest, ok := strings.CutPrefix(path, mountPath+"/"); ok {
+			engine.serve(w, r, method, strings.Split(rest, "/"))
+			return
+		}
+	}
+
+	for mountPath, engine := range s.kv {
+		if rest, ok := strings.CutPrefix(path, mountPath+"/"); ok {
+			engine.serve(w, r, method, strings.Split(rest, "/"))
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "no handler for route "+path)
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"data": data}); err != nil {
+		panic(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{msg}}); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,315 @@
+package vaulttest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type transitEngine struct {
+	keys map[string]*transitKey
+}
+
+type transitKey struct {
+	keyType  string
+	versions []crypto.Signer
+	created  []time.Time
+	// Versions lower than minAvailable were trimmed.
+	minAvailable  int
+	minDecryption int
+}
+
+func newTransitEngine() *transitEngine {
+	return &transitEngine{
+		keys: map[string]*transitKey{},
+	}
+}
+
+// serve handles requests to paths relative to the engine's mount path.
+func (e *transitEngine) serve(w http.ResponseWriter, r *http.Request, method string, path []string) {
+	switch {
+	case len(path) == 1 && path[0] == "keys" && method == "LIST":
+		e.listKeys(w)
+	case len(path) == 2 && path[0] == "keys" && method == http.MethodGet:
+		e.readKey(w, path[1])
+	case len(path) == 2 && path[0] == "keys" && (method == http.MethodPost || method == http.MethodPut):
+		e.createKey(w, r, path[1])
+	case len(path) == 3 && path[0] == "keys" && path[2] == "rotate" && (method == http.MethodPost || method == http.MethodPut):
+		e.rotateKey(w, path[1])
+	case len(path) == 3 && path[0] == "keys" && path[2] == "config" && (method == http.MethodPost || method == http.MethodPut):
+		e.configureKey(w, r, path[1])
+	case len(path) == 3 && path[0] == "keys" && path[2] == "trim" && (method == http.MethodPost || method == http.MethodPut):
+		e.trimKey(w, r, path[1])
+	case (len(path) == 2 || len(path) == 3) && path[0] == "sign" && (method == http.MethodPost || method == http.MethodPut):
+		hashAlgorithm := "sha2-256"
+		if len(path) == 3 {
+			hashAlgorithm = path[2]
+		}
+		e.sign(w, r, path[1], hashAlgorithm)
+	default:
+		writeError(w, http.StatusNotFound, "unsupported path")
+	}
+}
+
+func (e *transitEngine) listKeys(w http.ResponseWriter) {
+	if len(e.keys) == 0 {
+		writeError(w, http.StatusNotFound, "")
+		return
+	}
+
+	names := make([]string, 0, len(e.keys))
+	for name := range e.keys {
+		names = append(names, name)
+	}
+
+	writeData(w, map[string]interface{}{"keys": names})
+}
+
+func (e *transitEngine) readKey(w http.ResponseWriter, name string) {
+	key, ok := e.keys[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+
+	versions := map[string]interface{}{}
+	for i, signer := range key.versions {
+		if i+1 < key.minAvailable {
+			continue
+		}
+
+		der, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		versions[strconv.Itoa(i+1)] = map[string]interface{}{
+			"creation_time": key.created[i].Format(time.RFC3339Nano),
+			"public_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		}
+	}
+
+	writeData(w, map[string]interface{}{
+		"name":                   name,
+		"type":                   key.keyType,
+		"latest_version":         len(key.versions),
+		"min_available_version":  key.minAvailable,
+		"min_decryption_version": key.minDecryption,
+		"supports_signing":       true,
+		"keys":                   versions,
+	})
+}
+
+func (e *transitEngine) createKey(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, ok := e.keys[name]; ok {
+		// Creating an existing key is a no-op in Vault.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	key := &transitKey{keyType: req.Type, minDecryption: 1}
+	if err := key.rotate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	e.keys[name] = key
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *transitEngine) rotateKey(w http.ResponseWriter, name string) {
+	key, ok := e.keys[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "key not found")
+		return
+	}
+
+	if err := key.rotate(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *transitEngine) configureKey(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		MinDecryptionVersion int `json:"min_decryption_version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, ok := e.keys[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "key not found")
+		return
+	}
+
+	if req.MinDecryptionVersion != 0 {
+		if req.MinDecryptionVersion < key.minAvailable || req.MinDecryptionVersion > len(key.versions) {
+			writeError(w, http.StatusBadRequest, "invalid min decryption version")
+			return
+		}
+		key.minDecryption = req.MinDecryptionVersion
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// trimKey deletes versions lower than min_available_version.
+// Like in Vault, it cannot be greater than min_decryption_version.
+func (e *transitEngine) trimKey(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		MinAvailableVersion int `json:"min_available_version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, ok := e.keys[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "key not found")
+		return
+	}
+
+	if req.MinAvailableVersion < 1 || req.MinAvailableVersion > key.minDecryption {
+		writeError(w, http.StatusBadRequest, "invalid min available version")
+		return
+	}
+
+	key.minAvailable = req.MinAvailableVersion
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *transitEngine) sign(w http.ResponseWriter, r *http.Request, name, hashAlgorithm string) {
+	var req struct {
+		Input               string `json:"input"`
+		Prehashed           bool   `json:"prehashed"`
+		KeyVersion          int    `json:"key_version"`
+		SignatureAlgorithm  string `json:"signature_algorithm"`
+		MarshalingAlgorithm string `json:"marshaling_algorithm"`
+		SaltLength          string `json:"salt_length"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, ok := e.keys[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "key not found")
+		return
+	}
+
+	version := req.KeyVersion
+	if version == 0 {
+		version = len(key.versions)
+	}
+
+	if version < 1 || version < key.minAvailable || version > len(key.versions) {
+		writeError(w, http.StatusBadRequest, "invalid key version")
+		return
+	}
+
+	if req.MarshalingAlgorithm != "" && req.MarshalingAlgorithm != "asn1" {
+		writeError(w, http.StatusBadRequest, "unsupported marshaling algorithm")
+		return
+	}
+
+	hash, ok := map[string]crypto.Hash{
+		"sha2-256": crypto.SHA256,
+		"sha2-384": crypto.SHA384,
+		"sha2-512": crypto.SHA512,
+	}[hashAlgorithm]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "unsupported hash algorithm")
+		return
+	}
+
+	input, err := base64.StdEncoding.DecodeString(req.Input)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	digest := input
+	if !req.Prehashed {
+		h := hash.New()
+		h.Write(input)
+		digest = h.Sum(nil)
+	}
+
+	var opts crypto.SignerOpts = hash
+	if req.SignatureAlgorithm == "pss" {
+		saltLength := rsa.PSSSaltLengthAuto
+		if req.SaltLength == "hash" {
+			saltLength = rsa.PSSSaltLengthEqualsHash
+		}
+		opts = &rsa.PSSOptions{Hash: hash, SaltLength: saltLength}
+	}
+
+	signature, err := key.versions[version-1].Sign(rand.Reader, digest, opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeData(w, map[string]interface{}{
+		"signature":   "vault:v" + strconv.Itoa(version) + ":" + base64.StdEncoding.EncodeToString(signature),
+		"key_version": version,
+	})
+}
+
+// rotate appends a new version of the key.
+func (key *transitKey) rotate() error {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+
+	switch key.keyType {
+	case "ecdsa-p256":
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ecdsa-p384":
+		signer, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ecdsa-p521":
+		signer, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "rsa-2048":
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "rsa-3072":
+		signer, err = rsa.GenerateKey(rand.Reader, 3072)
+	case "rsa-4096":
+		signer, err = rsa.GenerateKey(rand.Reader, 4096)
+	default:
+		return fmt.Errorf("unsupported key type: %s", key.keyType)
+	}
+	if err != nil {
+		return err
+	}
+
+	key.versions = append(key.versions, signer)
+	key.created = append(key.created, time.Now())
+	return nil
+}
@@ -0,0 +1,37 @@
+package vaultclient
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthCheckInterval is how often ReportHealth checks the token.
+const healthCheckInterval = time.Second
+
+// ReportHealth sets the serving status of given service to NOT_SERVING
+// while the client's token is expired and to SERVING otherwise.
+// It blocks until provided context is cancelled.
+func (c *Client) ReportHealth(ctx context.Context, server *health.Server, service string) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		server.SetServingStatus(service, c.servingStatus(time.Now()))
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Client) servingStatus(now time.Time) healthpb.HealthCheckResponse_ServingStatus {
+	if c.Status().Expired(now) {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
@@ -0,0 +1,419 @@
+// Package vaultclient creates Vault clients authenticated with one of the
+// supported auth methods and keeps their tokens valid in the background.
+package vaultclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/krixlion/dev_forum-lib/cert"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/nulls"
+)
+
+type AuthMethod string
+
+const (
+	// TokenAuth uses a token issued beforehand, e.g. by an operator.
+	// The token is renewed if possible but it cannot be replaced once it expires.
+	TokenAuth AuthMethod = "token"
+	// KubernetesAuth logs in with the pod's service account token.
+	KubernetesAuth AuthMethod = "kubernetes"
+	// AppRoleAuth logs in with a role id and a secret id.
+	AppRoleAuth AuthMethod = "approle"
+	// CertAuth logs in with the client certificate set in Config.TLS.
+	CertAuth AuthMethod = "cert"
+)
+
+// DefaultServiceAccountTokenPath is where Kubernetes mounts the pod's service account token.
+const DefaultServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// retryInterval is the time to wait before logging in or looking up the token again after a failure.
+const retryInterval = time.Second * 5
+
+var ErrTokenExpired = errors.New("vault token expired")
+
+type Config struct {
+	Host string
+	Port string
+	Auth AuthConfig
+	// Vault is connected to over HTTPS if not nil.
+	TLS *TLSConfig
+}
+
+type TLSConfig struct {
+	// Path of the CA certificate used to verify Vault's certificate.
+	// The system's root CAs are used if empty.
+	CAPath string
+	// Paths of the client certificate and its key.
+	// Required only by CertAuth.
+	CertPath string
+	KeyPath  string
+	// Overrides the name Vault's certificate is verified against.
+	// Host is used if empty.
+	ServerName string
+}
+
+type AuthConfig struct {
+	// TokenAuth is used if empty.
+	Method AuthMethod
+	// Path the auth method is mounted on. Defaults to the method's name.
+	MountPath string
+	// Token used by TokenAuth.
+	Token string
+	// Role used by KubernetesAuth and optionally by CertAuth.
+	Role string
+	// Path of the service account token used by KubernetesAuth.
+	// DefaultServiceAccountTokenPath is used if empty.
+	ServiceAccountTokenPath string
+	// Credentials used by AppRoleAuth.
+	RoleId   string
+	SecretId string
+}
+
+// Client is a Vault client whose token is renewed in the background.
+// Tokens obtained by logging in are replaced once they can no longer be renewed.
+type Client struct {
+	*vault.Client
+	config Config
+	status atomic.Pointer[Status]
+	logger logging.Logger
+}
+
+// Status describes the client's token.
+type Status struct {
+	Method AuthMethod
+	// Zero if the token never expires or its TTL was not looked up yet.
+	ExpiresAt time.Time
+	Renewable bool
+	// Err is the last error encountered while acquiring or renewing the token.
+	Err error
+}
+
+// Expired returns true if the token is expired at given time.
+func (s Status) Expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)
+}
+
+// New returns a Client authenticated with the method set in config.Auth or a non nil error.
+// Login based methods log in before New returns. Tokens passed directly
+// are looked up in the background, so New does not contact Vault for them.
+//
+// The token is renewed until provided context is cancelled.
+func New(ctx context.Context, config Config, logger logging.Logger) (*Client, error) {
+	if logger == nil {
+		logger = nulls.NullLogger{}
+	}
+
+	if err := config.Auth.validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate vault auth config: %w", err)
+	}
+
+	if config.Auth.method() == CertAuth && (config.TLS == nil || config.TLS.CertPath == "") {
+		return nil, errors.New("failed to validate vault auth config: cert auth requires a client certificate")
+	}
+
+	c := vault.DefaultConfig()
+	c.Address = "http://" + config.Host + ":" + config.Port
+
+	if config.TLS != nil {
+		c.Address = "https://" + config.Host + ":" + config.Port
+
+		if err := configureTLS(c, *config.TLS); err != nil {
+			return nil, fmt.Errorf("failed to configure vault tls: %w", err)
+		}
+	}
+
+	vaultClient, err := vault.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		Client: vaultClient,
+		config: config,
+		logger: logger,
+	}
+	client.status.Store(&Status{Method: config.Auth.method()})
+
+	var secret *vault.Secret
+
+	if config.Auth.method() == TokenAuth {
+		vaultClient.SetToken(config.Auth.Token)
+	} else {
+		secret, err = client.login(ctx)
+		if err != nil {
+			return nil, err
+		}
+		client.update(secret)
+	}
+
+	go client.run(ctx, secret)
+
+	return client, nil
+}
+
+// Status returns the state of the client's token.
+func (c *Client) Status() Status {
+	return *c.status.Load()
+}
+
+// run blocks until provided context is cancelled.
+// It keeps renewing the token and acquires a new one once it can no longer be renewed.
+// Given secret is the token acquired so far or nil if there is none.
+func (c *Client) run(ctx context.Context, secret *vault.Secret) {
+	for {
+		if secret == nil {
+			var err error
+			secret, err = c.acquire(ctx)
+			if err != nil {
+				c.fail(ctx, err)
+
+				select {
+				case <-time.After(retryInterval):
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			c.update(secret)
+		}
+
+		if err := c.keepAlive(ctx, secret); err != nil {
+			c.fail(ctx, err)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		secret = nil
+	}
+}
+
+// keepAlive blocks until given token can no longer be renewed or the context is cancelled.
+func (c *Client) keepAlive(ctx context.Context, secret *vault.Secret) error {
+	if secret.Auth.LeaseDuration == 0 {
+		// The token never expires.
+		<-ctx.Done()
+		return nil
+	}
+
+	if !secret.Auth.Renewable {
+		// Acquire a new token shortly before this one expires.
+		select {
+		case <-time.After(time.Duration(secret.Auth.LeaseDuration) * time.Second * 2 / 3):
+		case <-ctx.Done():
+		}
+		return nil
+	}
+
+	watcher, err := c.NewLifetimeWatcher(&vault.LifetimeWatcherInput{Secret: secret})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	go watcher.Start()
+
+	for {
+		select {
+		case renewal := <-watcher.RenewCh():
+			c.update(renewal.Secret)
+		case err := <-watcher.DoneCh():
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// acquire logs in or looks up the token passed in the config.
+func (c *Client) acquire(ctx context.Context) (*vault.Secret, error) {
+	if c.config.Auth.method() == TokenAuth {
+		return c.lookupSelf(ctx)
+	}
+	return c.login(ctx)
+}
+
+func (c *Client) login(ctx context.Context) (*vault.Secret, error) {
+	auth := c.config.Auth
+	data := map[string]interface{}{}
+
+	switch auth.method() {
+	case KubernetesAuth:
+		jwt, err := os.ReadFile(auth.serviceAccountTokenPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account token: %w", err)
+		}
+		data["role"] = auth.Role
+		data["jwt"] = strings.TrimSpace(string(jwt))
+
+	case AppRoleAuth:
+		data["role_id"] = auth.RoleId
+		data["secret_id"] = auth.SecretId
+
+	case CertAuth:
+		// Vault tries all roles matching the certificate if the name is empty.
+		data["name"] = auth.Role
+	}
+
+	// Login endpoints don't require a token.
+	c.ClearToken()
+
+	secret, err := c.Logical().WriteWithContext(ctx, "auth/"+auth.mountPath()+"/login", data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in to vault: %w", err)
+	}
+
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, errors.New("failed to log in to vault: no token was returned")
+	}
+
+	c.SetToken(secret.Auth.ClientToken)
+
+	return secret, nil
+}
+
+// lookupSelf returns the client's current token in the format returned by login.
+func (c *Client) lookupSelf(ctx context.Context) (*vault.Secret, error) {
+	secret, err := c.Auth().Token().LookupSelfWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up vault token: %w", err)
+	}
+
+	ttl, err := secret.TokenTTL()
+	if err != nil {
+		return nil, err
+	}
+
+	renewable, err := secret.TokenIsRenewable()
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens which never expire have no expiration time.
+	if ttl < time.Second && secret.Data["expire_time"] != nil {
+		return nil, ErrTokenExpired
+	}
+
+	return &vault.Secret{
+		Auth: &vault.SecretAuth{
+			ClientToken:   c.Token(),
+			LeaseDuration: int(ttl.Seconds()),
+			Renewable:     renewable,
+		},
+	}, nil
+}
+
+// update stores the status of given token.
+func (c *Client) update(secret *vault.Secret) {
+	status := Status{
+		Method:    c.config.Auth.method(),
+		Renewable: secret.Auth.Renewable,
+	}
+
+	if secret.Auth.LeaseDuration > 0 {
+		status.ExpiresAt = time.Now().Add(time.Duration(secret.Auth.LeaseDuration) * time.Second)
+	}
+
+	c.status.Store(&status)
+}
+
+// fail records given error keeping the last known expiration time.
+func (c *Client) fail(ctx context.Context, err error) {
+	status := c.Status()
+	status.Err = err
+	c.status.Store(&status)
+
+	c.logger.Log(ctx, "failed to authenticate to vault", "err", err, "expires_at", status.ExpiresAt)
+}
+
+func (config AuthConfig) validate() error {
+	switch config.method() {
+	case TokenAuth:
+		if config.Token == "" {
+			return errors.New("token cannot be empty")
+		}
+	case KubernetesAuth:
+		if config.Role == "" {
+			return errors.New("kubernetes role cannot be empty")
+		}
+	case AppRoleAuth:
+		if config.RoleId == "" || config.SecretId == "" {
+			return errors.New("approle role id and secret id cannot be empty")
+		}
+	case CertAuth:
+	default:
+		return fmt.Errorf("unsupported auth method %q", config.Method)
+	}
+
+	return nil
+}
+
+// method returns the auth method in use.
+func (config AuthConfig) method() AuthMethod {
+	if config.Method == "" {
+		return TokenAuth
+	}
+	return config.Method
+}
+
+// mountPath returns the path the auth method is mounted on.
+func (config AuthConfig) mountPath() string {
+	if config.MountPath == "" {
+		return string(config.method())
+	}
+	return strings.Trim(config.MountPath, "/")
+}
+
+// serviceAccountTokenPath returns the path of the Kubernetes service account token.
+func (config AuthConfig) serviceAccountTokenPath() string {
+	if config.ServiceAccountTokenPath == "" {
+		return DefaultServiceAccountTokenPath
+	}
+	return config.ServiceAccountTokenPath
+}
+
+// configureTLS sets up the client's transport to verify Vault with
+// config.CAPath and to present the certificate from config.CertPath.
+func configureTLS(c *vault.Config, config TLSConfig) error {
+	transport, ok := c.HttpClient.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("unexpected http transport")
+	}
+
+	tlsConfig := transport.TLSClientConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	if config.CAPath != "" {
+		caPool, err := cert.LoadCaPool(config.CAPath)
+		if err != nil {
+			return err
+		}
+		tlsConfig.RootCAs = caPool
+	}
+
+	if config.CertPath != "" || config.KeyPath != "" {
+		clientCert, err := cert.LoadX509KeyPair(config.CertPath, config.KeyPath)
+		if err != nil {
+			return err
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	tlsConfig.ServerName = config.ServerName
+
+	return nil
+}
@@ -0,0 +1,421 @@
+package vaultclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vault/vaulttest"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const rootToken = "test-token"
+
+// waitFor polls condition until it returns true or the timeout is reached.
+func waitFor(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	return condition()
+}
+
+func makeConfig(server *vaulttest.Server, auth AuthConfig) Config {
+	host, port := server.HostPort()
+	return Config{Host: host, Port: port, Auth: auth}
+}
+
+// writePEM writes given DER bytes PEM encoded to a file in dir and returns its path.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// issueClientCert returns a CA pool and paths of a client certificate and its key issued by the CA.
+func issueClientCert(t *testing.T, dir string) (*x509.CertPool, string, string) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "auth-service"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create client certificate: %v", err)
+	}
+
+	clientKeyDER, err := x509.MarshalPKCS8PrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	return pool, writePEM(t, dir, "client.crt", "CERTIFICATE", clientDER), writePEM(t, dir, "client.key", "PRIVATE KEY", clientKeyDER)
+}
+
+func Test_New_TLS(t *testing.T) {
+	dir := t.TempDir()
+
+	clientCAs, certPath, keyPath := issueClientCert(t, dir)
+
+	server := vaulttest.NewTLSServer(rootToken, clientCAs)
+	defer server.Close()
+
+	server.EnableCertAuth("cert", "auth-service", vaulttest.TokenOptions{TTL: time.Hour})
+
+	caPath := writePEM(t, dir, "ca.crt", "CERTIFICATE", server.Certificate().Raw)
+
+	tests := []struct {
+		desc    string
+		auth    AuthConfig
+		tls     *TLSConfig
+		wantErr bool
+	}{
+		{
+			desc: "Test verifies Vault with given CA",
+			auth: AuthConfig{Token: rootToken},
+			tls:  &TLSConfig{CAPath: caPath},
+		},
+		{
+			desc: "Test verifies Vault against the overridden server name",
+			auth: AuthConfig{Token: rootToken},
+			tls:  &TLSConfig{CAPath: caPath, ServerName: "example.com"},
+		},
+		{
+			desc:    "Test rejects Vault's certificate for a different server name",
+			auth:    AuthConfig{Method: CertAuth, Role: "auth-service"},
+			tls:     &TLSConfig{CAPath: caPath, CertPath: certPath, KeyPath: keyPath, ServerName: "vault.internal"},
+			wantErr: true,
+		},
+		{
+			desc: "Test logs in with a client certificate",
+			auth: AuthConfig{Method: CertAuth, Role: "auth-service"},
+			tls:  &TLSConfig{CAPath: caPath, CertPath: certPath, KeyPath: keyPath},
+		},
+		{
+			desc:    "Test fails to log in with a different role",
+			auth:    AuthConfig{Method: CertAuth, Role: "other-service"},
+			tls:     &TLSConfig{CAPath: caPath, CertPath: certPath, KeyPath: keyPath},
+			wantErr: true,
+		},
+		{
+			desc:    "Test cert auth requires a client certificate",
+			auth:    AuthConfig{Method: CertAuth},
+			tls:     &TLSConfig{CAPath: caPath},
+			wantErr: true,
+		},
+		{
+			desc:    "Test fails on a missing CA",
+			auth:    AuthConfig{Token: rootToken},
+			tls:     &TLSConfig{CAPath: filepath.Join(dir, "missing.crt")},
+			wantErr: true,
+		},
+		{
+			desc:    "Test fails on a missing client key",
+			auth:    AuthConfig{Token: rootToken},
+			tls:     &TLSConfig{CAPath: caPath, CertPath: certPath, KeyPath: filepath.Join(dir, "missing.key")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			config := makeConfig(server, tt.auth)
+			config.TLS = tt.tls
+
+			client, err := New(ctx, config, nil)
+			if err == nil {
+				_, err = client.Auth().Token().LookupSelfWithContext(ctx)
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("Test does not trust Vault without the CA", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		config := makeConfig(server, AuthConfig{Token: rootToken})
+		config.TLS = &TLSConfig{}
+
+		client, err := New(ctx, config, nil)
+		if err != nil {
+			t.Fatalf("New(): error = %v", err)
+		}
+
+		if _, err := client.Auth().Token().LookupSelfWithContext(ctx); err == nil {
+			t.Errorf("Expected Vault's certificate to be rejected")
+		}
+	})
+}
+
+func Test_New(t *testing.T) {
+	server := vaulttest.NewServer(rootToken)
+	defer server.Close()
+
+	jwtPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwtPath, []byte("service-account-jwt\n"), 0o600); err != nil {
+		t.Fatalf("failed to write service account token: %v", err)
+	}
+
+	server.EnableKubernetesAuth("kubernetes", "auth-service", "service-account-jwt", vaulttest.TokenOptions{TTL: time.Hour})
+	server.EnableAppRoleAuth("custom-approle", "role-id", "secret-id", vaulttest.TokenOptions{TTL: time.Hour})
+
+	tests := []struct {
+		desc    string
+		auth    AuthConfig
+		wantErr bool
+	}{
+		{
+			desc: "Test logs in with a service account token",
+			auth: AuthConfig{Method: KubernetesAuth, Role: "auth-service", ServiceAccountTokenPath: jwtPath},
+		},
+		{
+			desc:    "Test fails on an invalid service account token",
+			auth:    AuthConfig{Method: KubernetesAuth, Role: "other-service", ServiceAccountTokenPath: jwtPath},
+			wantErr: true,
+		},
+		{
+			desc:    "Test fails on a missing service account token",
+			auth:    AuthConfig{Method: KubernetesAuth, Role: "auth-service", ServiceAccountTokenPath: filepath.Join(t.TempDir(), "missing")},
+			wantErr: true,
+		},
+		{
+			desc: "Test logs in with AppRole mounted on a custom path",
+			auth: AuthConfig{Method: AppRoleAuth, MountPath: "/custom-approle/", RoleId: "role-id", SecretId: "secret-id"},
+		},
+		{
+			desc:    "Test fails on invalid AppRole credentials",
+			auth:    AuthConfig{Method: AppRoleAuth, MountPath: "custom-approle", RoleId: "role-id", SecretId: "invalid"},
+			wantErr: true,
+		},
+		{
+			desc: "Test uses the token when no method is set",
+			auth: AuthConfig{Token: rootToken},
+		},
+		{
+			desc:    "Test rejects an unsupported method",
+			auth:    AuthConfig{Method: "userpass"},
+			wantErr: true,
+		},
+		{
+			desc:    "Test rejects missing credentials",
+			auth:    AuthConfig{Method: AppRoleAuth, RoleId: "role-id"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			client, err := New(ctx, makeConfig(server, tt.auth), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if _, err := client.Auth().Token().LookupSelfWithContext(ctx); err != nil {
+				t.Errorf("New(): client is not authenticated: %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_renewal(t *testing.T) {
+	t.Run("Test logs in again once the token reaches its max TTL", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := vaulttest.NewServer(rootToken)
+		defer server.Close()
+
+		server.EnableAppRoleAuth("approle", "role-id", "secret-id", vaulttest.TokenOptions{TTL: time.Second * 2, MaxTTL: time.Second * 3, Renewable: true})
+
+		client, err := New(ctx, makeConfig(server, AuthConfig{Method: AppRoleAuth, RoleId: "role-id", SecretId: "secret-id"}), nil)
+		if err != nil {
+			t.Fatalf("New(): error = %v", err)
+		}
+
+		if !waitFor(time.Second*10, func() bool { return server.Logins() >= 2 }) {
+			t.Fatalf("Client did not log in again, logins = %d", server.Logins())
+		}
+
+		if _, err := client.Auth().Token().LookupSelfWithContext(ctx); err != nil {
+			t.Errorf("Client lost access after logging in again: %v", err)
+		}
+
+		if status := client.Status(); status.Expired(time.Now()) || status.Method != AppRoleAuth {
+			t.Errorf("Invalid status: %+v", status)
+		}
+	})
+
+	t.Run("Test renews a renewable token", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := vaulttest.NewServer(rootToken)
+		defer server.Close()
+
+		token := server.IssueToken(vaulttest.TokenOptions{TTL: time.Second * 2, Renewable: true})
+
+		client, err := New(ctx, makeConfig(server, AuthConfig{Method: TokenAuth, Token: token}), nil)
+		if err != nil {
+			t.Fatalf("New(): error = %v", err)
+		}
+
+		// Outlive the token's initial TTL.
+		time.Sleep(time.Second * 3)
+
+		if _, err := client.Auth().Token().LookupSelfWithContext(ctx); err != nil {
+			t.Errorf("Token was not renewed: %v", err)
+		}
+
+		if status := client.Status(); status.Expired(time.Now()) || !status.Renewable || status.ExpiresAt.IsZero() {
+			t.Errorf("Invalid status: %+v", status)
+		}
+	})
+
+	t.Run("Test reports expiry of a token which cannot be renewed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := vaulttest.NewServer(rootToken)
+		defer server.Close()
+
+		token := server.IssueToken(vaulttest.TokenOptions{TTL: time.Second * 2})
+
+		client, err := New(ctx, makeConfig(server, AuthConfig{Token: token}), nil)
+		if err != nil {
+			t.Fatalf("New(): error = %v", err)
+		}
+
+		if !waitFor(time.Second*5, func() bool { return client.Status().Expired(time.Now()) }) {
+			t.Fatalf("Token was not reported as expired: %+v", client.Status())
+		}
+
+		if client.Status().Err == nil {
+			t.Errorf("Expected an error to be recorded: %+v", client.Status())
+		}
+	})
+
+	t.Run("Test the root token never expires", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := vaulttest.NewServer(rootToken)
+		defer server.Close()
+
+		client, err := New(ctx, makeConfig(server, AuthConfig{Token: rootToken}), nil)
+		if err != nil {
+			t.Fatalf("New(): error = %v", err)
+		}
+
+		if !waitFor(time.Second*2, func() bool { return server.Requests() > 0 }) {
+			t.Fatalf("Token was not looked up")
+		}
+
+		time.Sleep(time.Millisecond * 100)
+
+		if status := client.Status(); status.Expired(time.Now().Add(time.Hour*24*365)) || status.Err != nil {
+			t.Errorf("Invalid status: %+v", status)
+		}
+	})
+}
+
+func TestClient_ReportHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := vaulttest.NewServer(rootToken)
+	defer server.Close()
+
+	token := server.IssueToken(vaulttest.TokenOptions{TTL: time.Second * 2})
+
+	client, err := New(ctx, makeConfig(server, AuthConfig{Token: token}), nil)
+	if err != nil {
+		t.Fatalf("New(): error = %v", err)
+	}
+
+	healthServer := health.NewServer()
+	go client.ReportHealth(ctx, healthServer, "vault")
+
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := healthServer.Check(ctx, &healthpb.HealthCheckRequest{Service: "vault"})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return resp.GetStatus()
+	}
+
+	if !waitFor(time.Second, func() bool { return check() == healthpb.HealthCheckResponse_SERVING }) {
+		t.Fatalf("ReportHealth(): status = %v, want %v", check(), healthpb.HealthCheckResponse_SERVING)
+	}
+
+	if !waitFor(time.Second*5, func() bool { return check() == healthpb.HealthCheckResponse_NOT_SERVING }) {
+		t.Errorf("ReportHealth(): status = %v, want %v", check(), healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+}
@@ -0,0 +1,29 @@
+package tokens
+
+import "github.com/krixlion/dev_forum-auth/pkg/entity"
+
+// Claims are verified claims of an access token.
+type Claims struct {
+	// ID of the user the token was issued for.
+	Subject string
+	// Scopes the token is restricted to. Empty if the token is not restricted.
+	Scopes []string
+	// Actor identifies the admin acting on behalf of the subject as described in RFC 8693.
+	// It's empty unless the token was issued through impersonation.
+	Actor string
+	// Confirmation is the key the token is bound to as described in RFC 7800.
+	// It's zero for bearer tokens.
+	Confirmation entity.Confirmation
+}
+
+// IsImpersonated returns true if the token was issued through impersonation.
+// Services should use it to block destructive actions.
+func (c Claims) IsImpersonated() bool {
+	return c.Actor != ""
+}
+
+// IsSenderConstrained returns true if the token can only be used
+// along with a proof of possession of the key it's bound to.
+func (c Claims) IsSenderConstrained() bool {
+	return !c.Confirmation.IsZero()
+}
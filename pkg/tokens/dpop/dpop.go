@@ -0,0 +1,146 @@
+// Package dpop implements verification of DPoP proofs
+// as described in RFC 9449 - OAuth 2.0 Demonstrating Proof of Possession.
+package dpop
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+)
+
+// HeaderKey is the name of the header carrying the proof.
+// Note that gRPC metadata keys are always lowercase.
+const HeaderKey = "dpop"
+
+// ProofType is the required value of proof's "typ" header.
+const ProofType = "dpop+jwt"
+
+var (
+	ErrInvalidProof         = errors.New("invalid DPoP proof")
+	ErrInvalidProofType     = errors.New("invalid DPoP proof type")
+	ErrInvalidAlgorithm     = errors.New("DPoP proof has to be signed with an asymmetric algorithm")
+	ErrInvalidProofKey      = errors.New("DPoP proof has to contain a public JWK")
+	ErrMethodMismatch       = errors.New("DPoP proof htm does not match request's method")
+	ErrURIMismatch          = errors.New("DPoP proof htu does not match request's URI")
+	ErrProofExpired         = errors.New("DPoP proof issued outside of the allowed time window")
+	ErrProofReplayed        = errors.New("DPoP proof has already been used")
+	ErrAccessTokenMismatch  = errors.New("DPoP proof ath does not match the access token")
+	ErrThumbprintMismatch   = errors.New("DPoP proof key does not match the token's confirmation")
+	ErrProofRequired        = errors.New("DPoP-bound token requires a DPoP proof")
+	ErrMissingProofIdentity = errors.New("DPoP proof is missing jti")
+)
+
+// Proof contains verified claims of a DPoP proof.
+type Proof struct {
+	JTI      string
+	HTM      string
+	HTU      string
+	IssuedAt time.Time
+
+	// Base64URL encoded SHA-256 hash of the access token the proof was presented with.
+	// Empty if the proof was not meant to be presented along with an access token.
+	AccessTokenHash string
+
+	// JKT is a Base64URL encoded RFC 7638 SHA-256 thumbprint of the proof's key.
+	JKT string
+}
+
+type proofClaims struct {
+	JTI string `json:"jti"`
+	HTM string `json:"htm"`
+	HTU string `json:"htu"`
+	IAT int64  `json:"iat"`
+	ATH string `json:"ath,omitempty"`
+}
+
+// Parse verifies the proof's signature using the public key embedded in its
+// header and returns its claims. It does not validate the claims.
+func Parse(proof string) (Proof, error) {
+	msg, err := jws.ParseString(proof)
+	if err != nil {
+		return Proof{}, fmt.Errorf("%w: %s", ErrInvalidProof, err)
+	}
+
+	if len(msg.Signatures()) != 1 {
+		return Proof{}, ErrInvalidProof
+	}
+
+	headers := msg.Signatures()[0].ProtectedHeaders()
+
+	if headers.Type() != ProofType {
+		return Proof{}, ErrInvalidProofType
+	}
+
+	alg := headers.Algorithm()
+	if !isAsymmetric(alg) {
+		return Proof{}, ErrInvalidAlgorithm
+	}
+
+	key := headers.JWK()
+	if key == nil || !isPublic(key) {
+		return Proof{}, ErrInvalidProofKey
+	}
+
+	payload, err := jws.Verify([]byte(proof), alg, key)
+	if err != nil {
+		return Proof{}, fmt.Errorf("%w: %s", ErrInvalidProof, err)
+	}
+
+	var claims proofClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Proof{}, fmt.Errorf("%w: %s", ErrInvalidProof, err)
+	}
+
+	if claims.JTI == "" {
+		return Proof{}, ErrMissingProofIdentity
+	}
+
+	jkt, err := jwkutil.Thumbprint(key)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	return Proof{
+		JTI:             claims.JTI,
+		HTM:             claims.HTM,
+		HTU:             claims.HTU,
+		IssuedAt:        time.Unix(claims.IAT, 0),
+		AccessTokenHash: claims.ATH,
+		JKT:             jkt,
+	}, nil
+}
+
+// AccessTokenHash returns the expected value of the proof's "ath" claim for given access token.
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func isAsymmetric(alg jwa.SignatureAlgorithm) bool {
+	switch alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512,
+		jwa.PS256, jwa.PS384, jwa.PS512,
+		jwa.ES256, jwa.ES384, jwa.ES512,
+		jwa.EdDSA:
+		return true
+	default:
+		return false
+	}
+}
+
+func isPublic(key jwk.Key) bool {
+	switch key.(type) {
+	case jwk.RSAPublicKey, jwk.ECDSAPublicKey, jwk.OKPPublicKey:
+		return true
+	default:
+		return false
+	}
+}
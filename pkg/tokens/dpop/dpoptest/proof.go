@@ -0,0 +1,95 @@
+// Package dpoptest provides helpers for creating DPoP proofs in tests.
+package dpoptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+)
+
+// Claims of a proof to sign. ATH is left out of the proof if empty.
+type Claims struct {
+	JTI string `json:"jti"`
+	HTM string `json:"htm"`
+	HTU string `json:"htu"`
+	IAT int64  `json:"iat"`
+	ATH string `json:"ath,omitempty"`
+}
+
+// GenerateKey returns a new P-256 key to sign proofs with.
+// It panics on failure.
+func GenerateKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Thumbprint returns the expected dpop.Proof.JKT of proofs signed with given key.
+// It panics on failure.
+func Thumbprint(key *ecdsa.PrivateKey) string {
+	publicKey, err := jwk.New(key.Public())
+	if err != nil {
+		panic(err)
+	}
+
+	jkt, err := jwkutil.Thumbprint(publicKey)
+	if err != nil {
+		panic(err)
+	}
+	return jkt
+}
+
+// MakeProof returns a proof with given claims signed with ES256 using given key.
+// It panics on failure.
+func MakeProof(key *ecdsa.PrivateKey, claims Claims) string {
+	publicKey, err := jwk.New(key.Public())
+	if err != nil {
+		panic(err)
+	}
+
+	headers := jws.NewHeaders()
+	if err := headers.Set(jws.TypeKey, dpop.ProofType); err != nil {
+		panic(err)
+	}
+	if err := headers.Set(jws.JWKKey, publicKey); err != nil {
+		panic(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+
+	proof, err := jws.Sign(payload, jwa.ES256, key, jws.WithHeaders(headers))
+	if err != nil {
+		panic(err)
+	}
+	return string(proof)
+}
+
+// MakeClaims returns claims with a random jti for given request issued at given time.
+// It panics on failure.
+func MakeClaims(method, uri string, issuedAt time.Time) Claims {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		panic(err)
+	}
+
+	return Claims{
+		JTI: base64.RawURLEncoding.EncodeToString(jti),
+		HTM: method,
+		HTU: uri,
+		IAT: issuedAt.Unix(),
+	}
+}
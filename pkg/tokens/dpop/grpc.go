@@ -0,0 +1,32 @@
+package dpop
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// GRPCMethod is the HTTP method every gRPC call is made with.
+const GRPCMethod = "POST"
+
+// ProofFromIncomingContext returns the proof sent in the incoming request's metadata
+// along with the HTTP URI of the invoked gRPC method, which the proof's htu is expected to match.
+// Returns false if no proof was sent.
+func ProofFromIncomingContext(ctx context.Context) (proof string, uri string, ok bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	proofs := md.Get(HeaderKey)
+	if len(proofs) == 0 || proofs[0] == "" {
+		return "", "", false
+	}
+
+	var authority string
+	if values := md.Get(":authority"); len(values) > 0 {
+		authority = values[0]
+	}
+
+	method, _ := grpc.Method(ctx)
+
+	return proofs[0], "https://" + authority + method, true
+}
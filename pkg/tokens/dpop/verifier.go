@@ -0,0 +1,161 @@
+package dpop
+
+import (
+	"container/heap"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxAge is the default amount of time during which a proof is accepted after it was issued.
+const DefaultMaxAge = time.Minute
+
+// ReplayCache records jtis of accepted proofs.
+// Proofs are rejected as replayed only by verifiers sharing the same cache,
+// so replicated services have to share a cache backed by a common storage.
+type ReplayCache interface {
+	// MarkUsed records given jti until forgetAfter.
+	// It returns ErrProofReplayed if the jti is already recorded.
+	MarkUsed(ctx context.Context, jti string, forgetAfter time.Time) error
+}
+
+// Verifier validates DPoP proofs and rejects those which were already seen.
+type Verifier struct {
+	maxAge      time.Duration
+	now         func() time.Time
+	replayCache ReplayCache
+}
+
+// NewVerifier returns a new Verifier accepting proofs issued within maxAge from now.
+// If maxAge is not positive DefaultMaxAge is used.
+// If now is nil time.Now() is used by default.
+// If replayCache is nil jtis are recorded in a MemoryReplayCache.
+func NewVerifier(maxAge time.Duration, now func() time.Time, replayCache ReplayCache) *Verifier {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	if now == nil {
+		now = time.Now
+	}
+
+	if replayCache == nil {
+		replayCache = NewMemoryReplayCache(now)
+	}
+
+	return &Verifier{
+		maxAge:      maxAge,
+		now:         now,
+		replayCache: replayCache,
+	}
+}
+
+// Verify parses given proof and checks whether it was issued for given
+// HTTP method and URI, is fresh and was not presented before.
+// Query and fragment parts of the URIs are ignored as per RFC 9449 section 4.3.
+func (v *Verifier) Verify(ctx context.Context, proof, method, uri string) (Proof, error) {
+	p, err := Parse(proof)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	if !strings.EqualFold(p.HTM, method) {
+		return Proof{}, ErrMethodMismatch
+	}
+
+	if stripQuery(p.HTU) != stripQuery(uri) {
+		return Proof{}, ErrURIMismatch
+	}
+
+	now := v.now()
+	if p.IssuedAt.Before(now.Add(-v.maxAge)) || p.IssuedAt.After(now.Add(v.maxAge)) {
+		return Proof{}, ErrProofExpired
+	}
+
+	if err := v.replayCache.MarkUsed(ctx, p.JTI, p.IssuedAt.Add(v.maxAge)); err != nil {
+		return Proof{}, err
+	}
+
+	return p, nil
+}
+
+// MemoryReplayCache is a ReplayCache kept in memory of a single process.
+// A proof accepted by one process can be replayed against other processes
+// using their own caches, so it's suitable only for a single instance.
+type MemoryReplayCache struct {
+	now func() time.Time
+
+	// entries holds recorded jtis mapped to the time after which they can be
+	// forgotten because the proof would be rejected as expired anyway.
+	// queue orders the same entries by that time so that expired ones
+	// are evicted without scanning the whole cache.
+	mu      sync.Mutex
+	entries map[string]time.Time
+	queue   replayQueue
+}
+
+// NewMemoryReplayCache returns an empty MemoryReplayCache.
+// If now is nil time.Now() is used by default.
+func NewMemoryReplayCache(now func() time.Time) *MemoryReplayCache {
+	if now == nil {
+		now = time.Now
+	}
+
+	return &MemoryReplayCache{
+		now:     now,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// MarkUsed records given jti and returns ErrProofReplayed if it was already recorded.
+// Expired entries are evicted on each call.
+func (c *MemoryReplayCache) MarkUsed(_ context.Context, jti string, forgetAfter time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for len(c.queue) > 0 && now.After(c.queue[0].forgetAfter) {
+		entry := heap.Pop(&c.queue).(replayEntry)
+		delete(c.entries, entry.jti)
+	}
+
+	if _, ok := c.entries[jti]; ok {
+		return ErrProofReplayed
+	}
+
+	c.entries[jti] = forgetAfter
+	heap.Push(&c.queue, replayEntry{jti: jti, forgetAfter: forgetAfter})
+	return nil
+}
+
+type replayEntry struct {
+	jti         string
+	forgetAfter time.Time
+}
+
+// replayQueue is a min-heap of replay cache entries ordered by the time they can be forgotten.
+type replayQueue []replayEntry
+
+func (q replayQueue) Len() int           { return len(q) }
+func (q replayQueue) Less(i, j int) bool { return q[i].forgetAfter.Before(q[j].forgetAfter) }
+func (q replayQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *replayQueue) Push(x interface{}) {
+	*q = append(*q, x.(replayEntry))
+}
+
+func (q *replayQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	entry := old[n-1]
+	*q = old[:n-1]
+	return entry
+}
+
+func stripQuery(uri string) string {
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		return uri[:i]
+	}
+	return uri
+}
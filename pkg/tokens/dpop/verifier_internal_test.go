@@ -0,0 +1,43 @@
+package dpop
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryReplayCache_MarkUsed(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1682517486, 0)
+	now := start
+	c := NewMemoryReplayCache(func() time.Time { return now })
+
+	// Entries are recorded out of order since proofs' iat may differ from the time they are verified.
+	for i, offset := range []time.Duration{30, 10, 50, 20, 40} {
+		if err := c.MarkUsed(ctx, strconv.Itoa(i), start.Add(offset*time.Second)); err != nil {
+			t.Fatalf("MemoryReplayCache.MarkUsed() error = %v", err)
+		}
+	}
+
+	if err := c.MarkUsed(ctx, "1", start.Add(time.Minute)); !errors.Is(err, ErrProofReplayed) {
+		t.Errorf("MemoryReplayCache.MarkUsed() replay error = %v, wantErr = %v", err, ErrProofReplayed)
+	}
+
+	now = start.Add(35 * time.Second)
+	if err := c.MarkUsed(ctx, "5", start.Add(time.Minute)); err != nil {
+		t.Fatalf("MemoryReplayCache.MarkUsed() error = %v", err)
+	}
+
+	// Entries forgotten after 10s, 20s and 30s are evicted.
+	for _, jti := range []string{"0", "1", "3"} {
+		if _, ok := c.entries[jti]; ok {
+			t.Errorf("MemoryReplayCache.MarkUsed() did not evict expired jti %q", jti)
+		}
+	}
+
+	if got, want := len(c.entries), 3; got != want || len(c.queue) != want {
+		t.Errorf("MemoryReplayCache.MarkUsed() cache size = %d, queue size = %d, want = %d", got, len(c.queue), want)
+	}
+}
@@ -0,0 +1,145 @@
+package dpop_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop/dpoptest"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	now := time.Unix(1682517486, 0)
+	key := dpoptest.GenerateKey()
+	uri := "https://auth-service/Auth.AuthService/GetAccessToken"
+
+	withClaims := func(modify func(c *dpoptest.Claims)) string {
+		claims := dpoptest.MakeClaims("POST", uri, now)
+		modify(&claims)
+		return dpoptest.MakeProof(key, claims)
+	}
+
+	type args struct {
+		proof  string
+		method string
+		uri    string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr error
+	}{
+		{
+			name: "Test if valid proof is accepted",
+			args: args{
+				proof:  withClaims(func(c *dpoptest.Claims) {}),
+				method: "POST",
+				uri:    uri,
+			},
+		},
+		{
+			name: "Test if query is ignored when comparing htu",
+			args: args{
+				proof:  withClaims(func(c *dpoptest.Claims) {}),
+				method: "post",
+				uri:    uri + "?param=1",
+			},
+		},
+		{
+			name: "Test if fails on different method",
+			args: args{
+				proof:  withClaims(func(c *dpoptest.Claims) {}),
+				method: "GET",
+				uri:    uri,
+			},
+			wantErr: dpop.ErrMethodMismatch,
+		},
+		{
+			name: "Test if fails on different uri",
+			args: args{
+				proof:  withClaims(func(c *dpoptest.Claims) {}),
+				method: "POST",
+				uri:    "https://auth-service/Auth.AuthService/SignIn",
+			},
+			wantErr: dpop.ErrURIMismatch,
+		},
+		{
+			name: "Test if fails on stale proof",
+			args: args{
+				proof:  withClaims(func(c *dpoptest.Claims) { c.IAT = now.Add(-time.Hour).Unix() }),
+				method: "POST",
+				uri:    uri,
+			},
+			wantErr: dpop.ErrProofExpired,
+		},
+		{
+			name: "Test if fails on missing jti",
+			args: args{
+				proof:  withClaims(func(c *dpoptest.Claims) { c.JTI = "" }),
+				method: "POST",
+				uri:    uri,
+			},
+			wantErr: dpop.ErrMissingProofIdentity,
+		},
+		{
+			name: "Test if fails on tampered proof",
+			args: args{
+				proof:  withClaims(func(c *dpoptest.Claims) {}) + "A",
+				method: "POST",
+				uri:    uri,
+			},
+			wantErr: dpop.ErrInvalidProof,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := dpop.NewVerifier(time.Minute, func() time.Time { return now }, nil)
+
+			got, err := v.Verify(context.Background(), tt.args.proof, tt.args.method, tt.args.uri)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verifier.Verify() error = %v, wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr == nil && got.JKT != dpoptest.Thumbprint(key) {
+				t.Errorf("Verifier.Verify() JKT = %v, want = %v", got.JKT, dpoptest.Thumbprint(key))
+			}
+		})
+	}
+}
+
+func TestVerifier_Verify_Replay(t *testing.T) {
+	now := time.Unix(1682517486, 0)
+	uri := "https://auth-service/Auth.AuthService/GetAccessToken"
+	proof := dpoptest.MakeProof(dpoptest.GenerateKey(), dpoptest.MakeClaims("POST", uri, now))
+
+	v := dpop.NewVerifier(time.Minute, func() time.Time { return now }, nil)
+
+	if _, err := v.Verify(context.Background(), proof, "POST", uri); err != nil {
+		t.Fatalf("Verifier.Verify() first use error = %v", err)
+	}
+
+	if _, err := v.Verify(context.Background(), proof, "POST", uri); !errors.Is(err, dpop.ErrProofReplayed) {
+		t.Errorf("Verifier.Verify() replay error = %v, wantErr = %v", err, dpop.ErrProofReplayed)
+	}
+}
+
+func TestVerifier_Verify_SharedReplayCache(t *testing.T) {
+	now := time.Unix(1682517486, 0)
+	uri := "https://auth-service/Auth.AuthService/GetAccessToken"
+	proof := dpoptest.MakeProof(dpoptest.GenerateKey(), dpoptest.MakeClaims("POST", uri, now))
+
+	cache := dpop.NewMemoryReplayCache(func() time.Time { return now })
+	first := dpop.NewVerifier(time.Minute, func() time.Time { return now }, cache)
+	second := dpop.NewVerifier(time.Minute, func() time.Time { return now }, cache)
+
+	if _, err := first.Verify(context.Background(), proof, "POST", uri); err != nil {
+		t.Fatalf("Verifier.Verify() first use error = %v", err)
+	}
+
+	if _, err := second.Verify(context.Background(), proof, "POST", uri); !errors.Is(err, dpop.ErrProofReplayed) {
+		t.Errorf("Verifier.Verify() replay on another verifier error = %v, wantErr = %v", err, dpop.ErrProofReplayed)
+	}
+}
@@ -25,6 +25,23 @@ type Validator interface {
 	ValidateToken(string) error
 }
 
+// Parser validates given token and returns its verified claims.
+type Parser interface {
+	ParseToken(string) (Claims, error)
+}
+
+// ProofValidator validates sender-constrained tokens presented along with a DPoP proof.
+type ProofValidator interface {
+	Validator
+	ValidateTokenWithProof(ctx context.Context, token, proof, method, uri string) error
+}
+
 type Translator interface {
 	TranslateAccessToken(ctx context.Context, opaqueAccessToken string) (string, error)
 }
+
+// ProofTranslator translates sender-constrained tokens presented along with a DPoP proof.
+type ProofTranslator interface {
+	Translator
+	TranslateAccessTokenWithProof(ctx context.Context, opaqueAccessToken, proof, method, uri string) (string, error)
+}
@@ -1,6 +1,10 @@
 package manager
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"encoding/base64"
 	"hash/crc32"
 	"strconv"
@@ -25,6 +29,21 @@ func (m StdTokenManager) Encode(privateKey entity.Key, token entity.Token) ([]by
 	b.JwtID(token.Id)
 	b.Claim("type", token.Type)
 
+	if len(token.Scopes) > 0 {
+		// Scopes are space-delimited as described in RFC 8693.
+		b.Claim("scope", strings.Join(token.Scopes, " "))
+	}
+
+	if token.Actor != "" {
+		// Actor claim as described in RFC 8693.
+		b.Claim("act", map[string]string{"sub": token.Actor})
+	}
+
+	if !token.Confirmation.IsZero() {
+		// Confirmation claim as described in RFC 7800.
+		b.Claim("cnf", confirmationClaim(token.Confirmation))
+	}
+
 	jwtoken, err := b.Build()
 	if err != nil {
 		return nil, err
@@ -40,7 +59,12 @@ func (m StdTokenManager) Encode(privateKey entity.Key, token entity.Token) ([]by
 		return nil, err
 	}
 
-	signedJWT, err := jwt.Sign(jwtoken, algo, privateKey.Raw, jwt.WithHeaders(headers))
+	signingKey, err := signingKey(algo, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signedJWT, err := jwt.Sign(jwtoken, algo, signingKey, jwt.WithHeaders(headers))
 	if err != nil {
 		return nil, err
 	}
@@ -92,14 +116,90 @@ func (StdTokenManager) DecodeOpaque(typ tokens.OpaqueTokenPrefix, encodedOpaqueT
 	return decodeAndValidateOpaque(encodedOpaqueToken[4:])
 }
 
+// confirmationClaim returns members of the "cnf" claim
+// for each key the token is bound to.
+func confirmationClaim(cnf entity.Confirmation) map[string]string {
+	claim := map[string]string{}
+
+	if cnf.JKT != "" {
+		// DPoP key thumbprint as described in RFC 9449.
+		claim["jkt"] = cnf.JKT
+	}
+
+	if cnf.X5tS256 != "" {
+		// Certificate thumbprint as described in RFC 8705.
+		claim["x5t#S256"] = cnf.X5tS256
+	}
+
+	return claim
+}
+
+// signingKey returns the key to sign tokens with using given algorithm.
+// Asymmetric keys are used through the crypto.Signer interface so that keys
+// which never leave a remote KMS or an HSM, eg. Vault's Transit engine, can sign as well.
+// It returns tokens.ErrInvalidAlgorithm if the signer's public key does not match the algorithm.
+func signingKey(algo jwa.SignatureAlgorithm, key entity.Key) (interface{}, error) {
+	if key.Signer == nil {
+		if len(key.Secret) == 0 || algo != jwa.HS256 {
+			return nil, tokens.ErrInvalidAlgorithm
+		}
+		// Symmetric keys are used in-process.
+		return key.Secret, nil
+	}
+
+	switch public := key.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		switch algo {
+		case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+		default:
+			return nil, tokens.ErrInvalidAlgorithm
+		}
+	case *ecdsa.PublicKey:
+		if algo != ecdsaAlgorithms[public.Curve] {
+			return nil, tokens.ErrInvalidAlgorithm
+		}
+	case ed25519.PublicKey:
+		if algo != jwa.EdDSA {
+			return nil, tokens.ErrInvalidAlgorithm
+		}
+	default:
+		return nil, tokens.ErrInvalidAlgorithm
+	}
+
+	return key.Signer, nil
+}
+
+// ecdsaAlgorithms maps curves to the only algorithms they can be used with as defined in RFC 7518.
+var ecdsaAlgorithms = map[elliptic.Curve]jwa.SignatureAlgorithm{
+	elliptic.P256(): jwa.ES256,
+	elliptic.P384(): jwa.ES384,
+	elliptic.P521(): jwa.ES512,
+}
+
 func toJwaAlgorithm(algo entity.Algorithm) (jwa.SignatureAlgorithm, error) {
 	switch algo {
 	case entity.RS256:
 		return jwa.RS256, nil
+	case entity.RS384:
+		return jwa.RS384, nil
+	case entity.RS512:
+		return jwa.RS512, nil
+	case entity.PS256:
+		return jwa.PS256, nil
+	case entity.PS384:
+		return jwa.PS384, nil
+	case entity.PS512:
+		return jwa.PS512, nil
 	case entity.HS256:
 		return jwa.HS256, nil
 	case entity.ES256:
 		return jwa.ES256, nil
+	case entity.ES384:
+		return jwa.ES384, nil
+	case entity.ES512:
+		return jwa.ES512, nil
+	case entity.EdDSA:
+		return jwa.EdDSA, nil
 	default:
 		return "", tokens.ErrInvalidAlgorithm
 	}
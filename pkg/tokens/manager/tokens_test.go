@@ -1,6 +1,12 @@
 package manager
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -8,6 +14,7 @@ import (
 	"github.com/krixlion/dev_forum-auth/pkg/tokens"
 	"github.com/krixlion/dev_forum-auth/pkg/tokens/manager/testdata"
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
 )
 
 func setUpTokenManager() StdTokenManager {
@@ -36,6 +43,62 @@ func TestTokenManager_Encode(t *testing.T) {
 			},
 			want: testdata.SignedJWT,
 		},
+		{
+			name: "Test if scopes are encoded as a space-delimited scope claim",
+			args: args{
+				privateKey: testdata.TestKey,
+				token: func() entity.Token {
+					token := testdata.TestToken
+					token.Scopes = testdata.TestScopes
+					return token
+				}(),
+			},
+			want: testdata.SignedScopedJWT,
+		},
+		{
+			name: "Test if actor is encoded as an act claim",
+			args: args{
+				privateKey: testdata.TestKey,
+				token: func() entity.Token {
+					token := testdata.TestToken
+					token.Actor = testdata.TestActor
+					return token
+				}(),
+			},
+			want: testdata.SignedImpersonatedJWT,
+		},
+		{
+			name: "Test if DPoP-bound token contains cnf claim",
+			args: args{
+				privateKey: testdata.TestKey,
+				token: func() entity.Token {
+					token := testdata.TestToken
+					token.Confirmation = entity.Confirmation{JKT: testdata.TestJKT}
+					return token
+				}(),
+			},
+			want: testdata.SignedDPoPBoundJWT,
+		},
+		{
+			name: "Test if certificate-bound token contains cnf claim",
+			args: args{
+				privateKey: testdata.TestKey,
+				token: func() entity.Token {
+					token := testdata.TestToken
+					token.Confirmation = entity.Confirmation{X5tS256: testdata.TestX5tS256}
+					return token
+				}(),
+			},
+			want: testdata.SignedCertificateBoundJWT,
+		},
+		{
+			name: "Test if correctly encodes and signs a token with an Ed25519 key",
+			args: args{
+				privateKey: testdata.TestEd25519Key,
+				token:      testdata.TestToken,
+			},
+			want: testdata.SignedEdDSAJWT,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -53,6 +116,93 @@ func TestTokenManager_Encode(t *testing.T) {
 	}
 }
 
+// opaqueSigner hides the underlying private key behind the crypto.Signer
+// interface, the same way a remote KMS does.
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (s opaqueSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestTokenManager_Encode_Signer(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ecdsa key: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate rsa key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     entity.Key
+		wantErr bool
+	}{
+		{
+			name: "Test if signs with an ECDSA crypto.Signer",
+			key:  entity.Key{Id: "test", Algorithm: entity.ES256, Signer: opaqueSigner{ecdsaKey}},
+		},
+		{
+			name: "Test if signs with an RSA crypto.Signer",
+			key:  entity.Key{Id: "test", Algorithm: entity.RS256, Signer: opaqueSigner{rsaKey}},
+		},
+		{
+			name: "Test if signs with an Ed25519 crypto.Signer",
+			key:  entity.Key{Id: "test", Algorithm: entity.EdDSA, Signer: opaqueSigner{testdata.TestEd25519Key.Signer}},
+		},
+		{
+			name:    "Test if fails when signer does not match the algorithm",
+			key:     entity.Key{Id: "test", Algorithm: entity.RS256, Signer: opaqueSigner{ecdsaKey}},
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails when curve does not match the algorithm",
+			key:     entity.Key{Id: "test", Algorithm: entity.ES384, Signer: opaqueSigner{ecdsaKey}},
+			wantErr: true,
+		},
+		{
+			name: "Test if signs with an RSA crypto.Signer using PSS",
+			key:  entity.Key{Id: "test", Algorithm: entity.PS256, Signer: opaqueSigner{rsaKey}},
+		},
+		{
+			name:    "Test if fails when key has neither a signer nor a secret",
+			key:     entity.Key{Id: "test", Algorithm: entity.ES256},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := setUpTokenManager()
+			got, err := m.Encode(tt.key, testdata.TestToken)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TokenManager.Encode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			algo, err := toJwaAlgorithm(tt.key.Algorithm)
+			if err != nil {
+				t.Fatalf("toJwaAlgorithm() error = %v", err)
+			}
+
+			if _, err := jws.Verify(got, algo, tt.key.Signer.Public()); err != nil {
+				t.Errorf("TokenManager.Encode() produced an invalid signature: %v", err)
+			}
+		})
+	}
+}
+
 func TestTokenManager_GenerateOpaque(t *testing.T) {
 	type args struct {
 		prefixType tokens.OpaqueTokenPrefix
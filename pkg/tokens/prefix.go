@@ -1,5 +1,7 @@
 package tokens
 
+import "strings"
+
 type OpaqueTokenPrefix int
 
 const (
@@ -7,6 +9,14 @@ const (
 	RefreshToken OpaqueTokenPrefix = iota
 	// Opaque Access tokens are prefixed with "dfa_"
 	AccessToken
+	// Opaque Personal Access tokens are prefixed with "dfp_"
+	PersonalAccessToken
+	// Opaque Password Reset tokens are prefixed with "dfw_"
+	PasswordResetToken
+	// Opaque Email Verification tokens are prefixed with "dfv_"
+	EmailVerificationToken
+	// Opaque Magic Link tokens are prefixed with "dfm_"
+	MagicLinkToken
 )
 
 func (t OpaqueTokenPrefix) String() (string, error) {
@@ -15,7 +25,26 @@ func (t OpaqueTokenPrefix) String() (string, error) {
 		return "dfr", nil
 	case AccessToken:
 		return "dfa", nil
+	case PersonalAccessToken:
+		return "dfp", nil
+	case PasswordResetToken:
+		return "dfw", nil
+	case EmailVerificationToken:
+		return "dfv", nil
+	case MagicLinkToken:
+		return "dfm", nil
 	default:
 		return "", ErrInvalidTokenType
 	}
 }
+
+// Matches returns true if given encoded opaque token starts with this prefix.
+// It does not validate the rest of the token.
+func (t OpaqueTokenPrefix) Matches(encodedOpaqueToken string) bool {
+	prefix, err := t.String()
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(encodedOpaqueToken, prefix+"_")
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-var _ tokens.Translator = (*TokenTranslator)(nil)
+var _ tokens.ProofTranslator = (*TokenTranslator)(nil)
 
 type TokenTranslator struct {
 	*mock.Mock
@@ -23,3 +23,8 @@ func (m TokenTranslator) TranslateAccessToken(ctx context.Context, opaqueAccessT
 	args := m.Called(ctx, opaqueAccessToken)
 	return args.String(0), args.Error(1)
 }
+
+func (m TokenTranslator) TranslateAccessTokenWithProof(ctx context.Context, opaqueAccessToken, proof, method, uri string) (string, error) {
+	args := m.Called(ctx, opaqueAccessToken, proof, method, uri)
+	return args.String(0), args.Error(1)
+}
@@ -1,11 +1,14 @@
 package tokensmocks
 
 import (
+	"context"
+
 	"github.com/krixlion/dev_forum-auth/pkg/tokens"
 	"github.com/stretchr/testify/mock"
 )
 
-var _ tokens.Validator = (*TokenValidator)(nil)
+var _ tokens.ProofValidator = (*TokenValidator)(nil)
+var _ tokens.Parser = (*TokenValidator)(nil)
 
 type TokenValidator struct {
 	*mock.Mock
@@ -21,3 +24,13 @@ func (m TokenValidator) ValidateToken(token string) error {
 	args := m.Called(token)
 	return args.Error(0)
 }
+
+func (m TokenValidator) ValidateTokenWithProof(ctx context.Context, token, proof, method, uri string) error {
+	args := m.Called(ctx, token, proof, method, uri)
+	return args.Error(0)
+}
+
+func (m TokenValidator) ParseToken(token string) (tokens.Claims, error) {
+	args := m.Called(token)
+	return args.Get(0).(tokens.Claims), args.Error(1)
+}
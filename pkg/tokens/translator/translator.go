@@ -86,6 +86,29 @@ func (t *Translator) TranslateAccessToken(ctx context.Context, opaqueAccessToken
 	return res.TranslatedAccessToken, res.Err
 }
 
+// TranslateAccessTokenWithProof translates given opaqueAccessToken the same way as
+// TranslateAccessToken along with the DPoP proof the client presented it with.
+// Given method and URI are the ones of the client's request. Tokens bound to
+// a DPoP key can be translated only along with a valid proof.
+func (t *Translator) TranslateAccessTokenWithProof(ctx context.Context, opaqueAccessToken, proof, method, uri string) (_ string, err error) {
+	ctx, span := t.tracer.Start(ctx, "translator.TranslateAccessTokenWithProof")
+	defer span.End()
+	defer tracing.SetSpanErr(span, err)
+
+	job := job{
+		OpaqueAccessToken: opaqueAccessToken,
+		DPoPProof:         proof,
+		DPoPMethod:        method,
+		DPoPURI:           uri,
+		ResultC:           make(chan result),
+		Metadata:          tracing.ExtractMetadataFromContext(ctx),
+	}
+
+	t.jobs <- job
+	res := <-job.ResultC
+	return res.TranslatedAccessToken, res.Err
+}
+
 // job contains the request to be made and a channel to which the
 // translated token or an error will be sent. Channel should be initialized
 // by the caller. Only one result is sent through it, so
@@ -95,6 +118,11 @@ type job struct {
 	OpaqueAccessToken string
 	ResultC           chan result
 	Metadata          map[string]string
+
+	// DPoP proof presented along with the token, empty if there was none.
+	DPoPProof  string
+	DPoPMethod string
+	DPoPURI    string
 }
 
 // result contains either a translated token or a non-nil error.
@@ -117,7 +145,15 @@ func (t *Translator) handleJobs(ctx context.Context) {
 				t.mu.RLock()
 				defer t.mu.RUnlock()
 
-				if err := t.stream.Send(&pb.TranslateAccessTokenRequest{OpaqueAccessToken: job.OpaqueAccessToken, Metadata: job.Metadata}); err != nil {
+				req := &pb.TranslateAccessTokenRequest{
+					OpaqueAccessToken: job.OpaqueAccessToken,
+					Metadata:          job.Metadata,
+					DpopProof:         job.DPoPProof,
+					DpopMethod:        job.DPoPMethod,
+					DpopUri:           job.DPoPURI,
+				}
+
+				if err := t.stream.Send(req); err != nil {
 					t.maybeSendRenewStreamSig(err)
 					if err == io.EOF {
 						t.jobs <- job
@@ -286,6 +286,37 @@ func TestTranslator_TranslateAccessToken(t *testing.T) {
 	}
 }
 
+func TestTranslator_TranslateAccessTokenWithProof(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	s := mocks.NewAuthStreamClient()
+	s.On("Send", &pb.TranslateAccessTokenRequest{
+		OpaqueAccessToken: "test-opaque",
+		Metadata:          map[string]string{},
+		DpopProof:         "test-proof",
+		DpopMethod:        "POST",
+		DpopUri:           "https://test-service",
+	}).Return(nil).Once()
+	s.On("Recv").Return(&pb.TranslateAccessTokenResponse{AccessToken: "test-translated-token"}, nil).Once()
+
+	m := mocks.NewAuthClient()
+	m.On("TranslateAccessToken", mock.Anything, mock.Anything).Return(s, nil).Once()
+
+	tr := NewTranslator(m, Config{})
+	go tr.Run(ctx)
+
+	got, err := tr.TranslateAccessTokenWithProof(ctx, "test-opaque", "test-proof", "POST", "https://test-service")
+	if err != nil {
+		t.Errorf("Translator.TranslateAccessTokenWithProof() error = %v", err)
+		return
+	}
+
+	if got != "test-translated-token" {
+		t.Errorf("Translator.TranslateAccessTokenWithProof():\n got = %v\n want = %v", got, "test-translated-token")
+	}
+}
+
 func TestTranslator_maybeSendRenewStreamSig(t *testing.T) {
 	t.Run("Test a streamAborted signal is sent on an unknown error", func(t *testing.T) {
 		testErr := errors.New("test-error")
@@ -0,0 +1,112 @@
+package validator
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-auth/pkg/entity"
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/manager"
+)
+
+// TestJWTValidator_AlgorithmConformance signs a token with every supported
+// asymmetric algorithm and verifies it the same way backend services do - through
+// the public key serialized for GetValidationKeySet.
+func TestJWTValidator_AlgorithmConformance(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate rsa private key: %s", err)
+	}
+
+	newECDSAKey := func(curve elliptic.Curve) crypto.Signer {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate ecdsa private key: %s", err)
+		}
+		return key
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ed25519 private key: %s", err)
+	}
+
+	tests := []struct {
+		algorithm  entity.Algorithm
+		keyType    entity.KeyType
+		signer     crypto.Signer
+		encodeFunc entity.KeyEncodeFunc
+	}{
+		{algorithm: entity.RS256, keyType: entity.RSA, signer: rsaKey, encodeFunc: protokey.SerializeRSA},
+		{algorithm: entity.RS384, keyType: entity.RSA, signer: rsaKey, encodeFunc: protokey.SerializeRSA},
+		{algorithm: entity.RS512, keyType: entity.RSA, signer: rsaKey, encodeFunc: protokey.SerializeRSA},
+		{algorithm: entity.PS256, keyType: entity.RSA, signer: rsaKey, encodeFunc: protokey.SerializeRSA},
+		{algorithm: entity.PS384, keyType: entity.RSA, signer: rsaKey, encodeFunc: protokey.SerializeRSA},
+		{algorithm: entity.PS512, keyType: entity.RSA, signer: rsaKey, encodeFunc: protokey.SerializeRSA},
+		{algorithm: entity.ES256, keyType: entity.ECDSA, signer: newECDSAKey(elliptic.P256()), encodeFunc: protokey.SerializeECDSA},
+		{algorithm: entity.ES384, keyType: entity.ECDSA, signer: newECDSAKey(elliptic.P384()), encodeFunc: protokey.SerializeECDSA},
+		{algorithm: entity.ES512, keyType: entity.ECDSA, signer: newECDSAKey(elliptic.P521()), encodeFunc: protokey.SerializeECDSA},
+		{algorithm: entity.EdDSA, keyType: entity.OKP, signer: ed25519Key, encodeFunc: protokey.SerializeEd25519},
+	}
+	for _, tt := range tests {
+		t.Run("Test if token signed with "+string(tt.algorithm)+" is validated", func(t *testing.T) {
+			kid, err := protokey.Thumbprint(tt.signer.Public())
+			if err != nil {
+				t.Fatalf("protokey.Thumbprint() error = %v", err)
+			}
+
+			key, err := entity.NewKey(kid, tt.keyType, tt.algorithm, tt.signer, tt.encodeFunc)
+			if err != nil {
+				t.Fatalf("entity.NewKey() error = %v", err)
+			}
+
+			token := entity.Token{
+				Id:        "test",
+				UserId:    "test-id",
+				Type:      entity.AccessToken,
+				ExpiresAt: time.Now().Add(time.Minute),
+				IssuedAt:  time.Now(),
+			}
+
+			signed, err := manager.MakeManager(manager.Config{Issuer: testIssuer}).Encode(key, token)
+			if err != nil {
+				t.Fatalf("TokenManager.Encode() error = %v", err)
+			}
+
+			encoded, err := key.Encode()
+			if err != nil {
+				t.Fatalf("Key.Encode() error = %v", err)
+			}
+
+			public, err := protokey.DeserializeKey(encoded)
+			if err != nil {
+				t.Fatalf("protokey.DeserializeKey() error = %v", err)
+			}
+
+			refreshFunc := func(context.Context) ([]Key, error) {
+				return []Key{{
+					Id:        key.Id,
+					Algorithm: string(key.Algorithm),
+					Type:      string(key.Type),
+					Raw:       public,
+				}}, nil
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			v := setUpTokenValidator(ctx, refreshFunc, nil)
+
+			if err := v.ValidateToken(string(signed)); err != nil {
+				t.Errorf("JWTValidator.ValidateToken() error = %v", err)
+			}
+		})
+	}
+}
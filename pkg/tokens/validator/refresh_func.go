@@ -2,10 +2,13 @@ package validator
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/base64"
 	"io"
 
 	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
 	pb "github.com/krixlion/dev_forum-auth/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
 	"github.com/krixlion/dev_forum-lib/nulls"
 	"github.com/krixlion/dev_forum-lib/tracing"
 	"github.com/lestrrat-go/jwx/jwk"
@@ -21,6 +24,8 @@ type Key struct {
 	Algorithm string
 	Type      string
 	Raw       interface{}
+	// Certificates certifying the key, leaf first. Nil if the key is not certified.
+	Certificates []*x509.Certificate
 }
 
 // DefaultRefreshFunc returns a callback that uses the auth service as the
@@ -66,11 +71,17 @@ func DefaultRefreshFunc(authClient pb.AuthServiceClient, tracer trace.Tracer) Re
 				return nil, err
 			}
 
+			certificates, err := parseCertificates(jwk.GetX5C())
+			if err != nil {
+				return nil, err
+			}
+
 			key := Key{
-				Id:        jwk.GetKid(),
-				Algorithm: jwk.GetAlg(),
-				Type:      jwk.GetKty(),
-				Raw:       raw,
+				Id:           jwk.GetKid(),
+				Algorithm:    jwk.GetAlg(),
+				Type:         jwk.GetKty(),
+				Raw:          raw,
+				Certificates: certificates,
 			}
 
 			keyset = append(keyset, key)
@@ -106,8 +117,41 @@ func keySetFromKeys(keys []Key) (jwk.Set, error) {
 			return nil, err
 		}
 
+		if len(key.Certificates) > 0 {
+			chain := make([]string, 0, len(key.Certificates))
+			for _, certificate := range key.Certificates {
+				chain = append(chain, base64.StdEncoding.EncodeToString(certificate.Raw))
+			}
+
+			if err := jwKey.Set(jwk.X509CertChainKey, chain); err != nil {
+				return nil, err
+			}
+
+			if err := jwKey.Set(jwk.X509CertThumbprintS256Key, jwkutil.CertificateThumbprint(key.Certificates[0])); err != nil {
+				return nil, err
+			}
+		}
+
 		keySet.Add(jwKey)
 	}
 
 	return keySet, nil
 }
+
+// parseCertificates parses DER encoded certificates. It returns nil if there are none.
+func parseCertificates(chain [][]byte) ([]*x509.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, nil
+	}
+
+	certificates := make([]*x509.Certificate, 0, len(chain))
+	for _, der := range chain {
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	return certificates, nil
+}
@@ -2,7 +2,9 @@ package validator
 
 import (
 	"context"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -13,8 +15,11 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/krixlion/dev_forum-auth/pkg/entity"
 	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
+	keydata "github.com/krixlion/dev_forum-auth/pkg/grpc/protokey/testdata"
 	"github.com/krixlion/dev_forum-auth/pkg/grpc/server/servertest"
+	"github.com/krixlion/dev_forum-auth/pkg/jwkutil"
 	"github.com/krixlion/dev_forum-auth/pkg/storage/storagemocks"
+	"github.com/krixlion/dev_forum-auth/pkg/storage/vault"
 	"github.com/krixlion/dev_forum-lib/nulls"
 	"github.com/stretchr/testify/mock"
 )
@@ -28,6 +33,18 @@ func TestDefaultRefreshFunc(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to generate ecdsa private key: %s", err)
 	}
+	_, ed25519PrivKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ed25519 private key: %s", err)
+	}
+	thumbprint := func(key crypto.Signer) string {
+		kid, err := protokey.Thumbprint(key.Public())
+		if err != nil {
+			t.Fatalf("Failed to get key thumbprint: %s", err)
+		}
+		return kid
+	}
+	rsaId, ecdsaId, ed25519Id := thumbprint(rsaPrivKey), thumbprint(ecdsaPrivKey), thumbprint(ed25519PrivKey)
 
 	tests := []struct {
 		name    string
@@ -39,22 +56,19 @@ func TestDefaultRefreshFunc(t *testing.T) {
 			name: "Test keys are parsed and returned as expected on valid flow",
 			deps: servertest.Deps{
 				Vault: func() storagemocks.Vault {
-					keys := []entity.Key{
-						{
-							Id:         "test-rsa-id",
-							Type:       entity.RSA,
-							Algorithm:  entity.RS256,
-							Raw:        rsaPrivKey,
-							EncodeFunc: protokey.SerializeRSA,
-						},
-						{
-							Id:         "test-ecdsa-id",
-							Type:       entity.ECDSA,
-							Algorithm:  entity.ES256,
-							Raw:        ecdsaPrivKey,
-							EncodeFunc: protokey.SerializeECDSA,
-						},
+					rsaKey, err := entity.NewKey(rsaId, entity.RSA, entity.RS256, rsaPrivKey, protokey.SerializeRSA)
+					if err != nil {
+						t.Fatalf("Failed to make rsa key: %s", err)
+					}
+					ecdsaKey, err := entity.NewKey(ecdsaId, entity.ECDSA, entity.ES256, ecdsaPrivKey, protokey.SerializeECDSA)
+					if err != nil {
+						t.Fatalf("Failed to make ecdsa key: %s", err)
 					}
+					ed25519Key, err := entity.NewKey(ed25519Id, entity.OKP, entity.EdDSA, ed25519PrivKey, protokey.SerializeEd25519)
+					if err != nil {
+						t.Fatalf("Failed to make ed25519 key: %s", err)
+					}
+					keys := []entity.Key{rsaKey, ecdsaKey, ed25519Key}
 					m := storagemocks.NewVault()
 					m.On("GetKeySet", mock.Anything).Return(keys, nil)
 					return m
@@ -62,17 +76,23 @@ func TestDefaultRefreshFunc(t *testing.T) {
 			},
 			want: []Key{
 				{
-					Id:        "test-rsa-id",
+					Id:        rsaId,
 					Algorithm: "RS256",
 					Type:      "RSA",
 					Raw:       rsaPrivKey.Public(),
 				},
 				{
-					Id:        "test-ecdsa-id",
+					Id:        ecdsaId,
 					Algorithm: "ES256",
 					Type:      "ECDSA",
 					Raw:       ecdsaPrivKey.Public(),
 				},
+				{
+					Id:        ed25519Id,
+					Algorithm: "EdDSA",
+					Type:      "OKP",
+					Raw:       ed25519PrivKey.Public(),
+				},
 			},
 			wantErr: false,
 		},
@@ -108,12 +128,23 @@ func TestDefaultRefreshFunc(t *testing.T) {
 }
 
 func Test_keySetFromKeys(t *testing.T) {
+	ca, err := vault.NewCA(keydata.CA.CertPem, keydata.CA.PrivPem, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make ca: %s", err)
+	}
+	ed25519Certificates, err := ca.Issue(context.Background(), keydata.Ed25519.Id, keydata.Ed25519.PubKey)
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %s", err)
+	}
+
 	type args struct {
 		keys []Key
 	}
 	tests := []struct {
-		name    string
-		args    args
+		name string
+		args args
+		// wantX5c is the expected length of the published certificate chain.
+		wantX5c int
 		wantErr bool
 	}{
 		{
@@ -123,14 +154,57 @@ func Test_keySetFromKeys(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Test if accepts Ed25519 keys",
+			args: args{
+				keys: []Key{{
+					Id:        keydata.Ed25519.Id,
+					Type:      "OKP",
+					Algorithm: "EdDSA",
+					Raw:       keydata.Ed25519.PubKey,
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Test if publishes the key's certificate chain",
+			args: args{
+				keys: []Key{{
+					Id:           keydata.Ed25519.Id,
+					Type:         "OKP",
+					Algorithm:    "EdDSA",
+					Raw:          keydata.Ed25519.PubKey,
+					Certificates: ed25519Certificates,
+				}},
+			},
+			wantX5c: 2,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := keySetFromKeys(tt.args.keys)
+			got, err := keySetFromKeys(tt.args.keys)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("keySetFromKeys() error = %v, wantErr = %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
+
+			for _, key := range tt.args.keys {
+				published, ok := got.LookupKeyID(key.Id)
+				if !ok {
+					t.Fatalf("keySetFromKeys() is missing key %s", key.Id)
+				}
+
+				if x5c := published.X509CertChain(); len(x5c) != tt.wantX5c {
+					t.Errorf("keySetFromKeys() x5c length = %d, want %d", len(x5c), tt.wantX5c)
+				}
+
+				if tt.wantX5c > 0 && published.X509CertThumbprintS256() != jwkutil.CertificateThumbprint(key.Certificates[0]) {
+					t.Errorf("keySetFromKeys() x5t#S256 = %s, want leaf's thumbprint", published.X509CertThumbprintS256())
+				}
+			}
 		})
 	}
 }
@@ -2,12 +2,17 @@ package validator
 
 import (
 	"context"
+	"crypto"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/krixlion/dev_forum-auth/pkg/grpc/protokey"
 	"github.com/krixlion/dev_forum-auth/pkg/tokens"
+	"github.com/krixlion/dev_forum-auth/pkg/tokens/dpop"
 	"github.com/krixlion/dev_forum-lib/event"
 	"github.com/krixlion/dev_forum-lib/event/dispatcher"
 	"github.com/krixlion/dev_forum-lib/logging"
@@ -17,13 +22,16 @@ import (
 	"github.com/lestrrat-go/jwx/jwt"
 )
 
-var _ tokens.Validator = (*JWTValidator)(nil)
+var _ tokens.ProofValidator = (*JWTValidator)(nil)
+var _ tokens.Parser = (*JWTValidator)(nil)
 var _ dispatcher.Listener = (*JWTValidator)(nil)
 
 var (
 	ErrKeysNotReceived        = errors.New("no keys were received")
 	ErrKeySetNotFound         = errors.New("key set not found")
 	ErrRefreshFuncNotProvided = errors.New("no refreshFunc was provided to refresh the keyset")
+	ErrCertificateMissing     = errors.New("key is not certified")
+	ErrCertificateMismatch    = errors.New("certificate does not certify the key")
 )
 
 type JWTValidator struct {
@@ -41,6 +49,15 @@ type JWTValidator struct {
 
 	logger logging.Logger
 
+	// dpopVerifier validates DPoP proofs and keeps track of their jtis to prevent replays.
+	dpopVerifier    *dpop.Verifier
+	dpopMaxAge      time.Duration
+	dpopReplayCache dpop.ReplayCache
+
+	// trustedCertificates is used to verify keys' certificate chains.
+	// Chains are not verified if nil.
+	trustedCertificates *x509.CertPool
+
 	// keySetExpired is a channel which notifies when the current keyset is outdated
 	keySetExpired chan map[string]string
 
@@ -82,6 +99,8 @@ func NewValidator(issuer string, refreshFunc RefreshFunc, options ...Option) (*J
 		v.logger = nulls.NullLogger{}
 	}
 
+	v.dpopVerifier = dpop.NewVerifier(v.dpopMaxAge, v.clock.Now, v.dpopReplayCache)
+
 	return v, nil
 }
 
@@ -120,26 +139,71 @@ func (validator *JWTValidator) Run(ctx context.Context) {
 // Note that if the keyset expires, this method will not wait for a new keyset to be fetched
 // and instead it will return an error and will continue to do so until
 // an updated keyset is successfully retrieved.
+//
+// Tokens bound to a DPoP key are rejected, use ValidateTokenWithProof to validate them.
+// Binding of certificate-bound tokens is not verified here as it requires
+// the caller's certificate, see package github.com/krixlion/dev_forum-auth/pkg/grpc/auth.
 func (validator *JWTValidator) ValidateToken(token string) error {
-	jwToken, err := jwt.ParseString(token, jwt.WithKeySetProvider(validator.keySetProvider()))
+	claims, err := validator.ParseToken(token)
+	if err != nil {
+		return err
+	}
+
+	if claims.Confirmation.JKT != "" {
+		return dpop.ErrProofRequired
+	}
+
+	return nil
+}
+
+// ValidateTokenWithProof validates given token the same way as ValidateToken and
+// additionally verifies the DPoP proof it was presented with as described in RFC 9449.
+// The proof has to be signed with the key the token is bound to, issued for given
+// HTTP method and URI and must not have been used before, see WithDPoPReplayCache.
+//
+// The proof's ath is not verified here. Clients present the proof along with the
+// opaque access token, so its ath is the hash of the opaque token rather than of the
+// translated JWT. It is verified when the gateway translates the token.
+func (validator *JWTValidator) ValidateTokenWithProof(ctx context.Context, token, proof, method, uri string) error {
+	claims, err := validator.ParseToken(token)
+	if err != nil {
+		return err
+	}
+
+	p, err := validator.dpopVerifier.Verify(ctx, proof, method, uri)
 	if err != nil {
 		return err
 	}
 
+	if claims.Confirmation.JKT == "" || claims.Confirmation.JKT != p.JKT {
+		return dpop.ErrThumbprintMismatch
+	}
+
+	return nil
+}
+
+// ParseToken validates given token the same way as ValidateToken
+// and on success returns its verified claims.
+func (validator *JWTValidator) ParseToken(token string) (tokens.Claims, error) {
+	jwToken, err := jwt.ParseString(token, jwt.WithKeySetProvider(validator.keySetProvider()))
+	if err != nil {
+		return tokens.Claims{}, err
+	}
+
 	validateOptions := []jwt.ValidateOption{
 		jwt.WithIssuer(validator.issuer),
 		jwt.WithClock(validator.clock),
 	}
 
 	if err := jwt.Validate(jwToken, validateOptions...); err != nil {
-		return err
+		return tokens.Claims{}, err
 	}
 
 	if tokenType, ok := jwToken.Get("type"); !ok || tokenType != "access-token" {
-		return tokens.ErrInvalidTokenType
+		return tokens.Claims{}, tokens.ErrInvalidTokenType
 	}
 
-	return nil
+	return claimsFromToken(jwToken)
 }
 
 func (validator *JWTValidator) EventHandlers() map[event.EventType][]event.Handler {
@@ -169,6 +233,32 @@ func WithLogger(logger logging.Logger) Option {
 	})
 }
 
+// WithDPoPProofMaxAge sets for how long after being issued DPoP proofs are accepted.
+// Defaults to dpop.DefaultMaxAge.
+func WithDPoPProofMaxAge(maxAge time.Duration) Option {
+	return optionFunc(func(validator *JWTValidator) {
+		validator.dpopMaxAge = maxAge
+	})
+}
+
+// WithDPoPReplayCache sets the cache used to reject replayed DPoP proofs.
+// By default proofs are recorded in a dpop.MemoryReplayCache, so replicas
+// of a service have to share a cache to reject proofs replayed between them.
+func WithDPoPReplayCache(cache dpop.ReplayCache) Option {
+	return optionFunc(func(validator *JWTValidator) {
+		validator.dpopReplayCache = cache
+	})
+}
+
+// WithTrustedCertificates makes the validator accept only keys
+// certified by a chain leading to one of given certificates.
+// Symmetric keys are not affected. By default certificates are not verified.
+func WithTrustedCertificates(roots *x509.CertPool) Option {
+	return optionFunc(func(validator *JWTValidator) {
+		validator.trustedCertificates = roots
+	})
+}
+
 // fetchKeySet invokes the RefreshFunc and serializes keys into validator's keySet.
 // Safe for concurrent use.
 func (validator *JWTValidator) fetchKeySet(ctx context.Context) (err error) {
@@ -183,6 +273,12 @@ func (validator *JWTValidator) fetchKeySet(ctx context.Context) (err error) {
 		return err
 	}
 
+	keys = validator.keysWithValidIds(ctx, keys)
+
+	if validator.trustedCertificates != nil {
+		keys = validator.certifiedKeys(ctx, keys)
+	}
+
 	keySet, err := keySetFromKeys(keys)
 	if err != nil {
 		return err
@@ -197,6 +293,80 @@ func (validator *JWTValidator) fetchKeySet(ctx context.Context) (err error) {
 	return nil
 }
 
+// keysWithValidIds returns keys whose ids are their RFC 7638 thumbprints.
+// Other asymmetric keys were altered, so they're logged and dropped
+// without rejecting the rest of the keyset.
+// Symmetric keys are never published, so their ids are not checked.
+func (validator *JWTValidator) keysWithValidIds(ctx context.Context, keys []Key) []Key {
+	if keys == nil {
+		return nil
+	}
+
+	valid := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		if _, symmetric := key.Raw.([]byte); !symmetric {
+			if err := protokey.VerifyThumbprint(key.Id, key.Raw); err != nil {
+				validator.logger.Log(ctx, "Dropping key whose id is not its thumbprint", "kid", key.Id, "err", err)
+				continue
+			}
+		}
+		valid = append(valid, key)
+	}
+
+	return valid
+}
+
+// certifiedKeys returns keys whose certificates verify against trusted certificates.
+// Other asymmetric keys are logged and dropped so that tokens signed with them are rejected.
+func (validator *JWTValidator) certifiedKeys(ctx context.Context, keys []Key) []Key {
+	if keys == nil {
+		return nil
+	}
+
+	certified := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		if err := validator.verifyCertificates(key); err != nil {
+			validator.logger.Log(ctx, "Dropping key with an invalid certificate chain", "kid", key.Id, "err", err)
+			continue
+		}
+		certified = append(certified, key)
+	}
+
+	return certified
+}
+
+// verifyCertificates returns a non-nil error if key's certificate chain
+// does not certify the key or does not lead to a trusted certificate.
+func (validator *JWTValidator) verifyCertificates(key Key) error {
+	if _, symmetric := key.Raw.([]byte); symmetric {
+		return nil
+	}
+
+	if len(key.Certificates) == 0 {
+		return ErrCertificateMissing
+	}
+
+	leaf := key.Certificates[0]
+	publicKey, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(key.Raw) {
+		return ErrCertificateMismatch
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range key.Certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         validator.trustedCertificates,
+		Intermediates: intermediates,
+		CurrentTime:   validator.clock.Now(),
+		// Signing certificates do not need to declare extended key usage.
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
 // keySetProvider returns a callback that safely returns the keyset for the library to use when verifying a JWS.
 // Safe for concurrent use.
 func (validator *JWTValidator) keySetProvider() jwt.KeySetProvider {
@@ -215,3 +385,59 @@ func (validator *JWTValidator) keySetProvider() jwt.KeySetProvider {
 		return validator.keySet.Clone()
 	})
 }
+
+func claimsFromToken(token jwt.Token) (tokens.Claims, error) {
+	claims := tokens.Claims{
+		Subject: token.Subject(),
+	}
+
+	if scope, ok := token.Get("scope"); ok {
+		scope, ok := scope.(string)
+		if !ok {
+			return tokens.Claims{}, tokens.ErrMalformedToken
+		}
+		claims.Scopes = strings.Fields(scope)
+	}
+
+	if act, ok := token.Get("act"); ok {
+		act, ok := act.(map[string]interface{})
+		if !ok {
+			return tokens.Claims{}, tokens.ErrMalformedToken
+		}
+
+		actor, ok := act["sub"].(string)
+		if !ok || actor == "" {
+			return tokens.Claims{}, tokens.ErrMalformedToken
+		}
+		claims.Actor = actor
+	}
+
+	if cnf, ok := token.Get("cnf"); ok {
+		cnf, ok := cnf.(map[string]interface{})
+		if !ok {
+			return tokens.Claims{}, tokens.ErrMalformedToken
+		}
+
+		if jkt, ok := cnf["jkt"]; ok {
+			jkt, ok := jkt.(string)
+			if !ok || jkt == "" {
+				return tokens.Claims{}, tokens.ErrMalformedToken
+			}
+			claims.Confirmation.JKT = jkt
+		}
+
+		if x5t, ok := cnf["x5t#S256"]; ok {
+			x5t, ok := x5t.(string)
+			if !ok || x5t == "" {
+				return tokens.Claims{}, tokens.ErrMalformedToken
+			}
+			claims.Confirmation.X5tS256 = x5t
+		}
+
+		if claims.Confirmation.IsZero() {
+			return tokens.Claims{}, tokens.ErrMalformedToken
+		}
+	}
+
+	return claims, nil
+}